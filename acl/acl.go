@@ -0,0 +1,582 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package acl
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sdslabs/kiwi"
+
+	"github.com/tidwall/match"
+)
+
+// Various errors related to users and permissions.
+var (
+	ErrUserNotExist     = fmt.Errorf("user does not exist")
+	ErrAuthFailed       = fmt.Errorf("invalid username-password pair or user is disabled")
+	ErrPermissionDenied = fmt.Errorf("permission denied")
+	ErrInvalidRule      = fmt.Errorf("invalid ACL rule")
+)
+
+// newRuleErr creates an error with the invalid token.
+func newRuleErr(token, reason string) error {
+	return fmt.Errorf("%w: %q %s", ErrInvalidRule, token, reason)
+}
+
+// newDeniedErr creates an error where the user cannot execute the action.
+func newDeniedErr(user string, action kiwi.Action, key string) error {
+	return fmt.Errorf("%w: user %q cannot %s %q", ErrPermissionDenied, user, action, key)
+}
+
+// Actions which guard the operations other than executing actions on values.
+const (
+	AddKey    kiwi.Action = "ADDKEY"
+	UpdateKey kiwi.Action = "UPDATEKEY"
+	DeleteKey kiwi.Action = "DELETEKEY"
+	ToJSON    kiwi.Action = "TOJSON"
+	FromJSON  kiwi.Action = "FROMJSON"
+
+	// Admin guards the "ACL LIST" and "ACL SETUSER" commands.
+	Admin kiwi.Action = "ACL"
+
+	// Publish and Subscribe guard publishing and subscribing to the channels
+	// matching the patterns of a rule, which are checked like keys.
+	Publish   kiwi.Action = "PUBLISH"
+	Subscribe kiwi.Action = "SUBSCRIBE"
+
+	// Monitor guards watching every operation on the store.
+	Monitor kiwi.Action = "MONITOR"
+)
+
+// DefaultUser is the user sessions are authenticated as when they start.
+const DefaultUser = "default"
+
+// ACL maintains the users and their rules.
+type ACL struct {
+	users map[string]*user
+	mu    sync.RWMutex
+}
+
+// New creates an ACL with only the default user, which has all the
+// permissions and no password.
+func New() *ACL {
+	a := newACL()
+	a.setDefaultUser()
+	return a
+}
+
+// newACL creates an ACL without any users.
+func newACL() *ACL {
+	return &ACL{
+		users: make(map[string]*user),
+		mu:    sync.RWMutex{},
+	}
+}
+
+// setDefaultUser creates the default user with all the permissions.
+func (a *ACL) setDefaultUser() {
+	// The rules are valid so it cannot fail.
+	_ = a.SetUser(DefaultUser, "on", "nopass", "(~* +* %*)")
+}
+
+// Load creates an ACL from the ACL file read from r.
+//
+// Users not defined in the file are not created, except for the default user
+// which is created with all the permissions if it is not defined.
+func Load(r io.Reader) (*ACL, error) {
+	a := newACL()
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if fields[0] != "user" || len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected \"user <name> [rules...]\"", line)
+		}
+
+		if err := a.SetUser(fields[1], fields[2:]...); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := a.users[DefaultUser]; !ok {
+		a.setDefaultUser()
+	}
+
+	return a, nil
+}
+
+// LoadFile creates an ACL from the ACL file at the path.
+func LoadFile(path string) (*ACL, error) {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	return Load(f)
+}
+
+// SetUser creates the user or modifies the existing one by applying the
+// tokens in order. New users are disabled and have no passwords and rules.
+//
+// A rule can be passed as a single token or split over multiple ones, e.g.,
+// "(~key:* +GET)" or "(~key:*", "+GET)".
+//
+// The user is not modified if any of the tokens is invalid.
+func (a *ACL) SetUser(name string, tokens ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	u, ok := a.users[name]
+	if ok {
+		u = u.clone()
+	} else {
+		u = newUser(name)
+	}
+
+	if err := u.apply(tokens); err != nil {
+		return err
+	}
+
+	a.users[name] = u
+	return nil
+}
+
+// DelUser deletes the user. The default user cannot be deleted.
+func (a *ACL) DelUser(name string) error {
+	if name == DefaultUser {
+		return fmt.Errorf("the %q user cannot be deleted", DefaultUser)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.users[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUserNotExist, name)
+	}
+
+	delete(a.users, name)
+	return nil
+}
+
+// List returns the users, sorted by name, in the format of the ACL file.
+func (a *ACL) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = a.users[name].String()
+	}
+
+	return list
+}
+
+// Authenticate checks if the password is valid for the user.
+func (a *ACL) Authenticate(name, password string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	u, ok := a.users[name]
+	if !ok || !u.enabled {
+		return ErrAuthFailed
+	}
+
+	if u.nopass {
+		return nil
+	}
+
+	digest := hashPassword(password)
+
+	valid := 0
+	for hash := range u.passwords {
+		valid |= subtle.ConstantTimeCompare([]byte(hash), []byte(digest))
+	}
+
+	if valid != 1 {
+		return ErrAuthFailed
+	}
+
+	return nil
+}
+
+// enabled returns ErrAuthFailed if the user does not exist or is disabled.
+func (a *ACL) enabled(name string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if u, ok := a.users[name]; !ok || !u.enabled {
+		return ErrAuthFailed
+	}
+
+	return nil
+}
+
+// Check returns an error if the user is not allowed to execute the action on
+// the key. An empty type means that the type of the value is not known, e.g.,
+// when the key does not exist, and then only the key and action are checked.
+func (a *ACL) Check(name, key string, action kiwi.Action, typ kiwi.ValueType) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if u, ok := a.users[name]; ok && u.enabled {
+		for _, r := range u.rules {
+			if r.allowsKey(key) && r.allowsAction(action) && r.allowsType(typ) {
+				return nil
+			}
+		}
+	}
+
+	return newDeniedErr(name, action, key)
+}
+
+// CheckAction returns an error if the user is not allowed to execute the
+// action irrespective of the key.
+//
+// Only the rules for all the keys, i.e., with the pattern "*", are checked, so
+// that a user limited to some keys cannot execute actions, like ACL, which
+// affect the others.
+func (a *ACL) CheckAction(name string, action kiwi.Action) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if u, ok := a.users[name]; ok && u.enabled {
+		for _, r := range u.rules {
+			if r.allowsAllKeys() && r.allowsAction(action) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: user %q cannot %s", ErrPermissionDenied, name, action)
+}
+
+// hashPassword returns the hex encoded SHA-256 digest of the password.
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// user is a user with its passwords and rules.
+type user struct {
+	name      string
+	enabled   bool
+	nopass    bool
+	passwords map[string]struct{}
+	rules     []*rule
+}
+
+// newUser creates a disabled user with no passwords and rules.
+func newUser(name string) *user {
+	return &user{
+		name:      name,
+		passwords: make(map[string]struct{}),
+	}
+}
+
+// clone returns a deep copy of the user.
+func (u *user) clone() *user {
+	c := newUser(u.name)
+	c.enabled, c.nopass = u.enabled, u.nopass
+
+	for hash := range u.passwords {
+		c.passwords[hash] = struct{}{}
+	}
+
+	c.rules = make([]*rule, len(u.rules))
+	for i, r := range u.rules {
+		c.rules[i] = r.clone()
+	}
+
+	return c
+}
+
+// apply applies the tokens to the user.
+func (u *user) apply(tokens []string) error {
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		switch {
+		case token == "":
+			continue
+
+		case token == "on":
+			u.enabled = true
+
+		case token == "off":
+			u.enabled = false
+
+		case token == "nopass":
+			u.nopass = true
+			u.passwords = make(map[string]struct{})
+
+		case token == "resetpass":
+			u.nopass = false
+			u.passwords = make(map[string]struct{})
+
+		case token == "resetrules":
+			u.rules = nil
+
+		case token == "reset":
+			*u = *newUser(u.name)
+
+		case token[0] == '>':
+			u.nopass = false
+			u.passwords[hashPassword(token[1:])] = struct{}{}
+
+		case token[0] == '<':
+			hash := hashPassword(token[1:])
+			if _, ok := u.passwords[hash]; !ok {
+				return newRuleErr(token, "password does not exist")
+			}
+			delete(u.passwords, hash)
+
+		case token[0] == '#':
+			hash := strings.ToLower(token[1:])
+			if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+				return newRuleErr(token, "is not a SHA-256 hex digest")
+			}
+			u.nopass = false
+			u.passwords[hash] = struct{}{}
+
+		case token[0] == '(':
+			// the rule may be split over multiple tokens
+			ruleTokens := []string{token}
+			for !strings.HasSuffix(tokens[i], ")") {
+				if i++; i == len(tokens) {
+					return newRuleErr(strings.Join(ruleTokens, " "), "is missing \")\"")
+				}
+				ruleTokens = append(ruleTokens, tokens[i])
+			}
+
+			r, err := parseRule(strings.Join(ruleTokens, " "))
+			if err != nil {
+				return err
+			}
+			u.rules = append(u.rules, r)
+
+		default:
+			return newRuleErr(token, "is not a valid token")
+		}
+	}
+
+	return nil
+}
+
+// String returns the user in the format of the ACL file.
+func (u *user) String() string {
+	tokens := []string{"user", u.name, "off"}
+	if u.enabled {
+		tokens[2] = "on"
+	}
+
+	if u.nopass {
+		tokens = append(tokens, "nopass")
+	}
+
+	hashes := make([]string, 0, len(u.passwords))
+	for hash := range u.passwords {
+		hashes = append(hashes, "#"+hash)
+	}
+	sort.Strings(hashes)
+	tokens = append(tokens, hashes...)
+
+	for _, r := range u.rules {
+		tokens = append(tokens, r.String())
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// rule allows actions on values of some types for the keys matching any of
+// the patterns.
+type rule struct {
+	patterns []string
+
+	// actions maps actions to whether they are allowed or not. Actions not
+	// in the map are allowed only if allActions is set.
+	allActions bool
+	actions    map[kiwi.Action]bool
+
+	// types are the allowed types. All types are allowed if it's empty.
+	types map[kiwi.ValueType]struct{}
+}
+
+// parseRule parses the rule of the format "(~pattern +ACTION -ACTION %type)".
+func parseRule(s string) (*rule, error) {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, newRuleErr(s, "is not enclosed in \"(\" and \")\"")
+	}
+
+	r := &rule{
+		actions: make(map[kiwi.Action]bool),
+		types:   make(map[kiwi.ValueType]struct{}),
+	}
+
+	for _, token := range strings.Fields(s[1 : len(s)-1]) {
+		if len(token) < 2 {
+			return nil, newRuleErr(token, "is not a valid rule token")
+		}
+
+		switch arg := token[1:]; token[0] {
+		case '~':
+			r.patterns = append(r.patterns, arg)
+
+		case '+':
+			if arg == "*" {
+				r.allActions = true
+				r.actions = make(map[kiwi.Action]bool)
+			} else {
+				r.actions[kiwi.Action(arg)] = true
+			}
+
+		case '-':
+			if arg == "*" {
+				r.allActions = false
+				r.actions = make(map[kiwi.Action]bool)
+			} else {
+				r.actions[kiwi.Action(arg)] = false
+			}
+
+		case '%':
+			if arg != "*" {
+				r.types[kiwi.ValueType(arg)] = struct{}{}
+			}
+
+		default:
+			return nil, newRuleErr(token, "is not a valid rule token")
+		}
+	}
+
+	if len(r.patterns) == 0 {
+		return nil, newRuleErr(s, "has no key pattern")
+	}
+
+	return r, nil
+}
+
+// clone returns a deep copy of the rule.
+func (r *rule) clone() *rule {
+	c := &rule{
+		patterns:   append([]string(nil), r.patterns...),
+		allActions: r.allActions,
+		actions:    make(map[kiwi.Action]bool, len(r.actions)),
+		types:      make(map[kiwi.ValueType]struct{}, len(r.types)),
+	}
+
+	for action, allowed := range r.actions {
+		c.actions[action] = allowed
+	}
+	for typ := range r.types {
+		c.types[typ] = struct{}{}
+	}
+
+	return c
+}
+
+// allowsKey tells if the key matches any of the patterns.
+func (r *rule) allowsKey(key string) bool {
+	for _, pattern := range r.patterns {
+		if match.Match(key, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowsAllKeys tells if the rule is for all the keys.
+func (r *rule) allowsAllKeys() bool {
+	for _, pattern := range r.patterns {
+		if pattern == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowsAction tells if the action is allowed.
+func (r *rule) allowsAction(action kiwi.Action) bool {
+	if allowed, ok := r.actions[action]; ok {
+		return allowed
+	}
+
+	return r.allActions
+}
+
+// allowsType tells if the value type is allowed. Empty type is always allowed.
+func (r *rule) allowsType(typ kiwi.ValueType) bool {
+	if typ == "" || len(r.types) == 0 {
+		return true
+	}
+
+	_, ok := r.types[typ]
+	return ok
+}
+
+// String returns the rule in the format it is parsed from.
+func (r *rule) String() string {
+	tokens := make([]string, 0, len(r.patterns)+len(r.actions)+len(r.types)+1)
+
+	for _, pattern := range r.patterns {
+		tokens = append(tokens, "~"+pattern)
+	}
+
+	if r.allActions {
+		tokens = append(tokens, "+*")
+	}
+
+	actions := make([]string, 0, len(r.actions))
+	for action := range r.actions {
+		actions = append(actions, string(action))
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		if r.actions[kiwi.Action(action)] {
+			tokens = append(tokens, "+"+action)
+		} else {
+			tokens = append(tokens, "-"+action)
+		}
+	}
+
+	types := make([]string, 0, len(r.types))
+	for typ := range r.types {
+		types = append(types, "%"+string(typ))
+	}
+	if len(types) == 0 {
+		types = append(types, "%*")
+	}
+	sort.Strings(types)
+	tokens = append(tokens, types...)
+
+	return "(" + strings.Join(tokens, " ") + ")"
+}
+
+// Interface guard.
+var _ fmt.Stringer = (*user)(nil)
@@ -0,0 +1,175 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package acl
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestACL_SetUser(t *testing.T) {
+	a := New()
+
+	if err := a.SetUser("alice", "on", ">secret", "(~cache:*", "~session:*", "+GET", "+UPDATE", "%str)"); err != nil {
+		t.Fatalf("could not SetUser: %v", err)
+	}
+
+	if err := a.Authenticate("alice", "secret"); err != nil {
+		t.Errorf("could not Authenticate with valid password: %v", err)
+	}
+	if err := a.Authenticate("alice", "wrong"); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed with invalid password; got %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		action kiwi.Action
+		typ    kiwi.ValueType
+		allow  bool
+	}{
+		{"cache:a", "GET", "str", true},
+		{"session:a", "UPDATE", "str", true},
+		{"cache:a", "GET", "", true},
+		{"cache:a", "GET", "hash", false},
+		{"cache:a", "DELETE", "str", false},
+		{"other", "GET", "str", false},
+	}
+
+	for _, tt := range tests {
+		err := a.Check("alice", tt.key, tt.action, tt.typ)
+		if tt.allow && err != nil {
+			t.Errorf("expected %s %q (%s) to be allowed; got %v", tt.action, tt.key, tt.typ, err)
+		}
+		if !tt.allow && !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("expected %s %q (%s) to be denied; got %v", tt.action, tt.key, tt.typ, err)
+		}
+	}
+
+	// invalid tokens do not modify the user
+	for _, tokens := range [][]string{
+		{"off", "bogus"},
+		{"off", "(~a +GET"},
+		{"off", "(+GET)"},
+		{"off", "(~a =GET)"},
+		{"off", "#abc"},
+		{"off", "<notapassword"},
+	} {
+		if err := a.SetUser("alice", tokens...); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("expected ErrInvalidRule for %v; got %v", tokens, err)
+		}
+	}
+	if err := a.Authenticate("alice", "secret"); err != nil {
+		t.Errorf("expected user to be unmodified after invalid tokens; got %v", err)
+	}
+
+	// remove actions from a rule allowing everything
+	if err := a.SetUser("alice", "resetrules", "(~* +* -DELETE)"); err != nil {
+		t.Fatalf("could not SetUser: %v", err)
+	}
+	if err := a.Check("alice", "any", "GET", "list"); err != nil {
+		t.Errorf("expected GET to be allowed; got %v", err)
+	}
+	if err := a.Check("alice", "any", "DELETE", "list"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected DELETE to be denied; got %v", err)
+	}
+
+	if err := a.SetUser("alice", "off"); err != nil {
+		t.Fatalf("could not SetUser: %v", err)
+	}
+	if err := a.Authenticate("alice", "secret"); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed for disabled user; got %v", err)
+	}
+	if err := a.Check("alice", "any", "GET", "list"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected disabled user to be denied; got %v", err)
+	}
+
+	if err := a.DelUser("alice"); err != nil {
+		t.Errorf("could not DelUser: %v", err)
+	}
+	if err := a.DelUser("alice"); !errors.Is(err, ErrUserNotExist) {
+		t.Errorf("expected ErrUserNotExist while deleting again; got %v", err)
+	}
+	if err := a.DelUser(DefaultUser); err == nil {
+		t.Errorf("expected error while deleting the default user")
+	}
+}
+
+func TestACL_List(t *testing.T) {
+	a := New()
+
+	if err := a.SetUser("bob", "on", ">pass", "(~jobs:* +POP +APPEND -LEN %list)"); err != nil {
+		t.Fatalf("could not SetUser: %v", err)
+	}
+
+	expected := []string{
+		"user bob on #" + hashPassword("pass") + " (~jobs:* +APPEND -LEN +POP %list)",
+		"user default on nopass (~* +* %*)",
+	}
+
+	list := a.List()
+	if strings.Join(list, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected List:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(list, "\n"))
+	}
+
+	// the listed users can be loaded back
+	loaded, err := Load(strings.NewReader(strings.Join(list, "\n")))
+	if err != nil {
+		t.Fatalf("could not Load listed users: %v", err)
+	}
+	if l := loaded.List(); strings.Join(l, "\n") != strings.Join(list, "\n") {
+		t.Errorf("expected loaded List:\n%s\ngot:\n%s", strings.Join(list, "\n"), strings.Join(l, "\n"))
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	file := `# users of the app
+user default off
+
+user admin on >admin (~* +* %*)
+user reader on #` + hashPassword("reader") + ` (~cache:* +GET)
+`
+
+	f, err := ioutil.TempFile("", "users.acl")
+	if err != nil {
+		t.Fatalf("could not create ACL file: %v", err)
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck
+
+	if _, err = f.WriteString(file); err != nil {
+		t.Fatalf("could not write ACL file: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("could not close ACL file: %v", err)
+	}
+
+	a, err := LoadFile(f.Name())
+	if err != nil {
+		t.Fatalf("could not LoadFile: %v", err)
+	}
+
+	if err := a.Authenticate(DefaultUser, ""); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected default user to be disabled; got %v", err)
+	}
+	if err := a.Authenticate("reader", "reader"); err != nil {
+		t.Errorf("could not Authenticate reader: %v", err)
+	}
+	if err := a.Check("reader", "cache:x", "GET", "str"); err != nil {
+		t.Errorf("expected reader to GET cache keys; got %v", err)
+	}
+
+	_, err = Load(strings.NewReader("user admin on\nuser bad on (~a +GET\n"))
+	if !errors.Is(err, ErrInvalidRule) || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("expected ErrInvalidRule on line 2; got %v", err)
+	}
+
+	if _, err = Load(strings.NewReader("admin on\n")); err == nil {
+		t.Errorf("expected error for line not starting with \"user\"")
+	}
+}
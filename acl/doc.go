@@ -0,0 +1,50 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package acl implements users and access control lists for a kiwi store.
+//
+// Users authenticate with a password and are granted rules. Each rule allows
+// a set of actions on the values of some types for the keys matching any of
+// its glob patterns. A Session checks the rules of the authenticated user
+// before every operation on the store.
+//
+// Users are configured with the same syntax as the "ACL SETUSER" command and
+// the ACL file, which has one user per line:
+//
+//	# comments start with a '#'
+//	user admin on >secret (~* +* %*)
+//	user reader on >pass1 (~cache:* ~session:* +GET %str %hash)
+//	user worker on #<sha256 hex of password> (~jobs:* +APPEND +POP +LEN %list)
+//
+// The tokens configuring a user are:
+//
+//	on, off      enable or disable the user
+//	>password    add a password
+//	<password    remove a password
+//	#hash        add the SHA-256 hex digest of a password
+//	nopass       allow any password
+//	resetpass    remove all the passwords and nopass
+//	resetrules   remove all the rules
+//	reset        disable the user and remove all the passwords and rules
+//	(...)        add a rule
+//
+// The tokens in a rule are:
+//
+//	~pattern     allow the keys matching the glob pattern
+//	+ACTION      allow the action; "+*" allows all actions
+//	-ACTION      disallow the action
+//	%type        allow values of the type; all types are allowed if none is given
+//
+// Besides the actions of the values, ADDKEY, UPDATEKEY, DELETEKEY, TOJSON and
+// FROMJSON guard the respective operations of the store and ACL guards the
+// "ACL LIST" and "ACL SETUSER" commands. ACL is only granted by the rules for
+// all the keys, i.e., with the pattern "*".
+//
+// Servers exposing the pub/sub channels and monitors of the store check
+// PUBLISH and SUBSCRIBE with the channel in place of the key, and MONITOR like
+// ACL, since it sees the operations on all the keys.
+//
+// Passwords are stored as SHA-256 digests only. A new ACL has a "default"
+// user with all the permissions and no password, which sessions start with.
+package acl
@@ -0,0 +1,169 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// ErrInvalidCommand is returned for an unknown or malformed ACL command.
+var ErrInvalidCommand = fmt.Errorf("invalid ACL command")
+
+// Session accesses the store as a user, checking the permissions of the user
+// before every operation.
+//
+// A session is meant to be used by a single connection or client and is not
+// safe to be authenticated concurrently.
+type Session struct {
+	acl   *ACL
+	store *kiwi.Store
+	user  string
+}
+
+// NewSession creates a session for the store authenticated as the default user.
+func (a *ACL) NewSession(store *kiwi.Store) *Session {
+	return &Session{
+		acl:   a,
+		store: store,
+		user:  DefaultUser,
+	}
+}
+
+// Auth authenticates the session as the user. The session stays authenticated
+// as the previous user if the password is not valid.
+func (s *Session) Auth(name, password string) error {
+	if err := s.acl.Authenticate(name, password); err != nil {
+		return err
+	}
+
+	s.user = name
+	return nil
+}
+
+// AuthVerified authenticates the session as the user without a password, for
+// clients whose identity is verified otherwise, e.g., with a TLS client
+// certificate. The user should exist and be enabled.
+func (s *Session) AuthVerified(name string) error {
+	if err := s.acl.enabled(name); err != nil {
+		return err
+	}
+
+	s.user = name
+	return nil
+}
+
+// WhoAmI returns the user the session is authenticated as.
+func (s *Session) WhoAmI() string { return s.user }
+
+// AddKey adds a new key to the store if the user is allowed to.
+func (s *Session) AddKey(key string, typ kiwi.ValueType) error {
+	if err := s.acl.Check(s.user, key, AddKey, typ); err != nil {
+		return err
+	}
+
+	return s.store.AddKeyAs(s.user, key, typ)
+}
+
+// UpdateKey updates the value type of the key if the user is allowed to
+// replace the old value as well as to create the new one.
+func (s *Session) UpdateKey(key string, typ kiwi.ValueType) error {
+	if err := s.check(key, UpdateKey); err != nil {
+		return err
+	}
+
+	if err := s.acl.Check(s.user, key, UpdateKey, typ); err != nil {
+		return err
+	}
+
+	return s.store.UpdateKey(key, typ)
+}
+
+// DeleteKey deletes the key if the user is allowed to.
+func (s *Session) DeleteKey(key string) error {
+	if err := s.check(key, DeleteKey); err != nil {
+		return err
+	}
+
+	return s.store.DeleteKeyAs(s.user, key)
+}
+
+// Do executes the action for the value associated with the key if the user is
+// allowed to.
+func (s *Session) Do(key string, action kiwi.Action, params ...interface{}) (interface{}, error) {
+	if err := s.check(key, action); err != nil {
+		return nil, err
+	}
+
+	return s.store.DoAs(s.user, key, action, params...)
+}
+
+// ToJSON converts the data associated with the value into JSON format if the
+// user is allowed to.
+func (s *Session) ToJSON(key string) (json.RawMessage, error) {
+	if err := s.check(key, ToJSON); err != nil {
+		return nil, err
+	}
+
+	return s.store.ToJSON(key)
+}
+
+// FromJSON loads the raw JSON form of data into the value if the user is
+// allowed to.
+func (s *Session) FromJSON(key string, rawmessage json.RawMessage) error {
+	if err := s.check(key, FromJSON); err != nil {
+		return err
+	}
+
+	return s.store.FromJSON(key, rawmessage)
+}
+
+// check checks if the user can execute the action on the value of the key.
+func (s *Session) check(key string, action kiwi.Action) error {
+	// The type is left empty if the key does not exist so that the store can
+	// tell it doesn't, but only if the user can access the key otherwise.
+	typ, _ := s.store.GetValueType(key)
+	return s.acl.Check(s.user, key, action, typ)
+}
+
+// ACL executes the ACL command with the arguments, i.e., args[0] is one of
+// the subcommands:
+//
+//	LIST                  returns the users in the format of the ACL file ([]string)
+//	SETUSER name tokens   creates or modifies the user (returns nil)
+//	WHOAMI                returns the user of the session (string)
+//
+// LIST and SETUSER require the ACL action to be allowed for the user.
+func (s *Session) ACL(args ...string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: missing subcommand", ErrInvalidCommand)
+	}
+
+	switch sub := strings.ToUpper(args[0]); sub {
+	case "WHOAMI":
+		return s.WhoAmI(), nil
+
+	case "LIST":
+		if err := s.acl.CheckAction(s.user, Admin); err != nil {
+			return nil, err
+		}
+		return s.acl.List(), nil
+
+	case "SETUSER":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%w: SETUSER requires the name of the user", ErrInvalidCommand)
+		}
+		if err := s.acl.CheckAction(s.user, Admin); err != nil {
+			return nil, err
+		}
+		return nil, s.acl.SetUser(args[1], args[2:]...)
+
+	default:
+		return nil, fmt.Errorf("%w: unknown subcommand %q", ErrInvalidCommand, sub)
+	}
+}
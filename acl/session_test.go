@@ -0,0 +1,123 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package acl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/list"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+func TestSession(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{
+		"cache:a": str.Type,
+		"jobs":    list.Type,
+	})
+	if err != nil {
+		t.Fatalf("couldn't create store: %v", err)
+	}
+
+	a := New()
+	if err := a.SetUser("reader", "on", ">pass", "(~cache:* +GET +TOJSON %str)"); err != nil {
+		t.Fatalf("could not SetUser: %v", err)
+	}
+
+	s := a.NewSession(store)
+	if user := s.WhoAmI(); user != DefaultUser {
+		t.Errorf("expected new session to be %q; got %q", DefaultUser, user)
+	}
+
+	if err := s.Auth("reader", "wrong"); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed with wrong password; got %v", err)
+	}
+	if err := s.Auth("reader", "pass"); err != nil {
+		t.Fatalf("could not Auth: %v", err)
+	}
+
+	if v, err := s.ACL("whoami"); err != nil || v != "reader" {
+		t.Errorf("expected ACL WHOAMI to return %q; got %v, %v", "reader", v, err)
+	}
+
+	if _, err := s.Do("cache:a", str.Get); err != nil {
+		t.Errorf("expected GET on cache key to be allowed; got %v", err)
+	}
+	if _, err := s.ToJSON("cache:a"); err != nil {
+		t.Errorf("expected TOJSON on cache key to be allowed; got %v", err)
+	}
+
+	denied := map[string]error{}
+	_, denied["UPDATE"] = s.Do("cache:a", str.Update, "x")
+	_, denied["LEN"] = s.Do("jobs", list.Len)
+	denied["ADDKEY"] = s.AddKey("cache:b", list.Type)
+	denied["UPDATEKEY"] = s.UpdateKey("cache:a", str.Type)
+	denied["DELETEKEY"] = s.DeleteKey("cache:a")
+	denied["FROMJSON"] = s.FromJSON("cache:a", []byte(`"x"`))
+	_, denied["ACL LIST"] = s.ACL("LIST")
+	_, denied["ACL SETUSER"] = s.ACL("SETUSER", "reader", "(~* +*)")
+
+	for op, err := range denied {
+		if !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("expected %s to be denied; got %v", op, err)
+		}
+	}
+
+	// actions on all the keys are not granted by rules for some keys
+	if err := a.SetUser("cacher", "on", ">pass", "(~cache:* +*)"); err != nil {
+		t.Fatalf("could not SetUser: %v", err)
+	}
+	if err := s.Auth("cacher", "pass"); err != nil {
+		t.Fatalf("could not Auth: %v", err)
+	}
+	if _, err := s.ACL("SETUSER", "bob", "on", "nopass", "(~*", "+*)"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ACL SETUSER by user for some keys to be denied; got %v", err)
+	}
+	if _, err := s.ACL("LIST"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ACL LIST by user for some keys to be denied; got %v", err)
+	}
+	if err := s.Auth("reader", "pass"); err != nil {
+		t.Fatalf("could not Auth: %v", err)
+	}
+
+	// verified users are authenticated without a password
+	verified := a.NewSession(store)
+	if err := verified.AuthVerified("reader"); err != nil || verified.WhoAmI() != "reader" {
+		t.Errorf("expected AuthVerified to authenticate as reader; got %q (%v)", verified.WhoAmI(), err)
+	}
+	if err := verified.AuthVerified("nobody"); !errors.Is(err, ErrAuthFailed) || verified.WhoAmI() != "reader" {
+		t.Errorf("expected ErrAuthFailed for unknown user; got %q (%v)", verified.WhoAmI(), err)
+	}
+
+	// the default user can do everything
+	admin := a.NewSession(store)
+
+	if _, err := admin.ACL("SETUSER", "reader", "(~jobs", "+LEN)"); err != nil {
+		t.Fatalf("could not ACL SETUSER: %v", err)
+	}
+	if _, err := s.Do("jobs", list.Len); err != nil {
+		t.Errorf("expected LEN to be allowed after SETUSER; got %v", err)
+	}
+
+	v, err := admin.ACL("LIST")
+	if err != nil {
+		t.Fatalf("could not ACL LIST: %v", err)
+	}
+	if users, ok := v.([]string); !ok || len(users) != 3 {
+		t.Errorf("expected ACL LIST to return 3 users; got %v", v)
+	}
+
+	if _, err := admin.ACL("BOGUS"); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("expected ErrInvalidCommand for unknown subcommand; got %v", err)
+	}
+
+	if err := admin.AddKey("new", str.Type); err != nil {
+		t.Errorf("could not AddKey as default user: %v", err)
+	}
+	if err := admin.DeleteKey("new"); err != nil {
+		t.Errorf("could not DeleteKey as default user: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package kiwi
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTimeout is returned by Block when the timeout expires.
+var ErrTimeout = fmt.Errorf("timed out")
+
+// TryFunc tries an operation on the key, e.g., popping an element from a
+// list. It returns false if the key is not ready, e.g., the list is empty,
+// and the operation should be retried once the key changes.
+type TryFunc func(key string) (ok bool, err error)
+
+// Block calls try with each of the keys in order until it returns true for
+// one of them, which is returned. When it returns false for all the keys,
+// Block waits until an action in wake is executed on any of the keys, or the
+// key is added or loaded, and tries again.
+//
+// Waiters are woken in the order they started waiting for a key and only
+// try the keys they are woken for. The waiter which returns passes the
+// wakeup on to the next one, so that all the elements added at once are
+// consumed. A caller trying a key which is
+// ready does not wait, even if there are waiters.
+//
+// It returns ErrTimeout if the timeout, if not 0, expires, or the error of
+// the context if it is done before. An error returned by try is returned
+// immediately.
+func (s *Store) Block(ctx context.Context, timeout time.Duration, keys []string, wake []Action,
+	try TryFunc) (string, error) {
+	w := s.blocked.add(keys, wake)
+
+	var done string
+	defer func() { s.blocked.remove(w, done) }()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for toTry := keys; ; {
+		for _, key := range toTry {
+			ok, err := try(key)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				done = key
+				return key, nil
+			}
+		}
+
+		select {
+		case <-w.wake:
+			// only the keys the waiter is woken for are tried, so that it
+			// does not take the change of a key from the waiters before it
+			toTry = s.blocked.woken(w, keys)
+		case <-expired:
+			return "", ErrTimeout
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// blocked contains the waiters blocked on keys of a store.
+type blocked struct {
+	// waiting is the number of waiters, which avoids locking when there are
+	// none.
+	waiting int64
+
+	queues map[string]*list.List // of *waiter, for each key
+	mu     sync.Mutex
+}
+
+// newBlocked creates an empty blocked.
+func newBlocked() blocked {
+	return blocked{
+		queues: make(map[string]*list.List),
+		mu:     sync.Mutex{},
+	}
+}
+
+// waiter is a caller of Block.
+type waiter struct {
+	wake    chan struct{}
+	actions map[Action]struct{}
+
+	// elems are the elements of the waiter in the queues of its keys.
+	elems map[string]*list.Element
+
+	// wokenFor are the keys the waiter is woken for and has not tried since.
+	wokenFor map[string]struct{}
+}
+
+// add adds a waiter for the keys woken by the actions to the end of their
+// queues.
+func (b *blocked) add(keys []string, wake []Action) *waiter {
+	w := &waiter{
+		wake:     make(chan struct{}, 1),
+		actions:  make(map[Action]struct{}, len(wake)),
+		elems:    make(map[string]*list.Element, len(keys)),
+		wokenFor: make(map[string]struct{}),
+	}
+
+	for _, action := range wake {
+		w.actions[action] = struct{}{}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range keys {
+		if _, ok := w.elems[key]; ok {
+			continue
+		}
+
+		q, ok := b.queues[key]
+		if !ok {
+			q = list.New()
+			b.queues[key] = q
+		}
+		w.elems[key] = q.PushBack(w)
+	}
+
+	atomic.AddInt64(&b.waiting, 1)
+	return w
+}
+
+// woken returns the keys, in order, the waiter is woken for.
+func (b *blocked) woken(w *waiter, keys []string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var woken []string
+	for _, key := range keys {
+		if _, ok := w.wokenFor[key]; ok {
+			woken = append(woken, key)
+			delete(w.wokenFor, key)
+		}
+	}
+
+	return woken
+}
+
+// remove removes the waiter from the queues. The wakeup is passed on to the
+// next waiters for the key the waiter is done with, which may still be ready,
+// and for the keys it was woken for but did not try.
+func (b *blocked) remove(w *waiter, done string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, elem := range w.elems {
+		q := b.queues[key]
+		q.Remove(elem)
+		if q.Len() == 0 {
+			delete(b.queues, key)
+		}
+	}
+
+	atomic.AddInt64(&b.waiting, -1)
+
+	if done != "" {
+		w.wokenFor[done] = struct{}{}
+	}
+	for key := range w.wokenFor {
+		b.wakeFirst(key, "")
+	}
+}
+
+// notify wakes the first waiter for the key of the event, if the event can
+// make the key ready for it.
+func (b *blocked) notify(e Event) {
+	if atomic.LoadInt64(&b.waiting) == 0 {
+		return
+	}
+
+	switch e.Op {
+	case EventDo, EventAddKey, EventUpdateKey, EventFromJSON:
+	default:
+		return
+	}
+	if e.Err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.wakeFirst(e.Key, e.Action)
+}
+
+// wakeFirst wakes the first waiter for the key woken by the action, or by any
+// action if it is empty. The lock should be held.
+func (b *blocked) wakeFirst(key string, action Action) {
+	q, ok := b.queues[key]
+	if !ok {
+		return
+	}
+
+	for elem := q.Front(); elem != nil; elem = elem.Next() {
+		w := elem.Value.(*waiter) //nolint:errcheck
+		if action != "" {
+			if _, ok := w.actions[action]; !ok {
+				continue
+			}
+		}
+
+		w.wokenFor[key] = struct{}{}
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+		return
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package kiwi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// queueType is a value used to test blocking, which is a queue of strings.
+const queueType ValueType = "blocktest.queue"
+
+func init() {
+	RegisterValue(func() Value { return new(queueValue) })
+}
+
+type queueValue []string
+
+func (q *queueValue) Type() ValueType { return queueType }
+
+func (q *queueValue) DoMap() map[Action]DoFunc {
+	return map[Action]DoFunc{
+		"PUSH": func(params ...interface{}) (interface{}, error) {
+			for _, p := range params {
+				*q = append(*q, p.(string))
+			}
+			return nil, nil
+		},
+		"POP": func(params ...interface{}) (interface{}, error) {
+			if len(*q) == 0 {
+				return "", nil
+			}
+			s := (*q)[0]
+			*q = (*q)[1:]
+			return s, nil
+		},
+		"LEN": func(params ...interface{}) (interface{}, error) {
+			return len(*q), nil
+		},
+	}
+}
+
+func (q *queueValue) ToJSON() (json.RawMessage, error) { return json.Marshal(q) }
+
+func (q *queueValue) FromJSON(rawmessage json.RawMessage) error { return json.Unmarshal(rawmessage, q) }
+
+// popper pops from the queues with Block, sending what it popped to the
+// channel.
+func popper(store *Store, timeout time.Duration, keys ...string) <-chan string {
+	popped := make(chan string, 1)
+
+	go func() {
+		var elem string
+		key, err := store.Block(context.Background(), timeout, keys, []Action{"PUSH"}, func(key string) (bool, error) {
+			v, err := store.Do(key, "POP")
+			if errors.Is(err, ErrKeyNotExist) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			elem = v.(string)
+			return elem != "", nil
+		})
+		if err != nil {
+			popped <- err.Error()
+			return
+		}
+		popped <- key + ":" + elem
+	}()
+
+	return popped
+}
+
+// waitWaiting waits until n callers of Block are waiting.
+func waitWaiting(t *testing.T, store *Store, n int64) {
+	t.Helper()
+
+	for i := 0; atomic.LoadInt64(&store.blocked.waiting) != n; i++ {
+		if i == 1000 {
+			t.Fatalf("expected %d waiters; got %d", n, atomic.LoadInt64(&store.blocked.waiting))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStore_Block(t *testing.T) {
+	store, err := NewStoreFromSchema(Schema{"a": queueType, "b": queueType})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	// the waiters are served in order
+	first := popper(store, 0, "a", "b")
+	waitWaiting(t, store, 1)
+	second := popper(store, 0, "b")
+	waitWaiting(t, store, 2)
+	third := popper(store, 0, "a", "b")
+	waitWaiting(t, store, 3)
+
+	// actions other than the wake actions do not wake the waiters
+	if _, err := store.Do("b", "LEN"); err != nil {
+		t.Fatalf("error while getting length: %v", err)
+	}
+
+	// all the elements pushed at once are popped
+	if _, err := store.Do("b", "PUSH", "x", "y"); err != nil {
+		t.Fatalf("error while pushing: %v", err)
+	}
+
+	if got := <-first; got != "b:x" {
+		t.Errorf("expected first waiter to pop b:x; got %q", got)
+	}
+	if got := <-second; got != "b:y" {
+		t.Errorf("expected second waiter to pop b:y; got %q", got)
+	}
+
+	// a key added later wakes the waiters too
+	fourth := popper(store, 0, "c")
+	waitWaiting(t, store, 2)
+	if err := store.FromJSON("a", []byte(`["z"]`)); err != nil {
+		t.Fatalf("error while loading: %v", err)
+	}
+	if got := <-third; got != "a:z" {
+		t.Errorf("expected third waiter to pop a:z; got %q", got)
+	}
+
+	if err := store.AddKey("c", queueType); err != nil {
+		t.Fatalf("error while adding key: %v", err)
+	}
+	if _, err := store.Do("c", "PUSH", "w"); err != nil {
+		t.Fatalf("error while pushing: %v", err)
+	}
+	if got := <-fourth; got != "c:w" {
+		t.Errorf("expected fourth waiter to pop c:w; got %q", got)
+	}
+
+	if got := <-popper(store, 10*time.Millisecond, "a"); got != ErrTimeout.Error() {
+		t.Errorf("expected timeout; got %q", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := store.Block(ctx, 0, []string{"a"}, nil, func(string) (bool, error) { return false, nil })
+		done <- err
+	}()
+	waitWaiting(t, store, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled; got %v", err)
+	}
+
+	// an error of try is returned immediately
+	tryErr := errors.New("try")
+	if _, err := store.Block(context.Background(), 0, []string{"a"}, nil, func(string) (bool, error) {
+		return false, tryErr
+	}); err != tryErr {
+		t.Errorf("expected error of try; got %v", err)
+	}
+
+	waitWaiting(t, store, 0)
+	if len(store.blocked.queues) != 0 {
+		t.Errorf("expected no queues left; got %v", store.blocked.queues)
+	}
+}
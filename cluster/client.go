@@ -0,0 +1,217 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// ErrTooManyRedirects is returned when the command is redirected too many times.
+var ErrTooManyRedirects = fmt.Errorf("too many redirects")
+
+// Dialer returns the connection to the node with the ID.
+type Dialer func(id string) (Conn, error)
+
+// Client sends commands to the nodes owning the keys.
+//
+// It caches the slot map and updates it when it is redirected.
+type Client struct {
+	dial  Dialer
+	seeds []string
+
+	owners [Slots]string
+	conns  map[string]Conn
+
+	// MaxRedirects is the maximum number of redirects followed for a
+	// command. Defaults to 5.
+	MaxRedirects int
+
+	mu sync.RWMutex
+}
+
+// NewClient creates a client which loads the slot map from one of the seed
+// nodes.
+func NewClient(dial Dialer, seeds ...string) (*Client, error) {
+	c := &Client{
+		dial:         dial,
+		seeds:        seeds,
+		conns:        make(map[string]Conn),
+		MaxRedirects: 5,
+		mu:           sync.RWMutex{},
+	}
+
+	if err := c.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Refresh reloads the slot map from the first seed or known node which can be
+// reached.
+func (c *Client) Refresh() error {
+	c.mu.RLock()
+	ids := append([]string(nil), c.seeds...)
+	for id := range c.conns {
+		ids = append(ids, id)
+	}
+	c.mu.RUnlock()
+
+	var err error
+	for _, id := range ids {
+		var conn Conn
+		if conn, err = c.conn(id); err != nil {
+			continue
+		}
+
+		var ranges []SlotRange
+		if ranges, err = conn.Slots(); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		c.owners = [Slots]string{}
+		for _, r := range ranges {
+			for slot := int(r.Start); slot <= int(r.End); slot++ {
+				c.owners[slot] = r.Node
+			}
+		}
+		c.mu.Unlock()
+
+		return nil
+	}
+
+	return fmt.Errorf("cannot load slot map: %v", err)
+}
+
+// AddKey adds a new key to the cluster.
+func (c *Client) AddKey(key string, typ kiwi.ValueType) error {
+	_, err := c.Exec(&Command{Op: OpAddKey, Key: key, Type: typ})
+	return err
+}
+
+// UpdateKey updates the value type of the key.
+func (c *Client) UpdateKey(key string, typ kiwi.ValueType) error {
+	_, err := c.Exec(&Command{Op: OpUpdateKey, Key: key, Type: typ})
+	return err
+}
+
+// DeleteKey deletes the key from the cluster.
+func (c *Client) DeleteKey(key string) error {
+	_, err := c.Exec(&Command{Op: OpDeleteKey, Key: key})
+	return err
+}
+
+// GetValueType returns the type of value corresponding to the key.
+func (c *Client) GetValueType(key string) (kiwi.ValueType, error) {
+	v, err := c.Exec(&Command{Op: OpGetValueType, Key: key})
+	if err != nil {
+		return "", err
+	}
+
+	typ, _ := v.(kiwi.ValueType)
+	return typ, nil
+}
+
+// Do executes the action for the value associated with the key.
+func (c *Client) Do(key string, action kiwi.Action, params ...interface{}) (interface{}, error) {
+	return c.Exec(&Command{Op: OpDo, Key: key, Action: action, Params: params})
+}
+
+// ToJSON converts the data associated with the value into JSON format.
+func (c *Client) ToJSON(key string) (json.RawMessage, error) {
+	v, err := c.Exec(&Command{Op: OpToJSON, Key: key})
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := v.(json.RawMessage)
+	return data, nil
+}
+
+// FromJSON takes the raw JSON form of data and loads it into the value.
+func (c *Client) FromJSON(key string, rawmessage json.RawMessage) error {
+	_, err := c.Exec(&Command{Op: OpFromJSON, Key: key, Data: rawmessage})
+	return err
+}
+
+// Exec sends the command to the node owning the key, following redirects.
+func (c *Client) Exec(cmd *Command) (interface{}, error) {
+	slot := Slot(cmd.Key)
+
+	c.mu.RLock()
+	node := c.owners[slot]
+	c.mu.RUnlock()
+
+	if node == "" {
+		if err := c.Refresh(); err != nil {
+			return nil, err
+		}
+
+		c.mu.RLock()
+		node = c.owners[slot]
+		c.mu.RUnlock()
+
+		if node == "" {
+			return nil, newSlotErr(ErrSlotNotAssigned, int(slot))
+		}
+	}
+
+	asking := false
+	for i := 0; i <= c.MaxRedirects; i++ {
+		conn, err := c.conn(node)
+		if err != nil {
+			return nil, err
+		}
+
+		req := *cmd
+		req.Asking = asking
+
+		res, err := conn.Exec(&req)
+
+		var redirect *RedirectError
+		if !errors.As(err, &redirect) {
+			return res, err
+		}
+
+		node = redirect.Node
+		asking = redirect.Kind == Ask
+
+		if redirect.Kind == Moved {
+			c.mu.Lock()
+			c.owners[redirect.Slot] = redirect.Node
+			c.mu.Unlock()
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrTooManyRedirects, cmd.Key)
+}
+
+// conn returns the connection to the node, dialing it if required.
+func (c *Client) conn(id string) (Conn, error) {
+	c.mu.RLock()
+	conn, ok := c.conns[id]
+	c.mu.RUnlock()
+
+	if ok {
+		return conn, nil
+	}
+
+	conn, err := c.dial(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.conns[id] = conn
+	c.mu.Unlock()
+
+	return conn, nil
+}
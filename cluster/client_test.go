@@ -0,0 +1,295 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/list"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// newTestCluster creates two nodes "a" and "b" sharing the slots equally.
+func newTestCluster(t *testing.T) (map[string]*Node, *Client) {
+	slots := NewSlotMap()
+	if err := slots.Assign(0, Slots/2-1, "a"); err != nil {
+		t.Fatalf("could not Assign: %v", err)
+	}
+	if err := slots.Assign(Slots/2, Slots-1, "b"); err != nil {
+		t.Fatalf("could not Assign: %v", err)
+	}
+
+	nodes := map[string]*Node{
+		"a": NewNode("a", kiwi.NewStore(), slots),
+		"b": NewNode("b", kiwi.NewStore(), slots),
+	}
+
+	client, err := NewClient(func(id string) (Conn, error) {
+		node, ok := nodes[id]
+		if !ok {
+			return nil, fmt.Errorf("no node %q", id)
+		}
+		return node, nil
+	}, "a")
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	return nodes, client
+}
+
+func TestNode_Redirect(t *testing.T) {
+	nodes, _ := newTestCluster(t)
+
+	key := "foo" // slot 12182 is on "b"
+
+	_, err := nodes["a"].Exec(&Command{Op: OpAddKey, Key: key, Type: str.Type})
+
+	var redirect *RedirectError
+	if !errors.As(err, &redirect) {
+		t.Fatalf("expected RedirectError from node not owning the slot; got %v", err)
+	}
+	if redirect.Kind != Moved || redirect.Node != "b" || redirect.Slot != Slot(key) {
+		t.Errorf("expected MOVED %d b; got %v", Slot(key), redirect)
+	}
+
+	if _, err := nodes["b"].Exec(&Command{Op: OpAddKey, Key: key, Type: str.Type}); err != nil {
+		t.Errorf("could not AddKey on node owning the slot: %v", err)
+	}
+
+	// while migrating, keys not on the source are asked for on the target
+	slot := Slot(key)
+	if err := nodes["b"].setMigrating(slot, "a"); err != nil {
+		t.Fatalf("could not set slot migrating: %v", err)
+	}
+	nodes["a"].setImporting(slot, "b")
+
+	if _, err := nodes["b"].Exec(&Command{Op: OpToJSON, Key: key}); err != nil {
+		t.Errorf("expected key still on source to be served; got %v", err)
+	}
+
+	newKey := "{foo}:new"
+	_, err = nodes["b"].Exec(&Command{Op: OpAddKey, Key: newKey, Type: str.Type})
+	if !errors.As(err, &redirect) || redirect.Kind != Ask || redirect.Node != "a" {
+		t.Fatalf("expected ASK %d a for new key while migrating; got %v", slot, err)
+	}
+
+	_, err = nodes["a"].Exec(&Command{Op: OpAddKey, Key: newKey, Type: str.Type})
+	if !errors.As(err, &redirect) || redirect.Kind != Moved || redirect.Node != "b" {
+		t.Errorf("expected MOVED %d b without asking; got %v", slot, err)
+	}
+
+	if _, err := nodes["a"].Exec(&Command{Op: OpAddKey, Key: newKey, Type: str.Type, Asking: true}); err != nil {
+		t.Errorf("could not AddKey on target while asking: %v", err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	nodes, client := newTestCluster(t)
+
+	// related keys are on the same node
+	keys := []string{"{user:1}:name", "{user:1}:email", "foo", "bar"}
+	for _, key := range keys {
+		if err := client.AddKey(key, str.Type); err != nil {
+			t.Fatalf("could not AddKey %q: %v", key, err)
+		}
+		if _, err := client.Do(key, str.Update, key); err != nil {
+			t.Fatalf("could not Do UPDATE for %q: %v", key, err)
+		}
+	}
+
+	if nodes["a"].store.KeyExists("{user:1}:name") != nodes["a"].store.KeyExists("{user:1}:email") {
+		t.Errorf("expected keys with same hashtag on the same node")
+	}
+
+	for _, key := range keys {
+		v, err := client.Do(key, str.Get)
+		if err != nil {
+			t.Fatalf("could not Do GET for %q: %v", key, err)
+		}
+		if v != key {
+			t.Errorf("expected GET %q; got %v", key, v)
+		}
+
+		owner := nodes[client.owners[Slot(key)]]
+		if !owner.store.KeyExists(key) {
+			t.Errorf("expected key %q on node %q", key, owner.ID())
+		}
+	}
+
+	data, err := client.ToJSON("foo")
+	if err != nil {
+		t.Fatalf("could not ToJSON: %v", err)
+	}
+	if string(data) != `"foo"` {
+		t.Errorf("expected JSON %q; got %q", `"foo"`, data)
+	}
+
+	if err := client.FromJSON("bar", data); err != nil {
+		t.Fatalf("could not FromJSON: %v", err)
+	}
+	if v, _ := client.Do("bar", str.Get); v != "foo" {
+		t.Errorf("expected GET %q after FromJSON; got %v", "foo", v)
+	}
+
+	typ, err := client.GetValueType("bar")
+	if err != nil {
+		t.Fatalf("could not GetValueType: %v", err)
+	}
+	if typ != str.Type {
+		t.Errorf("expected value type %q; got %q", str.Type, typ)
+	}
+
+	if err := client.DeleteKey("bar"); err != nil {
+		t.Fatalf("could not DeleteKey: %v", err)
+	}
+	if _, err := client.Do("bar", str.Get); !errors.Is(err, kiwi.ErrKeyNotExist) {
+		t.Errorf("expected ErrKeyNotExist after deleting; got %v", err)
+	}
+}
+
+func TestMigrateSlot(t *testing.T) {
+	nodes, client := newTestCluster(t)
+	a, b := nodes["a"], nodes["b"]
+
+	// keys sharing the same slot on "a"
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("{bar}:%d", i)
+		if err := client.AddKey(keys[i], list.Type); err != nil {
+			t.Fatalf("could not AddKey: %v", err)
+		}
+	}
+	slot := Slot("bar")
+
+	if owner := client.owners[slot]; owner != "a" {
+		t.Fatalf("expected slot %d on %q; got %q", slot, "a", owner)
+	}
+
+	// keep appending to the keys while the slot is migrated
+	const writes = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(keys))
+
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				if _, err := client.Do(key, list.Append, fmt.Sprint(i)); err != nil {
+					errs <- fmt.Errorf("could not APPEND to %q: %v", key, err)
+					return
+				}
+			}
+		}(key)
+	}
+
+	if err := MigrateSlot(slot, a, b); err != nil {
+		t.Fatalf("could not MigrateSlot: %v", err)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if keys := a.KeysInSlot(slot); len(keys) != 0 {
+		t.Errorf("expected no keys left on source; got %v", keys)
+	}
+	if owner := a.slots.Owner(slot); owner != "b" {
+		t.Errorf("expected slot to be owned by %q; got %q", "b", owner)
+	}
+
+	// no write should be lost
+	for _, key := range keys {
+		v, err := b.Exec(&Command{Op: OpDo, Key: key, Action: list.Len})
+		if err != nil {
+			t.Fatalf("could not Do LEN on target: %v", err)
+		}
+		if v != writes {
+			t.Errorf("expected %d elements in %q; got %v", writes, key, v)
+		}
+	}
+
+	// the client learns about the new owner
+	if _, err := client.Do(keys[0], list.Len); err != nil {
+		t.Fatalf("could not Do LEN: %v", err)
+	}
+	if owner := client.owners[slot]; owner != "b" {
+		t.Errorf("expected client to cache new owner %q; got %q", "b", owner)
+	}
+
+	// the slot cannot be migrated again from the old owner
+	if err := MigrateSlot(slot, a, b); !errors.Is(err, ErrSlotNotOwned) {
+		t.Errorf("expected ErrSlotNotOwned while migrating slot not owned; got %v", err)
+	}
+}
+
+func TestMigrateSlot_Resume(t *testing.T) {
+	nodes, client := newTestCluster(t)
+	a, b := nodes["a"], nodes["b"]
+
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("{bar}:%d", i)
+		if err := client.AddKey(keys[i], str.Type); err != nil {
+			t.Fatalf("could not AddKey: %v", err)
+		}
+		if _, err := client.Do(keys[i], str.Update, keys[i]); err != nil {
+			t.Fatalf("could not UPDATE: %v", err)
+		}
+	}
+	slot := Slot("bar")
+
+	// a stale key on the target fails the migration
+	if err := b.store.AddKey(keys[5], str.Type); err != nil {
+		t.Fatalf("could not AddKey on target: %v", err)
+	}
+
+	if err := MigrateSlot(slot, a, b); !errors.Is(err, kiwi.ErrKeyExists) {
+		t.Fatalf("expected ErrKeyExists while migrating; got %v", err)
+	}
+
+	if owner := a.slots.Owner(slot); owner != "a" {
+		t.Errorf("expected slot to be owned by %q; got %q", "a", owner)
+	}
+
+	// the keys moved and those left are all served
+	for _, key := range keys {
+		v, err := client.Do(key, str.Get)
+		if err != nil || v != key {
+			t.Errorf("expected GET %q to return %q; got %v (%v)", key, key, v, err)
+		}
+	}
+
+	// the slot cannot be migrated elsewhere meanwhile
+	if err := MigrateSlot(slot, a, a); !errors.Is(err, ErrSlotMigrating) {
+		t.Errorf("expected ErrSlotMigrating; got %v", err)
+	}
+
+	if err := b.store.DeleteKey(keys[5]); err != nil {
+		t.Fatalf("could not DeleteKey on target: %v", err)
+	}
+
+	if err := MigrateSlot(slot, a, b); err != nil {
+		t.Fatalf("could not resume MigrateSlot: %v", err)
+	}
+
+	if keys := a.KeysInSlot(slot); len(keys) != 0 {
+		t.Errorf("expected no keys left on source; got %v", keys)
+	}
+	for _, key := range keys {
+		v, err := client.Do(key, str.Get)
+		if err != nil || v != key {
+			t.Errorf("expected GET %q to return %q; got %v (%v)", key, key, v, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package cluster implements sharding of keys across multiple kiwi stores.
+//
+// Keys are split into a fixed number of hash slots and ranges of slots are
+// assigned to nodes, each of which owns a kiwi.Store. Only the part of the key
+// between the first "{" and the following "}" is hashed, if it is not empty, so
+// related keys like "{user:1}:name" and "{user:1}:email" always end up on the
+// same node.
+//
+// A node serves commands only for the keys in its slots. For other keys it
+// returns a RedirectError telling which node to ask instead: a MOVED redirect
+// when the slot belongs to another node and an ASK redirect when the slot is
+// being migrated and the key has already been moved.
+//
+// Client is cluster-aware: it caches the slot map, sends each command to the
+// node owning the key and follows the redirects.
+//
+//
+// Get Started
+//
+//	slots := cluster.NewSlotMap()
+//	_ = slots.Assign(0, 8191, "a")
+//	_ = slots.Assign(8192, cluster.Slots-1, "b")
+//
+//	nodes := map[string]*cluster.Node{
+//	  "a": cluster.NewNode("a", kiwi.NewStore(), slots),
+//	  "b": cluster.NewNode("b", kiwi.NewStore(), slots),
+//	}
+//
+//	client, err := cluster.NewClient(func(id string) (cluster.Conn, error) {
+//	  return nodes[id], nil
+//	}, "a")
+//	if err != nil {
+//	  // handle error
+//	}
+//
+//	if err := client.AddKey("my_string", "str"); err != nil {
+//	  // handle error
+//	}
+//
+//	// move a slot from "a" to "b" while the client is being used
+//	if err := cluster.MigrateSlot(42, nodes["a"], nodes["b"]); err != nil {
+//	  // handle error
+//	}
+package cluster
@@ -0,0 +1,311 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// Various errors related to the slots of a node.
+var (
+	ErrSlotNotAssigned = fmt.Errorf("slot not assigned")
+	ErrSlotNotOwned    = fmt.Errorf("slot not owned by node")
+	ErrSlotMigrating   = fmt.Errorf("slot already being migrated")
+)
+
+// RedirectKind tells whether the redirect is permanent or not.
+type RedirectKind uint8
+
+const (
+	// Moved redirects are returned when the slot is assigned to another
+	// node. Clients should update their slot map.
+	Moved RedirectKind = iota
+
+	// Ask redirects are returned when the slot is being migrated and the key
+	// is not on the node anymore. Clients should send only the next command
+	// to the other node, with Asking set.
+	Ask
+)
+
+// String implements the fmt.Stringer interface.
+func (k RedirectKind) String() string {
+	if k == Ask {
+		return "ASK"
+	}
+
+	return "MOVED"
+}
+
+// RedirectError is returned when the command should be sent to another node.
+type RedirectError struct {
+	Kind RedirectKind
+	Slot uint16
+	Node string
+}
+
+// Error implements the error interface.
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("%s %d %s", e.Kind, e.Slot, e.Node)
+}
+
+// Op is the operation executed by a command.
+type Op uint8
+
+const (
+	// OpDo executes an action for the value associated with the key.
+	OpDo Op = iota
+
+	// OpAddKey adds a new key.
+	OpAddKey
+
+	// OpUpdateKey updates the value type of the key.
+	OpUpdateKey
+
+	// OpDeleteKey deletes the key.
+	OpDeleteKey
+
+	// OpGetValueType returns the value type of the key.
+	OpGetValueType
+
+	// OpToJSON returns the JSON data of the value associated with the key.
+	OpToJSON
+
+	// OpFromJSON loads the JSON data into the value associated with the key.
+	OpFromJSON
+)
+
+// Command is executed on the node owning the key.
+type Command struct {
+	Op     Op
+	Key    string
+	Type   kiwi.ValueType
+	Action kiwi.Action
+	Params []interface{}
+	Data   json.RawMessage
+
+	// Asking is set when the command is sent after an ASK redirect.
+	Asking bool
+}
+
+// Conn is a connection to a node of the cluster.
+type Conn interface {
+	// Exec executes the command on the node.
+	Exec(cmd *Command) (interface{}, error)
+
+	// Slots returns the slot map known to the node.
+	Slots() ([]SlotRange, error)
+}
+
+// Node owns the keys of the slots assigned to it.
+type Node struct {
+	id    string
+	store *kiwi.Store
+	slots *SlotMap
+
+	// migrating maps the slots being migrated to the target nodes.
+	migrating map[uint16]string
+
+	// importing maps the slots being imported to the source nodes.
+	importing map[uint16]string
+
+	mu sync.RWMutex
+}
+
+// NewNode creates a node with the ID which stores the keys in the store.
+//
+// The slot map is shared by all the nodes of the cluster.
+func NewNode(id string, store *kiwi.Store, slots *SlotMap) *Node {
+	return &Node{
+		id:        id,
+		store:     store,
+		slots:     slots,
+		migrating: make(map[uint16]string),
+		importing: make(map[uint16]string),
+		mu:        sync.RWMutex{},
+	}
+}
+
+// ID returns the ID of the node.
+func (n *Node) ID() string { return n.id }
+
+// Slots implements the Conn interface.
+func (n *Node) Slots() ([]SlotRange, error) {
+	return n.slots.Ranges(), nil
+}
+
+// Exec implements the Conn interface.
+func (n *Node) Exec(cmd *Command) (interface{}, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	slot := Slot(cmd.Key)
+
+	switch owner := n.slots.Owner(slot); {
+	case owner == "":
+		return nil, newSlotErr(ErrSlotNotAssigned, int(slot))
+
+	case owner != n.id:
+		if _, ok := n.importing[slot]; ok && cmd.Asking {
+			break
+		}
+		return nil, &RedirectError{Kind: Moved, Slot: slot, Node: owner}
+
+	default:
+		// Keys which are not here anymore have already been migrated.
+		if target, ok := n.migrating[slot]; ok && !n.store.KeyExists(cmd.Key) {
+			return nil, &RedirectError{Kind: Ask, Slot: slot, Node: target}
+		}
+	}
+
+	return execute(n.store, cmd)
+}
+
+// execute executes the command on the store.
+func execute(store *kiwi.Store, cmd *Command) (interface{}, error) {
+	switch cmd.Op {
+	case OpDo:
+		return store.Do(cmd.Key, cmd.Action, cmd.Params...)
+	case OpAddKey:
+		return nil, store.AddKey(cmd.Key, cmd.Type)
+	case OpUpdateKey:
+		return nil, store.UpdateKey(cmd.Key, cmd.Type)
+	case OpDeleteKey:
+		return nil, store.DeleteKey(cmd.Key)
+	case OpGetValueType:
+		return store.GetValueType(cmd.Key)
+	case OpToJSON:
+		return store.ToJSON(cmd.Key)
+	case OpFromJSON:
+		return nil, store.FromJSON(cmd.Key, cmd.Data)
+	default:
+		return nil, fmt.Errorf("unknown command op: %d", cmd.Op)
+	}
+}
+
+// KeysInSlot returns the keys stored on the node which belong to the slot.
+func (n *Node) KeysInSlot(slot uint16) []string {
+	var keys []string
+	for key := range n.store.GetSchema() {
+		if Slot(key) == slot {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// MigrateSlot moves the slot, along with all of its keys, from the source
+// node to the target node. The nodes keep serving commands while the keys
+// are being moved, redirecting the clients as required.
+//
+// The source node should own the slot and both the nodes should share the
+// same slot map.
+//
+// If a key cannot be moved, the slot is left migrating so that the keys moved
+// and those left are all still served. Once the cause is fixed, MigrateSlot can
+// be called again with the same nodes to resume the migration.
+func MigrateSlot(slot uint16, source, target *Node) error {
+	if slot >= Slots {
+		return newSlotErr(ErrInvalidSlot, int(slot))
+	}
+
+	if err := source.setMigrating(slot, target.id); err != nil {
+		return err
+	}
+	target.setImporting(slot, source.id)
+
+	for _, key := range source.KeysInSlot(slot) {
+		if err := source.migrateKey(key, target); err != nil {
+			return fmt.Errorf("could not migrate %q: %w", key, err)
+		}
+	}
+
+	source.clearMigration(slot, target.id)
+	target.clearMigration(slot, "")
+	return nil
+}
+
+// setMigrating marks the slot as being migrated to the target. A migration to
+// the same target is resumed.
+func (n *Node) setMigrating(slot uint16, target string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if owner := n.slots.Owner(slot); owner != n.id {
+		return fmt.Errorf("%w: %d owned by %q", ErrSlotNotOwned, slot, owner)
+	}
+
+	if migrating, ok := n.migrating[slot]; ok && migrating != target {
+		return newSlotErr(ErrSlotMigrating, int(slot))
+	}
+
+	n.migrating[slot] = target
+	return nil
+}
+
+// setImporting marks the slot as being imported from the source.
+func (n *Node) setImporting(slot uint16, source string) {
+	n.mu.Lock()
+	n.importing[slot] = source
+	n.mu.Unlock()
+}
+
+// clearMigration clears the migration state of the slot. If the owner is not
+// empty, the slot is assigned to it before any command can be served.
+func (n *Node) clearMigration(slot uint16, owner string) {
+	n.mu.Lock()
+
+	if owner != "" {
+		// The slot is valid so it cannot fail.
+		_ = n.slots.Assign(slot, slot, owner)
+	}
+
+	delete(n.migrating, slot)
+	delete(n.importing, slot)
+	n.mu.Unlock()
+}
+
+// migrateKey moves the key to the target node.
+//
+// No command is served by the node while the key is being moved.
+func (n *Node) migrateKey(key string, target *Node) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	typ, err := n.store.GetValueType(key)
+	if err != nil {
+		// Deleted in the meantime.
+		return nil
+	}
+
+	data, err := n.store.ToJSON(key)
+	if err != nil {
+		return err
+	}
+
+	// The target is not locked here so that two nodes migrating slots to
+	// each other do not deadlock.
+	if err := target.store.AddKey(key, typ); err != nil {
+		return err
+	}
+
+	if err := target.store.FromJSON(key, data); err != nil {
+		_ = target.store.DeleteKey(key)
+		return err
+	}
+
+	return n.store.DeleteKey(key)
+}
+
+// Interface guards.
+var (
+	_ Conn         = (*Node)(nil)
+	_ error        = (*RedirectError)(nil)
+	_ fmt.Stringer = RedirectKind(0)
+)
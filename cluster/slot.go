@@ -0,0 +1,151 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Slots is the number of hash slots the keys are split into.
+const Slots = 16384
+
+// ErrInvalidSlot is returned when the slot is out of range.
+var ErrInvalidSlot = fmt.Errorf("invalid slot")
+
+// newSlotErr creates an error with the related slot.
+func newSlotErr(err error, slot int) error {
+	return fmt.Errorf("%w: %d", err, slot)
+}
+
+// Slot returns the hash slot of the key.
+//
+// If the key contains a "{...}" hashtag which is not empty, only the hashtag
+// is hashed.
+func Slot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return crc16(key) % Slots
+}
+
+// crc16Table is the lookup table for CRC-16/XMODEM.
+var crc16Table = func() (table [256]uint16) {
+	const poly = 0x1021
+
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+
+	return table
+}()
+
+// crc16 returns the CRC-16/XMODEM checksum of the string.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+
+	return crc
+}
+
+// SlotRange is a range of slots (both included) assigned to a node.
+type SlotRange struct {
+	Start uint16
+	End   uint16
+	Node  string
+}
+
+// SlotMap maps the slots to the IDs of the nodes they are assigned to.
+//
+// It is safe to be used by multiple goroutines, so a single slot map can be
+// shared by all the nodes in the same process.
+type SlotMap struct {
+	owners [Slots]string
+	epoch  uint64
+	mu     sync.RWMutex
+}
+
+// NewSlotMap creates a slot map with no slots assigned.
+func NewSlotMap() *SlotMap {
+	return &SlotMap{}
+}
+
+// Assign assigns the slots from start to end (both included) to the node.
+// An empty node unassigns the slots.
+func (m *SlotMap) Assign(start, end uint16, node string) error {
+	if end >= Slots {
+		return newSlotErr(ErrInvalidSlot, int(end))
+	}
+	if start > end {
+		return fmt.Errorf("%w: range %d-%d", ErrInvalidSlot, start, end)
+	}
+
+	m.mu.Lock()
+	for slot := int(start); slot <= int(end); slot++ {
+		m.owners[slot] = node
+	}
+	m.epoch++
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Owner returns the node the slot is assigned to. It returns an empty string
+// if the slot is not assigned.
+func (m *SlotMap) Owner(slot uint16) string {
+	if slot >= Slots {
+		return ""
+	}
+
+	m.mu.RLock()
+	owner := m.owners[slot]
+	m.mu.RUnlock()
+	return owner
+}
+
+// Epoch returns the number of times the slot map has been changed.
+func (m *SlotMap) Epoch() uint64 {
+	m.mu.RLock()
+	epoch := m.epoch
+	m.mu.RUnlock()
+	return epoch
+}
+
+// Ranges returns the assigned slots as contiguous ranges in increasing order.
+func (m *SlotMap) Ranges() []SlotRange {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ranges []SlotRange
+	for slot := 0; slot < Slots; slot++ {
+		owner := m.owners[slot]
+		if owner == "" {
+			continue
+		}
+
+		if l := len(ranges) - 1; l >= 0 && ranges[l].Node == owner && int(ranges[l].End) == slot-1 {
+			ranges[l].End = uint16(slot)
+			continue
+		}
+
+		ranges = append(ranges, SlotRange{Start: uint16(slot), End: uint16(slot), Node: owner})
+	}
+
+	return ranges
+}
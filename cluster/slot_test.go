@@ -0,0 +1,93 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSlot(t *testing.T) {
+	if sum := crc16("123456789"); sum != 0x31c3 {
+		t.Errorf("expected crc16 check value 0x31c3; got %#x", sum)
+	}
+
+	// known slots
+	for key, slot := range map[string]uint16{
+		"foo": 12182,
+		"bar": 5061,
+	} {
+		if s := Slot(key); s != slot {
+			t.Errorf("expected slot of %q to be %d; got %d", key, slot, s)
+		}
+	}
+
+	// keys hashed using the hashtag
+	for key, tag := range map[string]string{
+		"{user1000}.following": "user1000",
+		"foo{bar}{zap}":        "bar",
+		"foo{{bar}}zap":        "{bar",
+		"{}foo":                "{}foo",
+		"foo{}{bar}":           "foo{}{bar}",
+		"foo{bar":              "foo{bar",
+	} {
+		if s, expected := Slot(key), crc16(tag)%Slots; s != expected {
+			t.Errorf("expected slot of %q to be the one of %q (%d); got %d", key, tag, expected, s)
+		}
+	}
+}
+
+func TestSlotMap(t *testing.T) {
+	m := NewSlotMap()
+
+	if ranges := m.Ranges(); len(ranges) != 0 {
+		t.Errorf("expected no ranges in new slot map; got %v", ranges)
+	}
+
+	if err := m.Assign(0, 99, "a"); err != nil {
+		t.Fatalf("could not Assign: %v", err)
+	}
+	if err := m.Assign(100, Slots-1, "b"); err != nil {
+		t.Fatalf("could not Assign: %v", err)
+	}
+	if err := m.Assign(50, 50, "b"); err != nil {
+		t.Fatalf("could not Assign: %v", err)
+	}
+
+	if err := m.Assign(10, Slots, "a"); !errors.Is(err, ErrInvalidSlot) {
+		t.Errorf("expected ErrInvalidSlot while assigning out of range; got %v", err)
+	}
+	if err := m.Assign(10, 5, "a"); !errors.Is(err, ErrInvalidSlot) {
+		t.Errorf("expected ErrInvalidSlot while assigning reversed range; got %v", err)
+	}
+
+	if epoch := m.Epoch(); epoch != 3 {
+		t.Errorf("expected epoch 3; got %d", epoch)
+	}
+
+	expected := []SlotRange{
+		{Start: 0, End: 49, Node: "a"},
+		{Start: 50, End: 50, Node: "b"},
+		{Start: 51, End: 99, Node: "a"},
+		{Start: 100, End: Slots - 1, Node: "b"},
+	}
+
+	ranges := m.Ranges()
+	if len(ranges) != len(expected) {
+		t.Fatalf("expected ranges %v; got %v", expected, ranges)
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("expected range %v; got %v", expected[i], ranges[i])
+		}
+	}
+
+	if owner := m.Owner(50); owner != "b" {
+		t.Errorf("expected owner of slot 50 to be %q; got %q", "b", owner)
+	}
+	if owner := m.Owner(Slots); owner != "" {
+		t.Errorf("expected no owner for slot out of range; got %q", owner)
+	}
+}
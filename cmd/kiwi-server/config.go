@@ -0,0 +1,296 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the server.
+type Config struct {
+	Memcached       MemcachedConfig   `json:"memcached"`
+	RESP            RESPConfig        `json:"resp"`
+	Watch           WatchConfig       `json:"watch"`
+	Persistence     PersistenceConfig `json:"persistence"`
+	ACLFile         string            `json:"acl_file"`
+	ShutdownTimeout Duration          `json:"shutdown_timeout"`
+}
+
+// MemcachedConfig configures the memcached front-end.
+type MemcachedConfig struct {
+	Listen         []string   `json:"listen"`
+	SocketPerm     string     `json:"socket_perm"`
+	TLS            *TLSConfig `json:"tls"`
+	MaxItemSize    int        `json:"max_item_size"`
+	MaxConnections int        `json:"max_connections"`
+}
+
+// RESPConfig configures the RESP front-end.
+type RESPConfig struct {
+	Listen         []string   `json:"listen"`
+	SocketPerm     string     `json:"socket_perm"`
+	TLS            *TLSConfig `json:"tls"`
+	MaxConnections int        `json:"max_connections"`
+}
+
+// WatchConfig configures the HTTP endpoint streaming the changes of the store.
+type WatchConfig struct {
+	Listen      []string   `json:"listen"`
+	SocketPerm  string     `json:"socket_perm"`
+	TLS         *TLSConfig `json:"tls"`
+	HistorySize int        `json:"history_size"`
+}
+
+// TLSConfig configures TLS for the listeners of a front-end. The clients are
+// required to present a certificate signed by one of the CAs in the client CA
+// file, if any.
+type TLSConfig struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	MinVersion   string `json:"min_version"`
+	ClientCAFile string `json:"client_ca_file"`
+}
+
+// PersistenceConfig configures the snapshots of the store.
+type PersistenceConfig struct {
+	Path     string   `json:"path"`
+	Interval Duration `json:"interval"`
+}
+
+// DefaultConfig returns the config used when no config file is given, which
+// serves memcached on port 11211 without persistence. Config files with an
+// ACL file do not get the memcached listen address by default.
+func DefaultConfig() *Config {
+	return &Config{
+		Memcached: MemcachedConfig{
+			Listen:      []string{"tcp://:11211"},
+			SocketPerm:  "0660",
+			MaxItemSize: 1 << 20,
+		},
+		RESP: RESPConfig{
+			SocketPerm: "0660",
+		},
+		Watch: WatchConfig{
+			SocketPerm:  "0660",
+			HistorySize: 1024,
+		},
+		ShutdownTimeout: Duration(30 * time.Second),
+	}
+}
+
+// LoadConfig reads the config file at the path. Fields not in the file keep
+// their default values.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(data)
+}
+
+// ParseConfig parses the config from JSON and validates it. Unknown fields are
+// an error so that typos do not go unnoticed.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := DefaultConfig()
+
+	// memcached clients cannot authenticate, so memcached only listens by
+	// default without an ACL file
+	memcachedListen := cfg.Memcached.Listen
+	cfg.Memcached.Listen = nil
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	if cfg.Memcached.Listen == nil && cfg.ACLFile == "" {
+		cfg.Memcached.Listen = memcachedListen
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the config, returning an error listing all the problems.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if len(c.Memcached.Listen) == 0 && len(c.RESP.Listen) == 0 && len(c.Watch.Listen) == 0 {
+		add("no listen addresses")
+	}
+
+	for _, addr := range c.Memcached.Listen {
+		if _, _, err := parseAddr(addr); err != nil {
+			add("memcached.listen: %v", err)
+		}
+	}
+	for _, addr := range c.RESP.Listen {
+		if _, _, err := parseAddr(addr); err != nil {
+			add("resp.listen: %v", err)
+		}
+	}
+	for _, addr := range c.Watch.Listen {
+		if _, _, err := parseAddr(addr); err != nil {
+			add("watch.listen: %v", err)
+		}
+	}
+
+	if _, err := parsePerm(c.Memcached.SocketPerm); err != nil {
+		add("memcached.socket_perm: %v", err)
+	}
+	if _, err := parsePerm(c.RESP.SocketPerm); err != nil {
+		add("resp.socket_perm: %v", err)
+	}
+	if _, err := parsePerm(c.Watch.SocketPerm); err != nil {
+		add("watch.socket_perm: %v", err)
+	}
+
+	c.Memcached.TLS.validate("memcached.tls", add)
+	c.RESP.TLS.validate("resp.tls", add)
+	c.Watch.TLS.validate("watch.tls", add)
+
+	if c.Memcached.MaxItemSize <= 0 {
+		add("memcached.max_item_size: should be positive")
+	}
+	if c.Memcached.MaxConnections < 0 {
+		add("memcached.max_connections: should not be negative")
+	}
+	if c.RESP.MaxConnections < 0 {
+		add("resp.max_connections: should not be negative")
+	}
+	if c.Watch.HistorySize < 0 {
+		add("watch.history_size: should not be negative")
+	}
+
+	if c.Persistence.Interval < 0 {
+		add("persistence.interval: should not be negative")
+	}
+	if c.Persistence.Interval > 0 && c.Persistence.Path == "" {
+		add("persistence.interval: set without persistence.path")
+	}
+	// the ACL would be bypassed by the front-ends without authentication
+	if c.ACLFile != "" && len(c.Memcached.Listen) > 0 {
+		add("acl_file: memcached clients cannot authenticate, so memcached.listen should be empty")
+	}
+	if c.ACLFile != "" && len(c.Watch.Listen) > 0 {
+		add("acl_file: watch clients cannot authenticate, so watch.listen should be empty")
+	}
+
+	if c.ShutdownTimeout < 0 {
+		add("shutdown_timeout: should not be negative")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// validate adds the problems of the TLS config, if any, to the problems of the
+// config.
+func (c *TLSConfig) validate(field string, add func(format string, a ...interface{})) {
+	if c == nil {
+		return
+	}
+
+	if c.CertFile == "" || c.KeyFile == "" {
+		add("%s: cert_file and key_file are required", field)
+	}
+	if _, err := parseTLSVersion(c.MinVersion); err != nil {
+		add("%s.min_version: %v", field, err)
+	}
+}
+
+// parseAddr splits the listen address into the network and the address.
+func parseAddr(addr string) (network, address string, err error) {
+	i := strings.Index(addr, "://")
+	if i < 0 {
+		return "", "", fmt.Errorf("%q should be tcp://host:port or unix://path", addr)
+	}
+
+	network, address = addr[:i], addr[i+len("://"):]
+	switch network {
+	case "tcp", "unix":
+	default:
+		return "", "", fmt.Errorf("%q has unknown network %q", addr, network)
+	}
+
+	if address == "" {
+		return "", "", fmt.Errorf("%q has no address", addr)
+	}
+
+	return network, address, nil
+}
+
+// parsePerm parses the octal permissions of a Unix socket.
+func parsePerm(perm string) (os.FileMode, error) {
+	p, err := strconv.ParseUint(perm, 8, 32)
+	if err != nil || p > 0777 {
+		return 0, fmt.Errorf("%q is not an octal permission", perm)
+	}
+
+	return os.FileMode(p), nil
+}
+
+// parseTLSVersion parses the TLS version, like "1.2". It defaults to TLS 1.2
+// if empty.
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("%q should be one of 1.0, 1.1, 1.2 and 1.3", version)
+	}
+}
+
+// Duration is a time.Duration read from JSON as a string like "30s".
+type Duration time.Duration
+
+// Duration returns the time.Duration.
+func (d Duration) Duration() time.Duration { return time.Duration(d) }
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration should be a string like \"30s\"")
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
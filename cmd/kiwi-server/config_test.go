@@ -0,0 +1,82 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{
+		"memcached": {"listen": ["unix:///tmp/kiwi.sock"], "max_connections": 10},
+		"persistence": {"path": "kiwi.json", "interval": "1m"}
+	}`))
+	if err != nil {
+		t.Fatalf("could not parse config: %v", err)
+	}
+
+	if cfg.Memcached.MaxConnections != 10 {
+		t.Errorf("expected 10 max connections; got %d", cfg.Memcached.MaxConnections)
+	}
+	if cfg.Persistence.Interval.Duration() != time.Minute {
+		t.Errorf("expected interval of 1m; got %v", cfg.Persistence.Interval.Duration())
+	}
+	if cfg.Memcached.MaxItemSize != 1<<20 || cfg.Memcached.SocketPerm != "0660" {
+		t.Errorf("expected defaults for missing fields; got %+v", cfg.Memcached)
+	}
+
+	_, err = ParseConfig([]byte(`{
+		"memcached": {"listen": ["localhost:11211", "udp://:1"], "socket_perm": "rw", "max_item_size": 0},
+		"resp": {"listen": ["tcp//:6379"], "max_connections": -1, "tls": {"cert_file": "a.crt", "min_version": "1.4"}},
+		"persistence": {"interval": "1m"},
+		"acl_file": "users.acl",
+		"shutdown_timeout": "-1s"
+	}`))
+	if err == nil {
+		t.Fatalf("expected error for invalid config")
+	}
+
+	for _, problem := range []string{
+		`memcached.listen: "localhost:11211" should be tcp://host:port or unix://path`,
+		`memcached.listen: "udp://:1" has unknown network "udp"`,
+		`memcached.socket_perm: "rw" is not an octal permission`,
+		"memcached.max_item_size: should be positive",
+		`resp.listen: "tcp//:6379" should be tcp://host:port or unix://path`,
+		"resp.max_connections: should not be negative",
+		"resp.tls: cert_file and key_file are required",
+		`resp.tls.min_version: "1.4" should be one of 1.0, 1.1, 1.2 and 1.3`,
+		"acl_file: memcached clients cannot authenticate, so memcached.listen should be empty",
+		"persistence.interval: set without persistence.path",
+		"shutdown_timeout: should not be negative",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected error to contain %q; got %v", problem, err)
+		}
+	}
+
+	// memcached listens by default only without an ACL file
+	if cfg, err := ParseConfig([]byte(`{}`)); err != nil || len(cfg.Memcached.Listen) != 1 {
+		t.Errorf("expected memcached to listen by default; got %v (%v)", cfg, err)
+	}
+	cfg, err = ParseConfig([]byte(`{"resp": {"listen": ["tcp://:6379"]}, "acl_file": "users.acl"}`))
+	if err != nil {
+		t.Fatalf("could not parse config with ACL file: %v", err)
+	}
+	if len(cfg.Memcached.Listen) != 0 {
+		t.Errorf("expected memcached not to listen with ACL file; got %v", cfg.Memcached.Listen)
+	}
+	if _, err := ParseConfig([]byte(`{"acl_file": "users.acl"}`)); err == nil || !strings.Contains(err.Error(), "no listen addresses") {
+		t.Errorf("expected no listen addresses with only an ACL file; got %v", err)
+	}
+
+	if _, err := ParseConfig([]byte(`{"memcached": {"listn": []}}`)); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+	if _, err := ParseConfig([]byte(`{"shutdown_timeout": 30}`)); err == nil {
+		t.Errorf("expected error for duration without unit")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Command kiwi-server serves a kiwi store over the network, using the
+// memcached text protocol and the Redis protocol, and streaming its changes
+// over HTTP.
+//
+// The server is configured with a JSON file passed with the -config flag:
+//
+//	{
+//	  "memcached": {
+//	    "listen": ["tcp://:11211", "unix:///run/kiwi/kiwi.sock"],
+//	    "socket_perm": "0660",
+//	    "max_item_size": 1048576,
+//	    "max_connections": 1024
+//	  },
+//	  "resp": {
+//	    "listen": ["tcp://:6379"],
+//	    "tls": {
+//	      "cert_file": "/etc/kiwi/server.crt",
+//	      "key_file": "/etc/kiwi/server.key",
+//	      "min_version": "1.2",
+//	      "client_ca_file": "/etc/kiwi/clients.crt"
+//	    },
+//	    "max_connections": 1024
+//	  },
+//	  "watch": {
+//	    "listen": ["tcp://127.0.0.1:8080"],
+//	    "history_size": 1024
+//	  },
+//	  "persistence": {
+//	    "path": "/var/lib/kiwi/snapshot.json",
+//	    "interval": "5m"
+//	  },
+//	  "acl_file": "/etc/kiwi/users.acl",
+//	  "shutdown_timeout": "30s"
+//	}
+//
+// Listen addresses are either "tcp://host:port" or "unix://path". Unix sockets
+// are created with the permissions of socket_perm, replacing a stale socket
+// left behind at the path.
+//
+// Every front-end serves TLS on all its listeners if it has a tls config, with
+// the certificate and key in the PEM files and TLS 1.2 as the minimum version
+// by default. With a client CA file, the clients have to present a certificate
+// signed by one of its CAs. Such a certificate authenticates a Redis protocol
+// connection as the ACL user named by its common name.
+//
+// The Redis protocol front-end is described in the resp package. Its
+// connections authenticate as the users of the ACL file, whose syntax is
+// described in the acl package, and support pub/sub, MONITOR and CLIENT
+// TRACKING. Without an ACL file, the default user has all the permissions. The
+// memcached and watch front-ends cannot authenticate, so they cannot be served
+// along with an ACL file. Without a config file, or if the config file does not
+// set memcached.listen, memcached listens on "tcp://:11211", unless there is an
+// ACL file.
+//
+// The store is loaded from the snapshot at the persistence path on startup,
+// and a snapshot is written every interval if it is not 0.
+//
+// On SIGTERM or SIGINT, the server stops accepting connections, waits for the
+// commands being executed to finish, for at most shutdown_timeout, and writes
+// a final snapshot of the store.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	path := flag.String("config", "", "path of the config file")
+	flag.Parse()
+
+	if err := run(*path); err != nil {
+		fmt.Fprintf(os.Stderr, "kiwi-server: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the server with the config file until it is signalled to stop.
+func run(path string) error {
+	cfg := DefaultConfig()
+	if path != "" {
+		var err error
+		if cfg, err = LoadConfig(path); err != nil {
+			return err
+		}
+	}
+
+	srv, err := newServer(cfg)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(stop)
+
+	errs, err := srv.start()
+	if err != nil {
+		return err
+	}
+
+	var serveErr error
+	select {
+	case <-stop:
+	case serveErr = <-errs:
+	}
+
+	ctx := context.Background()
+	if cfg.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ShutdownTimeout.Duration())
+		defer cancel()
+	}
+
+	if err := srv.shutdown(ctx); err != nil {
+		return err
+	}
+
+	return serveErr
+}
@@ -0,0 +1,426 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/acl"
+	"github.com/sdslabs/kiwi/memcached"
+	"github.com/sdslabs/kiwi/resp"
+	"github.com/sdslabs/kiwi/tracking"
+	"github.com/sdslabs/kiwi/watch"
+
+	// the snapshot can have values of any type
+	_ "github.com/sdslabs/kiwi/values/bitmap"
+	_ "github.com/sdslabs/kiwi/values/bloom"
+	_ "github.com/sdslabs/kiwi/values/bytes"
+	_ "github.com/sdslabs/kiwi/values/cms"
+	_ "github.com/sdslabs/kiwi/values/counter"
+	_ "github.com/sdslabs/kiwi/values/decimal"
+	_ "github.com/sdslabs/kiwi/values/float"
+	_ "github.com/sdslabs/kiwi/values/geo"
+	_ "github.com/sdslabs/kiwi/values/hash"
+	_ "github.com/sdslabs/kiwi/values/hll"
+	_ "github.com/sdslabs/kiwi/values/jsondoc"
+	_ "github.com/sdslabs/kiwi/values/lease"
+	_ "github.com/sdslabs/kiwi/values/list"
+	_ "github.com/sdslabs/kiwi/values/ratelimit"
+	_ "github.com/sdslabs/kiwi/values/set"
+	_ "github.com/sdslabs/kiwi/values/str"
+	_ "github.com/sdslabs/kiwi/values/stream"
+	_ "github.com/sdslabs/kiwi/values/timeseries"
+	_ "github.com/sdslabs/kiwi/values/topk"
+	_ "github.com/sdslabs/kiwi/values/trie"
+	_ "github.com/sdslabs/kiwi/values/zhash"
+	_ "github.com/sdslabs/kiwi/values/zset"
+)
+
+// server serves a store with the front-ends in the config.
+type server struct {
+	cfg   *Config
+	store *kiwi.Store
+
+	memcached *memcached.Server
+	resp      *resp.Server
+	tracker   *tracking.Tracker
+	hub       *watch.Hub
+	http      *http.Server
+
+	// stopSnapshots stops the periodic snapshots, which are done when
+	// snapshotsDone is closed.
+	stopSnapshots chan struct{}
+	snapshotsDone chan struct{}
+
+	// snapshotMu serializes writing the snapshots.
+	snapshotMu sync.Mutex
+}
+
+// newServer creates the server for the config, loading the store from the
+// snapshot if there is one.
+func newServer(cfg *Config) (*server, error) {
+	users := acl.New()
+	if cfg.ACLFile != "" {
+		var err error
+		if users, err = acl.LoadFile(cfg.ACLFile); err != nil {
+			return nil, fmt.Errorf("could not load ACL file %q: %v", cfg.ACLFile, err)
+		}
+	}
+
+	store := kiwi.NewStore()
+
+	if path := cfg.Persistence.Path; path != "" {
+		data, err := ioutil.ReadFile(path)
+		switch {
+		case err == nil:
+			if ierr := store.Import(data, kiwi.ImportOpts{AddKeys: true}); ierr != nil {
+				return nil, fmt.Errorf("could not load snapshot %q: %v", path, ierr)
+			}
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("could not read snapshot: %v", err)
+		}
+	}
+
+	mc := memcached.NewServer(store)
+	mc.MaxItemSize = cfg.Memcached.MaxItemSize
+	mc.MaxConns = cfg.Memcached.MaxConnections
+
+	tracker := tracking.NewTracker(store)
+
+	rs := resp.NewServer(store)
+	rs.ACL = users
+	rs.Tracker = tracker
+	rs.MaxConns = cfg.RESP.MaxConnections
+
+	hub := watch.NewHub(store, cfg.Watch.HistorySize)
+
+	return &server{
+		cfg:           cfg,
+		store:         store,
+		memcached:     mc,
+		resp:          rs,
+		tracker:       tracker,
+		hub:           hub,
+		http:          &http.Server{Handler: hub},
+		stopSnapshots: make(chan struct{}),
+		snapshotsDone: make(chan struct{}),
+		snapshotMu:    sync.Mutex{},
+	}, nil
+}
+
+// start listens on the addresses of the config and serves them in the
+// background. The returned channel receives the errors which stop serving a
+// listener.
+func (s *server) start() (<-chan error, error) {
+	mcListeners, err := listenAll(s.cfg.Memcached.Listen, s.cfg.Memcached.SocketPerm, s.cfg.Memcached.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	respListeners, err := listenAll(s.cfg.RESP.Listen, s.cfg.RESP.SocketPerm, s.cfg.RESP.TLS)
+	if err != nil {
+		closeAll(mcListeners)
+		return nil, err
+	}
+
+	watchListeners, err := listenAll(s.cfg.Watch.Listen, s.cfg.Watch.SocketPerm, s.cfg.Watch.TLS)
+	if err != nil {
+		closeAll(mcListeners)
+		closeAll(respListeners)
+		return nil, err
+	}
+
+	errs := make(chan error, len(mcListeners)+len(respListeners)+len(watchListeners))
+
+	for _, l := range mcListeners {
+		go func(l net.Listener) {
+			if err := s.memcached.Serve(l); err != memcached.ErrServerClosed {
+				errs <- fmt.Errorf("memcached %s: %v", l.Addr(), err)
+			}
+		}(l)
+	}
+
+	for _, l := range respListeners {
+		go func(l net.Listener) {
+			if err := s.resp.Serve(l); err != resp.ErrServerClosed {
+				errs <- fmt.Errorf("resp %s: %v", l.Addr(), err)
+			}
+		}(l)
+	}
+
+	for _, l := range watchListeners {
+		go func(l net.Listener) {
+			if err := s.http.Serve(l); err != http.ErrServerClosed {
+				errs <- fmt.Errorf("watch %s: %v", l.Addr(), err)
+			}
+		}(l)
+	}
+
+	go s.snapshotEvery(s.cfg.Persistence.Interval.Duration())
+
+	return errs, nil
+}
+
+// shutdown stops accepting connections, waits for the commands being executed
+// to finish until the context is done and writes the final snapshot.
+//
+// The snapshot is written even if the context is done before the commands
+// finish, in which case the connections are closed forcibly.
+func (s *server) shutdown(ctx context.Context) error {
+	close(s.stopSnapshots)
+	<-s.snapshotsDone
+
+	// the subscriptions are closed first so that the streams end
+	s.hub.Close()
+	httpErr := s.http.Shutdown(ctx)
+	if httpErr != nil {
+		_ = s.http.Close()
+	}
+
+	mcErr := s.memcached.Shutdown(ctx)
+	respErr := s.resp.Shutdown(ctx)
+	s.tracker.Close()
+
+	if err := s.snapshot(); err != nil {
+		return err
+	}
+
+	if mcErr != nil {
+		return fmt.Errorf("memcached connections not drained: %v", mcErr)
+	}
+	if respErr != nil {
+		return fmt.Errorf("resp connections not drained: %v", respErr)
+	}
+	if httpErr != nil {
+		return fmt.Errorf("watch connections not drained: %v", httpErr)
+	}
+
+	return nil
+}
+
+// snapshotEvery writes a snapshot every interval until the snapshots are
+// stopped. It returns immediately if the interval is 0.
+func (s *server) snapshotEvery(interval time.Duration) {
+	defer close(s.snapshotsDone)
+
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopSnapshots:
+			return
+		case <-ticker.C:
+			if err := s.snapshot(); err != nil {
+				fmt.Fprintf(os.Stderr, "kiwi-server: %v\n", err)
+			}
+		}
+	}
+}
+
+// snapshot writes the store to the persistence path, if any. The snapshot is
+// written to a temporary file first so that a crash never leaves a partial
+// snapshot behind.
+func (s *server) snapshot() error {
+	path := s.cfg.Persistence.Path
+	if path == "" {
+		return nil
+	}
+
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	data, err := s.store.Export()
+	if err != nil {
+		return fmt.Errorf("could not export snapshot: %v", err)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not write snapshot: %v", err)
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("could not write snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// listenAll listens on all the addresses, creating Unix sockets with the
+// permissions and serving TLS with the config, if any. Nothing is left
+// listening if it fails.
+func listenAll(addrs []string, perm string, tlsCfg *TLSConfig) ([]net.Listener, error) {
+	mode, err := parsePerm(perm)
+	if err != nil {
+		return nil, err
+	}
+
+	var config *tls.Config
+	if tlsCfg != nil {
+		if config, err = loadTLS(tlsCfg); err != nil {
+			return nil, err
+		}
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := listen(addr, mode)
+		if err != nil {
+			closeAll(listeners)
+			return nil, err
+		}
+
+		if config != nil {
+			l = tls.NewListener(l, config)
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// listen listens on the address, either "tcp://host:port" or "unix://path".
+//
+// A stale Unix socket at the path, which no one is listening on, is removed
+// first. Any other file is left alone and results in an error.
+func listen(addr string, mode os.FileMode) (net.Listener, error) {
+	network, address, err := parseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+
+	if fi, err := os.Lstat(address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial(network, address); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("socket %q is in use", address)
+		}
+		if err := os.Remove(address); err != nil {
+			return nil, fmt.Errorf("could not remove stale socket: %v", err)
+		}
+	}
+
+	return listenUnix(address, mode)
+}
+
+// listenUnix listens on a Unix socket at the path with the mode.
+//
+// The socket is created with the default umask, so it is created inside a
+// private directory and only renamed into place once it has the mode. This
+// way no one can connect to it before its permissions are set.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".kiwi-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create socket directory: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	tmp := filepath.Join(dir, "sock")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	// the socket is removed from its final path instead
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmp, mode); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("could not set socket permissions: %v", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = l.Close()
+		return nil, fmt.Errorf("could not move socket into place: %v", err)
+	}
+
+	return &unixListener{Listener: l, path: path}, nil
+}
+
+// unixListener removes its socket when closed, since it has been renamed.
+type unixListener struct {
+	net.Listener
+	path      string
+	closeOnce sync.Once
+}
+
+// Close closes the listener and removes its socket.
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { _ = os.Remove(l.path) })
+	return err
+}
+
+// loadTLS creates the config to serve TLS with, which requires and verifies
+// the client certificates if there is a client CA file.
+func loadTLS(c *TLSConfig) (*tls.Config, error) {
+	minVersion, err := parseTLSVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load TLS certificate: %v", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read client CA file: %v", err)
+		}
+
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in client CA file %q", c.ClientCAFile)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// closeAll closes all the listeners.
+func closeAll(listeners []net.Listener) {
+	for _, l := range listeners {
+		_ = l.Close()
+	}
+}
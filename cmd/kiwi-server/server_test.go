@@ -0,0 +1,170 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kiwi-server")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	sock := filepath.Join(dir, "kiwi.sock")
+	snapshot := filepath.Join(dir, "kiwi.json")
+
+	// a stale socket is replaced
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	cfg := DefaultConfig()
+	cfg.Memcached.Listen = []string{"unix://" + sock}
+	cfg.Memcached.SocketPerm = "0600"
+	cfg.Persistence.Path = snapshot
+
+	srv, err := newServer(cfg)
+	if err != nil {
+		t.Fatalf("could not create server: %v", err)
+	}
+	if _, err := srv.start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatalf("could not stat socket: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected socket permissions 0600; got %o", perm)
+	}
+
+	// the socket is created in a private directory which is removed
+	if matches, err := filepath.Glob(filepath.Join(dir, ".kiwi-*")); err != nil || len(matches) > 0 {
+		t.Errorf("expected socket directory to be removed; got %v (%v)", matches, err)
+	}
+
+	// the socket is in use now
+	if _, err := listen("unix://"+sock, 0600); err == nil {
+		t.Errorf("expected error listening on socket in use")
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if _, err := conn.Write([]byte("set a 0 0 5\r\nhello\r\n")); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "STORED\r\n" {
+		t.Fatalf("expected STORED; got %q (%v)", line, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.shutdown(ctx); err != nil {
+		t.Fatalf("could not shutdown: %v", err)
+	}
+
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("expected socket to be removed; got %v", err)
+	}
+
+	// the next server starts from the final snapshot
+	cfg.Memcached.Listen = nil
+	cfg.Watch.Listen = []string{"tcp://127.0.0.1:0"}
+
+	srv, err = newServer(cfg)
+	if err != nil {
+		t.Fatalf("could not create server from snapshot: %v", err)
+	}
+
+	if v, err := srv.store.Do("a", str.Get); err != nil || v != "hello" {
+		t.Errorf("expected %q from snapshot; got %v (%v)", "hello", v, err)
+	}
+}
+
+func TestServer_RESP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kiwi-server")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	sock := filepath.Join(dir, "resp.sock")
+	aclFile := filepath.Join(dir, "users.acl")
+
+	users := "user default off\nuser app on >secret (~app:* +* %str)\n"
+	if err := ioutil.WriteFile(aclFile, []byte(users), 0600); err != nil {
+		t.Fatalf("could not write ACL file: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Memcached.Listen = nil
+	cfg.RESP.Listen = []string{"unix://" + sock}
+	cfg.ACLFile = aclFile
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	srv, err := newServer(cfg)
+	if err != nil {
+		t.Fatalf("could not create server: %v", err)
+	}
+	if _, err := srv.start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	r := bufio.NewReader(conn)
+	for _, cmd := range []struct{ send, reply string }{
+		{"SET app:a hello", "-NOPERM "},
+		{"AUTH app secret", "+OK\r\n"},
+		{"SET app:a hello", "+OK\r\n"},
+		{"SET other hello", "-NOPERM "},
+	} {
+		if _, err := conn.Write([]byte(cmd.send + "\r\n")); err != nil {
+			t.Fatalf("could not write: %v", err)
+		}
+		if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, cmd.reply) {
+			t.Errorf("expected %q for %q; got %q (%v)", cmd.reply, cmd.send, line, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.shutdown(ctx); err != nil {
+		t.Fatalf("could not shutdown: %v", err)
+	}
+
+	if v, err := srv.store.Do("app:a", str.Get); err != nil || v != "hello" {
+		t.Errorf("expected %q to be set; got %v (%v)", "hello", v, err)
+	}
+}
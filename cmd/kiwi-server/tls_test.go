@@ -0,0 +1,239 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi/resp"
+)
+
+// testCA issues the certificates for the tests.
+type testCA struct {
+	t    *testing.T
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCA creates a CA writing its certificate to ca.crt in the directory.
+func newTestCA(t *testing.T, dir string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kiwi test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create CA certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse CA certificate: %v", err)
+	}
+
+	ca := &testCA{t: t, dir: dir, cert: cert, key: key}
+	ca.write("ca.crt", "CERTIFICATE", der)
+	return ca
+}
+
+// issue issues a certificate for the common name, writing it and its key to
+// name.crt and name.key in the directory.
+func (ca *testCA) issue(name, commonName string, usage x509.ExtKeyUsage) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatalf("could not generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		ca.t.Fatalf("could not create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		ca.t.Fatalf("could not marshal key: %v", err)
+	}
+
+	ca.write(name+".crt", "CERTIFICATE", der)
+	ca.write(name+".key", "EC PRIVATE KEY", keyDER)
+}
+
+// write writes the PEM block to the file in the directory.
+func (ca *testCA) write(file, typ string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(ca.dir, file), data, 0600); err != nil {
+		ca.t.Fatalf("could not write %s: %v", file, err)
+	}
+}
+
+// path returns the path of the file in the directory.
+func (ca *testCA) path(file string) string {
+	return filepath.Join(ca.dir, file)
+}
+
+func TestServer_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kiwi-server")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	ca := newTestCA(t, dir)
+	ca.issue("server", "localhost", x509.ExtKeyUsageServerAuth)
+	ca.issue("app", "app", x509.ExtKeyUsageClientAuth)
+	ca.issue("ghost", "ghost", x509.ExtKeyUsageClientAuth)
+
+	// the user of the certificate does not need a password
+	users := "user default off\nuser app on (~app:* +* %str)\n"
+	if err := ioutil.WriteFile(ca.path("users.acl"), []byte(users), 0600); err != nil {
+		t.Fatalf("could not write ACL file: %v", err)
+	}
+
+	sock := ca.path("resp.sock")
+
+	cfg := DefaultConfig()
+	cfg.Memcached.Listen = nil
+	cfg.RESP.Listen = []string{"unix://" + sock}
+	cfg.RESP.TLS = &TLSConfig{
+		CertFile:     ca.path("server.crt"),
+		KeyFile:      ca.path("server.key"),
+		MinVersion:   "1.3",
+		ClientCAFile: ca.path("ca.crt"),
+	}
+	cfg.ACLFile = ca.path("users.acl")
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+
+	srv, err := newServer(cfg)
+	if err != nil {
+		t.Fatalf("could not create server: %v", err)
+	}
+	if _, err := srv.start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.shutdown(ctx); err != nil {
+			t.Errorf("could not shutdown: %v", err)
+		}
+	}()
+
+	dial := func(cert string, maxVersion uint16) (*resp.Client, error) {
+		t.Helper()
+
+		var config *tls.Config
+		if cert != "" {
+			config, err = resp.LoadClientTLS(ca.path("ca.crt"), ca.path(cert+".crt"), ca.path(cert+".key"))
+		} else {
+			config, err = resp.LoadClientTLS(ca.path("ca.crt"), "", "")
+		}
+		if err != nil {
+			t.Fatalf("could not load client TLS config: %v", err)
+		}
+		config.ServerName = "localhost"
+		config.MaxVersion = maxVersion
+
+		return resp.Dial("unix", sock, resp.ClientOpts{TLS: config, DialTimeout: 5 * time.Second})
+	}
+
+	// the client certificate authenticates the user
+	c, err := dial("app", 0)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer c.Close() //nolint:errcheck
+
+	if user, err := c.WhoAmI(); err != nil || user != "app" {
+		t.Errorf("expected to be authenticated as %q; got %q (%v)", "app", user, err)
+	}
+	if err := c.Set("app:a", "hello"); err != nil {
+		t.Errorf("could not Set: %v", err)
+	}
+
+	var serr resp.ServerError
+	if err := c.Set("other", "hello"); !errors.As(err, &serr) || serr.Code() != "NOPERM" {
+		t.Errorf("expected NOPERM for key of other users; got %v", err)
+	}
+
+	// a certificate of an unknown user is refused after the handshake
+	ghost, err := dial("ghost", 0)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	if _, err := ghost.WhoAmI(); !errors.As(err, &serr) || serr.Code() != "WRONGPASS" {
+		t.Errorf("expected WRONGPASS for unknown user; got %v", err)
+	}
+	_ = ghost.Close()
+
+	// the client certificate is required
+	if c, err := dial("", 0); err == nil {
+		_, err = c.WhoAmI()
+		_ = c.Close()
+		if err == nil {
+			t.Errorf("expected error without client certificate")
+		}
+	}
+
+	// the minimum version is enforced
+	if c, err := dial("app", tls.VersionTLS12); err == nil {
+		_ = c.Close()
+		t.Errorf("expected error for TLS 1.2 below the minimum version")
+	}
+
+	// plain connections are refused
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if reply, _ := ioutil.ReadAll(conn); len(reply) > 0 && string(reply[:1]) == "+" {
+		t.Errorf("expected plain connection to be refused; got %q", reply)
+	}
+}
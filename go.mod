@@ -5,5 +5,8 @@ go 1.14
 require (
 	github.com/stretchr/testify v1.6.1 // indirect
 	github.com/tidwall/buntdb v1.1.4
+	github.com/tidwall/gjson v1.6.1
+	github.com/tidwall/match v1.0.1
+	github.com/tidwall/rtree v0.0.0-20201027154624-32188eeb08a8
 	github.com/wangjia184/sortedset v0.0.0-20200422044937-080872f546ba
 )
@@ -0,0 +1,134 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package kiwi
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EventOp is the operation on the store an event is emitted for.
+type EventOp string
+
+// Operations for which events are emitted.
+const (
+	// EventAddKey is emitted when a key is added.
+	EventAddKey EventOp = "addkey"
+
+	// EventUpdateKey is emitted when the value type of a key is updated.
+	EventUpdateKey EventOp = "updatekey"
+
+	// EventDeleteKey is emitted when a key is deleted.
+	EventDeleteKey EventOp = "deletekey"
+
+	// EventDo is emitted when an action is executed for an existing key,
+	// with the error if it fails. The action can be one which does not
+	// modify the value.
+	EventDo EventOp = "do"
+
+	// EventFromJSON is emitted when a value is loaded from JSON, including
+	// while importing the store.
+	EventFromJSON EventOp = "fromjson"
+)
+
+// Event describes an operation on the store.
+type Event struct {
+	// Op is the operation.
+	Op EventOp
+
+	// Key is the key operated on.
+	Key string
+
+	// Type is the type of the value of the key after the operation, or before
+	// it if the key is deleted.
+	Type ValueType
+
+	// Action and Params are the action executed and its parameters, set only
+	// for EventDo. Params should not be modified.
+	Action Action
+	Params []interface{}
+
+	// ReadOnly is set for EventDo if the value tells that the action does not
+	// modify it.
+	ReadOnly bool
+
+	// Err is the error of the action if it failed, set only for EventDo.
+	// Hooks interested in the changes of the values should skip such events.
+	Err error
+
+	// Caller is the caller passed to DoAs, AddKeyAs or DeleteKeyAs, if any.
+	Caller string
+}
+
+// Hook is a function called with the events of a store.
+type Hook func(Event)
+
+// hook wraps a Hook so that it can be compared while removing it.
+type hook struct {
+	fn Hook
+}
+
+// hookList is the copy-on-write list of hooks of a store.
+type hookList struct {
+	hooks atomic.Value // []*hook
+	mu    sync.Mutex
+}
+
+// AddHook adds the hook which is called with every operation on the store.
+// It returns a function which removes the hook.
+//
+// Hooks are called synchronously after the operation is complete and the store
+// is unlocked, so a hook can access the store but it should return quickly.
+// When operations happen concurrently, a hook can be called concurrently and
+// the events can be out of order.
+func (s *Store) AddHook(fn Hook) (remove func()) {
+	h := &hook{fn: fn}
+
+	s.hooks.mu.Lock()
+	old, _ := s.hooks.hooks.Load().([]*hook)
+	hooks := make([]*hook, len(old), len(old)+1)
+	copy(hooks, old)
+	s.hooks.hooks.Store(append(hooks, h))
+	s.hooks.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.removeHook(h) })
+	}
+}
+
+// removeHook removes the hook from the store.
+func (s *Store) removeHook(h *hook) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+
+	old, _ := s.hooks.hooks.Load().([]*hook)
+	hooks := make([]*hook, 0, len(old))
+	for _, o := range old {
+		if o != h {
+			hooks = append(hooks, o)
+		}
+	}
+
+	s.hooks.hooks.Store(hooks)
+}
+
+// listening tells if there are any hooks or callers of Block to emit events
+// to, so that the events can be skipped otherwise.
+func (s *Store) listening() bool {
+	hooks, _ := s.hooks.hooks.Load().([]*hook)
+	return len(hooks) > 0 || atomic.LoadInt64(&s.blocked.waiting) > 0
+}
+
+// emit calls the hooks with the event and wakes the callers of Block waiting
+// for it.
+func (s *Store) emit(e Event) {
+	hooks, _ := s.hooks.hooks.Load().([]*hook)
+	for _, h := range hooks {
+		h.fn(e)
+	}
+
+	s.blocked.notify(e)
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package memcached
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Errors while executing the commands.
+var (
+	errTooLarge   = fmt.Errorf("object too large for cache")
+	errNonNumeric = fmt.Errorf("cannot increment or decrement non-numeric value")
+)
+
+// Replies of the commands.
+const (
+	replyStored    = "STORED"
+	replyNotStored = "NOT_STORED"
+	replyExists    = "EXISTS"
+	replyNotFound  = "NOT_FOUND"
+	replyDeleted   = "DELETED"
+	replyTouched   = "TOUCHED"
+	replyOK        = "OK"
+)
+
+// storeItem executes one of the storage commands, i.e., set, add, replace,
+// append, prepend and cas, and returns the reply.
+func (s *Server) storeItem(
+	cmd, key, value string,
+	flags uint32,
+	exptime int64,
+	casUnique uint64,
+) (string, error) {
+	s.lock()
+	defer s.mu.Unlock()
+
+	s.stats.cmdSet++
+
+	old, it, err := s.get(key)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return "", err
+	}
+	found := err == nil
+
+	exp := s.expiry(exptime)
+
+	switch cmd {
+	case "add":
+		if found {
+			return replyNotStored, nil
+		}
+
+	case "replace":
+		if !found {
+			return replyNotStored, nil
+		}
+
+	case "append", "prepend":
+		if !found {
+			return replyNotStored, nil
+		}
+
+		if cmd == "append" {
+			value = old + value
+		} else {
+			value += old
+		}
+
+		// append and prepend ignore the flags and exptime
+		flags, exp = it.flags, it.exp
+
+	case "cas":
+		if !found {
+			s.stats.casMisses++
+			return replyNotFound, nil
+		}
+		if it.cas != casUnique {
+			s.stats.casBadval++
+			return replyExists, nil
+		}
+		s.stats.casHits++
+	}
+
+	if len(value) > s.MaxItemSize {
+		return "", errTooLarge
+	}
+
+	if err := s.set(key, value, flags, exp); err != nil {
+		return "", err
+	}
+
+	return replyStored, nil
+}
+
+// deleteItem deletes the item and returns the reply.
+func (s *Server) deleteItem(key string) (string, error) {
+	s.lock()
+	defer s.mu.Unlock()
+
+	_, _, err := s.get(key)
+	if err == nil {
+		err = s.del(key)
+	}
+
+	switch {
+	case err == nil:
+		s.stats.deleteHits++
+		return replyDeleted, nil
+	case errors.Is(err, errNotFound):
+		s.stats.deleteMisses++
+		return replyNotFound, nil
+	default:
+		return "", err
+	}
+}
+
+// incrItem increments or decrements the numeric value of the item and returns
+// the reply, which is the new value.
+//
+// Incrementing wraps around at 64 bits and decrementing below 0 sets the value
+// to 0.
+func (s *Server) incrItem(key string, delta uint64, incr bool) (string, error) {
+	s.lock()
+	defer s.mu.Unlock()
+
+	hits, misses := &s.stats.incrHits, &s.stats.incrMisses
+	if !incr {
+		hits, misses = &s.stats.decrHits, &s.stats.decrMisses
+	}
+
+	value, it, err := s.get(key)
+	if errors.Is(err, errNotFound) {
+		*misses++
+		return replyNotFound, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	*hits++
+
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return "", errNonNumeric
+	}
+
+	switch {
+	case incr:
+		n += delta
+	case delta > n:
+		n = 0
+	default:
+		n -= delta
+	}
+
+	value = strconv.FormatUint(n, 10)
+	if err := s.set(key, value, it.flags, it.exp); err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// touchItem updates the expiration time of the item and returns the reply.
+func (s *Server) touchItem(key string, exptime int64) (string, error) {
+	s.lock()
+	defer s.mu.Unlock()
+
+	s.stats.cmdTouch++
+
+	_, it, err := s.get(key)
+	if errors.Is(err, errNotFound) {
+		s.stats.touchMisses++
+		return replyNotFound, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.stats.touchHits++
+
+	it.exp = s.expiry(exptime)
+	if it.expired(s.now()) {
+		if err := s.del(key); err != nil {
+			return "", err
+		}
+	}
+
+	return replyTouched, nil
+}
+
+// flushAll invalidates all the items after the delay in seconds, or at once
+// if the delay is not positive, and returns the reply.
+func (s *Server) flushAll(delay int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.cmdFlush++
+
+	if delay <= 0 {
+		s.flush()
+	} else {
+		s.flushAt = s.expiry(delay)
+	}
+
+	return replyOK
+}
+
+// statLines returns the lines replied to the stats command, except "END".
+func (s *Server) statLines() []string {
+	s.lock()
+	st := s.stats
+	items := s.count()
+	now := s.now()
+	s.mu.Unlock()
+
+	s.connMu.Lock()
+	cs := s.connStats
+	s.connMu.Unlock()
+
+	stats := []struct {
+		name  string
+		value interface{}
+	}{
+		{"pid", os.Getpid()},
+		{"uptime", int64(now.Sub(s.started) / time.Second)},
+		{"time", now.Unix()},
+		{"version", Version},
+		{"curr_connections", cs.current},
+		{"total_connections", cs.total},
+		{"cmd_get", st.cmdGet},
+		{"cmd_set", st.cmdSet},
+		{"cmd_flush", st.cmdFlush},
+		{"cmd_touch", st.cmdTouch},
+		{"get_hits", st.getHits},
+		{"get_misses", st.getMisses},
+		{"delete_hits", st.deleteHits},
+		{"delete_misses", st.deleteMisses},
+		{"incr_hits", st.incrHits},
+		{"incr_misses", st.incrMisses},
+		{"decr_hits", st.decrHits},
+		{"decr_misses", st.decrMisses},
+		{"cas_hits", st.casHits},
+		{"cas_misses", st.casMisses},
+		{"cas_badval", st.casBadval},
+		{"touch_hits", st.touchHits},
+		{"touch_misses", st.touchMisses},
+		{"curr_items", items},
+	}
+
+	lines := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		lines = append(lines, fmt.Sprintf("STAT %s %v", stat.name, stat.value))
+	}
+
+	return lines
+}
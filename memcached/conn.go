@@ -0,0 +1,398 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxLineLen is the maximum length of a command line.
+	maxLineLen = 2048
+
+	// maxKeyLen is the maximum length of a key.
+	maxKeyLen = 250
+)
+
+// Error replies.
+const (
+	replyError  = "ERROR"
+	replyFormat = "CLIENT_ERROR bad command line format"
+)
+
+// conn is a connection with a client.
+type conn struct {
+	s  *Server
+	rw net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+
+	// active tells if a command is being executed, guarded by the server
+	active bool
+}
+
+// serve reads and executes the commands until the connection is closed.
+func (c *conn) serve() {
+	defer c.s.closeConn(c)
+
+	for {
+		line, err := c.r.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				c.writeLine("CLIENT_ERROR line too long")
+				_ = c.w.Flush()
+			}
+			return
+		}
+
+		c.s.setActive(c, true)
+		quit := c.exec(strings.Fields(string(line)))
+
+		// replies to pipelined commands are flushed together
+		if quit || c.r.Buffered() == 0 || c.s.isDraining() {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+
+		if quit || !c.s.setActive(c, false) {
+			return
+		}
+	}
+}
+
+// exec executes the command and writes its reply. It returns true if the
+// connection should be closed.
+func (c *conn) exec(fields []string) bool {
+	if len(fields) == 0 {
+		c.writeLine(replyError)
+		return false
+	}
+
+	cmd, args := fields[0], fields[1:]
+
+	switch cmd {
+	case "get", "gets":
+		c.get(args, cmd == "gets")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return c.store(cmd, args)
+	case "delete":
+		c.delete(args)
+	case "incr", "decr":
+		c.incr(args, cmd == "incr")
+	case "touch":
+		c.touch(args)
+	case "flush_all":
+		c.flushAll(args)
+	case "stats":
+		c.stats(args)
+	case "version":
+		c.writeLine("VERSION " + Version)
+	case "quit":
+		return true
+	default:
+		c.writeLine(replyError)
+	}
+
+	return false
+}
+
+// get executes "get <key>*" and "gets <key>*".
+func (c *conn) get(keys []string, withCAS bool) {
+	if len(keys) == 0 {
+		c.writeLine(replyError)
+		return
+	}
+
+	for _, key := range keys {
+		if !validKey(key) {
+			c.writeLine(replyFormat)
+			return
+		}
+	}
+
+	s := c.s
+	for _, key := range keys {
+		s.lock()
+
+		s.stats.cmdGet++
+		value, it, err := s.get(key)
+
+		var flags uint32
+		var cas uint64
+		switch {
+		case err == nil:
+			s.stats.getHits++
+			flags, cas = it.flags, it.cas
+		case errors.Is(err, errNotFound):
+			s.stats.getMisses++
+		}
+
+		s.mu.Unlock()
+
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			c.writeErr(err)
+			return
+		}
+
+		if withCAS {
+			fmt.Fprintf(c.w, "VALUE %s %d %d %d\r\n", key, flags, len(value), cas)
+		} else {
+			fmt.Fprintf(c.w, "VALUE %s %d %d\r\n", key, flags, len(value))
+		}
+		c.writeLine(value)
+	}
+
+	c.writeLine("END")
+}
+
+// store executes the storage commands:
+//
+//	<cmd> <key> <flags> <exptime> <bytes> [noreply]
+//	cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+//
+// followed by the data block. It returns true if the connection should be
+// closed since the data block could not be read.
+func (c *conn) store(cmd string, args []string) bool {
+	args, quiet := noreply(args)
+
+	n := 4
+	if cmd == "cas" {
+		n = 5
+	}
+	if len(args) != n {
+		c.writeLine(replyError)
+		return false
+	}
+
+	var p argParser
+	key := args[0]
+	flags := p.uint(args[1], 32)
+	exptime := p.int(args[2])
+	size := p.int(args[3])
+
+	var casUnique uint64
+	if cmd == "cas" {
+		casUnique = p.uint(args[4], 64)
+	}
+
+	if p.err != nil || !validKey(key) || size < 0 {
+		c.writeLine(replyFormat)
+		return false
+	}
+
+	if size > int64(c.s.MaxItemSize) {
+		// the data block is still sent by the client
+		if _, err := io.CopyN(ioutil.Discard, c.r, size+2); err != nil {
+			return true
+		}
+		c.writeErr(errTooLarge)
+		return false
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return true
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		c.writeLine("CLIENT_ERROR bad data chunk")
+		return true
+	}
+
+	reply, err := c.s.storeItem(cmd, key, string(data[:size]), uint32(flags), exptime, casUnique)
+	if err != nil {
+		c.writeErr(err)
+		return false
+	}
+
+	c.reply(quiet, reply)
+	return false
+}
+
+// delete executes "delete <key> [noreply]".
+func (c *conn) delete(args []string) {
+	args, quiet := noreply(args)
+	if len(args) != 1 || !validKey(args[0]) {
+		c.writeLine(replyFormat)
+		return
+	}
+
+	reply, err := c.s.deleteItem(args[0])
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	c.reply(quiet, reply)
+}
+
+// incr executes "incr <key> <value> [noreply]" and "decr <key> <value> [noreply]".
+func (c *conn) incr(args []string, incr bool) {
+	args, quiet := noreply(args)
+	if len(args) != 2 || !validKey(args[0]) {
+		c.writeLine(replyError)
+		return
+	}
+
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		c.writeLine("CLIENT_ERROR invalid numeric delta argument")
+		return
+	}
+
+	reply, err := c.s.incrItem(args[0], delta, incr)
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	c.reply(quiet, reply)
+}
+
+// touch executes "touch <key> <exptime> [noreply]".
+func (c *conn) touch(args []string) {
+	args, quiet := noreply(args)
+	if len(args) != 2 || !validKey(args[0]) {
+		c.writeLine(replyError)
+		return
+	}
+
+	var p argParser
+	exptime := p.int(args[1])
+	if p.err != nil {
+		c.writeLine("CLIENT_ERROR invalid exptime argument")
+		return
+	}
+
+	reply, err := c.s.touchItem(args[0], exptime)
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	c.reply(quiet, reply)
+}
+
+// flushAll executes "flush_all [delay] [noreply]".
+func (c *conn) flushAll(args []string) {
+	args, quiet := noreply(args)
+	if len(args) > 1 {
+		c.writeLine(replyError)
+		return
+	}
+
+	var p argParser
+	var delay int64
+	if len(args) == 1 {
+		delay = p.int(args[0])
+	}
+	if p.err != nil {
+		c.writeLine(replyFormat)
+		return
+	}
+
+	c.reply(quiet, c.s.flushAll(delay))
+}
+
+// stats executes "stats". Other statistics, like "stats items", are not
+// supported.
+func (c *conn) stats(args []string) {
+	if len(args) != 0 {
+		c.writeLine(replyError)
+		return
+	}
+
+	for _, line := range c.s.statLines() {
+		c.writeLine(line)
+	}
+	c.writeLine("END")
+}
+
+// reply writes the reply line unless the client asked for no reply.
+func (c *conn) reply(quiet bool, line string) {
+	if !quiet {
+		c.writeLine(line)
+	}
+}
+
+// writeErr writes the reply for the error, which is always sent to the client.
+func (c *conn) writeErr(err error) {
+	if errors.Is(err, errNonNumeric) {
+		c.writeLine("CLIENT_ERROR " + err.Error())
+		return
+	}
+
+	c.writeLine("SERVER_ERROR " + err.Error())
+}
+
+// writeLine writes the line terminated with "\r\n". Write errors are returned
+// when the buffer is flushed.
+func (c *conn) writeLine(line string) {
+	_, _ = c.w.WriteString(line)
+	_, _ = c.w.WriteString("\r\n")
+}
+
+// noreply removes the optional "noreply" argument, telling if it was there.
+func noreply(args []string) ([]string, bool) {
+	if n := len(args); n > 0 && args[n-1] == "noreply" {
+		return args[:n-1], true
+	}
+
+	return args, false
+}
+
+// validKey tells if the key is not too long and has no control characters.
+func validKey(key string) bool {
+	if len(key) > maxKeyLen {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// argParser parses the numeric arguments of a command, remembering the first
+// error.
+type argParser struct {
+	err error
+}
+
+// uint parses an unsigned integer of the bit size.
+func (p *argParser) uint(s string, bitSize int) uint64 {
+	if p.err != nil {
+		return 0
+	}
+
+	var v uint64
+	v, p.err = strconv.ParseUint(s, 10, bitSize)
+	return v
+}
+
+// int parses a signed 64-bit integer.
+func (p *argParser) int(s string) int64 {
+	if p.err != nil {
+		return 0
+	}
+
+	var v int64
+	v, p.err = strconv.ParseInt(s, 10, 64)
+	return v
+}
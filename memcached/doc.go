@@ -0,0 +1,43 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package memcached implements a server speaking the memcached text protocol
+// on top of a kiwi store, so that applications using a memcached client can
+// use kiwi without any changes.
+//
+// Every "str" key of the store is a memcached item. The flags, expiration time
+// and CAS unique of the items are kept by the server, which means that items
+// updated through the store directly keep their old CAS unique. Keys holding
+// values of other types are not visible to memcached clients.
+//
+// The supported commands are get, gets, set, add, replace, append, prepend,
+// cas, delete, incr, decr, touch, flush_all, stats, version and quit.
+//
+// Expiration times follow the memcached semantics: 0 means the item never
+// expires, a negative time expires it immediately, times up to 30 days are
+// relative to the current time in seconds and larger times are absolute Unix
+// timestamps. Expired items are removed from the store when they are accessed
+// and, while serving, periodically every SweepInterval.
+//
+// The flush_all command only deletes the items stored by memcached clients;
+// the str values added to the store directly are left as they are.
+//
+//
+// Get Started
+//
+//	store := kiwi.NewStore()
+//	server := memcached.NewServer(store)
+//
+//	go func() {
+//	  if err := server.ListenAndServe(":11211"); err != memcached.ErrServerClosed {
+//	    // handle error
+//	  }
+//	}()
+//
+//	// ...
+//
+//	if err := server.Close(); err != nil {
+//	  // handle error
+//	}
+package memcached
@@ -0,0 +1,214 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package memcached
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// Errors while accessing the items.
+var (
+	errNotFound = fmt.Errorf("item not found")
+	errNotStr   = fmt.Errorf("key holds a value which is not a str")
+)
+
+// maxRelativeExptime is the largest expiration time, in seconds, which is
+// relative to the current time. Larger times are Unix timestamps.
+const maxRelativeExptime = 30 * 24 * 60 * 60
+
+// item is the metadata of a str value stored as a memcached item.
+type item struct {
+	flags uint32
+	exp   time.Time // zero if the item never expires
+	cas   uint64
+
+	// stored is set for the items stored by the clients, as opposed to the
+	// str values added to the store directly, and only those are flushed.
+	stored bool
+}
+
+// expired tells if the item is expired at the time.
+func (it *item) expired(now time.Time) bool {
+	return !it.exp.IsZero() && !now.Before(it.exp)
+}
+
+// expiry returns the time at which an item with the memcached exptime expires.
+func (s *Server) expiry(exptime int64) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return s.now().Add(-time.Second)
+	case exptime <= maxRelativeExptime:
+		return s.now().Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
+
+// lock locks the items, flushing them first if a delayed flush is due.
+func (s *Server) lock() {
+	s.mu.Lock()
+
+	if !s.flushAt.IsZero() && !s.now().Before(s.flushAt) {
+		s.flush()
+	}
+}
+
+// get returns the value and the item of the key if it is a str value which
+// has not expired.
+//
+// It should be called with the items locked.
+func (s *Server) get(key string) (string, *item, error) {
+	typ, err := s.store.GetValueType(key)
+	if err != nil || typ != str.Type {
+		delete(s.items, key)
+		return "", nil, errNotFound
+	}
+
+	it, ok := s.items[key]
+	if !ok {
+		// the key was added to the store directly
+		s.cas++
+		it = &item{cas: s.cas}
+		s.items[key] = it
+	}
+
+	if it.expired(s.now()) {
+		_ = s.del(key)
+		return "", nil, errNotFound
+	}
+
+	v, err := s.store.Do(key, str.Get)
+	if err != nil {
+		return "", nil, err
+	}
+
+	value, ok := v.(string)
+	if !ok {
+		return "", nil, fmt.Errorf("str.Get returned a %T", v)
+	}
+
+	return value, it, nil
+}
+
+// set stores the value for the key with a new CAS unique. The key is deleted
+// instead if the expiration time has already passed.
+//
+// It should be called with the items locked.
+func (s *Server) set(key, value string, flags uint32, exp time.Time) error {
+	it := &item{flags: flags, exp: exp, stored: true}
+	if it.expired(s.now()) {
+		if err := s.del(key); err != nil && !errors.Is(err, errNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	typ, err := s.store.GetValueType(key)
+	switch {
+	case errors.Is(err, kiwi.ErrKeyNotExist):
+		if err := s.store.AddKey(key, str.Type); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case typ != str.Type:
+		return errNotStr
+	}
+
+	if _, err := s.store.Do(key, str.Update, value); err != nil {
+		return err
+	}
+
+	s.cas++
+	it.cas = s.cas
+	s.items[key] = it
+	return nil
+}
+
+// del deletes the key if it is a str value.
+//
+// It should be called with the items locked.
+func (s *Server) del(key string) error {
+	delete(s.items, key)
+
+	typ, err := s.store.GetValueType(key)
+	if err != nil || typ != str.Type {
+		return errNotFound
+	}
+
+	return s.store.DeleteKey(key)
+}
+
+// flush deletes the items stored by the clients from the store, leaving the
+// str values added to the store directly.
+//
+// It should be called with the items locked.
+func (s *Server) flush() {
+	for key, it := range s.items {
+		if it.stored {
+			// the key can only be missing if it was deleted meanwhile
+			_ = s.del(key)
+		}
+	}
+
+	s.items = make(map[string]*item)
+	s.flushAt = time.Time{}
+}
+
+// sweep deletes the expired items from the store.
+func (s *Server) sweep() {
+	s.lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, it := range s.items {
+		if it.expired(now) {
+			_ = s.del(key)
+		}
+	}
+}
+
+// sweepEvery sweeps the expired items at the interval until the server is
+// closed.
+func (s *Server) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopSweep:
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+// count returns the number of items which have not expired.
+//
+// It should be called with the items locked.
+func (s *Server) count() int {
+	now := s.now()
+
+	n := 0
+	for key, typ := range s.store.GetSchema() {
+		if typ != str.Type {
+			continue
+		}
+		if it, ok := s.items[key]; ok && it.expired(now) {
+			continue
+		}
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after the server is
+// closed.
+var ErrServerClosed = fmt.Errorf("memcached: server closed")
+
+// errTooManyConns is the reason a connection is refused when the server has
+// MaxConns connections.
+var errTooManyConns = fmt.Errorf("too many open connections")
+
+// shutdownPollInterval is the interval at which Shutdown checks if all the
+// connections are closed.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Version is the version reported by the version and stats commands.
+const Version = "kiwi"
+
+// Server serves memcached clients using the str values of a store.
+type Server struct {
+	store *kiwi.Store
+
+	// MaxItemSize is the maximum size of a value in bytes. Defaults to 1 MiB.
+	MaxItemSize int
+
+	// MaxConns is the maximum number of open connections. New connections
+	// are refused with an error when reached. There is no limit if it is 0.
+	MaxConns int
+
+	// SweepInterval is the interval at which the expired items are deleted
+	// from the store while serving, besides when they are accessed. Defaults
+	// to 1 minute.
+	SweepInterval time.Duration
+
+	now     func() time.Time
+	started time.Time
+
+	items   map[string]*item
+	cas     uint64
+	flushAt time.Time // zero if no flush is delayed
+	stats   stats
+	mu      sync.Mutex
+
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	connStats connStats
+	closed    bool
+	draining  bool
+	sweeping  bool
+	stopSweep chan struct{}
+	connMu    sync.Mutex
+}
+
+// stats are the counters reported by the stats command.
+type stats struct {
+	cmdGet       uint64
+	cmdSet       uint64
+	cmdFlush     uint64
+	cmdTouch     uint64
+	getHits      uint64
+	getMisses    uint64
+	deleteHits   uint64
+	deleteMisses uint64
+	incrHits     uint64
+	incrMisses   uint64
+	decrHits     uint64
+	decrMisses   uint64
+	casHits      uint64
+	casMisses    uint64
+	casBadval    uint64
+	touchHits    uint64
+	touchMisses  uint64
+}
+
+// connStats are the connection counters reported by the stats command.
+type connStats struct {
+	current uint64
+	total   uint64
+}
+
+// NewServer creates a server for the store.
+func NewServer(store *kiwi.Store) *Server {
+	return &Server{
+		store:         store,
+		MaxItemSize:   1 << 20,
+		SweepInterval: time.Minute,
+		now:           time.Now,
+		started:       time.Now(),
+		items:         make(map[string]*item),
+		mu:            sync.Mutex{},
+		listeners:     make(map[net.Listener]struct{}),
+		conns:         make(map[*conn]struct{}),
+		stopSweep:     make(chan struct{}),
+		connMu:        sync.Mutex{},
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves the connections.
+//
+// It always returns a non-nil error, which is ErrServerClosed after Close.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts the connections on the listener and serves each of them in a
+// new goroutine. The listener is closed when Serve returns.
+//
+// It always returns a non-nil error, which is ErrServerClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.connMu.Lock()
+	if s.closed {
+		s.connMu.Unlock()
+		_ = l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	if !s.sweeping {
+		s.sweeping = true
+		go s.sweepEvery(s.SweepInterval)
+	}
+	s.connMu.Unlock()
+
+	defer func() {
+		s.connMu.Lock()
+		delete(s.listeners, l)
+		s.connMu.Unlock()
+		_ = l.Close()
+	}()
+
+	for {
+		rw, err := l.Accept()
+		if err != nil {
+			s.connMu.Lock()
+			closed := s.closed
+			s.connMu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		c, err := s.newConn(rw)
+		if err == ErrServerClosed {
+			_ = rw.Close()
+			return err
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(rw, "SERVER_ERROR %v\r\n", err)
+			_ = rw.Close()
+			continue
+		}
+
+		go c.serve()
+	}
+}
+
+// Close closes all the listeners and connections of the server.
+func (s *Server) Close() error {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	s.close()
+
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for c := range s.conns {
+		if cerr := c.rw.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// Shutdown gracefully shuts down the server. It closes the listeners and the
+// idle connections, and then waits for the other connections to complete the
+// command being executed and close.
+//
+// If the context expires first, the remaining connections are closed and the
+// error of the context is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.connMu.Lock()
+	s.close()
+	s.draining = true
+
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for c := range s.conns {
+		if !c.active {
+			_ = c.rw.Close()
+		}
+	}
+	s.connMu.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.connMu.Lock()
+		n := len(s.conns)
+		s.connMu.Unlock()
+
+		if n == 0 {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// close marks the server closed and stops sweeping the expired items.
+//
+// It should be called with connMu locked.
+func (s *Server) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.stopSweep)
+	}
+}
+
+// setActive marks the connection as executing a command or as idle. It returns
+// false if the idle connection should be closed since the server is shutting
+// down.
+func (s *Server) setActive(c *conn, active bool) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	c.active = active
+	return active || !s.draining
+}
+
+// isDraining tells if the server is shutting down gracefully.
+func (s *Server) isDraining() bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	return s.draining
+}
+
+// newConn tracks a new connection. It returns ErrServerClosed if the server
+// is closed.
+func (s *Server) newConn(rw net.Conn) (*conn, error) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.closed {
+		return nil, ErrServerClosed
+	}
+
+	if s.MaxConns > 0 && s.connStats.current >= uint64(s.MaxConns) {
+		return nil, errTooManyConns
+	}
+
+	c := &conn{
+		s:  s,
+		rw: rw,
+		r:  bufio.NewReaderSize(rw, maxLineLen),
+		w:  bufio.NewWriter(rw),
+	}
+
+	s.conns[c] = struct{}{}
+	s.connStats.current++
+	s.connStats.total++
+	return c, nil
+}
+
+// closeConn closes the connection and stops tracking it.
+func (s *Server) closeConn(c *conn) {
+	s.connMu.Lock()
+	delete(s.conns, c)
+	s.connStats.current--
+	s.connMu.Unlock()
+
+	_ = c.rw.Close()
+}
@@ -0,0 +1,372 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/list"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// testClock is a clock for the server which is moved by the test.
+type testClock struct {
+	unix int64
+}
+
+func (c *testClock) now() time.Time {
+	return time.Unix(atomic.LoadInt64(&c.unix), 0)
+}
+
+func (c *testClock) advance(secs int64) {
+	atomic.AddInt64(&c.unix, secs)
+}
+
+// testClient sends commands to the server and checks the replies.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// newTestServer starts a server for the store and returns a client connected
+// to it.
+func newTestServer(t *testing.T, store *kiwi.Store) (*testClient, *testClock) {
+	clock := &testClock{unix: 1600000000}
+
+	s := NewServer(store)
+	s.now = clock.now
+	s.SweepInterval = 10 * time.Millisecond
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Errorf("could not close server: %v", err)
+		}
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("expected Serve to return ErrServerClosed; got %v", err)
+		}
+	})
+
+	return &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}, clock
+}
+
+// do sends the command and checks that the reply has the expected lines.
+func (c *testClient) do(cmd string, expected ...string) {
+	c.t.Helper()
+
+	if _, err := c.conn.Write([]byte(cmd + "\r\n")); err != nil {
+		c.t.Fatalf("could not send %q: %v", cmd, err)
+	}
+
+	c.expect(cmd, expected...)
+}
+
+// expect checks that the next lines of the reply to the command are the
+// expected ones.
+func (c *testClient) expect(cmd string, expected ...string) {
+	c.t.Helper()
+
+	for _, exp := range expected {
+		if err := c.conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			c.t.Fatalf("could not set deadline: %v", err)
+		}
+
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("could not read reply to %q: %v", cmd, err)
+		}
+
+		if line = strings.TrimSuffix(line, "\r\n"); line != exp {
+			c.t.Errorf("%q: expected %q; got %q", cmd, exp, line)
+		}
+	}
+}
+
+// reply sends the command and returns the first line of the reply.
+func (c *testClient) reply(cmd string) string {
+	c.t.Helper()
+
+	if _, err := c.conn.Write([]byte(cmd + "\r\n")); err != nil {
+		c.t.Fatalf("could not send %q: %v", cmd, err)
+	}
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("could not read reply to %q: %v", cmd, err)
+	}
+
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+func TestServer_Storage(t *testing.T) {
+	store := kiwi.NewStore()
+	if err := store.AddKey("jobs", list.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+
+	c, _ := newTestServer(t, store)
+
+	c.do("set a 5 0 3\r\nabc", "STORED")
+	c.do("get a b", "VALUE a 5 3", "abc", "END")
+
+	// the value is stored in the store as a str
+	if v, err := store.Do("a", str.Get); err != nil || v != "abc" {
+		t.Errorf("expected str %q in store; got %v, %v", "abc", v, err)
+	}
+
+	c.do("add a 0 0 1\r\nx", "NOT_STORED")
+	c.do("add b 0 0 1\r\nx", "STORED")
+	c.do("replace c 0 0 1\r\nx", "NOT_STORED")
+	c.do("replace b 7 0 1\r\ny", "STORED")
+	c.do("append b 0 0 2\r\nzz", "STORED")
+	c.do("prepend b 0 0 2\r\nxx", "STORED")
+	c.do("get b", "VALUE b 7 5", "xxyzz", "END")
+
+	gets := c.reply("gets a")
+	c.expect("gets a", "abc", "END")
+
+	fields := strings.Fields(gets)
+	if len(fields) != 5 {
+		t.Fatalf("expected gets to return the CAS unique; got %q", gets)
+	}
+	cas := fields[4]
+
+	c.do("cas a 0 0 1 "+cas+"\r\nd", "STORED")
+	c.do("cas a 0 0 1 "+cas+"\r\ne", "EXISTS")
+	c.do("cas c 0 0 1 "+cas+"\r\ne", "NOT_FOUND")
+	c.do("get a", "VALUE a 0 1", "d", "END")
+
+	// noreply suppresses the reply
+	c.do("set a 0 0 1 noreply\r\nf")
+	c.do("delete a", "DELETED")
+	c.do("delete a", "NOT_FOUND")
+
+	// keys with values of other types are not items
+	c.do("get jobs", "END")
+	c.do("set jobs 0 0 1\r\nx", "SERVER_ERROR "+errNotStr.Error())
+	c.do("delete jobs", "NOT_FOUND")
+
+	// invalid commands
+	c.do("bogus", "ERROR")
+	c.do("set a 0 0", "ERROR")
+	c.do("set a x 0 1", replyFormat)
+	c.do("set a 0 0 1\r\nxyz", "CLIENT_ERROR bad data chunk")
+}
+
+func TestServer_Incr(t *testing.T) {
+	c, _ := newTestServer(t, kiwi.NewStore())
+
+	c.do("incr n 1", "NOT_FOUND")
+	c.do("set n 0 0 2\r\n10", "STORED")
+	c.do("incr n 5", "15")
+	c.do("decr n 3", "12")
+	c.do("decr n 100", "0")
+	c.do("set n 0 0 20\r\n18446744073709551615", "STORED")
+	c.do("incr n 2", "1")
+	c.do("incr n x", "CLIENT_ERROR invalid numeric delta argument")
+
+	c.do("set s 0 0 3\r\nabc", "STORED")
+	c.do("incr s 1", "CLIENT_ERROR "+errNonNumeric.Error())
+}
+
+func TestServer_Expiry(t *testing.T) {
+	store := kiwi.NewStore()
+	c, clock := newTestServer(t, store)
+
+	c.do("set a 0 10 1\r\na", "STORED")
+	c.do("set b 0 0 1\r\nb", "STORED")
+	c.do("set c 0 -1 1\r\nc", "STORED")
+	c.do("get c", "END")
+
+	clock.advance(9)
+	c.do("get a", "VALUE a 0 1", "a", "END")
+	c.do("touch a 20", "TOUCHED")
+	c.do("touch c 20", "NOT_FOUND")
+
+	clock.advance(19)
+	c.do("get a", "VALUE a 0 1", "a", "END")
+
+	clock.advance(1)
+	c.do("get a b", "VALUE b 0 1", "b", "END")
+	if store.KeyExists("a") {
+		t.Errorf("expected expired key to be deleted from the store")
+	}
+
+	// absolute expiration time
+	exp := clock.now().Unix() + 2*maxRelativeExptime
+	c.do("set d 0 "+strconv.FormatInt(exp, 10)+" 1\r\nd", "STORED")
+
+	clock.advance(2*maxRelativeExptime - 1)
+	c.do("get d", "VALUE d 0 1", "d", "END")
+	clock.advance(1)
+	c.do("get d", "END")
+
+	// delayed flush
+	c.do("flush_all 5", "OK")
+	c.do("get b", "VALUE b 0 1", "b", "END")
+	clock.advance(5)
+	c.do("get b", "END")
+
+	c.do("set e 0 0 1\r\ne", "STORED")
+	c.do("flush_all", "OK")
+	c.do("get e", "END")
+}
+
+func TestServer_Sweep(t *testing.T) {
+	store := kiwi.NewStore()
+	c, clock := newTestServer(t, store)
+
+	c.do("set a 0 10 1\r\na", "STORED")
+	c.do("set b 0 0 1\r\nb", "STORED")
+
+	clock.advance(10)
+	deadline := time.Now().Add(time.Second)
+	for store.KeyExists("a") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected expired key to be swept from the store")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !store.KeyExists("b") {
+		t.Errorf("expected key without expiration not to be swept")
+	}
+}
+
+func TestServer_FlushOwn(t *testing.T) {
+	store := kiwi.NewStore()
+	if err := store.AddKey("own", str.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+	if _, err := store.Do("own", str.Update, "x"); err != nil {
+		t.Fatalf("could not update key: %v", err)
+	}
+
+	c, _ := newTestServer(t, store)
+
+	c.do("set a 0 0 1\r\na", "STORED")
+	c.do("get own", "VALUE own 0 1", "x", "END")
+	c.do("flush_all", "OK")
+	c.do("get a", "END")
+	c.do("get own", "VALUE own 0 1", "x", "END")
+	if !store.KeyExists("own") {
+		t.Errorf("expected str key added to the store not to be flushed")
+	}
+}
+
+func TestServer_Stats(t *testing.T) {
+	c, _ := newTestServer(t, kiwi.NewStore())
+
+	c.do("set a 0 0 1\r\na", "STORED")
+	c.do("get a b", "VALUE a 0 1", "a", "END")
+	c.do("version", "VERSION "+Version)
+
+	if _, err := c.conn.Write([]byte("stats\r\n")); err != nil {
+		t.Fatalf("could not send stats: %v", err)
+	}
+
+	stats := map[string]string{}
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("could not read stats: %v", err)
+		}
+		if line == "END\r\n" {
+			break
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "STAT" {
+			t.Fatalf("invalid stats line: %q", line)
+		}
+		stats[fields[1]] = fields[2]
+	}
+
+	expected := map[string]string{
+		"cmd_get":          "2",
+		"cmd_set":          "1",
+		"get_hits":         "1",
+		"get_misses":       "1",
+		"curr_items":       "1",
+		"curr_connections": "1",
+	}
+	for name, value := range expected {
+		if stats[name] != value {
+			t.Errorf("expected stat %s to be %s; got %q", name, value, stats[name])
+		}
+	}
+
+	c.do("quit")
+	if _, err := c.r.ReadString('\n'); err == nil {
+		t.Errorf("expected connection to be closed after quit")
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	s := NewServer(kiwi.NewStore())
+	s.MaxConns = 1
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	c := &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	c.do("set a 0 0 1\r\na", "STORED")
+
+	// connections over the limit are refused
+	refused, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer refused.Close() //nolint:errcheck
+
+	rc := &testClient{t: t, conn: refused, r: bufio.NewReader(refused)}
+	rc.expect("connect", "SERVER_ERROR "+errTooManyConns.Error())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("could not shutdown: %v", err)
+	}
+	if err := <-done; err != ErrServerClosed {
+		t.Errorf("expected Serve to return ErrServerClosed; got %v", err)
+	}
+
+	// the idle connection is closed
+	if _, err := c.r.ReadString('\n'); err == nil {
+		t.Errorf("expected idle connection to be closed")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package kiwi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// monitorBufferSize is the number of entries buffered for a monitor.
+const monitorBufferSize = 1024
+
+// MonitorEntry is an operation on the store seen by a monitor.
+type MonitorEntry struct {
+	// Time is when the operation completed.
+	Time time.Time
+
+	// Caller is the caller passed to DoAs, AddKeyAs or DeleteKeyAs, if any.
+	Caller string
+
+	// Op is the operation.
+	Op EventOp
+
+	// Key is the key operated on.
+	Key string
+
+	// Action is the action executed, set only for EventDo.
+	Action Action
+
+	// Params are the formatted parameters of the action.
+	Params string
+
+	// Err is the error of the action if it failed.
+	Err error
+}
+
+// String returns the entry in the format:
+//
+//	1600000000.000042 [caller] do "key" UPDATE "param" 42
+//
+// The caller is omitted if not known and the action and params are only
+// present for EventDo. The error of a failed action is appended as:
+//
+//	1600000000.000042 do "key" GET 5 (error: index out of range)
+func (e MonitorEntry) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d.%06d", e.Time.Unix(), e.Time.Nanosecond()/1000)
+	if e.Caller != "" {
+		fmt.Fprintf(&b, " [%s]", e.Caller)
+	}
+	fmt.Fprintf(&b, " %s %q", e.Op, e.Key)
+
+	if e.Op == EventDo {
+		fmt.Fprintf(&b, " %s", e.Action)
+		if e.Params != "" {
+			b.WriteString(" " + e.Params)
+		}
+		if e.Err != nil {
+			fmt.Fprintf(&b, " (error: %v)", e.Err)
+		}
+	}
+
+	return b.String()
+}
+
+// Monitor receives every operation on the store.
+//
+// Entries are dropped instead of blocking the store when they are not
+// received fast enough.
+type Monitor struct {
+	// dropped is first so that it is aligned for atomic operations.
+	dropped uint64
+
+	// C receives the entries. It is closed when the monitor is closed.
+	C <-chan MonitorEntry
+
+	c      chan MonitorEntry
+	remove func()
+	closed bool
+	mu     sync.Mutex
+}
+
+// Monitor creates a monitor which receives the operations on the store from
+// now on until it is closed.
+//
+// The actions which fail are seen along with their errors, as well as the
+// actions which do not modify the values. Monitors are hooks, so the store
+// does not do any more work when none is attached.
+func (s *Store) Monitor() *Monitor {
+	c := make(chan MonitorEntry, monitorBufferSize)
+
+	m := &Monitor{
+		C:  c,
+		c:  c,
+		mu: sync.Mutex{},
+	}
+
+	m.remove = s.AddHook(m.handle)
+	return m
+}
+
+// Dropped returns the number of entries dropped since they were not received
+// fast enough.
+func (m *Monitor) Dropped() uint64 {
+	return atomic.LoadUint64(&m.dropped)
+}
+
+// Close stops the monitor and closes C.
+func (m *Monitor) Close() {
+	m.remove()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.closed {
+		m.closed = true
+		close(m.c)
+	}
+}
+
+// handle is the hook which sends the event to the monitor.
+func (m *Monitor) handle(e Event) {
+	entry := MonitorEntry{
+		Time:   time.Now(),
+		Caller: e.Caller,
+		Op:     e.Op,
+		Key:    e.Key,
+		Action: e.Action,
+		Params: formatParams(e.Params),
+		Err:    e.Err,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return
+	}
+
+	select {
+	case m.c <- entry:
+	default:
+		atomic.AddUint64(&m.dropped, 1)
+	}
+}
+
+// formatParams formats the parameters separated by spaces, quoting strings.
+func formatParams(params []interface{}) string {
+	strs := make([]string, len(params))
+	for i, p := range params {
+		if s, ok := p.(string); ok {
+			strs[i] = strconv.Quote(s)
+		} else {
+			strs[i] = fmt.Sprintf("%v", p)
+		}
+	}
+
+	return strings.Join(strs, " ")
+}
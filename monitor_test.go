@@ -0,0 +1,68 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package kiwi_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/list"
+)
+
+func TestStore_Monitor(t *testing.T) {
+	store := kiwi.NewStore()
+
+	m := store.Monitor()
+
+	if err := store.AddKeyAs("worker-1", "jobs", list.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+	if _, err := store.DoAs("worker-1", "jobs", list.Append, "a", "b c"); err != nil {
+		t.Fatalf("could not append: %v", err)
+	}
+	if _, err := store.Do("jobs", list.Len); err != nil {
+		t.Fatalf("could not get length: %v", err)
+	}
+	if _, err := store.Do("jobs", list.Get, 5); err == nil {
+		t.Fatalf("expected error while getting invalid index")
+	}
+	if err := store.DeleteKeyAs("worker-2", "jobs"); err != nil {
+		t.Fatalf("could not delete key: %v", err)
+	}
+
+	m.Close()
+
+	var entries []string
+	for entry := range m.C {
+		if entry.Time.IsZero() {
+			t.Errorf("expected entry to have a time: %+v", entry)
+		}
+
+		// strip the timestamp
+		s := entry.String()
+		entries = append(entries, s[strings.Index(s, " ")+1:])
+	}
+
+	expected := []string{
+		`[worker-1] addkey "jobs"`,
+		`[worker-1] do "jobs" APPEND "a" "b c"`,
+		`do "jobs" LEN`,
+		`do "jobs" GET 5 (error: cannot access invalid index: 5 in slice of length=2)`,
+		`[worker-2] deletekey "jobs"`,
+	}
+
+	if strings.Join(entries, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected entries:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(entries, "\n"))
+	}
+
+	// closed monitors do not receive entries
+	if err := store.AddKey("jobs", list.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+	if m.Dropped() != 0 {
+		t.Errorf("expected no dropped entries; got %d", m.Dropped())
+	}
+}
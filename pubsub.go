@@ -0,0 +1,415 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package kiwi
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tidwall/match"
+)
+
+// Message is a message published to a channel.
+type Message struct {
+	// Channel is the channel the message is published to.
+	Channel string
+
+	// Pattern is the pattern matching the channel if the message is received
+	// for a pattern subscription, and empty otherwise.
+	Pattern string
+
+	// Payload is the content of the message.
+	Payload string
+}
+
+// Backpressure decides what happens when a message is published to a
+// subscriber whose buffer is full.
+type Backpressure int
+
+const (
+	// DropNewest discards the message being published.
+	DropNewest Backpressure = iota
+
+	// DropOldest discards the oldest message in the buffer to make space for
+	// the message being published.
+	DropOldest
+
+	// Block blocks the publisher until there is space in the buffer or the
+	// subscriber is closed.
+	Block
+
+	// Disconnect closes the subscriber.
+	Disconnect
+)
+
+// SubscriberOpts are the options to create a subscriber with.
+type SubscriberOpts struct {
+	// BufferSize is the number of messages buffered for the subscriber.
+	BufferSize int
+
+	// Backpressure is the policy used when the buffer is full.
+	Backpressure Backpressure
+}
+
+// DefaultSubscriberOpts are the options of the subscribers created by
+// Subscribe and PSubscribe.
+var DefaultSubscriberOpts = SubscriberOpts{
+	BufferSize:   128,
+	Backpressure: DropNewest,
+}
+
+// pubsub contains the subscriptions of a store.
+type pubsub struct {
+	channels map[string]map[*Subscriber]struct{}
+	patterns map[string]map[*Subscriber]struct{}
+	mu       sync.RWMutex
+}
+
+// newPubsub creates a pubsub without any subscriptions.
+func newPubsub() pubsub {
+	return pubsub{
+		channels: make(map[string]map[*Subscriber]struct{}),
+		patterns: make(map[string]map[*Subscriber]struct{}),
+		mu:       sync.RWMutex{},
+	}
+}
+
+// Publish publishes the message to the channel and returns the number of
+// subscribers it is delivered to.
+//
+// A subscriber subscribed to the channel as well as to a pattern matching it
+// receives the message for each of its subscriptions.
+func (s *Store) Publish(channel, payload string) int {
+	type delivery struct {
+		sub *Subscriber
+		msg Message
+	}
+
+	// The subscribers are collected first so that the store is not locked
+	// while publishers are blocked.
+	s.pubsub.mu.RLock()
+	var deliveries []delivery
+	for sub := range s.pubsub.channels[channel] {
+		deliveries = append(deliveries, delivery{
+			sub: sub,
+			msg: Message{Channel: channel, Payload: payload},
+		})
+	}
+	for pattern, subs := range s.pubsub.patterns {
+		if !match.Match(channel, pattern) {
+			continue
+		}
+		for sub := range subs {
+			deliveries = append(deliveries, delivery{
+				sub: sub,
+				msg: Message{Channel: channel, Pattern: pattern, Payload: payload},
+			})
+		}
+	}
+	s.pubsub.mu.RUnlock()
+
+	n := 0
+	for _, d := range deliveries {
+		if d.sub.send(d.msg) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// NewSubscriber creates a subscriber without any subscriptions.
+func (s *Store) NewSubscriber(opts SubscriberOpts) *Subscriber {
+	c := make(chan Message, opts.BufferSize)
+
+	return &Subscriber{
+		C:        c,
+		c:        c,
+		store:    s,
+		opts:     opts,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		done:     make(chan struct{}),
+		sendMu:   sync.RWMutex{},
+	}
+}
+
+// Subscribe creates a subscriber with the default options, subscribed to
+// the channels.
+func (s *Store) Subscribe(channels ...string) *Subscriber {
+	sub := s.NewSubscriber(DefaultSubscriberOpts)
+	sub.Subscribe(channels...)
+	return sub
+}
+
+// PSubscribe creates a subscriber with the default options, subscribed to
+// the channels matching the glob patterns.
+func (s *Store) PSubscribe(patterns ...string) *Subscriber {
+	sub := s.NewSubscriber(DefaultSubscriberOpts)
+	sub.PSubscribe(patterns...)
+	return sub
+}
+
+// NumSub returns the number of subscribers of each of the channels. Pattern
+// subscriptions are not counted.
+func (s *Store) NumSub(channels ...string) map[string]int {
+	s.pubsub.mu.RLock()
+	defer s.pubsub.mu.RUnlock()
+
+	num := make(map[string]int, len(channels))
+	for _, channel := range channels {
+		num[channel] = len(s.pubsub.channels[channel])
+	}
+
+	return num
+}
+
+// NumPat returns the number of patterns subscribed to.
+func (s *Store) NumPat() int {
+	s.pubsub.mu.RLock()
+	defer s.pubsub.mu.RUnlock()
+
+	return len(s.pubsub.patterns)
+}
+
+// ActiveChannels returns the sorted channels, matching the glob pattern, which
+// have at least one subscriber. All the channels are returned if the pattern
+// is empty. Pattern subscriptions are not considered.
+func (s *Store) ActiveChannels(pattern string) []string {
+	s.pubsub.mu.RLock()
+	defer s.pubsub.mu.RUnlock()
+
+	channels := make([]string, 0, len(s.pubsub.channels))
+	for channel := range s.pubsub.channels {
+		if pattern == "" || match.Match(channel, pattern) {
+			channels = append(channels, channel)
+		}
+	}
+
+	sort.Strings(channels)
+	return channels
+}
+
+// Subscriber receives the messages published to the channels it is
+// subscribed to.
+type Subscriber struct {
+	// dropped is first so that it is aligned for atomic operations.
+	dropped uint64
+
+	// C receives the messages. It is closed when the subscriber is closed.
+	C <-chan Message
+
+	c     chan Message
+	store *Store
+	opts  SubscriberOpts
+
+	// guarded by the lock of the store's pubsub
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	closed   bool
+
+	// done is closed before c so that blocked publishers return, and sendMu
+	// is held by the publishers so that c is not closed while sending.
+	done   chan struct{}
+	sendMu sync.RWMutex
+}
+
+// Subscribe subscribes to the channels.
+func (sub *Subscriber) Subscribe(channels ...string) {
+	sub.subscribe(sub.store.pubsub.channels, sub.channels, channels)
+}
+
+// PSubscribe subscribes to the channels matching the glob patterns.
+func (sub *Subscriber) PSubscribe(patterns ...string) {
+	sub.subscribe(sub.store.pubsub.patterns, sub.patterns, patterns)
+}
+
+// Unsubscribe unsubscribes from the channels, or from all the channels if
+// none is given.
+func (sub *Subscriber) Unsubscribe(channels ...string) {
+	sub.unsubscribe(sub.store.pubsub.channels, sub.channels, channels)
+}
+
+// PUnsubscribe unsubscribes from the patterns, or from all the patterns if
+// none is given.
+func (sub *Subscriber) PUnsubscribe(patterns ...string) {
+	sub.unsubscribe(sub.store.pubsub.patterns, sub.patterns, patterns)
+}
+
+// Subscriptions returns the number of channels and patterns subscribed to.
+func (sub *Subscriber) Subscriptions() int {
+	sub.store.pubsub.mu.RLock()
+	defer sub.store.pubsub.mu.RUnlock()
+
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// Dropped returns the number of messages which were not delivered since the
+// buffer was full.
+func (sub *Subscriber) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+// Close unsubscribes from all the channels and patterns and closes C.
+func (sub *Subscriber) Close() {
+	ps := &sub.store.pubsub
+
+	ps.mu.Lock()
+	if sub.closed {
+		ps.mu.Unlock()
+		return
+	}
+	sub.closed = true
+	sub.remove(ps.channels, sub.channels, keys(sub.channels))
+	sub.remove(ps.patterns, sub.patterns, keys(sub.patterns))
+	ps.mu.Unlock()
+
+	close(sub.done)
+
+	sub.sendMu.Lock()
+	close(sub.c)
+	sub.sendMu.Unlock()
+}
+
+// subscribe adds the names to the subscriptions of the subscriber.
+func (sub *Subscriber) subscribe(all map[string]map[*Subscriber]struct{}, own map[string]struct{}, names []string) {
+	ps := &sub.store.pubsub
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if sub.closed {
+		return
+	}
+
+	for _, name := range names {
+		subs, ok := all[name]
+		if !ok {
+			subs = make(map[*Subscriber]struct{})
+			all[name] = subs
+		}
+
+		subs[sub] = struct{}{}
+		own[name] = struct{}{}
+	}
+}
+
+// unsubscribe removes the names, or all of them if none is given, from the
+// subscriptions of the subscriber.
+func (sub *Subscriber) unsubscribe(all map[string]map[*Subscriber]struct{}, own map[string]struct{}, names []string) {
+	ps := &sub.store.pubsub
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if len(names) == 0 {
+		names = keys(own)
+	}
+
+	sub.remove(all, own, names)
+}
+
+// remove removes the names from the subscriptions of the subscriber.
+//
+// It should be called with the store's pubsub locked.
+func (sub *Subscriber) remove(all map[string]map[*Subscriber]struct{}, own map[string]struct{}, names []string) {
+	for _, name := range names {
+		if _, ok := own[name]; !ok {
+			continue
+		}
+		delete(own, name)
+
+		subs := all[name]
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(all, name)
+		}
+	}
+}
+
+// send delivers the message as per the backpressure policy and tells if it
+// is delivered.
+func (sub *Subscriber) send(msg Message) bool {
+	sub.sendMu.RLock()
+
+	select {
+	case <-sub.done:
+		sub.sendMu.RUnlock()
+		return false
+	default:
+	}
+
+	delivered, disconnect := false, false
+
+	switch sub.opts.Backpressure {
+	case DropOldest:
+		// The space made can be taken by another publisher, so the oldest
+		// message is dropped until this one fits. Each of the messages
+		// dropped is counted once and the message published is only lost
+		// if nothing can be buffered.
+	evict:
+		for !delivered {
+			select {
+			case sub.c <- msg:
+				delivered = true
+			default:
+				if cap(sub.c) == 0 {
+					break evict
+				}
+
+				// make space unless the subscriber just did
+				select {
+				case <-sub.c:
+					atomic.AddUint64(&sub.dropped, 1)
+				default:
+				}
+			}
+		}
+
+	case Block:
+		select {
+		case sub.c <- msg:
+			delivered = true
+		case <-sub.done:
+		}
+
+	case Disconnect:
+		select {
+		case sub.c <- msg:
+			delivered = true
+		default:
+			disconnect = true
+		}
+
+	default: // DropNewest
+		select {
+		case sub.c <- msg:
+			delivered = true
+		default:
+		}
+	}
+
+	sub.sendMu.RUnlock()
+
+	if !delivered {
+		atomic.AddUint64(&sub.dropped, 1)
+	}
+	if disconnect {
+		sub.Close()
+	}
+
+	return delivered
+}
+
+// keys returns the keys of the map.
+func keys(m map[string]struct{}) []string {
+	k := make([]string, 0, len(m))
+	for key := range m {
+		k = append(k, key)
+	}
+
+	return k
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package kiwi
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receive returns the buffered messages of the subscriber.
+func receive(sub *Subscriber) []Message {
+	var msgs []Message
+	for {
+		select {
+		case msg, ok := <-sub.C:
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestStore_Publish(t *testing.T) {
+	store := NewStore()
+
+	news := store.Subscribe("news", "sports")
+	all := store.PSubscribe("n*", "*")
+
+	if n := store.Publish("news", "hello"); n != 3 {
+		t.Errorf("expected message to be delivered to 3 subscriptions; got %d", n)
+	}
+	if n := store.Publish("weather", "sunny"); n != 1 {
+		t.Errorf("expected message to be delivered to 1 subscription; got %d", n)
+	}
+
+	expected := []Message{{Channel: "news", Payload: "hello"}}
+	if msgs := receive(news); !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("expected messages %v; got %v", expected, msgs)
+	}
+
+	msgs := receive(all)
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages for patterns; got %v", msgs)
+	}
+	patterns := map[string]bool{}
+	for _, msg := range msgs[:2] {
+		if msg.Channel != "news" || msg.Payload != "hello" {
+			t.Errorf("unexpected message: %v", msg)
+		}
+		patterns[msg.Pattern] = true
+	}
+	if !patterns["n*"] || !patterns["*"] {
+		t.Errorf("expected message for both patterns; got %v", msgs[:2])
+	}
+	if expected := (Message{Channel: "weather", Pattern: "*", Payload: "sunny"}); msgs[2] != expected {
+		t.Errorf("expected message %v; got %v", expected, msgs[2])
+	}
+
+	other := store.Subscribe("news")
+
+	if num := store.NumSub("news", "sports", "weather"); !reflect.DeepEqual(num,
+		map[string]int{"news": 2, "sports": 1, "weather": 0}) {
+		t.Errorf("unexpected NumSub: %v", num)
+	}
+	if n := store.NumPat(); n != 2 {
+		t.Errorf("expected 2 patterns; got %d", n)
+	}
+	if channels := store.ActiveChannels("s*"); !reflect.DeepEqual(channels, []string{"sports"}) {
+		t.Errorf("unexpected active channels: %v", channels)
+	}
+
+	news.Unsubscribe("news")
+	if n := news.Subscriptions(); n != 1 {
+		t.Errorf("expected 1 subscription after unsubscribing; got %d", n)
+	}
+	all.PUnsubscribe()
+	if n := store.NumPat(); n != 0 {
+		t.Errorf("expected no patterns after unsubscribing; got %d", n)
+	}
+
+	if n := store.Publish("news", "bye"); n != 1 {
+		t.Errorf("expected message to be delivered to 1 subscription; got %d", n)
+	}
+
+	other.Close()
+	other.Close()
+	if _, ok := <-other.C; !ok {
+		t.Errorf("expected buffered message to be received after closing")
+	}
+	if _, ok := <-other.C; ok {
+		t.Errorf("expected subscriber to be closed")
+	}
+
+	other.Subscribe("news")
+	if channels := store.ActiveChannels(""); !reflect.DeepEqual(channels, []string{"sports"}) {
+		t.Errorf("expected closed subscriber to not subscribe; got channels %v", channels)
+	}
+}
+
+func TestSubscriber_Backpressure(t *testing.T) {
+	store := NewStore()
+
+	subs := map[Backpressure]*Subscriber{}
+	for _, bp := range []Backpressure{DropNewest, DropOldest, Disconnect} {
+		subs[bp] = store.NewSubscriber(SubscriberOpts{BufferSize: 2, Backpressure: bp})
+		subs[bp].Subscribe("c")
+	}
+
+	for _, payload := range []string{"1", "2", "3"} {
+		store.Publish("c", payload)
+	}
+
+	payloads := func(sub *Subscriber) []string {
+		var p []string
+		for _, msg := range receive(sub) {
+			p = append(p, msg.Payload)
+		}
+		return p
+	}
+
+	if p := payloads(subs[DropNewest]); !reflect.DeepEqual(p, []string{"1", "2"}) {
+		t.Errorf("DropNewest: unexpected messages %v", p)
+	}
+	if p := payloads(subs[DropOldest]); !reflect.DeepEqual(p, []string{"2", "3"}) {
+		t.Errorf("DropOldest: unexpected messages %v", p)
+	}
+	if p := payloads(subs[Disconnect]); !reflect.DeepEqual(p, []string{"1", "2"}) {
+		t.Errorf("Disconnect: unexpected messages %v", p)
+	}
+	if _, ok := <-subs[Disconnect].C; ok {
+		t.Errorf("Disconnect: expected subscriber to be closed")
+	}
+
+	for bp, sub := range subs {
+		if sub.Dropped() != 1 {
+			t.Errorf("expected 1 dropped message for %d; got %d", bp, sub.Dropped())
+		}
+	}
+
+	// the messages dropped to make space are counted once each
+	concurrent := store.NewSubscriber(SubscriberOpts{BufferSize: 1, Backpressure: DropOldest})
+	concurrent.Subscribe("d")
+
+	const messages = 100
+
+	var wg sync.WaitGroup
+	for i := 0; i < messages; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Publish("d", "x")
+		}()
+	}
+	wg.Wait()
+
+	if n := len(receive(concurrent)); uint64(n)+concurrent.Dropped() != messages {
+		t.Errorf("expected %d messages received or dropped; got %d and %d", messages, n, concurrent.Dropped())
+	}
+
+	blocking := store.NewSubscriber(SubscriberOpts{BufferSize: 1, Backpressure: Block})
+	blocking.Subscribe("b")
+	store.Publish("b", "1")
+
+	published := make(chan int)
+	go func() { published <- store.Publish("b", "2") }()
+
+	select {
+	case <-published:
+		t.Fatalf("expected publisher to be blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if msg := <-blocking.C; msg.Payload != "1" {
+		t.Errorf("expected first message; got %v", msg)
+	}
+	if n := <-published; n != 1 {
+		t.Errorf("expected blocked message to be delivered; got %d", n)
+	}
+
+	// closing unblocks the publishers
+	go func() { published <- store.Publish("b", "3") }()
+	time.Sleep(10 * time.Millisecond)
+	blocking.Close()
+
+	if n := <-published; n != 0 {
+		t.Errorf("expected message to not be delivered after closing; got %d", n)
+	}
+}
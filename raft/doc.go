@@ -0,0 +1,65 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package raft implements a fault-tolerant kiwi store replicated using the
+// Raft consensus algorithm.
+//
+// Each member of the cluster is a Node which owns a kiwi.Store. Changes to the
+// store (adding, updating and deleting keys as well as executing actions) are
+// proposed to the leader, replicated to a majority of the members and then
+// applied, in the same order, to the store of every member. Reads are made
+// linearizable using the read-index technique, i.e., the leader confirms it is
+// still the leader with a majority before serving the read from its store.
+//
+// The log is compacted by taking snapshots of the store using Export, which are
+// shipped to members that fall too far behind. Members can be added to and
+// removed from the cluster one at a time.
+//
+// Nodes talk to each other using a Transport. The package provides Network, an
+// in-process transport which can simulate network partitions.
+//
+// The state of a node is held in memory only, like the kiwi.Store itself. A node
+// that has been stopped cannot rejoin with the same ID; a new member should be
+// added in its place.
+//
+// Commands must be deterministic, since every member applies them to its own
+// store. Actions which read the clock of the member are not, and must not be
+// replicated: generating stream IDs with "*" and the times of the stream's
+// pending entries, the expiry of leases and the refill of rate limiters. Pass
+// explicit stream IDs instead, and keep leases and rate limiters in a store
+// which is not replicated.
+//
+//
+// Get Started
+//
+//	network := raft.NewNetwork()
+//	members := []string{"a", "b", "c"}
+//
+//	for _, id := range members {
+//	  node, err := raft.NewNode(raft.Config{
+//	    ID:        id,
+//	    Members:   members,
+//	    Store:     kiwi.NewStore(),
+//	    Transport: network.Transport(id),
+//	  })
+//	  if err != nil {
+//	    // handle error
+//	  }
+//	  // ...
+//	}
+//
+//	// on the leader
+//	if err := node.AddKey(ctx, "my_string", "str"); err != nil {
+//	  // handle error
+//	}
+//
+//	if _, err := node.Do(ctx, "my_string", "UPDATE", "Hello, World!"); err != nil {
+//	  // handle error
+//	}
+//
+//	err := node.Read(ctx, func(store *kiwi.Store) error {
+//	  v, err := store.Do("my_string", "GET")
+//	  // ...
+//	})
+package raft
@@ -0,0 +1,185 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package raft
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// EntryKind tells what an entry in the log holds.
+type EntryKind uint8
+
+const (
+	// EntryNoop is appended by a leader when it is elected so that it can
+	// commit entries from its term.
+	EntryNoop EntryKind = iota
+
+	// EntryCommand holds a command to be applied to the store.
+	EntryCommand
+
+	// EntryMembers holds the new members of the cluster.
+	EntryMembers
+)
+
+// Op is the operation executed by a command on the store.
+type Op uint8
+
+const (
+	// OpDo executes an action for the value associated with the key.
+	OpDo Op = iota
+
+	// OpAddKey adds a new key to the store.
+	OpAddKey
+
+	// OpUpdateKey updates the value type of the key.
+	OpUpdateKey
+
+	// OpDeleteKey deletes the key from the store.
+	OpDeleteKey
+)
+
+// Command is a change to the store which is replicated through the log.
+type Command struct {
+	Op     Op
+	Key    string
+	Type   kiwi.ValueType
+	Action kiwi.Action
+	Params []interface{}
+}
+
+// apply executes the command on the store.
+func (c *Command) apply(store *kiwi.Store) (interface{}, error) {
+	switch c.Op {
+	case OpDo:
+		return store.Do(c.Key, c.Action, c.Params...)
+	case OpAddKey:
+		return nil, store.AddKey(c.Key, c.Type)
+	case OpUpdateKey:
+		return nil, store.UpdateKey(c.Key, c.Type)
+	case OpDeleteKey:
+		return nil, store.DeleteKey(c.Key)
+	default:
+		return nil, fmt.Errorf("unknown command op: %d", c.Op)
+	}
+}
+
+// Entry is an entry in the replicated log.
+type Entry struct {
+	Index uint64
+	Term  uint64
+	Kind  EntryKind
+
+	// Command is set for entries of EntryCommand kind.
+	Command Command
+
+	// Members is set for entries of EntryMembers kind.
+	Members []string
+}
+
+// snapshot is the compacted state of the log up to (and including) Index.
+type snapshot struct {
+	Index   uint64
+	Term    uint64
+	Members []string
+	Data    json.RawMessage
+}
+
+// restore replaces all the data of the store with the one in the snapshot.
+func restore(store *kiwi.Store, data json.RawMessage) error {
+	for key := range store.GetSchema() {
+		if err := store.DeleteKey(key); err != nil {
+			return err
+		}
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	return store.Import(data, kiwi.ImportOpts{AddKeys: true})
+}
+
+//
+// Helpers for the log of a node. These are to be called with the lock held.
+//
+// The first entry of the log is a sentinel which holds the index and term of
+// the last snapshot, so the log is never empty.
+//
+
+// baseIndex returns the index of the last entry compacted in the snapshot.
+func (n *Node) baseIndex() uint64 { return n.log[0].Index }
+
+// lastIndex returns the index of the last entry in the log.
+func (n *Node) lastIndex() uint64 { return n.log[len(n.log)-1].Index }
+
+// lastTerm returns the term of the last entry in the log.
+func (n *Node) lastTerm() uint64 { return n.log[len(n.log)-1].Term }
+
+// entry returns the entry at the index. The index should be in the log.
+func (n *Node) entry(index uint64) *Entry { return &n.log[index-n.baseIndex()] }
+
+// termAt returns the term of the entry at the index. It returns false if the
+// entry is not in the log, i.e., it has been compacted or does not exist yet.
+func (n *Node) termAt(index uint64) (uint64, bool) {
+	if index < n.baseIndex() || index > n.lastIndex() {
+		return 0, false
+	}
+
+	return n.entry(index).Term, true
+}
+
+// entries returns a copy of the entries from lo to hi (both included).
+func (n *Node) entries(lo, hi uint64) []Entry {
+	if lo > hi {
+		return nil
+	}
+
+	base := n.baseIndex()
+	return append([]Entry(nil), n.log[lo-base:hi-base+1]...)
+}
+
+// truncate removes all the entries after the index.
+func (n *Node) truncate(index uint64) {
+	n.log = n.log[:index-n.baseIndex()+1]
+}
+
+// compact discards the entries up to the index and stores the snapshot.
+func (n *Node) compact(snap snapshot) {
+	log := []Entry{{Index: snap.Index, Term: snap.Term}}
+	if snap.Index < n.lastIndex() {
+		if term, ok := n.termAt(snap.Index); ok && term == snap.Term {
+			log = append(log, n.log[snap.Index-n.baseIndex()+1:]...)
+		}
+	}
+
+	n.log = log
+	n.snapshot = snap
+}
+
+// membersAt returns the members of the cluster as of the entry at index.
+func (n *Node) membersAt(index uint64) []string {
+	for i := index; i > n.baseIndex(); i-- {
+		if e := n.entry(i); e.Kind == EntryMembers {
+			return e.Members
+		}
+	}
+
+	return n.snapshot.Members
+}
+
+// lastMembersIndex returns the index of the latest members entry in the log.
+// It returns 0 if the members are from the snapshot.
+func (n *Node) lastMembersIndex() uint64 {
+	for i := n.lastIndex(); i > n.baseIndex(); i-- {
+		if n.entry(i).Kind == EntryMembers {
+			return i
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,1061 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package raft
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// Various errors related to the node.
+var (
+	ErrNotLeader           = fmt.Errorf("node is not the leader")
+	ErrLeadershipLost      = fmt.Errorf("leadership lost before the entry was committed")
+	ErrStopped             = fmt.Errorf("node is stopped")
+	ErrMembersChangeActive = fmt.Errorf("another members change is in progress")
+	ErrInvalidMember       = fmt.Errorf("invalid member")
+)
+
+// newNotLeaderErr creates an error with the ID of the leader known to the node.
+func newNotLeaderErr(leader string) error {
+	return fmt.Errorf("%w: leader is %q", ErrNotLeader, leader)
+}
+
+// newMemberErr creates an error with the related member.
+func newMemberErr(id, reason string) error {
+	return fmt.Errorf("%w: %s %s", ErrInvalidMember, id, reason)
+}
+
+// State is the role of the node in the cluster.
+type State uint8
+
+// Various states of a node.
+const (
+	Follower State = iota
+	Candidate
+	Leader
+)
+
+// String implements the fmt.Stringer interface.
+func (s State) String() string {
+	switch s {
+	case Follower:
+		return "follower"
+	case Candidate:
+		return "candidate"
+	case Leader:
+		return "leader"
+	default:
+		return fmt.Sprintf("state(%d)", s)
+	}
+}
+
+// Config is used to configure the node.
+type Config struct {
+	// ID uniquely identifies the node in the cluster.
+	ID string
+
+	// Members are the IDs of the nodes the cluster is bootstrapped with.
+	// All the nodes of the cluster should be created with the same members.
+	// It should be empty for a node which is to be added to an existing
+	// cluster using AddMember.
+	Members []string
+
+	// Store is the store the committed changes are applied to. It should not
+	// be modified except through the node.
+	Store *kiwi.Store
+
+	// Transport is used to talk to other nodes.
+	Transport Transport
+
+	// TickInterval is the interval of the logical clock of the node.
+	// Defaults to 50ms.
+	TickInterval time.Duration
+
+	// ElectionTicks is the minimum number of ticks without hearing from the
+	// leader after which a follower starts an election. The actual timeout is
+	// randomized between ElectionTicks and twice of it. Defaults to 10.
+	ElectionTicks int
+
+	// HeartbeatTicks is the number of ticks after which the leader sends
+	// heartbeats. It should be much lower than ElectionTicks. Defaults to 2.
+	HeartbeatTicks int
+
+	// SnapshotThreshold is the number of entries applied since the last
+	// snapshot after which the log is compacted. Defaults to 1024.
+	SnapshotThreshold int
+
+	// MaxAppendEntries is the maximum number of entries sent in a single
+	// request. Defaults to 256.
+	MaxAppendEntries int
+}
+
+// setDefaults validates the config and fills in the defaults.
+func (c *Config) setDefaults() error {
+	if c.ID == "" {
+		return fmt.Errorf("raft config requires an ID")
+	}
+	if c.Store == nil {
+		return fmt.Errorf("raft config requires a Store")
+	}
+	if c.Transport == nil {
+		return fmt.Errorf("raft config requires a Transport")
+	}
+
+	if c.TickInterval <= 0 {
+		c.TickInterval = 50 * time.Millisecond
+	}
+	if c.ElectionTicks <= 0 {
+		c.ElectionTicks = 10
+	}
+	if c.HeartbeatTicks <= 0 {
+		c.HeartbeatTicks = 2
+	}
+	if c.HeartbeatTicks >= c.ElectionTicks {
+		return fmt.Errorf("raft config HeartbeatTicks should be less than ElectionTicks")
+	}
+	if c.SnapshotThreshold <= 0 {
+		c.SnapshotThreshold = 1024
+	}
+	if c.MaxAppendEntries <= 0 {
+		c.MaxAppendEntries = 256
+	}
+
+	return nil
+}
+
+// Status is the status of the node at a particular time.
+type Status struct {
+	ID            string
+	State         State
+	Term          uint64
+	Leader        string
+	Members       []string
+	CommitIndex   uint64
+	AppliedIndex  uint64
+	SnapshotIndex uint64
+}
+
+// Node is a member of the raft cluster.
+type Node struct {
+	cfg   Config
+	store *kiwi.Store
+	rand  *rand.Rand
+
+	state    State
+	term     uint64
+	votedFor string
+	leader   string
+	members  []string
+
+	log      []Entry
+	snapshot snapshot
+
+	commitIndex uint64
+	lastApplied uint64
+
+	// leader state
+	peers   map[string]*peer
+	readSeq uint64
+	waiters map[uint64]waiter
+
+	electionElapsed  int
+	electionTimeout  int
+	heartbeatElapsed int
+	lastHeard        time.Time
+
+	// changed is closed and replaced whenever the state of the node changes
+	// in a way someone might be waiting for.
+	changed chan struct{}
+
+	applyCond *sync.Cond
+	stopCh    chan struct{}
+	stopped   bool
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+}
+
+// waiter waits for the result of applying the entry proposed in the term.
+type waiter struct {
+	term uint64
+	ch   chan result
+}
+
+// result is the result of applying an entry.
+type result struct {
+	res interface{}
+	err error
+}
+
+// NewNode creates a node and starts it.
+func NewNode(cfg Config) (*Node, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		cfg:     cfg,
+		store:   cfg.Store,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+		log:     []Entry{{}},
+		waiters: make(map[uint64]waiter),
+		changed: make(chan struct{}),
+		stopCh:  make(chan struct{}),
+	}
+	n.applyCond = sync.NewCond(&n.mu)
+
+	n.snapshot.Members = append([]string(nil), cfg.Members...)
+	n.members = n.snapshot.Members
+	n.resetElectionTimeout()
+
+	cfg.Transport.Register(n)
+
+	n.wg.Add(2)
+	go n.run()
+	go n.applier()
+
+	return n, nil
+}
+
+// ID returns the ID of the node.
+func (n *Node) ID() string { return n.cfg.ID }
+
+// Status returns the current status of the node.
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return Status{
+		ID:            n.cfg.ID,
+		State:         n.state,
+		Term:          n.term,
+		Leader:        n.leader,
+		Members:       append([]string(nil), n.members...),
+		CommitIndex:   n.commitIndex,
+		AppliedIndex:  n.lastApplied,
+		SnapshotIndex: n.baseIndex(),
+	}
+}
+
+// Stop stops the node. It cannot be started again.
+func (n *Node) Stop() {
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+		return
+	}
+
+	n.stopped = true
+	n.stopPeers()
+	n.state = Follower
+	close(n.stopCh)
+	n.applyCond.Broadcast()
+	n.mu.Unlock()
+
+	n.wg.Wait()
+}
+
+// AddKey adds a new key to the store of the cluster.
+func (n *Node) AddKey(ctx context.Context, key string, typ kiwi.ValueType) error {
+	_, err := n.propose(ctx, Entry{Kind: EntryCommand, Command: Command{Op: OpAddKey, Key: key, Type: typ}})
+	return err
+}
+
+// UpdateKey updates the value type of the key in the store of the cluster.
+func (n *Node) UpdateKey(ctx context.Context, key string, typ kiwi.ValueType) error {
+	_, err := n.propose(ctx, Entry{Kind: EntryCommand, Command: Command{Op: OpUpdateKey, Key: key, Type: typ}})
+	return err
+}
+
+// DeleteKey deletes the key from the store of the cluster.
+func (n *Node) DeleteKey(ctx context.Context, key string) error {
+	_, err := n.propose(ctx, Entry{Kind: EntryCommand, Command: Command{Op: OpDeleteKey, Key: key}})
+	return err
+}
+
+// Do executes the action for the value associated with the key once it is
+// committed by the cluster. It returns the result of the action as executed
+// on the store of this node.
+//
+// Params are shared with the log and should not be modified afterwards.
+func (n *Node) Do(ctx context.Context, key string, action kiwi.Action, params ...interface{}) (interface{}, error) {
+	return n.propose(ctx, Entry{
+		Kind:    EntryCommand,
+		Command: Command{Op: OpDo, Key: key, Action: action, Params: params},
+	})
+}
+
+// Read executes fn with the store once it is up-to-date with all the changes
+// committed before Read was called, which makes the read linearizable.
+//
+// fn should only read from the store. Changes made in fn are not replicated.
+func (n *Node) Read(ctx context.Context, fn func(*kiwi.Store) error) error {
+	index, err := n.readIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := n.waitApplied(ctx, index); err != nil {
+		return err
+	}
+
+	return fn(n.store)
+}
+
+// AddMember adds the node with the ID to the cluster. The node should be
+// created without any Members before being added.
+func (n *Node) AddMember(ctx context.Context, id string) error {
+	return n.changeMembers(ctx, func(members []string) ([]string, error) {
+		if contains(members, id) {
+			return nil, newMemberErr(id, "already exists")
+		}
+
+		return append(append([]string(nil), members...), id), nil
+	})
+}
+
+// RemoveMember removes the node with the ID from the cluster.
+func (n *Node) RemoveMember(ctx context.Context, id string) error {
+	return n.changeMembers(ctx, func(members []string) ([]string, error) {
+		if !contains(members, id) {
+			return nil, newMemberErr(id, "does not exist")
+		}
+
+		newMembers := make([]string, 0, len(members)-1)
+		for _, m := range members {
+			if m != id {
+				newMembers = append(newMembers, m)
+			}
+		}
+
+		return newMembers, nil
+	})
+}
+
+// changeMembers proposes the members returned by fn as the new members.
+func (n *Node) changeMembers(ctx context.Context, fn func([]string) ([]string, error)) error {
+	n.mu.Lock()
+
+	if n.state != Leader {
+		n.mu.Unlock()
+		return newNotLeaderErr(n.leader)
+	}
+
+	// Only a single member is added or removed at a time, and not before the
+	// leader has committed an entry from its term.
+	if term, _ := n.termAt(n.commitIndex); term != n.term || n.lastMembersIndex() > n.commitIndex {
+		n.mu.Unlock()
+		return ErrMembersChangeActive
+	}
+
+	members, err := fn(n.members)
+	if err != nil {
+		n.mu.Unlock()
+		return err
+	}
+
+	ch := n.appendEntry(Entry{Kind: EntryMembers, Members: members})
+	n.mu.Unlock()
+
+	_, err = n.wait(ctx, ch)
+	return err
+}
+
+// propose appends the entry to the log and waits for it to be applied.
+func (n *Node) propose(ctx context.Context, e Entry) (interface{}, error) {
+	n.mu.Lock()
+
+	if n.stopped {
+		n.mu.Unlock()
+		return nil, ErrStopped
+	}
+
+	if n.state != Leader {
+		n.mu.Unlock()
+		return nil, newNotLeaderErr(n.leader)
+	}
+
+	ch := n.appendEntry(e)
+	n.mu.Unlock()
+
+	return n.wait(ctx, ch)
+}
+
+// wait waits for the result of applying the entry.
+func (n *Node) wait(ctx context.Context, ch <-chan result) (interface{}, error) {
+	select {
+	case r := <-ch:
+		return r.res, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-n.stopCh:
+		return nil, ErrStopped
+	}
+}
+
+// appendEntry appends the entry to the log of the leader and starts
+// replicating it. The lock should be held.
+func (n *Node) appendEntry(e Entry) <-chan result {
+	e.Index = n.lastIndex() + 1
+	e.Term = n.term
+	n.log = append(n.log, e)
+
+	ch := make(chan result, 1)
+	n.waiters[e.Index] = waiter{term: e.Term, ch: ch}
+
+	if e.Kind == EntryMembers {
+		n.members = e.Members
+		n.syncPeers()
+	}
+
+	n.maybeCommit()
+	n.broadcast()
+	return ch
+}
+
+// readIndex confirms that the node is the leader and returns the index up to
+// which the entries should be applied before serving a read.
+func (n *Node) readIndex(ctx context.Context) (uint64, error) {
+	n.mu.Lock()
+
+	if n.state != Leader {
+		n.mu.Unlock()
+		return 0, newNotLeaderErr(n.leader)
+	}
+	term := n.term
+
+	// The commit index is known to be up-to-date only once the leader has
+	// committed an entry from its term.
+	for {
+		if n.state != Leader || n.term != term {
+			n.mu.Unlock()
+			return 0, ErrLeadershipLost
+		}
+
+		if t, _ := n.termAt(n.commitIndex); t == term {
+			break
+		}
+
+		if err := n.waitChange(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	index := n.commitIndex
+	n.readSeq++
+	seq := n.readSeq
+	n.broadcast()
+
+	// Wait for a majority to acknowledge the leadership.
+	for {
+		if n.state != Leader || n.term != term {
+			n.mu.Unlock()
+			return 0, ErrLeadershipLost
+		}
+
+		acks := 0
+		for _, id := range n.members {
+			if id == n.cfg.ID {
+				acks++
+			} else if p, ok := n.peers[id]; ok && p.ackSeq >= seq {
+				acks++
+			}
+		}
+
+		if acks >= quorum(n.members) {
+			break
+		}
+
+		if err := n.waitChange(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	n.mu.Unlock()
+	return index, nil
+}
+
+// waitApplied waits for the entries up to the index to be applied.
+func (n *Node) waitApplied(ctx context.Context, index uint64) error {
+	n.mu.Lock()
+	for n.lastApplied < index {
+		if err := n.waitChange(ctx); err != nil {
+			return err
+		}
+	}
+	n.mu.Unlock()
+	return nil
+}
+
+// waitChange waits for the state of the node to change. It should be called
+// with the lock held, which is released while waiting. In case of an error
+// the lock is not held after returning.
+func (n *Node) waitChange(ctx context.Context) error {
+	ch := n.changed
+	n.mu.Unlock()
+
+	select {
+	case <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-n.stopCh:
+		return ErrStopped
+	}
+
+	n.mu.Lock()
+	return nil
+}
+
+// notifyChange wakes up everyone waiting for the state to change.
+func (n *Node) notifyChange() {
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+// run drives the logical clock of the node.
+func (n *Node) run() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.cfg.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stopCh:
+			return
+		case <-ticker.C:
+			n.tick()
+		}
+	}
+}
+
+// tick advances the logical clock of the node.
+func (n *Node) tick() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.stopped {
+		return
+	}
+
+	n.electionElapsed++
+
+	if n.state != Leader {
+		if n.electionElapsed >= n.electionTimeout {
+			n.campaign(true)
+		}
+		return
+	}
+
+	n.heartbeatElapsed++
+	if n.heartbeatElapsed >= n.cfg.HeartbeatTicks {
+		n.broadcast()
+	}
+
+	// Step down if the leader has not heard from a majority of the members,
+	// so clients can find the new leader quickly.
+	if n.electionElapsed >= n.cfg.ElectionTicks {
+		n.electionElapsed = 0
+		if !n.hasQuorumContact() {
+			n.becomeFollower(n.term, "")
+		}
+	}
+}
+
+// resetElectionTimeout resets the election timer with a new random timeout.
+func (n *Node) resetElectionTimeout() {
+	n.electionElapsed = 0
+	n.electionTimeout = n.cfg.ElectionTicks + n.rand.Intn(n.cfg.ElectionTicks)
+}
+
+// campaign starts an election.
+//
+// A pre-vote is held before the actual election, which does not increase the
+// term. This keeps a node which cannot win the election, e.g., because it is
+// partitioned, from disrupting the cluster with its higher term.
+func (n *Node) campaign(preVote bool) {
+	n.resetElectionTimeout()
+
+	if !contains(n.members, n.cfg.ID) {
+		return
+	}
+
+	term := n.term + 1
+	if !preVote {
+		n.state = Candidate
+		n.term = term
+		n.votedFor = n.cfg.ID
+		n.leader = ""
+		n.notifyChange()
+	}
+
+	won := func() {
+		if preVote {
+			n.campaign(false)
+		} else {
+			n.becomeLeader()
+		}
+	}
+
+	votes := 1
+	if votes == quorum(n.members) {
+		won()
+		return
+	}
+
+	req := &VoteRequest{
+		Term:         term,
+		CandidateID:  n.cfg.ID,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.lastTerm(),
+		PreVote:      preVote,
+	}
+
+	for _, id := range n.members {
+		if id == n.cfg.ID {
+			continue
+		}
+
+		go func(id string) {
+			resp, err := n.cfg.Transport.RequestVote(id, req)
+			if err != nil {
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+
+			if n.stopped {
+				return
+			}
+
+			if resp.Term > n.term && !resp.Granted {
+				n.becomeFollower(resp.Term, "")
+				return
+			}
+
+			if preVote && (n.state == Leader || n.term+1 != req.Term) {
+				return
+			}
+			if !preVote && (n.state != Candidate || n.term != req.Term) {
+				return
+			}
+
+			if !resp.Granted {
+				return
+			}
+
+			votes++
+			if votes == quorum(n.members) {
+				won()
+			}
+		}(id)
+	}
+}
+
+// becomeFollower steps down to a follower in the term.
+func (n *Node) becomeFollower(term uint64, leader string) {
+	if n.state == Leader {
+		n.stopPeers()
+	}
+
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+	}
+
+	n.state = Follower
+	n.leader = leader
+	n.resetElectionTimeout()
+	n.notifyChange()
+}
+
+// becomeLeader makes the node the leader of the cluster.
+func (n *Node) becomeLeader() {
+	n.state = Leader
+	n.leader = n.cfg.ID
+	n.heartbeatElapsed = 0
+	n.electionElapsed = 0
+	n.peers = make(map[string]*peer)
+	n.syncPeers()
+	n.notifyChange()
+
+	n.log = append(n.log, Entry{Index: n.lastIndex() + 1, Term: n.term, Kind: EntryNoop})
+	n.maybeCommit()
+	n.broadcast()
+}
+
+// maybeCommit advances the commit index of the leader to the highest index
+// replicated on a majority of the members.
+func (n *Node) maybeCommit() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if term, _ := n.termAt(index); term != n.term {
+			// Entries from previous terms are committed indirectly.
+			return
+		}
+
+		replicated := 0
+		for _, id := range n.members {
+			if id == n.cfg.ID {
+				replicated++
+			} else if p, ok := n.peers[id]; ok && p.matchIndex >= index {
+				replicated++
+			}
+		}
+
+		if replicated >= quorum(n.members) {
+			n.setCommitIndex(index)
+			return
+		}
+	}
+}
+
+// setCommitIndex updates the commit index and notifies the applier.
+func (n *Node) setCommitIndex(index uint64) {
+	if index <= n.commitIndex {
+		return
+	}
+
+	n.commitIndex = index
+	n.applyCond.Signal()
+}
+
+// hasQuorumContact tells if the leader has heard from a majority of the
+// members within the election timeout.
+func (n *Node) hasQuorumContact() bool {
+	timeout := time.Duration(n.cfg.ElectionTicks) * n.cfg.TickInterval
+
+	contacts := 0
+	for _, id := range n.members {
+		if id == n.cfg.ID {
+			contacts++
+		} else if p, ok := n.peers[id]; ok && time.Since(p.lastContact) < timeout {
+			contacts++
+		}
+	}
+
+	return contacts >= quorum(n.members)
+}
+
+// applier applies the committed entries to the store.
+func (n *Node) applier() {
+	defer n.wg.Done()
+
+	for {
+		n.mu.Lock()
+		for !n.stopped && n.lastApplied >= n.commitIndex {
+			n.applyCond.Wait()
+		}
+
+		if n.stopped {
+			n.mu.Unlock()
+			return
+		}
+
+		if n.lastApplied < n.baseIndex() {
+			n.applySnapshot()
+			continue
+		}
+
+		entries := n.entries(n.lastApplied+1, n.commitIndex)
+		n.mu.Unlock()
+
+		for i := range entries {
+			e := &entries[i]
+
+			var r result
+			if e.Kind == EntryCommand {
+				r.res, r.err = e.Command.apply(n.store)
+			}
+
+			n.mu.Lock()
+			n.applied(e, r)
+			n.mu.Unlock()
+		}
+
+		n.maybeSnapshot()
+	}
+}
+
+// applied marks the entry as applied. The lock should be held.
+func (n *Node) applied(e *Entry, r result) {
+	if e.Index <= n.lastApplied {
+		// A snapshot was restored in the meantime.
+		return
+	}
+
+	n.lastApplied = e.Index
+
+	if w, ok := n.waiters[e.Index]; ok {
+		delete(n.waiters, e.Index)
+		if w.term != e.Term {
+			r = result{err: ErrLeadershipLost}
+		}
+		w.ch <- r
+	}
+
+	// The leader steps down once it is removed from the cluster.
+	if e.Kind == EntryMembers && n.state == Leader && !contains(n.members, n.cfg.ID) {
+		n.becomeFollower(n.term, "")
+	}
+
+	n.notifyChange()
+}
+
+// applySnapshot restores the store from the snapshot. It is called with the
+// lock held, which is released before returning.
+func (n *Node) applySnapshot() {
+	snap := n.snapshot
+	n.mu.Unlock()
+
+	err := restore(n.store, snap.Data)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err != nil {
+		// There's nothing better to do with a snapshot that cannot be
+		// restored. Discarding it would leave the store inconsistent.
+		panic(fmt.Errorf("raft: cannot restore snapshot at index %d: %v", snap.Index, err))
+	}
+
+	for index, w := range n.waiters {
+		if index <= snap.Index {
+			delete(n.waiters, index)
+			w.ch <- result{err: ErrLeadershipLost}
+		}
+	}
+
+	if snap.Index > n.lastApplied {
+		n.lastApplied = snap.Index
+	}
+	n.notifyChange()
+}
+
+// maybeSnapshot compacts the log if enough entries have been applied since
+// the last snapshot.
+func (n *Node) maybeSnapshot() {
+	n.mu.Lock()
+	index := n.lastApplied
+	if index < n.baseIndex()+uint64(n.cfg.SnapshotThreshold) {
+		n.mu.Unlock()
+		return
+	}
+	n.mu.Unlock()
+
+	// The applier is the only one which modifies the store, so the store is
+	// at the state of the last applied entry.
+	data, err := n.store.Export()
+	if err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	if index > n.baseIndex() {
+		term, _ := n.termAt(index)
+		n.compact(snapshot{
+			Index:   index,
+			Term:    term,
+			Members: n.membersAt(index),
+			Data:    data,
+		})
+	}
+	n.mu.Unlock()
+}
+
+// RequestVote implements the Handler interface.
+func (n *Node) RequestVote(req *VoteRequest) (*VoteResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.stopped {
+		return nil, ErrStopped
+	}
+
+	resp := &VoteResponse{Term: n.term}
+	if req.Term < n.term {
+		return resp, nil
+	}
+
+	// Ignore the vote request if the leader is known to be alive, so that the
+	// members removed from the cluster do not disrupt it.
+	if req.Term > n.term && n.leader != "" {
+		timeout := time.Duration(n.cfg.ElectionTicks) * n.cfg.TickInterval
+		if n.state == Leader || time.Since(n.lastHeard) < timeout {
+			return resp, nil
+		}
+	}
+
+	upToDate := req.LastLogTerm > n.lastTerm() ||
+		(req.LastLogTerm == n.lastTerm() && req.LastLogIndex >= n.lastIndex())
+
+	// Pre-votes do not change the state of the node.
+	if req.PreVote {
+		resp.Granted = req.Term > n.term && upToDate
+		return resp, nil
+	}
+
+	if req.Term > n.term {
+		n.becomeFollower(req.Term, "")
+	}
+	resp.Term = n.term
+
+	if (n.votedFor == "" || n.votedFor == req.CandidateID) && upToDate {
+		n.votedFor = req.CandidateID
+		n.resetElectionTimeout()
+		resp.Granted = true
+	}
+
+	return resp, nil
+}
+
+// AppendEntries implements the Handler interface.
+func (n *Node) AppendEntries(req *AppendRequest) (*AppendResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.stopped {
+		return nil, ErrStopped
+	}
+
+	resp := &AppendResponse{Term: n.term}
+	if req.Term < n.term {
+		return resp, nil
+	}
+
+	n.heardFrom(req.Term, req.LeaderID)
+	resp.Term = n.term
+
+	prevIndex, prevTerm, entries := req.PrevLogIndex, req.PrevLogTerm, req.Entries
+	lastNew := req.PrevLogIndex + uint64(len(req.Entries))
+
+	// The entries up to the snapshot are already committed, so skip them.
+	if base := n.baseIndex(); prevIndex < base {
+		skip := base - prevIndex
+		if uint64(len(entries)) <= skip {
+			entries = nil
+		} else {
+			entries = entries[skip:]
+		}
+		prevIndex, prevTerm = base, n.log[0].Term
+	}
+
+	if prevIndex > n.lastIndex() {
+		resp.ConflictIndex = n.lastIndex() + 1
+		return resp, nil
+	}
+
+	if term, _ := n.termAt(prevIndex); term != prevTerm {
+		// Skip all the entries of the conflicting term.
+		index := prevIndex
+		for index-1 > n.baseIndex() {
+			if t, _ := n.termAt(index - 1); t != term {
+				break
+			}
+			index--
+		}
+
+		resp.ConflictIndex = index
+		return resp, nil
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		if e.Index <= n.lastIndex() {
+			if term, _ := n.termAt(e.Index); term == e.Term {
+				continue
+			}
+			n.truncate(e.Index - 1)
+		}
+
+		n.log = append(n.log, entries[i:]...)
+		n.members = n.membersAt(n.lastIndex())
+		break
+	}
+
+	if req.LeaderCommit > n.commitIndex {
+		index := req.LeaderCommit
+		if lastNew < index {
+			index = lastNew
+		}
+		n.setCommitIndex(index)
+	}
+
+	resp.Success = true
+	return resp, nil
+}
+
+// InstallSnapshot implements the Handler interface.
+func (n *Node) InstallSnapshot(req *SnapshotRequest) (*SnapshotResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.stopped {
+		return nil, ErrStopped
+	}
+
+	resp := &SnapshotResponse{Term: n.term}
+	if req.Term < n.term {
+		return resp, nil
+	}
+
+	n.heardFrom(req.Term, req.LeaderID)
+	resp.Term = n.term
+
+	if req.LastIndex <= n.commitIndex {
+		return resp, nil
+	}
+
+	n.compact(snapshot{
+		Index:   req.LastIndex,
+		Term:    req.LastTerm,
+		Members: req.Members,
+		Data:    req.Data,
+	})
+	n.members = n.membersAt(n.lastIndex())
+	n.setCommitIndex(req.LastIndex)
+
+	return resp, nil
+}
+
+// heardFrom updates the state of the node on receiving a request from the
+// leader of the term.
+func (n *Node) heardFrom(term uint64, leader string) {
+	if term > n.term || n.state != Follower {
+		n.becomeFollower(term, leader)
+	}
+
+	if n.leader != leader {
+		n.leader = leader
+		n.notifyChange()
+	}
+
+	n.lastHeard = time.Now()
+	n.resetElectionTimeout()
+}
+
+// quorum returns the number of members which form a majority.
+func quorum(members []string) int {
+	return len(members)/2 + 1
+}
+
+// contains tells if the ID is one of the members.
+func contains(members []string, id string) bool {
+	for _, m := range members {
+		if m == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Interface guard.
+var _ Handler = (*Node)(nil)
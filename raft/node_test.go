@@ -0,0 +1,368 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package raft
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/list"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// testTimeout is the maximum time to wait for the cluster to reach a state.
+const testTimeout = 5 * time.Second
+
+// testCluster is a cluster of nodes running in-process.
+type testCluster struct {
+	t         *testing.T
+	network   *Network
+	nodes     map[string]*Node
+	threshold int
+}
+
+// newTestCluster creates a cluster with the nodes with the IDs.
+func newTestCluster(t *testing.T, threshold int, ids ...string) *testCluster {
+	c := &testCluster{
+		t:         t,
+		network:   NewNetwork(),
+		nodes:     make(map[string]*Node),
+		threshold: threshold,
+	}
+
+	for _, id := range ids {
+		c.addNode(id, ids)
+	}
+
+	t.Cleanup(c.stop)
+	return c
+}
+
+// addNode creates a node which is a part of the cluster.
+func (c *testCluster) addNode(id string, members []string) *Node {
+	node, err := NewNode(Config{
+		ID:                id,
+		Members:           members,
+		Store:             kiwi.NewStore(),
+		Transport:         c.network.Transport(id),
+		TickInterval:      2 * time.Millisecond,
+		ElectionTicks:     15,
+		HeartbeatTicks:    2,
+		SnapshotThreshold: c.threshold,
+		MaxAppendEntries:  8,
+	})
+	if err != nil {
+		c.t.Fatalf("cannot create node %q: %v", id, err)
+	}
+
+	c.nodes[id] = node
+	return node
+}
+
+// stop stops all the nodes.
+func (c *testCluster) stop() {
+	for _, node := range c.nodes {
+		node.Stop()
+	}
+}
+
+// leader waits for one of the nodes with the IDs to become the leader.
+func (c *testCluster) leader(ids ...string) *Node {
+	var leader *Node
+
+	c.eventually("leader to be elected", func() bool {
+		leader = nil
+		for _, id := range ids {
+			if c.nodes[id].Status().State == Leader {
+				if leader != nil {
+					return false
+				}
+				leader = c.nodes[id]
+			}
+		}
+		return leader != nil
+	})
+
+	return leader
+}
+
+// eventually waits for the condition to be true.
+func (c *testCluster) eventually(what string, cond func() bool) {
+	c.t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			c.t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// converged waits for the stores of the nodes with the IDs to have the same
+// data as the store of the leader.
+func (c *testCluster) converged(leader *Node, ids ...string) {
+	c.t.Helper()
+
+	c.eventually("stores to converge", func() bool {
+		expected, err := leader.store.Export()
+		if err != nil {
+			c.t.Fatalf("cannot export store of %q: %v", leader.ID(), err)
+		}
+
+		for _, id := range ids {
+			data, err := c.nodes[id].store.Export()
+			if err != nil {
+				c.t.Fatalf("cannot export store of %q: %v", id, err)
+			}
+			if !bytes.Equal(data, expected) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// others returns the IDs except the one given.
+func others(ids []string, except ...string) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !contains(except, id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// getStr reads the string stored in the key using a linearizable read.
+func getStr(ctx context.Context, node *Node, key string) (string, error) {
+	var s string
+
+	err := node.Read(ctx, func(store *kiwi.Store) error {
+		v, err := store.Do(key, str.Get)
+		if err != nil {
+			return err
+		}
+		s = v.(string)
+		return nil
+	})
+
+	return s, err
+}
+
+func TestNode_Replication(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+	c := newTestCluster(t, 1000, ids...)
+	leader := c.leader(ids...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := leader.AddKey(ctx, "key", str.Type); err != nil {
+		t.Fatalf("could not AddKey: %v", err)
+	}
+
+	if err := leader.AddKey(ctx, "key", str.Type); !errors.Is(err, kiwi.ErrKeyExists) {
+		t.Errorf("expected ErrKeyExists while adding key again; got %v", err)
+	}
+
+	v, err := leader.Do(ctx, "key", str.Update, "hello")
+	if err != nil {
+		t.Fatalf("could not Do UPDATE: %v", err)
+	}
+	if v != "hello" {
+		t.Errorf("expected UPDATE to return %q; got %v", "hello", v)
+	}
+
+	s, err := getStr(ctx, leader, "key")
+	if err != nil {
+		t.Fatalf("could not Read: %v", err)
+	}
+	if s != "hello" {
+		t.Errorf("expected read %q; got %q", "hello", s)
+	}
+
+	for _, id := range others(ids, leader.ID()) {
+		node := c.nodes[id]
+
+		if _, err := node.Do(ctx, "key", str.Update, "bye"); !errors.Is(err, ErrNotLeader) {
+			t.Errorf("expected ErrNotLeader on Do from follower; got %v", err)
+		}
+		if _, err := getStr(ctx, node, "key"); !errors.Is(err, ErrNotLeader) {
+			t.Errorf("expected ErrNotLeader on Read from follower; got %v", err)
+		}
+		if st := node.Status(); st.Leader != leader.ID() {
+			t.Errorf("expected follower %q to know leader %q; got %q", id, leader.ID(), st.Leader)
+		}
+	}
+
+	c.converged(leader, ids...)
+
+	if err := leader.DeleteKey(ctx, "key"); err != nil {
+		t.Fatalf("could not DeleteKey: %v", err)
+	}
+	c.converged(leader, ids...)
+
+	if c.nodes[ids[0]].store.KeyExists("key") {
+		t.Errorf("expected key to be deleted from all the stores")
+	}
+}
+
+func TestNode_Partition(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e"}
+	c := newTestCluster(t, 1000, ids...)
+	oldLeader := c.leader(ids...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := oldLeader.AddKey(ctx, "key", str.Type); err != nil {
+		t.Fatalf("could not AddKey: %v", err)
+	}
+	if _, err := oldLeader.Do(ctx, "key", str.Update, "before"); err != nil {
+		t.Fatalf("could not Do UPDATE: %v", err)
+	}
+
+	// isolate the leader with one of the followers in the minority
+	minority := []string{oldLeader.ID(), others(ids, oldLeader.ID())[0]}
+	majority := others(ids, minority...)
+	c.network.Partition(minority, majority)
+
+	newLeader := c.leader(majority...)
+
+	if _, err := newLeader.Do(ctx, "key", str.Update, "after"); err != nil {
+		t.Fatalf("could not Do UPDATE on new leader: %v", err)
+	}
+
+	// the old leader cannot commit anything or serve linearizable reads
+	shortCtx, shortCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer shortCancel()
+
+	if _, err := oldLeader.Do(shortCtx, "key", str.Update, "lost"); err == nil {
+		t.Errorf("expected error on Do from the old leader in minority; got nil")
+	}
+	if s, err := getStr(shortCtx, oldLeader, "key"); err == nil {
+		t.Errorf("expected error on Read from the old leader in minority; got %q", s)
+	}
+
+	s, err := getStr(ctx, newLeader, "key")
+	if err != nil {
+		t.Fatalf("could not Read from new leader: %v", err)
+	}
+	if s != "after" {
+		t.Errorf("expected read %q; got %q", "after", s)
+	}
+
+	c.network.Heal()
+
+	leader := c.leader(ids...)
+	c.converged(leader, ids...)
+
+	if s, err = getStr(ctx, leader, "key"); err != nil {
+		t.Fatalf("could not Read after healing: %v", err)
+	}
+	if s != "after" {
+		t.Errorf("expected read after healing %q; got %q", "after", s)
+	}
+}
+
+func TestNode_Snapshot(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+	c := newTestCluster(t, 10, ids...)
+	leader := c.leader(ids...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := leader.AddKey(ctx, "list", list.Type); err != nil {
+		t.Fatalf("could not AddKey: %v", err)
+	}
+
+	// cut a follower off so that it falls behind the snapshot
+	lagging := others(ids, leader.ID())[0]
+	c.network.Partition([]string{lagging}, others(ids, lagging))
+
+	for i := 0; i < 50; i++ {
+		if _, err := leader.Do(ctx, "list", list.Append, fmt.Sprint(i)); err != nil {
+			t.Fatalf("could not Do APPEND: %v", err)
+		}
+	}
+
+	if st := leader.Status(); st.SnapshotIndex == 0 {
+		t.Errorf("expected leader to have taken a snapshot")
+	}
+
+	c.network.Heal()
+
+	leader = c.leader(ids...)
+	c.converged(leader, ids...)
+
+	if st := c.nodes[lagging].Status(); st.SnapshotIndex == 0 {
+		t.Errorf("expected lagging node to have installed a snapshot")
+	}
+
+	v, err := leader.Do(ctx, "list", list.Len)
+	if err != nil {
+		t.Fatalf("could not Do LEN: %v", err)
+	}
+	if v != 50 {
+		t.Errorf("expected list of length 50; got %v", v)
+	}
+}
+
+func TestNode_Members(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+	c := newTestCluster(t, 10, ids...)
+	leader := c.leader(ids...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := leader.AddKey(ctx, "list", list.Type); err != nil {
+		t.Fatalf("could not AddKey: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := leader.Do(ctx, "list", list.Append, fmt.Sprint(i)); err != nil {
+			t.Fatalf("could not Do APPEND: %v", err)
+		}
+	}
+
+	c.addNode("d", nil)
+	if err := leader.AddMember(ctx, "d"); err != nil {
+		t.Fatalf("could not AddMember: %v", err)
+	}
+	if err := leader.AddMember(ctx, "d"); !errors.Is(err, ErrInvalidMember) {
+		t.Errorf("expected ErrInvalidMember while adding member again; got %v", err)
+	}
+
+	ids = append(ids, "d")
+	c.converged(leader, ids...)
+
+	if members := c.nodes["d"].Status().Members; len(members) != 4 {
+		t.Errorf("expected new node to know 4 members; got %v", members)
+	}
+
+	// remove the leader itself
+	if err := leader.RemoveMember(ctx, leader.ID()); err != nil {
+		t.Fatalf("could not RemoveMember: %v", err)
+	}
+
+	remaining := others(ids, leader.ID())
+	newLeader := c.leader(remaining...)
+
+	if _, err := newLeader.Do(ctx, "list", list.Append, "new"); err != nil {
+		t.Fatalf("could not Do APPEND on new leader: %v", err)
+	}
+	c.converged(newLeader, remaining...)
+
+	if st := leader.Status(); st.State == Leader {
+		t.Errorf("expected removed leader to step down")
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package raft
+
+import "time"
+
+// peer is the state the leader keeps for each of the other members.
+type peer struct {
+	id         string
+	nextIndex  uint64
+	matchIndex uint64
+
+	// ackSeq is the highest read sequence acknowledged by the peer.
+	ackSeq      uint64
+	lastContact time.Time
+
+	trigger chan struct{}
+	stop    chan struct{}
+}
+
+// syncPeers starts replicating to the new members and stops replicating to
+// the removed ones. The lock should be held.
+func (n *Node) syncPeers() {
+	for id, p := range n.peers {
+		if !contains(n.members, id) {
+			close(p.stop)
+			delete(n.peers, id)
+		}
+	}
+
+	for _, id := range n.members {
+		if _, ok := n.peers[id]; ok || id == n.cfg.ID {
+			continue
+		}
+
+		p := &peer{
+			id:          id,
+			nextIndex:   n.lastIndex() + 1,
+			lastContact: time.Now(),
+			trigger:     make(chan struct{}, 1),
+			stop:        make(chan struct{}),
+		}
+		n.peers[id] = p
+
+		n.wg.Add(1)
+		go n.replicate(p)
+	}
+}
+
+// stopPeers stops replicating to all the peers. The lock should be held.
+func (n *Node) stopPeers() {
+	for id, p := range n.peers {
+		close(p.stop)
+		delete(n.peers, id)
+	}
+}
+
+// broadcast triggers replication to all the peers. The lock should be held.
+func (n *Node) broadcast() {
+	n.heartbeatElapsed = 0
+
+	for _, p := range n.peers {
+		select {
+		case p.trigger <- struct{}{}:
+		default:
+			// already triggered
+		}
+	}
+}
+
+// replicate sends entries to the peer whenever triggered.
+func (n *Node) replicate(p *peer) {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-p.trigger:
+		}
+
+		for n.sendTo(p) {
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// sendTo sends the next batch of entries (or the snapshot) to the peer. It
+// returns true if more entries should be sent right away.
+func (n *Node) sendTo(p *peer) bool {
+	n.mu.Lock()
+
+	if n.state != Leader || n.peers[p.id] != p {
+		n.mu.Unlock()
+		return false
+	}
+
+	if p.nextIndex <= n.baseIndex() {
+		return n.sendSnapshotTo(p)
+	}
+
+	prevIndex := p.nextIndex - 1
+	prevTerm, _ := n.termAt(prevIndex)
+
+	last := n.lastIndex()
+	if limit := prevIndex + uint64(n.cfg.MaxAppendEntries); last > limit {
+		last = limit
+	}
+
+	req := &AppendRequest{
+		Term:         n.term,
+		LeaderID:     n.cfg.ID,
+		PrevLogIndex: prevIndex,
+		PrevLogTerm:  prevTerm,
+		Entries:      n.entries(p.nextIndex, last),
+		LeaderCommit: n.commitIndex,
+	}
+	seq := n.readSeq
+	n.mu.Unlock()
+
+	resp, err := n.cfg.Transport.AppendEntries(p.id, req)
+	if err != nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.handleResponse(p, req.Term, resp.Term, seq) {
+		return false
+	}
+
+	if !resp.Success {
+		next := resp.ConflictIndex
+		if next < 1 {
+			next = 1
+		}
+		if next > prevIndex {
+			// The follower did not report a useful index.
+			next = prevIndex
+		}
+		p.nextIndex = next
+		return true
+	}
+
+	n.matched(p, prevIndex+uint64(len(req.Entries)))
+	return p.nextIndex <= n.lastIndex()
+}
+
+// sendSnapshotTo sends the snapshot to the peer. It is called with the lock
+// held, which is released before returning.
+func (n *Node) sendSnapshotTo(p *peer) bool {
+	req := &SnapshotRequest{
+		Term:      n.term,
+		LeaderID:  n.cfg.ID,
+		LastIndex: n.snapshot.Index,
+		LastTerm:  n.snapshot.Term,
+		Members:   n.snapshot.Members,
+		Data:      n.snapshot.Data,
+	}
+	seq := n.readSeq
+	n.mu.Unlock()
+
+	resp, err := n.cfg.Transport.InstallSnapshot(p.id, req)
+	if err != nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.handleResponse(p, req.Term, resp.Term, seq) {
+		return false
+	}
+
+	n.matched(p, req.LastIndex)
+	return p.nextIndex <= n.lastIndex()
+}
+
+// handleResponse updates the state of the leader from the response of the
+// peer. It returns false if the response should not be processed further.
+func (n *Node) handleResponse(p *peer, reqTerm, respTerm, seq uint64) bool {
+	if respTerm > n.term {
+		n.becomeFollower(respTerm, "")
+		return false
+	}
+
+	if n.state != Leader || n.term != reqTerm || n.peers[p.id] != p {
+		return false
+	}
+
+	p.lastContact = time.Now()
+	if seq > p.ackSeq {
+		p.ackSeq = seq
+		n.notifyChange()
+	}
+
+	return true
+}
+
+// matched records that the log of the peer matches with the leader up to
+// the index.
+func (n *Node) matched(p *peer, index uint64) {
+	if index > p.matchIndex {
+		p.matchIndex = index
+	}
+	p.nextIndex = p.matchIndex + 1
+
+	n.maybeCommit()
+}
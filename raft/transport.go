@@ -0,0 +1,211 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package raft
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ErrUnreachable is returned by the transport when the node cannot be reached.
+var ErrUnreachable = fmt.Errorf("node unreachable")
+
+// newUnreachableErr creates an error for when the node cannot be reached.
+func newUnreachableErr(id string) error {
+	return fmt.Errorf("%w: %s", ErrUnreachable, id)
+}
+
+// VoteRequest is sent by candidates to gather votes.
+type VoteRequest struct {
+	Term         uint64
+	CandidateID  string
+	LastLogIndex uint64
+	LastLogTerm  uint64
+
+	// PreVote tells if the candidate is only checking whether it can win
+	// the election before increasing its term.
+	PreVote bool
+}
+
+// VoteResponse is the response to a VoteRequest.
+type VoteResponse struct {
+	Term    uint64
+	Granted bool
+}
+
+// AppendRequest is sent by the leader to replicate entries. It is also used as
+// a heartbeat when there are no entries.
+type AppendRequest struct {
+	Term         uint64
+	LeaderID     string
+	PrevLogIndex uint64
+	PrevLogTerm  uint64
+	Entries      []Entry
+	LeaderCommit uint64
+}
+
+// AppendResponse is the response to an AppendRequest.
+type AppendResponse struct {
+	Term    uint64
+	Success bool
+
+	// ConflictIndex is the index from where the leader should retry when
+	// the request was not successful.
+	ConflictIndex uint64
+}
+
+// SnapshotRequest is sent by the leader to members that are too far behind
+// to be sent the entries of the log.
+type SnapshotRequest struct {
+	Term      uint64
+	LeaderID  string
+	LastIndex uint64
+	LastTerm  uint64
+	Members   []string
+	Data      json.RawMessage
+}
+
+// SnapshotResponse is the response to a SnapshotRequest.
+type SnapshotResponse struct {
+	Term uint64
+}
+
+// Handler serves the requests sent to a node.
+//
+// It is implemented by Node.
+type Handler interface {
+	// RequestVote handles a VoteRequest.
+	RequestVote(req *VoteRequest) (*VoteResponse, error)
+
+	// AppendEntries handles an AppendRequest.
+	AppendEntries(req *AppendRequest) (*AppendResponse, error)
+
+	// InstallSnapshot handles a SnapshotRequest.
+	InstallSnapshot(req *SnapshotRequest) (*SnapshotResponse, error)
+}
+
+// Transport sends requests to other nodes of the cluster.
+type Transport interface {
+	// Register sets the handler which serves requests sent to the local node.
+	Register(h Handler)
+
+	// RequestVote sends a VoteRequest to the node with the ID.
+	RequestVote(id string, req *VoteRequest) (*VoteResponse, error)
+
+	// AppendEntries sends an AppendRequest to the node with the ID.
+	AppendEntries(id string, req *AppendRequest) (*AppendResponse, error)
+
+	// InstallSnapshot sends a SnapshotRequest to the node with the ID.
+	InstallSnapshot(id string, req *SnapshotRequest) (*SnapshotResponse, error)
+}
+
+// Network connects nodes running in the same process.
+//
+// It can simulate network partitions, which makes it useful for tests.
+type Network struct {
+	handlers map[string]Handler
+
+	// groups maps the node IDs to their partitions. Nodes can only talk to
+	// other nodes in the same partition. Nodes not in the map are in the
+	// partition "0".
+	groups map[string]int
+
+	mu sync.RWMutex
+}
+
+// NewNetwork creates a network with no partitions.
+func NewNetwork() *Network {
+	return &Network{
+		handlers: make(map[string]Handler),
+		groups:   make(map[string]int),
+		mu:       sync.RWMutex{},
+	}
+}
+
+// Transport returns the transport for the node with the ID.
+func (nw *Network) Transport(id string) Transport {
+	return &memTransport{id: id, network: nw}
+}
+
+// Partition splits the network so that the nodes in a group can only talk to
+// other nodes of the same group. Nodes which are not in any group can talk to
+// each other. Partition replaces the existing partitions.
+func (nw *Network) Partition(groups ...[]string) {
+	nw.mu.Lock()
+
+	nw.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, id := range group {
+			nw.groups[id] = i + 1
+		}
+	}
+
+	nw.mu.Unlock()
+}
+
+// Heal removes all the partitions from the network.
+func (nw *Network) Heal() {
+	nw.Partition()
+}
+
+// handler returns the handler for "to" if it can be reached from "from".
+func (nw *Network) handler(from, to string) (Handler, error) {
+	nw.mu.RLock()
+	defer nw.mu.RUnlock()
+
+	h, ok := nw.handlers[to]
+	if !ok || nw.groups[from] != nw.groups[to] {
+		return nil, newUnreachableErr(to)
+	}
+
+	return h, nil
+}
+
+// memTransport implements the Transport for a node in a Network.
+type memTransport struct {
+	id      string
+	network *Network
+}
+
+// Register implements the Transport interface.
+func (t *memTransport) Register(h Handler) {
+	t.network.mu.Lock()
+	t.network.handlers[t.id] = h
+	t.network.mu.Unlock()
+}
+
+// RequestVote implements the Transport interface.
+func (t *memTransport) RequestVote(id string, req *VoteRequest) (*VoteResponse, error) {
+	h, err := t.network.handler(t.id, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.RequestVote(req)
+}
+
+// AppendEntries implements the Transport interface.
+func (t *memTransport) AppendEntries(id string, req *AppendRequest) (*AppendResponse, error) {
+	h, err := t.network.handler(t.id, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.AppendEntries(req)
+}
+
+// InstallSnapshot implements the Transport interface.
+func (t *memTransport) InstallSnapshot(id string, req *SnapshotRequest) (*SnapshotResponse, error) {
+	h, err := t.network.handler(t.id, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.InstallSnapshot(req)
+}
+
+// Interface guard.
+var _ Transport = (*memTransport)(nil)
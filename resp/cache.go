@@ -0,0 +1,153 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCacheSize is the number of keys cached by a tracking client if its
+// options do not set it.
+const DefaultCacheSize = 1024
+
+// clientCache is the bounded near-cache of a tracking client, which evicts the
+// least recently used key when it is full.
+type clientCache struct {
+	size int
+
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// pending has a token for each key being loaded, which is removed when
+	// the key is invalidated so that the stale value is not cached.
+	pending map[string]uint64
+	token   uint64
+
+	// disabled is set when the invalidations are no longer received.
+	disabled bool
+
+	hits   uint64
+	misses uint64
+	mu     sync.Mutex
+}
+
+// clientCacheEntry is an entry in the cache. Missing keys are cached as well.
+type clientCacheEntry struct {
+	key    string
+	value  string
+	exists bool
+}
+
+// newClientCache creates a cache of at most size keys.
+func newClientCache(size int) *clientCache {
+	return &clientCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		pending: make(map[string]uint64),
+		mu:      sync.Mutex{},
+	}
+}
+
+// get returns the cached value of the key and whether it exists. If the key is
+// not cached, it returns false for cached along with a token to add it with
+// once loaded.
+func (c *clientCache) get(key string) (value string, exists, cached bool, token uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*clientCacheEntry) //nolint:errcheck
+		c.lru.MoveToFront(e)
+		c.hits++
+		return entry.value, entry.exists, true, 0
+	}
+
+	c.misses++
+	c.token++
+	c.pending[key] = c.token
+	return "", false, false, c.token
+}
+
+// loaded caches the value of the key loaded with the token, unless loading
+// failed or the key was invalidated while loading.
+func (c *clientCache) loaded(key string, token uint64, value string, exists bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending[key] != token {
+		// invalidated while loading, or loaded again meanwhile
+		return
+	}
+	delete(c.pending, key)
+
+	if err != nil || c.disabled {
+		return
+	}
+
+	entry := &clientCacheEntry{key: key, value: value, exists: exists}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	if c.lru.Len() >= c.size {
+		c.evict(c.lru.Back())
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+}
+
+// invalidate evicts the keys from the cache.
+func (c *clientCache) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.pending, key)
+		if e, ok := c.entries[key]; ok {
+			c.evict(e)
+		}
+	}
+}
+
+// flush evicts all the keys from the cache. If disable is true, no key is
+// cached anymore.
+func (c *clientCache) flush(disable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.pending = make(map[string]uint64)
+	c.disabled = c.disabled || disable
+}
+
+// len returns the number of keys in the cache.
+func (c *clientCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// stats returns the number of hits and misses of the cache.
+func (c *clientCache) stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+// evict removes the entry from the cache.
+//
+// It should be called with the cache locked.
+func (c *clientCache) evict(e *list.Element) {
+	entry := e.Value.(*clientCacheEntry) //nolint:errcheck
+	delete(c.entries, entry.key)
+	c.lru.Remove(e)
+}
@@ -0,0 +1,378 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerError is an error replied by the server, like "NOPERM user ...".
+type ServerError string
+
+// Error implements error.
+func (e ServerError) Error() string { return string(e) }
+
+// Code returns the code of the error, like "NOPERM".
+func (e ServerError) Code() string {
+	code := string(e)
+	if i := strings.IndexByte(code, ' '); i >= 0 {
+		code = code[:i]
+	}
+
+	return code
+}
+
+// ClientOpts are the options to connect to a server with.
+type ClientOpts struct {
+	// TLS is the config to connect over TLS with, if not nil. A client
+	// certificate in it authenticates the client as the user named by its
+	// common name when the server verifies it.
+	TLS *tls.Config
+
+	// User and Password authenticate the client after connecting, if the
+	// password is not empty. User defaults to the default user.
+	User     string
+	Password string
+
+	// DialTimeout is the maximum time to connect, including the TLS
+	// handshake. There is no timeout if it is 0.
+	DialTimeout time.Duration
+
+	// Tracking caches the values read with Get in a local cache of at most
+	// CacheSize keys, which defaults to DefaultCacheSize if not positive.
+	// The client opens a second connection receiving the invalidations and
+	// enables "CLIENT TRACKING ON REDIRECT" to it, so that the keys are
+	// evicted from the cache when they change. The cache is flushed and
+	// disabled if the second connection is lost.
+	Tracking  bool
+	CacheSize int
+}
+
+// Client is a connection to a server, which is safe for concurrent use. The
+// commands are sent one by one, each waiting for its reply.
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	mu   sync.Mutex
+
+	// cache, if not nil, caches the keys invalidated by the server through
+	// the invalidations connection, which is read until receiving is closed.
+	cache         *clientCache
+	invalidations *Client
+	receiving     chan struct{}
+}
+
+// Dial connects to the server at the address on the network, e.g., "tcp" or
+// "unix", and authenticates with the options.
+func Dial(network, address string, opts ClientOpts) (*Client, error) {
+	c, err := dial(network, address, opts)
+	if err != nil || !opts.Tracking {
+		return c, err
+	}
+
+	if err := c.startTracking(network, address, opts); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// dial connects to the server and authenticates without tracking.
+func dial(network, address string, opts ClientOpts) (*Client, error) {
+	dialer := &net.Dialer{Timeout: opts.DialTimeout}
+
+	var conn net.Conn
+	var err error
+	if opts.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, network, address, opts.TLS)
+	} else {
+		conn, err = dialer.Dial(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+		mu:   sync.Mutex{},
+	}
+
+	if opts.Password != "" {
+		args := []string{"AUTH", opts.Password}
+		if opts.User != "" {
+			args = []string{"AUTH", opts.User, opts.Password}
+		}
+
+		if _, err := c.Do(args...); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Do sends the command and returns its reply, which is a string, an int64,
+// nil or a []interface{} of those. Error replies are returned as ServerError.
+func (c *Client) Do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _ = c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		writeValue(c.w, arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	v, err := readReply(c.r)
+	if err != nil {
+		return nil, err
+	}
+
+	if e, ok := v.(replyErr); ok {
+		return nil, ServerError(e)
+	}
+
+	return fromReply(v), nil
+}
+
+// Get returns the value of the key, and false if the key does not exist. With
+// tracking, the key is read from the cache if possible.
+func (c *Client) Get(key string) (string, bool, error) {
+	if c.cache == nil {
+		return c.get(key)
+	}
+
+	value, exists, cached, token := c.cache.get(key)
+	if cached {
+		return value, exists, nil
+	}
+
+	value, exists, err := c.get(key)
+	c.cache.loaded(key, token, value, exists, err)
+
+	return value, exists, err
+}
+
+// get executes "GET key".
+func (c *Client) get(key string) (string, bool, error) {
+	v, err := c.Do("GET", key)
+	if err != nil || v == nil {
+		return "", false, err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", false, newReplyTypeErr("GET", v)
+	}
+
+	return s, true, nil
+}
+
+// Set sets the value of the key.
+func (c *Client) Set(key, value string) error {
+	_, err := c.Do("SET", key, value)
+	c.evict(key)
+
+	return err
+}
+
+// Del deletes the keys, returning the number of keys deleted.
+func (c *Client) Del(keys ...string) (int64, error) {
+	n, err := c.doInt(append([]string{"DEL"}, keys...)...)
+	c.evict(keys...)
+
+	return n, err
+}
+
+// Publish publishes the message to the channel, returning the number of
+// subscribers it is sent to.
+func (c *Client) Publish(channel, message string) (int64, error) {
+	return c.doInt("PUBLISH", channel, message)
+}
+
+// WhoAmI returns the user the client is authenticated as.
+func (c *Client) WhoAmI() (string, error) {
+	v, err := c.Do("ACL", "WHOAMI")
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", newReplyTypeErr("ACL WHOAMI", v)
+	}
+
+	return s, nil
+}
+
+// Close closes the connection, and the invalidations connection if tracking.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+
+	if c.invalidations != nil {
+		_ = c.invalidations.Close()
+		<-c.receiving
+	}
+
+	return err
+}
+
+// startTracking connects the invalidations connection, subscribes it to the
+// invalidations and enables tracking with REDIRECT to it.
+func (c *Client) startTracking(network, address string, opts ClientOpts) error {
+	inv, err := dial(network, address, opts)
+	if err != nil {
+		return err
+	}
+
+	id, err := inv.doInt("CLIENT", "ID")
+	if err == nil {
+		_, err = inv.Do("SUBSCRIBE", InvalidateChannel)
+	}
+	if err != nil {
+		_ = inv.Close()
+		return err
+	}
+
+	size := opts.CacheSize
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	c.cache = newClientCache(size)
+	c.invalidations = inv
+	c.receiving = make(chan struct{})
+	go c.receiveInvalidations()
+
+	_, err = c.Do("CLIENT", "TRACKING", "ON", "REDIRECT", strconv.FormatInt(id, 10))
+	return err
+}
+
+// receiveInvalidations evicts the keys pushed to the invalidations connection
+// from the cache until it is closed, after which the cache is disabled.
+func (c *Client) receiveInvalidations() {
+	defer close(c.receiving)
+
+	for {
+		v, err := readReply(c.invalidations.r)
+		if err != nil {
+			// the keys cannot be invalidated anymore
+			c.cache.flush(true)
+			return
+		}
+
+		msg, ok := v.([]interface{})
+		if !ok || len(msg) != 3 || msg[0] != "message" || msg[1] != InvalidateChannel {
+			continue
+		}
+
+		keys, ok := msg[2].([]interface{})
+		if !ok {
+			// a null array invalidates all the keys
+			c.cache.flush(false)
+			continue
+		}
+
+		for _, key := range keys {
+			if key, ok := key.(string); ok {
+				c.cache.invalidate(key)
+			}
+		}
+	}
+}
+
+// evict evicts the keys changed by the client from the cache, if tracking,
+// without waiting for the invalidations.
+func (c *Client) evict(keys ...string) {
+	if c.cache != nil {
+		c.cache.invalidate(keys...)
+	}
+}
+
+// doInt sends the command whose reply is an integer.
+func (c *Client) doInt(args ...string) (int64, error) {
+	v, err := c.Do(args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int64)
+	if !ok {
+		return 0, newReplyTypeErr(args[0], v)
+	}
+
+	return n, nil
+}
+
+// LoadClientTLS creates the config to connect over TLS with. The server is
+// verified with the CA certificates in the PEM file at caFile, or with the CAs
+// of the system if it is empty. The certificate and key in the PEM files at
+// certFile and keyFile, if not empty, are presented to the server for mutual
+// TLS.
+func LoadClientTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %q", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// fromReply converts the simple strings in the reply to strings and the errors
+// to ServerError.
+func fromReply(v interface{}) interface{} {
+	switch v := v.(type) {
+	case simple:
+		return string(v)
+	case replyErr:
+		return ServerError(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = fromReply(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// newReplyTypeErr creates an error for a reply of an unexpected type.
+func newReplyTypeErr(cmd string, v interface{}) error {
+	return fmt.Errorf("%w: unexpected reply %T to %s", ErrProtocol, v, cmd)
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/acl"
+	"github.com/sdslabs/kiwi/tracking"
+)
+
+func TestClient(t *testing.T) {
+	a, err := acl.Load(strings.NewReader("user default off\nuser app on >secret (~* +* %*)\n"))
+	if err != nil {
+		t.Fatalf("could not load ACL: %v", err)
+	}
+
+	s := NewServer(kiwi.NewStore())
+	s.ACL = a
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go s.Serve(l) //nolint:errcheck
+
+	defer s.Close() //nolint:errcheck
+
+	_, err = Dial("tcp", l.Addr().String(), ClientOpts{User: "app", Password: "wrong"})
+	var serr ServerError
+	if !errors.As(err, &serr) || serr.Code() != "WRONGPASS" {
+		t.Errorf("expected WRONGPASS error with wrong password; got %v", err)
+	}
+
+	c, err := Dial("tcp", l.Addr().String(), ClientOpts{User: "app", Password: "secret"})
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer c.Close() //nolint:errcheck
+
+	if user, err := c.WhoAmI(); err != nil || user != "app" {
+		t.Errorf("expected to be authenticated as %q; got %q (%v)", "app", user, err)
+	}
+
+	if _, ok, err := c.Get("a"); err != nil || ok {
+		t.Errorf("expected missing key; got %v (%v)", ok, err)
+	}
+	if err := c.Set("a", "hello"); err != nil {
+		t.Errorf("could not Set: %v", err)
+	}
+	if v, ok, err := c.Get("a"); err != nil || !ok || v != "hello" {
+		t.Errorf("expected %q; got %q, %v (%v)", "hello", v, ok, err)
+	}
+	if n, err := c.Del("a", "b"); err != nil || n != 1 {
+		t.Errorf("expected 1 key to be deleted; got %d (%v)", n, err)
+	}
+	if n, err := c.Publish("news", "hi"); err != nil || n != 0 {
+		t.Errorf("expected no subscribers; got %d (%v)", n, err)
+	}
+
+	if v, err := c.Do("PING"); err != nil || v != "PONG" {
+		t.Errorf("expected PONG; got %#v (%v)", v, err)
+	}
+	if v, err := c.Do("ACL", "LIST"); err != nil || !reflect.DeepEqual(v, []interface{}{
+		"user app on #2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b (~* +* %*)",
+		"user default off",
+	}) {
+		t.Errorf("unexpected ACL LIST: %#v (%v)", v, err)
+	}
+	if _, err := c.Do("BOGUS"); !errors.As(err, &serr) || serr.Code() != "ERR" {
+		t.Errorf("expected ERR for unknown command; got %v", err)
+	}
+}
+
+func TestClient_Tracking(t *testing.T) {
+	store := kiwi.NewStore()
+	s := NewServer(store)
+	s.Tracker = tracking.NewTracker(store)
+	defer s.Tracker.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go s.Serve(l) //nolint:errcheck
+
+	defer s.Close() //nolint:errcheck
+
+	c, err := Dial("tcp", l.Addr().String(), ClientOpts{Tracking: true, CacheSize: 2})
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer c.Close() //nolint:errcheck
+
+	w, err := Dial("tcp", l.Addr().String(), ClientOpts{})
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer w.Close() //nolint:errcheck
+
+	get := func(key, expected string) {
+		t.Helper()
+
+		if v, _, err := c.Get(key); err != nil || v != expected {
+			t.Errorf("expected %q for %q; got %q (%v)", expected, key, v, err)
+		}
+	}
+
+	checkStats := func(hits, misses uint64) {
+		t.Helper()
+
+		if h, m := c.cache.stats(); h != hits || m != misses {
+			t.Errorf("expected %d hits and %d misses; got %d and %d", hits, misses, h, m)
+		}
+	}
+
+	// missing keys are cached as well
+	if _, ok, err := c.Get("a"); err != nil || ok {
+		t.Errorf("expected missing key; got %v (%v)", ok, err)
+	}
+	if _, ok, err := c.Get("a"); err != nil || ok {
+		t.Errorf("expected missing key; got %v (%v)", ok, err)
+	}
+	checkStats(1, 1)
+
+	// the change by another client is pushed to the invalidations connection
+	if err := w.Set("a", "x"); err != nil {
+		t.Fatalf("could not Set: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); c.cache.len() > 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the key to be invalidated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	get("a", "x")
+	get("a", "x")
+	checkStats(2, 2)
+
+	// own changes evict the key right away
+	if err := c.Set("a", "y"); err != nil {
+		t.Fatalf("could not Set: %v", err)
+	}
+	get("a", "y")
+	checkStats(2, 3)
+
+	// the least recently used key is evicted
+	get("b", "")
+	get("c", "")
+	if n := c.cache.len(); n != 2 {
+		t.Errorf("expected 2 keys in the cache; got %d", n)
+	}
+	get("a", "y")
+	checkStats(2, 6)
+
+	// the cache is disabled without the invalidations
+	_ = c.invalidations.Close()
+	<-c.receiving
+	get("b", "")
+	get("b", "")
+	if n := c.cache.len(); n != 0 {
+		t.Errorf("expected the cache to be disabled; got %d keys", n)
+	}
+}
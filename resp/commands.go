@@ -0,0 +1,407 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/acl"
+	"github.com/sdslabs/kiwi/tracking"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// InvalidateChannel is the channel the invalidated keys are pushed on.
+const InvalidateChannel = "__redis__:invalidate"
+
+// Errors of CLIENT TRACKING.
+var (
+	errNoTracker  = fmt.Errorf("tracking is not enabled on the server")
+	errNoRedirect = fmt.Errorf("tracking requires REDIRECT to the connection receiving the invalidations")
+	errNoClient   = fmt.Errorf("the client ID to redirect to does not exist")
+)
+
+// get executes "GET key".
+func (c *conn) get(args []string) {
+	if len(args) != 1 {
+		c.writeErr(newArgsErr("get"))
+		return
+	}
+
+	key := args[0]
+	typ, err := c.valueType(key)
+	if err == nil {
+		err = c.check(key, str.Get, typ)
+	}
+	if err == nil && typ != "" && typ != str.Type {
+		err = errWrongType
+	}
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	// the key is tracked even if it does not exist
+	var v interface{}
+	if c.tracking != nil {
+		v, err = c.tracking.Do(key, str.Get)
+	} else {
+		v, err = c.s.store.DoAs(c.caller, key, str.Get)
+	}
+
+	switch {
+	case errors.Is(err, kiwi.ErrKeyNotExist):
+		c.write(nil)
+	case err != nil:
+		c.writeErr(err)
+	default:
+		c.write(v.(string)) //nolint:errcheck
+	}
+}
+
+// set executes "SET key value", adding the key if it does not exist.
+func (c *conn) set(args []string) {
+	if len(args) != 2 {
+		c.writeErr(newArgsErr("set"))
+		return
+	}
+
+	key, value := args[0], args[1]
+	typ, err := c.valueType(key)
+	if err == nil {
+		err = c.check(key, str.Update, typ)
+	}
+	if err == nil && typ == "" {
+		err = c.check(key, acl.AddKey, str.Type)
+	}
+	if err == nil && typ != "" && typ != str.Type {
+		err = errWrongType
+	}
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	if typ == "" {
+		if err := c.s.store.AddKeyAs(c.caller, key, str.Type); err != nil && !errors.Is(err, kiwi.ErrKeyExists) {
+			c.writeErr(err)
+			return
+		}
+	}
+
+	if _, err := c.s.store.DoAs(c.caller, key, str.Update, value); err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	c.write(simple("OK"))
+}
+
+// del executes "DEL key [key...]". The user should be allowed to delete all
+// the keys, otherwise none of them is deleted.
+func (c *conn) del(args []string) {
+	if len(args) == 0 {
+		c.writeErr(newArgsErr("del"))
+		return
+	}
+
+	for _, key := range args {
+		typ, err := c.valueType(key)
+		if err == nil {
+			err = c.check(key, acl.DeleteKey, typ)
+		}
+		if err != nil {
+			c.writeErr(err)
+			return
+		}
+	}
+
+	deleted := 0
+	for _, key := range args {
+		err := c.s.store.DeleteKeyAs(c.caller, key)
+		if errors.Is(err, kiwi.ErrKeyNotExist) {
+			continue
+		}
+		if err != nil {
+			c.writeErr(err)
+			return
+		}
+
+		deleted++
+	}
+
+	c.write(deleted)
+}
+
+// valueType returns the type of the value of the key, which is empty if the
+// key does not exist.
+func (c *conn) valueType(key string) (kiwi.ValueType, error) {
+	typ, err := c.s.store.GetValueType(key)
+	if errors.Is(err, kiwi.ErrKeyNotExist) {
+		return "", nil
+	}
+
+	return typ, err
+}
+
+// publish executes "PUBLISH channel message".
+func (c *conn) publish(args []string) {
+	if len(args) != 2 {
+		c.writeErr(newArgsErr("publish"))
+		return
+	}
+
+	if err := c.check(args[0], acl.Publish, ""); err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	c.write(c.s.store.Publish(args[0], args[1]))
+}
+
+// subscribe executes "SUBSCRIBE channel [channel...]" or "PSUBSCRIBE pattern
+// [pattern...]", replying with the number of subscriptions after each.
+func (c *conn) subscribe(args []string, pattern bool) {
+	kind, own := "subscribe", c.channels
+	if pattern {
+		kind, own = "psubscribe", c.patterns
+	}
+
+	if len(args) == 0 {
+		c.writeErr(newArgsErr(kind))
+		return
+	}
+
+	for _, name := range args {
+		var err error
+		if pattern {
+			// a pattern cannot be checked like a key, so it has to be
+			// allowed for all the channels
+			err = c.s.ACL.CheckAction(c.user(), acl.Subscribe)
+		} else {
+			err = c.check(name, acl.Subscribe, "")
+		}
+		if err != nil {
+			c.writeErr(err)
+			return
+		}
+	}
+
+	if c.sub == nil {
+		c.sub = c.s.store.NewSubscriber(c.s.SubscriberOpts)
+		atomic.StoreInt32(&c.receiving, 1)
+
+		c.pushers.Add(2)
+		go c.pushMessages(c.sub)
+		go c.pushInvalidations(c.sub)
+	}
+
+	for _, name := range args {
+		if pattern {
+			c.sub.PSubscribe(name)
+		} else {
+			c.sub.Subscribe(name)
+		}
+		own[name] = struct{}{}
+
+		c.write([]interface{}{kind, name, len(c.channels) + len(c.patterns)})
+	}
+}
+
+// unsubscribe executes "UNSUBSCRIBE [channel...]" or "PUNSUBSCRIBE
+// [pattern...]", unsubscribing from all of them if none is given.
+func (c *conn) unsubscribe(args []string, pattern bool) {
+	kind, own := "unsubscribe", c.channels
+	if pattern {
+		kind, own = "punsubscribe", c.patterns
+	}
+
+	names := args
+	if len(names) == 0 {
+		for name := range own {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	if len(names) == 0 {
+		c.write([]interface{}{kind, nil, len(c.channels) + len(c.patterns)})
+		return
+	}
+
+	for _, name := range names {
+		if c.sub != nil {
+			if pattern {
+				c.sub.PUnsubscribe(name)
+			} else {
+				c.sub.Unsubscribe(name)
+			}
+		}
+		delete(own, name)
+
+		c.write([]interface{}{kind, name, len(c.channels) + len(c.patterns)})
+	}
+}
+
+// pushMessages pushes the messages received by the subscriber until it is
+// closed, which closes the connection.
+func (c *conn) pushMessages(sub *kiwi.Subscriber) {
+	defer c.pushers.Done()
+
+	for msg := range sub.C {
+		if msg.Pattern != "" {
+			c.push([]interface{}{"pmessage", msg.Pattern, msg.Channel, msg.Payload})
+		} else {
+			c.push([]interface{}{"message", msg.Channel, msg.Payload})
+		}
+	}
+
+	// the subscriber is closed by the store if it cannot keep up
+	_ = c.rw.Close()
+}
+
+// pushInvalidations pushes the invalidated keys while the connection is in the
+// subscribed mode until it is closed.
+func (c *conn) pushInvalidations(sub *kiwi.Subscriber) {
+	defer c.pushers.Done()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case key := <-c.invalidations:
+			if sub.Subscriptions() > 0 {
+				c.push([]interface{}{"message", InvalidateChannel, []string{key}})
+			}
+		}
+	}
+}
+
+// startMonitor executes "MONITOR".
+func (c *conn) startMonitor(args []string) {
+	if len(args) != 0 {
+		c.writeErr(newArgsErr("monitor"))
+		return
+	}
+
+	if err := c.s.ACL.CheckAction(c.user(), acl.Monitor); err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	if c.monitor == nil {
+		c.monitor = c.s.store.Monitor()
+
+		c.pushers.Add(1)
+		go c.pushMonitor(c.monitor)
+	}
+
+	c.write(simple("OK"))
+}
+
+// pushMonitor pushes the entries received by the monitor until it is closed.
+func (c *conn) pushMonitor(m *kiwi.Monitor) {
+	defer c.pushers.Done()
+
+	for entry := range m.C {
+		c.push(simple(entry.String()))
+	}
+}
+
+// clientTracking executes "CLIENT TRACKING ON|OFF [REDIRECT id] [BCAST]
+// [PREFIX prefix...] [NOLOOP]".
+func (c *conn) clientTracking(args []string) {
+	if c.s.Tracker == nil {
+		c.writeErr(errNoTracker)
+		return
+	}
+
+	if len(args) == 0 {
+		c.writeErr(newArgsErr("client tracking"))
+		return
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "ON":
+	case "OFF":
+		if c.tracking != nil {
+			c.tracking.Close()
+			c.tracking = nil
+		}
+		c.write(simple("OK"))
+		return
+	default:
+		c.writeErr(errSyntax)
+		return
+	}
+
+	opts, redirect, err := parseTracking(args[1:])
+	if err == nil && opts.Mode == tracking.ModeBroadcast {
+		err = c.s.ACL.CheckAction(c.user(), acl.Monitor)
+	}
+	if err == nil {
+		c.s.connMu.Lock()
+		if _, ok := c.s.conns[redirect]; !ok {
+			err = errNoClient
+		}
+		c.s.connMu.Unlock()
+	}
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	if c.tracking != nil {
+		c.tracking.Close()
+	}
+
+	s := c.s
+	c.tracking = s.Tracker.NewClient(c.caller, opts, func(key string) {
+		s.invalidate(redirect, key)
+	})
+
+	c.write(simple("OK"))
+}
+
+// parseTracking parses the options of CLIENT TRACKING ON.
+func parseTracking(args []string) (opts tracking.Options, redirect int64, err error) {
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "BCAST":
+			opts.Mode = tracking.ModeBroadcast
+		case "NOLOOP":
+			opts.NoLoop = true
+		case "PREFIX":
+			if i++; i == len(args) {
+				return opts, 0, errSyntax
+			}
+			opts.Prefixes = append(opts.Prefixes, args[i])
+		case "REDIRECT":
+			if i++; i == len(args) {
+				return opts, 0, errSyntax
+			}
+			if redirect, err = strconv.ParseInt(args[i], 10, 64); err != nil {
+				return opts, 0, fmt.Errorf("%w: invalid client ID %q", errSyntax, args[i])
+			}
+		default:
+			return opts, 0, errSyntax
+		}
+	}
+
+	if redirect == 0 {
+		return opts, 0, errNoRedirect
+	}
+
+	if len(opts.Prefixes) > 0 && opts.Mode != tracking.ModeBroadcast {
+		return opts, 0, fmt.Errorf("%w: PREFIX requires BCAST", errSyntax)
+	}
+
+	return opts, redirect, nil
+}
@@ -0,0 +1,351 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/acl"
+	"github.com/sdslabs/kiwi/tracking"
+)
+
+// Errors while executing the commands.
+var (
+	errUnknownCommand = fmt.Errorf("unknown command")
+	errWrongArgs      = fmt.Errorf("wrong number of arguments")
+	errSyntax         = fmt.Errorf("syntax error")
+	errWrongType      = fmt.Errorf("operation against a key holding the wrong kind of value")
+	errSubscribed     = fmt.Errorf("only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed while subscribed")
+	errHandshake      = fmt.Errorf("TLS handshake failed")
+)
+
+// handshakeTimeout is the time a TLS client has to complete the handshake.
+const handshakeTimeout = 10 * time.Second
+
+// newArgsErr creates an error for a command called with the wrong number of
+// arguments.
+func newArgsErr(cmd string) error {
+	return fmt.Errorf("%w for '%s' command", errWrongArgs, strings.ToLower(cmd))
+}
+
+// conn is a connection with a client.
+type conn struct {
+	s      *Server
+	id     int64
+	caller string
+	rw     net.Conn
+	r      *bufio.Reader
+
+	// w is guarded by wmu since the pushes are written by other goroutines.
+	w   *bufio.Writer
+	wmu sync.Mutex
+
+	session *acl.Session
+
+	// The subscriptions, monitor and tracking are only accessed by the
+	// goroutine serving the connection.
+	sub      *kiwi.Subscriber
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	monitor  *kiwi.Monitor
+	tracking *tracking.Client
+
+	// invalidations buffers the keys invalidated for the connection once it
+	// is receiving them, i.e., has subscribed, which is set atomically.
+	invalidations chan string
+	receiving     int32
+
+	// done is closed when the connection is closed, after which pushers are
+	// waited for.
+	done    chan struct{}
+	pushers sync.WaitGroup
+
+	// active tells if a command is being executed, guarded by the server
+	active bool
+}
+
+// serve reads and executes the commands until the connection is closed.
+func (c *conn) serve() {
+	defer c.s.closeConn(c)
+
+	if err := c.authTLS(); err != nil {
+		if !errors.Is(err, errHandshake) {
+			c.wmu.Lock()
+			c.writeErr(err)
+			_ = c.w.Flush()
+			c.wmu.Unlock()
+		}
+		return
+	}
+
+	for {
+		args, err := readCommand(c.r, c.s.MaxArgSize)
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				c.push(replyErr("ERR " + err.Error()))
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		c.s.setActive(c, true)
+
+		c.wmu.Lock()
+		quit := c.exec(args)
+
+		// replies to pipelined commands are flushed together
+		if quit || c.r.Buffered() == 0 || c.s.isDraining() {
+			err = c.w.Flush()
+		}
+		c.wmu.Unlock()
+
+		if err != nil || quit || !c.s.setActive(c, false) {
+			return
+		}
+	}
+}
+
+// exec executes the command and writes its reply. It returns true if the
+// connection should be closed.
+func (c *conn) exec(args []string) bool {
+	cmd, args := strings.ToUpper(args[0]), args[1:]
+
+	if c.subscribed() && !allowedWhileSubscribed(cmd) {
+		c.writeErr(errSubscribed)
+		return false
+	}
+
+	switch cmd {
+	case "PING":
+		c.ping(args)
+	case "QUIT":
+		c.write(simple("OK"))
+		return true
+	case "AUTH":
+		c.auth(args)
+	case "ACL":
+		c.acl(args)
+	case "CLIENT":
+		c.client(args)
+	case "GET":
+		c.get(args)
+	case "SET":
+		c.set(args)
+	case "DEL":
+		c.del(args)
+	case "PUBLISH":
+		c.publish(args)
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		c.subscribe(args, cmd == "PSUBSCRIBE")
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		c.unsubscribe(args, cmd == "PUNSUBSCRIBE")
+	case "MONITOR":
+		c.startMonitor(args)
+	default:
+		c.writeErr(fmt.Errorf("%w '%s'", errUnknownCommand, strings.ToLower(cmd)))
+	}
+
+	return false
+}
+
+// authTLS completes the handshake of a TLS connection and authenticates its
+// session as the user named by the common name of the verified client
+// certificate, if any.
+func (c *conn) authTLS() error {
+	tc, ok := c.rw.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	_ = tc.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := tc.Handshake(); err != nil {
+		return fmt.Errorf("%w: %v", errHandshake, err)
+	}
+	_ = tc.SetDeadline(time.Time{})
+
+	chains := tc.ConnectionState().VerifiedChains
+	if len(chains) == 0 {
+		return nil
+	}
+
+	return c.session.AuthVerified(chains[0][0].Subject.CommonName)
+}
+
+// allowedWhileSubscribed tells if the command can be executed in the
+// subscribed mode.
+func allowedWhileSubscribed(cmd string) bool {
+	switch cmd {
+	case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE", "PING", "QUIT":
+		return true
+	default:
+		return false
+	}
+}
+
+// ping executes "PING [message]".
+func (c *conn) ping(args []string) {
+	if len(args) > 1 {
+		c.writeErr(newArgsErr("ping"))
+		return
+	}
+
+	message := ""
+	if len(args) == 1 {
+		message = args[0]
+	}
+
+	switch {
+	case c.subscribed():
+		c.write([]interface{}{"pong", message})
+	case len(args) == 1:
+		c.write(message)
+	default:
+		c.write(simple("PONG"))
+	}
+}
+
+// auth executes "AUTH [user] password".
+func (c *conn) auth(args []string) {
+	var err error
+	switch len(args) {
+	case 1:
+		err = c.session.Auth(acl.DefaultUser, args[0])
+	case 2:
+		err = c.session.Auth(args[0], args[1])
+	default:
+		err = newArgsErr("auth")
+	}
+
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	c.write(simple("OK"))
+}
+
+// acl executes the ACL subcommands of the session.
+func (c *conn) acl(args []string) {
+	v, err := c.session.ACL(args...)
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+
+	if v == nil {
+		c.write(simple("OK"))
+		return
+	}
+
+	c.write(v)
+}
+
+// client executes "CLIENT ID" and "CLIENT TRACKING".
+func (c *conn) client(args []string) {
+	if len(args) == 0 {
+		c.writeErr(newArgsErr("client"))
+		return
+	}
+
+	switch sub := strings.ToUpper(args[0]); sub {
+	case "ID":
+		c.write(c.id)
+	case "TRACKING":
+		c.clientTracking(args[1:])
+	default:
+		c.writeErr(fmt.Errorf("%w 'client %s'", errUnknownCommand, strings.ToLower(sub)))
+	}
+}
+
+// user returns the user the connection is authenticated as.
+func (c *conn) user() string {
+	return c.session.WhoAmI()
+}
+
+// check returns an error if the user is not allowed to execute the action on
+// the key holding a value of the type.
+func (c *conn) check(key string, action kiwi.Action, typ kiwi.ValueType) error {
+	return c.s.ACL.Check(c.user(), key, action, typ)
+}
+
+// subscribed tells if the connection is in the subscribed mode.
+func (c *conn) subscribed() bool {
+	return len(c.channels)+len(c.patterns) > 0
+}
+
+// invalidate queues the key to be pushed to the connection if it is receiving
+// the invalidations. The connection is closed if it cannot keep up.
+//
+// It is called by the tracker, so it does not block.
+func (c *conn) invalidate(key string) {
+	if atomic.LoadInt32(&c.receiving) == 0 {
+		return
+	}
+
+	select {
+	case c.invalidations <- key:
+	default:
+		_ = c.rw.Close()
+	}
+}
+
+// close stops the subscriptions, monitor and tracking of the connection and
+// waits for the pushers to return.
+func (c *conn) close() {
+	if c.tracking != nil {
+		c.tracking.Close()
+	}
+	if c.monitor != nil {
+		c.monitor.Close()
+	}
+	if c.sub != nil {
+		c.sub.Close()
+	}
+
+	close(c.done)
+	c.pushers.Wait()
+}
+
+// push writes the value and flushes it to the client.
+func (c *conn) push(v interface{}) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	writeValue(c.w, v)
+	_ = c.w.Flush()
+}
+
+// write writes the value as the reply. It should be called with wmu locked.
+func (c *conn) write(v interface{}) {
+	writeValue(c.w, v)
+}
+
+// writeErr writes the error reply for the error, prefixed with the code of
+// the error. It should be called with wmu locked.
+func (c *conn) writeErr(err error) {
+	code := "ERR"
+	switch {
+	case errors.Is(err, errWrongType):
+		code = "WRONGTYPE"
+	case errors.Is(err, acl.ErrPermissionDenied):
+		code = "NOPERM"
+	case errors.Is(err, acl.ErrAuthFailed):
+		code = "WRONGPASS"
+	}
+
+	c.write(replyErr(code + " " + err.Error()))
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package resp implements a server speaking the Redis serialization protocol
+// (RESP2) on top of a kiwi store, so that Redis clients can read and write its
+// str values, use its pub/sub channels, monitor it and cache its keys.
+//
+// The supported commands are:
+//
+//	PING [message]
+//	QUIT
+//	AUTH [user] password
+//	ACL LIST | SETUSER user [tokens...] | WHOAMI
+//	GET key
+//	SET key value
+//	DEL key [key...]
+//	PUBLISH channel message
+//	SUBSCRIBE channel [channel...]
+//	UNSUBSCRIBE [channel...]
+//	PSUBSCRIBE pattern [pattern...]
+//	PUNSUBSCRIBE [pattern...]
+//	MONITOR
+//	CLIENT ID
+//	CLIENT TRACKING ON|OFF [REDIRECT id] [BCAST] [PREFIX prefix...] [NOLOOP]
+//
+// GET and SET access the "str" values of the store only, while DEL deletes
+// keys of any type.
+//
+// Every connection starts authenticated as the default user of the ACL of the
+// server and is checked against the rules of its user before each command; see
+// the acl package. Channels are checked like keys with the PUBLISH and
+// SUBSCRIBE actions, while PSUBSCRIBE and MONITOR have to be allowed for all
+// the keys.
+//
+// A TLS connection, served from a listener created by tls.NewListener, starts
+// authenticated as the user named by the common name of its client certificate
+// instead if the certificate is verified, and is refused if the user does not
+// exist or is disabled.
+//
+// A connection is in the subscribed mode while it is subscribed to a channel
+// or pattern. Messages are pushed to it as ["message", channel, payload] and
+// ["pmessage", pattern, channel, payload], and it can only subscribe,
+// unsubscribe, PING and QUIT.
+//
+// After MONITOR, every operation on the store is pushed to the connection as a
+// status reply in the format of kiwi.MonitorEntry, with the ID and address of
+// the connection executing it as the caller.
+//
+// CLIENT TRACKING tracks the keys read by the connection with the Tracker of
+// the server, if any. As in RESP2, the invalidated keys are pushed to another
+// connection, given by REDIRECT, as ["message", "__redis__:invalidate",
+// [key]], and only while it is in the subscribed mode. Since broadcasting
+// tells about the changes of keys the user may not access, BCAST has to be
+// allowed to MONITOR. A connection which cannot keep up with its invalidations
+// is closed so that its clients do not keep stale keys.
+//
+// Client is a minimal Go client for such a server, which can connect over TLS
+// and authenticate with a password or a client certificate. With the Tracking
+// option, it keeps a bounded local cache of the keys it reads, invalidated by
+// the server through CLIENT TRACKING.
+//
+//
+// Get Started
+//
+//	store := kiwi.NewStore()
+//	server := resp.NewServer(store)
+//
+//	go func() {
+//	  if err := server.ListenAndServe(":6379"); err != resp.ErrServerClosed {
+//	    // handle error
+//	  }
+//	}()
+//
+//	// ...
+//
+//	if err := server.Close(); err != nil {
+//	  // handle error
+//	}
+//
+// and to connect to a server serving TLS with a client certificate:
+//
+//	config, err := resp.LoadClientTLS("ca.crt", "client.crt", "client.key")
+//	if err != nil {
+//	  // handle error
+//	}
+//
+//	client, err := resp.Dial("tcp", "localhost:6379", resp.ClientOpts{TLS: config})
+//	if err != nil {
+//	  // handle error
+//	}
+//	defer client.Close()
+package resp
@@ -0,0 +1,216 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxLineLen is the maximum length of a line, i.e., of an inline command
+	// or the header of an array or bulk string.
+	maxLineLen = 64 * 1024
+
+	// maxArgs is the maximum number of arguments of a command, and of
+	// elements of an array reply.
+	maxArgs = 1024 * 1024
+
+	// maxBulkReply is the maximum size of a bulk string reply.
+	maxBulkReply = 512 * 1024 * 1024
+)
+
+// ErrProtocol is returned when the other side does not follow the protocol.
+var ErrProtocol = fmt.Errorf("protocol error")
+
+// newProtocolErr creates an error describing the protocol error.
+func newProtocolErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrProtocol, reason)
+}
+
+// readLine reads a line without the trailing "\r\n" or "\n".
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return "", newProtocolErr("line too long")
+		}
+		return "", err
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+
+	return string(line), nil
+}
+
+// readCommand reads a command, which is either an array of bulk strings or an
+// inline command with the arguments separated by spaces. Bulk strings longer
+// than maxArgSize are a protocol error.
+func readCommand(r *bufio.Reader, maxArgSize int) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxArgs {
+		return nil, newProtocolErr("invalid multibulk length")
+	}
+
+	args := make([]string, 0, min(n, 16))
+	for i := 0; i < n; i++ {
+		arg, err := readBulk(r, maxArgSize)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// readBulk reads a bulk string of at most maxSize bytes.
+func readBulk(r *bufio.Reader, maxSize int) (string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+
+	return readBulkBody(r, line, maxSize)
+}
+
+// readBulkBody reads the bulk string of at most maxSize bytes after its
+// header line, like "$5".
+func readBulkBody(r *bufio.Reader, line string, maxSize int) (string, error) {
+	if !strings.HasPrefix(line, "$") {
+		return "", newProtocolErr(fmt.Sprintf("expected '$', got %q", line))
+	}
+
+	size, err := strconv.Atoi(line[1:])
+	if err != nil || size < 0 || size > maxSize {
+		return "", newProtocolErr("invalid bulk length")
+	}
+
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+
+	if buf[size] != '\r' || buf[size+1] != '\n' {
+		return "", newProtocolErr("bulk string not terminated with CRLF")
+	}
+
+	return string(buf[:size]), nil
+}
+
+// readReply reads a reply. The replies are returned as the values written by
+// writeValue, except integers which are int64.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if line == "" {
+		return nil, newProtocolErr("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return simple(line[1:]), nil
+	case '-':
+		return replyErr(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, newProtocolErr("invalid integer")
+		}
+		return n, nil
+	case '$':
+		if line == "$-1" {
+			return nil, nil
+		}
+		return readBulkBody(r, line, maxBulkReply)
+	case '*':
+		if line == "*-1" {
+			return nil, nil
+		}
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 || n > maxArgs {
+			return nil, newProtocolErr("invalid multibulk length")
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	default:
+		return nil, newProtocolErr(fmt.Sprintf("unknown reply %q", line))
+	}
+}
+
+// simple is a simple string reply, like "+OK".
+type simple string
+
+// replyErr is an error reply, like "-ERR unknown command".
+type replyErr string
+
+// lineBreaks replaces the line breaks in simple strings and errors, which
+// cannot span lines.
+var lineBreaks = strings.NewReplacer("\r", " ", "\n", " ")
+
+// writeValue writes the value as a reply. Strings are written as bulk strings,
+// integers as integers, nil as a null bulk string and slices as arrays. Write
+// errors are returned when the writer is flushed.
+func writeValue(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case simple:
+		_, _ = w.WriteString("+" + lineBreaks.Replace(string(v)) + "\r\n")
+	case replyErr:
+		_, _ = w.WriteString("-" + lineBreaks.Replace(string(v)) + "\r\n")
+	case string:
+		_, _ = w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case int:
+		_, _ = w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case int64:
+		_, _ = w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case nil:
+		_, _ = w.WriteString("$-1\r\n")
+	case []string:
+		_, _ = w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, s := range v {
+			writeValue(w, s)
+		}
+	case []interface{}:
+		_, _ = w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, e := range v {
+			writeValue(w, e)
+		}
+	default:
+		panic(fmt.Sprintf("resp: cannot write %T", v))
+	}
+}
+
+// min returns the smaller of the integers.
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
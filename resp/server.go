@@ -0,0 +1,281 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/acl"
+	"github.com/sdslabs/kiwi/tracking"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after the server is
+// closed.
+var ErrServerClosed = fmt.Errorf("resp: server closed")
+
+// errTooManyConns is the reason a connection is refused when the server has
+// MaxConns connections.
+var errTooManyConns = fmt.Errorf("too many open connections")
+
+// shutdownPollInterval is the interval at which Shutdown checks if all the
+// connections are closed.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// invalidationsBufferSize is the number of invalidations buffered for a
+// connection before it is closed for not keeping up.
+const invalidationsBufferSize = 1024
+
+// Server serves Redis clients using a store.
+type Server struct {
+	store *kiwi.Store
+
+	// ACL has the users the connections authenticate as. Defaults to an ACL
+	// with only the default user, which has all the permissions.
+	ACL *acl.ACL
+
+	// Tracker tracks the keys for CLIENT TRACKING, which is refused if it
+	// is nil.
+	Tracker *tracking.Tracker
+
+	// SubscriberOpts are the options of the subscribers of the connections
+	// which subscribe to channels.
+	SubscriberOpts kiwi.SubscriberOpts
+
+	// MaxArgSize is the maximum size of an argument of a command in bytes.
+	// Defaults to 1 MiB.
+	MaxArgSize int
+
+	// MaxConns is the maximum number of open connections. New connections
+	// are refused with an error when reached. There is no limit if it is 0.
+	MaxConns int
+
+	listeners map[net.Listener]struct{}
+	conns     map[int64]*conn
+	lastID    int64
+	closed    bool
+	draining  bool
+	connMu    sync.Mutex
+}
+
+// NewServer creates a server for the store.
+func NewServer(store *kiwi.Store) *Server {
+	return &Server{
+		store:          store,
+		ACL:            acl.New(),
+		SubscriberOpts: kiwi.DefaultSubscriberOpts,
+		MaxArgSize:     1 << 20,
+		listeners:      make(map[net.Listener]struct{}),
+		conns:          make(map[int64]*conn),
+		connMu:         sync.Mutex{},
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves the connections.
+//
+// It always returns a non-nil error, which is ErrServerClosed after Close.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts the connections on the listener and serves each of them in a
+// new goroutine. The listener is closed when Serve returns.
+//
+// It always returns a non-nil error, which is ErrServerClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.connMu.Lock()
+	if s.closed {
+		s.connMu.Unlock()
+		_ = l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.connMu.Unlock()
+
+	defer func() {
+		s.connMu.Lock()
+		delete(s.listeners, l)
+		s.connMu.Unlock()
+		_ = l.Close()
+	}()
+
+	for {
+		rw, err := l.Accept()
+		if err != nil {
+			s.connMu.Lock()
+			closed := s.closed
+			s.connMu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		c, err := s.newConn(rw)
+		if err == ErrServerClosed {
+			_ = rw.Close()
+			return err
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(rw, "-ERR %v\r\n", err)
+			_ = rw.Close()
+			continue
+		}
+
+		go c.serve()
+	}
+}
+
+// Close closes all the listeners and connections of the server.
+func (s *Server) Close() error {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	s.closed = true
+
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for _, c := range s.conns {
+		if cerr := c.rw.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// Shutdown gracefully shuts down the server. It closes the listeners and the
+// idle connections, including the subscribed and monitoring ones, and then
+// waits for the other connections to complete the command being executed and
+// close.
+//
+// If the context expires first, the remaining connections are closed and the
+// error of the context is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.connMu.Lock()
+	s.closed = true
+	s.draining = true
+
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for _, c := range s.conns {
+		if !c.active {
+			_ = c.rw.Close()
+		}
+	}
+	s.connMu.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.connMu.Lock()
+		n := len(s.conns)
+		s.connMu.Unlock()
+
+		if n == 0 {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// setActive marks the connection as executing a command or as idle. It returns
+// false if the idle connection should be closed since the server is shutting
+// down.
+func (s *Server) setActive(c *conn, active bool) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	c.active = active
+	return active || !s.draining
+}
+
+// isDraining tells if the server is shutting down gracefully.
+func (s *Server) isDraining() bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	return s.draining
+}
+
+// newConn tracks a new connection. It returns ErrServerClosed if the server
+// is closed.
+func (s *Server) newConn(rw net.Conn) (*conn, error) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.closed {
+		return nil, ErrServerClosed
+	}
+
+	if s.MaxConns > 0 && len(s.conns) >= s.MaxConns {
+		return nil, errTooManyConns
+	}
+
+	s.lastID++
+	c := &conn{
+		s:             s,
+		id:            s.lastID,
+		caller:        fmt.Sprintf("%d %s", s.lastID, rw.RemoteAddr()),
+		rw:            rw,
+		r:             bufio.NewReaderSize(rw, maxLineLen),
+		w:             bufio.NewWriter(rw),
+		session:       s.ACL.NewSession(s.store),
+		channels:      make(map[string]struct{}),
+		patterns:      make(map[string]struct{}),
+		invalidations: make(chan string, invalidationsBufferSize),
+		done:          make(chan struct{}),
+	}
+
+	s.conns[c.id] = c
+	return c, nil
+}
+
+// closeConn closes the connection and stops tracking it.
+func (s *Server) closeConn(c *conn) {
+	s.connMu.Lock()
+	delete(s.conns, c.id)
+	s.connMu.Unlock()
+
+	_ = c.rw.Close()
+	c.close()
+}
+
+// invalidate pushes the key to the connection with the ID, if it is open.
+func (s *Server) invalidate(id int64, key string) {
+	s.connMu.Lock()
+	c, ok := s.conns[id]
+	s.connMu.Unlock()
+
+	if ok {
+		c.invalidate(key)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/acl"
+	"github.com/sdslabs/kiwi/tracking"
+	"github.com/sdslabs/kiwi/values/list"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// testClient sends commands to the server and checks the replies.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// newTestServer starts the server and returns a function connecting clients
+// to it.
+func newTestServer(t *testing.T, s *Server) func() *testClient {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Serve(l) }()
+
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Errorf("could not close server: %v", err)
+		}
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("expected Serve to return ErrServerClosed; got %v", err)
+		}
+	})
+
+	return func() *testClient {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("could not dial: %v", err)
+		}
+
+		return &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	}
+}
+
+// send sends the command as an array of bulk strings.
+func (c *testClient) send(args ...string) {
+	c.t.Helper()
+
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		c.t.Fatalf("could not send %q: %v", args, err)
+	}
+}
+
+// read reads a reply, failing if none is received within a second.
+func (c *testClient) read() interface{} {
+	c.t.Helper()
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	v, err := readReply(c.r)
+	if err != nil {
+		c.t.Fatalf("could not read reply: %v", err)
+	}
+
+	return v
+}
+
+// do sends the command and checks the reply.
+func (c *testClient) do(expected interface{}, args ...string) {
+	c.t.Helper()
+
+	c.send(args...)
+	c.expect(expected)
+}
+
+// expect checks that the next reply is the expected one.
+func (c *testClient) expect(expected interface{}) {
+	c.t.Helper()
+
+	if got := c.read(); !reflect.DeepEqual(got, expected) {
+		c.t.Errorf("expected %#v; got %#v", expected, got)
+	}
+}
+
+// doErr sends the command and checks that the reply is an error with the
+// code.
+func (c *testClient) doErr(code string, args ...string) {
+	c.t.Helper()
+
+	c.send(args...)
+	got, ok := c.read().(replyErr)
+	if !ok || !strings.HasPrefix(string(got), code+" ") {
+		c.t.Errorf("expected %s error for %q; got %#v", code, args, got)
+	}
+}
+
+func TestServer_Commands(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{"jobs": list.Type})
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+
+	c := newTestServer(t, NewServer(store))()
+
+	c.do(simple("PONG"), "PING")
+	c.do("hello", "ping", "hello")
+	c.do(nil, "GET", "a")
+	c.do(simple("OK"), "SET", "a", "hello world")
+	c.do("hello world", "GET", "a")
+
+	if v, err := store.Do("a", str.Get); err != nil || v != "hello world" {
+		t.Errorf("expected SET to update the store; got %v (%v)", v, err)
+	}
+
+	c.doErr("WRONGTYPE", "GET", "jobs")
+	c.doErr("WRONGTYPE", "SET", "jobs", "x")
+	c.doErr("ERR", "GET")
+	c.doErr("ERR", "NOSUCHCOMMAND")
+
+	c.do(int64(2), "DEL", "a", "jobs", "missing")
+	if store.KeyExists("jobs") {
+		t.Errorf("expected DEL to delete keys of any type")
+	}
+
+	// inline commands
+	if _, err := c.conn.Write([]byte("SET b inline\r\nGET b\r\n")); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	c.expect(simple("OK"))
+	c.expect("inline")
+
+	c.do(simple("OK"), "QUIT")
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("expected connection to be closed after QUIT; got %v", err)
+	}
+
+	// protocol errors close the connection
+	c = newTestServer(t, NewServer(store))()
+	if _, err := c.conn.Write([]byte("*1\r\n+PING\r\n")); err != nil {
+		t.Fatalf("could not write: %v", err)
+	}
+	if got, ok := c.read().(replyErr); !ok || !strings.HasPrefix(string(got), "ERR protocol error") {
+		t.Errorf("expected protocol error; got %#v", got)
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		t.Errorf("expected connection to be closed after protocol error; got %v", err)
+	}
+}
+
+func TestServer_ACL(t *testing.T) {
+	a, err := acl.Load(strings.NewReader(`
+		user default off
+		user admin on >secret (~* +* %*)
+		user reader on >pass (~cache:* +GET %str) (~news +SUBSCRIBE)
+	`))
+	if err != nil {
+		t.Fatalf("could not load ACL: %v", err)
+	}
+
+	store := kiwi.NewStore()
+	s := NewServer(store)
+	s.ACL = a
+	connect := newTestServer(t, s)
+
+	c := connect()
+	c.doErr("NOPERM", "SET", "cache:a", "x")
+	c.doErr("WRONGPASS", "AUTH", "reader", "wrong")
+	c.do(simple("OK"), "AUTH", "admin", "secret")
+	c.do(simple("OK"), "SET", "cache:a", "x")
+	c.do(simple("OK"), "SET", "secret", "y")
+
+	c.do(simple("OK"), "AUTH", "reader", "pass")
+	c.do("reader", "ACL", "WHOAMI")
+	c.do("x", "GET", "cache:a")
+	c.doErr("NOPERM", "GET", "secret")
+	c.doErr("NOPERM", "SET", "cache:a", "z")
+	c.doErr("NOPERM", "DEL", "cache:a")
+	c.doErr("NOPERM", "PUBLISH", "news", "hi")
+	c.doErr("NOPERM", "PSUBSCRIBE", "*")
+	c.doErr("NOPERM", "MONITOR")
+	c.doErr("NOPERM", "ACL", "LIST")
+
+	if v, err := store.Do("cache:a", str.Get); err != nil || v != "x" {
+		t.Errorf("expected denied SET not to update the store; got %v (%v)", v, err)
+	}
+
+	c.do([]interface{}{"subscribe", "news", int64(1)}, "SUBSCRIBE", "news")
+}
+
+func TestServer_PubSub(t *testing.T) {
+	connect := newTestServer(t, NewServer(kiwi.NewStore()))
+
+	sub, pub := connect(), connect()
+
+	sub.do([]interface{}{"subscribe", "news", int64(1)}, "SUBSCRIBE", "news")
+	sub.do([]interface{}{"psubscribe", "n*", int64(2)}, "PSUBSCRIBE", "n*")
+
+	pub.do(int64(2), "PUBLISH", "news", "hello")
+	sub.expect([]interface{}{"message", "news", "hello"})
+	sub.expect([]interface{}{"pmessage", "n*", "news", "hello"})
+
+	sub.doErr("ERR", "GET", "a")
+	sub.do([]interface{}{"pong", ""}, "PING")
+
+	sub.do([]interface{}{"unsubscribe", "news", int64(1)}, "UNSUBSCRIBE")
+	pub.do(int64(1), "PUBLISH", "news", "again")
+	sub.expect([]interface{}{"pmessage", "n*", "news", "again"})
+
+	sub.do([]interface{}{"punsubscribe", "n*", int64(0)}, "PUNSUBSCRIBE", "n*")
+	sub.do(nil, "GET", "a")
+	pub.do(int64(0), "PUBLISH", "news", "nobody")
+}
+
+func TestServer_Monitor(t *testing.T) {
+	connect := newTestServer(t, NewServer(kiwi.NewStore()))
+
+	m, c := connect(), connect()
+	c.do(int64(2), "CLIENT", "ID")
+
+	m.do(simple("OK"), "MONITOR")
+	c.do(simple("OK"), "SET", "a", "x")
+	c.do(int64(1), "DEL", "a")
+
+	// the key is added before it is updated
+	for _, entry := range []string{`addkey "a"`, `do "a" UPDATE "x"`, `deletekey "a"`} {
+		got, ok := m.read().(simple)
+		if !ok || !strings.HasSuffix(string(got), entry) {
+			t.Fatalf("expected entry %q; got %#v", entry, got)
+		}
+		if !strings.Contains(string(got), " [2 127.0.0.1:") {
+			t.Errorf("expected entry with ID and address of the client; got %q", got)
+		}
+	}
+}
+
+func TestServer_Tracking(t *testing.T) {
+	store := kiwi.NewStore()
+	s := NewServer(store)
+
+	connect := newTestServer(t, s)
+	c, r, w := connect(), connect(), connect()
+
+	c.doErr("ERR", "CLIENT", "TRACKING", "ON", "REDIRECT", "2")
+
+	s.Tracker = tracking.NewTracker(store)
+	defer s.Tracker.Close()
+
+	r.do(int64(2), "CLIENT", "ID")
+	r.do([]interface{}{"subscribe", InvalidateChannel, int64(1)}, "SUBSCRIBE", InvalidateChannel)
+
+	c.doErr("ERR", "CLIENT", "TRACKING", "ON")
+	c.doErr("ERR", "CLIENT", "TRACKING", "ON", "REDIRECT", "42")
+	c.doErr("ERR", "CLIENT", "TRACKING", "ON", "REDIRECT", "2", "PREFIX", "a")
+	c.do(simple("OK"), "CLIENT", "TRACKING", "ON", "REDIRECT", "2")
+
+	// missing keys are tracked as well
+	c.do(nil, "GET", "a")
+	w.do(simple("OK"), "SET", "a", "x")
+	r.expect([]interface{}{"message", InvalidateChannel, []interface{}{"a"}})
+
+	// the key is not tracked until it is read again
+	w.do(simple("OK"), "SET", "a", "y")
+	c.do("y", "GET", "a")
+	w.do(simple("OK"), "SET", "a", "z")
+	r.expect([]interface{}{"message", InvalidateChannel, []interface{}{"a"}})
+
+	c.do(simple("OK"), "CLIENT", "TRACKING", "OFF")
+	c.do("z", "GET", "a")
+	w.do(simple("OK"), "SET", "a", "x")
+
+	// broadcast
+	c.do(simple("OK"), "CLIENT", "TRACKING", "ON", "REDIRECT", "2", "BCAST", "PREFIX", "b")
+	w.do(simple("OK"), "SET", "a", "y")
+	w.do(simple("OK"), "SET", "b", "y")
+	r.expect([]interface{}{"message", InvalidateChannel, []interface{}{"b"}})
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	s := NewServer(kiwi.NewStore())
+	connect := newTestServer(t, s)
+
+	sub, c := connect(), connect()
+	sub.do([]interface{}{"subscribe", "news", int64(1)}, "SUBSCRIBE", "news")
+	c.do(simple("PONG"), "PING")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("could not shutdown: %v", err)
+	}
+
+	for _, client := range []*testClient{sub, c} {
+		if _, err := client.r.ReadByte(); err != io.EOF {
+			t.Errorf("expected idle connection to be closed; got %v", err)
+		}
+	}
+}
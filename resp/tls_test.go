@@ -0,0 +1,175 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package resp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/acl"
+)
+
+// issueCert creates a certificate for the common name signed by the parent,
+// or self-signed as a CA if the parent is nil, and writes it and its key as
+// PEM to name.crt and name.key in the directory.
+func issueCert(t *testing.T, dir, name, commonName string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent == nil {
+		template.KeyUsage = x509.KeyUsageCertSign
+		template.BasicConstraintsValid = true
+		template.IsCA = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("could not marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	for file, data := range map[string][]byte{name + ".crt": certPEM, name + ".key": keyPEM} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), data, 0600); err != nil {
+			t.Fatalf("could not write %s: %v", file, err)
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("could not load certificate: %v", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("could not parse certificate: %v", err)
+	}
+
+	return cert
+}
+
+func TestServer_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kiwi-resp")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	ca := issueCert(t, dir, "ca", "kiwi test CA", nil)
+	server := issueCert(t, dir, "server", "localhost", &ca)
+	issueCert(t, dir, "app", "app", &ca)
+	issueCert(t, dir, "ghost", "ghost", &ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	a, err := acl.Load(strings.NewReader("user default off\nuser app on (~app:* +* %str)\n"))
+	if err != nil {
+		t.Fatalf("could not load ACL: %v", err)
+	}
+
+	s := NewServer(kiwi.NewStore())
+	s.ACL = a
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	go s.Serve(tls.NewListener(l, &tls.Config{ //nolint:errcheck
+		Certificates: []tls.Certificate{server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}))
+
+	defer s.Close() //nolint:errcheck
+
+	dial := func(cert string) (*Client, error) {
+		t.Helper()
+
+		certFile, keyFile := "", ""
+		if cert != "" {
+			certFile, keyFile = filepath.Join(dir, cert+".crt"), filepath.Join(dir, cert+".key")
+		}
+
+		config, err := LoadClientTLS(filepath.Join(dir, "ca.crt"), certFile, keyFile)
+		if err != nil {
+			t.Fatalf("could not load client TLS config: %v", err)
+		}
+		config.ServerName = "localhost"
+
+		return Dial("tcp", l.Addr().String(), ClientOpts{TLS: config, DialTimeout: 5 * time.Second})
+	}
+
+	// the client certificate authenticates the user
+	c, err := dial("app")
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer c.Close() //nolint:errcheck
+
+	if user, err := c.WhoAmI(); err != nil || user != "app" {
+		t.Errorf("expected to be authenticated as %q; got %q (%v)", "app", user, err)
+	}
+	if err := c.Set("app:a", "hello"); err != nil {
+		t.Errorf("could not Set: %v", err)
+	}
+
+	var serr ServerError
+	if err := c.Set("other", "hello"); !errors.As(err, &serr) || serr.Code() != "NOPERM" {
+		t.Errorf("expected NOPERM for key of other users; got %v", err)
+	}
+
+	// a certificate of an unknown user is refused after the handshake
+	ghost, err := dial("ghost")
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	if _, err := ghost.WhoAmI(); !errors.As(err, &serr) || serr.Code() != "WRONGPASS" {
+		t.Errorf("expected WRONGPASS for unknown user; got %v", err)
+	}
+	_ = ghost.Close()
+
+	// the client certificate is required
+	if c, err := dial(""); err == nil {
+		_, err = c.WhoAmI()
+		_ = c.Close()
+		if err == nil {
+			t.Errorf("expected error without client certificate")
+		}
+	}
+}
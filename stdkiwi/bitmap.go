@@ -0,0 +1,122 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/bitmap"
+)
+
+// Bitmap implements methods for bitmap value type.
+type Bitmap struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of bitmap type.
+func (b *Bitmap) Guard() {
+	if err := b.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (b *Bitmap) GuardE() error { return b.store.guardValueE(bitmap.Type, b.key) }
+
+// SetBit sets the bit at the offset, returning the old bit.
+func (b *Bitmap) SetBit(offset int, bit bool) (bool, error) {
+	n, err := b.do(bitmap.SetBit, offset, toBit(bit))
+	return n == 1, err
+}
+
+// GetBit gets the bit at the offset.
+func (b *Bitmap) GetBit(offset int) (bool, error) {
+	n, err := b.do(bitmap.GetBit, offset)
+	return n == 1, err
+}
+
+// BitCount counts the bits set in the bitmap.
+func (b *Bitmap) BitCount() (int, error) {
+	return b.do(bitmap.BitCount)
+}
+
+// BitCountRange counts the bits set between the start and end offsets, both
+// inclusive. Negative offsets count from the end of the bitmap.
+func (b *Bitmap) BitCountRange(start, end int) (int, error) {
+	return b.do(bitmap.BitCount, start, end)
+}
+
+// BitPos returns the offset of the first bit equal to bit, or -1 if there is
+// none. Looking for an unset bit finds the end of the bitmap if all the bits
+// are set.
+func (b *Bitmap) BitPos(bit bool) (int, error) {
+	return b.do(bitmap.BitPos, toBit(bit))
+}
+
+// BitPosRange is same as BitPos but only looks between the start and end
+// offsets, both inclusive. Negative offsets count from the end of the bitmap.
+func (b *Bitmap) BitPosRange(bit bool, start, end int) (int, error) {
+	return b.do(bitmap.BitPos, toBit(bit), start, end)
+}
+
+// Len returns the length of the bitmap in bits.
+func (b *Bitmap) Len() (int, error) {
+	return b.do(bitmap.Len)
+}
+
+// Bytes returns the bytes of the bitmap.
+func (b *Bitmap) Bytes() ([]byte, error) {
+	v, err := b.store.Do(b.key, bitmap.Get)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, newTypeErr(data, v)
+	}
+
+	return data, nil
+}
+
+// SetBytes replaces the bitmap with the bytes.
+func (b *Bitmap) SetBytes(data []byte) error {
+	_, err := b.do(bitmap.Set, data)
+	return err
+}
+
+// do executes the action which returns an int.
+func (b *Bitmap) do(action kiwi.Action, params ...interface{}) (int, error) {
+	v, err := b.store.Do(b.key, action, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// toBit converts the bool to 0 or 1.
+func toBit(bit bool) int {
+	if bit {
+		return 1
+	}
+
+	return 0
+}
+
+// BitOp combines the bitmaps of the keys with the operation and stores the
+// result in the dest key, which is added if it does not exist. It returns the
+// length of the result in bytes.
+func (s *Store) BitOp(op bitmap.Op, dest string, keys ...string) (int, error) {
+	return bitmap.BitOp(s.Store, op, dest, keys...)
+}
+
+// Interface guard.
+var _ Value = (*Bitmap)(nil)
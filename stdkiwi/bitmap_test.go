@@ -0,0 +1,77 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/bitmap"
+)
+
+func TestBitmap(t *testing.T) {
+	store := newTestStore(t, bitmap.Type)
+	b := store.Bitmap(testKey)
+
+	// check that it does not panic
+	b.Guard()
+
+	// and the same should work with GuardE as well
+	if err := b.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	for _, offset := range []int{3, 10, 12} {
+		if old, err := b.SetBit(offset, true); err != nil || old {
+			t.Errorf("expected SetBit(%d) to return false; got %v (%v)", offset, old, err)
+		}
+	}
+
+	if old, err := b.SetBit(10, false); err != nil || !old {
+		t.Errorf("expected SetBit to return true; got %v (%v)", old, err)
+	}
+
+	if bit, err := b.GetBit(12); err != nil || !bit {
+		t.Errorf("expected GetBit to return true; got %v (%v)", bit, err)
+	}
+
+	if n, err := b.BitCount(); err != nil || n != 2 {
+		t.Errorf("expected BitCount 2; got %d (%v)", n, err)
+	}
+	if n, err := b.BitCountRange(8, -1); err != nil || n != 1 {
+		t.Errorf("expected BitCountRange 1; got %d (%v)", n, err)
+	}
+	if n, err := b.BitPos(true); err != nil || n != 3 {
+		t.Errorf("expected BitPos 3; got %d (%v)", n, err)
+	}
+	if n, err := b.BitPosRange(true, 4, 11); err != nil || n != -1 {
+		t.Errorf("expected BitPosRange -1; got %d (%v)", n, err)
+	}
+	if n, err := b.Len(); err != nil || n != 16 {
+		t.Errorf("expected Len 16; got %d (%v)", n, err)
+	}
+
+	if err := b.SetBytes([]byte{0x0f}); err != nil {
+		t.Errorf("could not SetBytes: %v", err)
+	}
+
+	if n, err := store.BitOp(bitmap.OpNot, "dest", testKey); err != nil || n != 1 {
+		t.Errorf("expected BitOp to return 1; got %d (%v)", n, err)
+	}
+
+	data, err := store.Bitmap("dest").Bytes()
+	if err != nil {
+		t.Errorf("could not get Bytes: %v", err)
+	}
+	if !bytes.Equal(data, []byte{0xf0}) {
+		t.Errorf("expected bytes f0; got %x", data)
+	}
+
+	// check guard for invalid key
+	err = store.Bitmap("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
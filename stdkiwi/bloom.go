@@ -0,0 +1,107 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/bloom"
+)
+
+// Bloom implements methods for bloom value type.
+type Bloom struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of bloom type.
+func (b *Bloom) Guard() {
+	if err := b.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (b *Bloom) GuardE() error { return b.store.guardValueE(bloom.Type, b.key) }
+
+// Reserve sets the expected capacity and the false-positive rate of the
+// filter, which should be empty.
+func (b *Bloom) Reserve(capacity int, errorRate float64) error {
+	_, err := b.store.Do(b.key, bloom.Reserve, capacity, errorRate)
+	return err
+}
+
+// Add adds the element, returning true if it was definitely not added before.
+func (b *Bloom) Add(element string) (bool, error) {
+	return b.doBool(bloom.Add, element)
+}
+
+// MAdd adds the elements, returning for each of them if it was definitely not
+// added before.
+func (b *Bloom) MAdd(elements ...string) ([]bool, error) {
+	return b.doBools(bloom.MAdd, elements)
+}
+
+// Exists returns false if the element was definitely not added.
+func (b *Bloom) Exists(element string) (bool, error) {
+	return b.doBool(bloom.Exists, element)
+}
+
+// MExists returns for each of the elements if it may have been added.
+func (b *Bloom) MExists(elements ...string) ([]bool, error) {
+	return b.doBools(bloom.MExists, elements)
+}
+
+// Info returns the information about the filter.
+func (b *Bloom) Info() (bloom.FilterInfo, error) {
+	v, err := b.store.Do(b.key, bloom.Info)
+	if err != nil {
+		return bloom.FilterInfo{}, err
+	}
+
+	info, ok := v.(bloom.FilterInfo)
+	if !ok {
+		return bloom.FilterInfo{}, newTypeErr(info, v)
+	}
+
+	return info, nil
+}
+
+// doBool executes the action for the element which returns a bool.
+func (b *Bloom) doBool(action kiwi.Action, element string) (bool, error) {
+	v, err := b.store.Do(b.key, action, element)
+	if err != nil {
+		return false, err
+	}
+
+	res, ok := v.(bool)
+	if !ok {
+		return false, newTypeErr(res, v)
+	}
+
+	return res, nil
+}
+
+// doBools executes the action for the elements which returns a []bool.
+func (b *Bloom) doBools(action kiwi.Action, elements []string) ([]bool, error) {
+	params := make([]interface{}, len(elements))
+	for i := range elements {
+		params[i] = elements[i]
+	}
+
+	v, err := b.store.Do(b.key, action, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, ok := v.([]bool)
+	if !ok {
+		return nil, newTypeErr(res, v)
+	}
+
+	return res, nil
+}
+
+// Interface guard.
+var _ Value = (*Bloom)(nil)
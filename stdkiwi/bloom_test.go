@@ -0,0 +1,61 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/bloom"
+)
+
+func TestBloom(t *testing.T) {
+	store := newTestStore(t, bloom.Type)
+	b := store.Bloom(testKey)
+
+	// check that it does not panic
+	b.Guard()
+
+	// and the same should work with GuardE as well
+	if err := b.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if err := b.Reserve(1000, 0.001); err != nil {
+		t.Errorf("could not Reserve: %v", err)
+	}
+
+	if added, err := b.Add("a"); err != nil || !added {
+		t.Errorf("expected Add to return true; got %v (%v)", added, err)
+	}
+
+	added, err := b.MAdd("a", "b")
+	if err != nil || !reflect.DeepEqual(added, []bool{false, true}) {
+		t.Errorf("expected MAdd to return [false true]; got %v (%v)", added, err)
+	}
+
+	if exists, err := b.Exists("c"); err != nil || exists {
+		t.Errorf("expected Exists to return false; got %v (%v)", exists, err)
+	}
+
+	exists, err := b.MExists("a", "b", "c")
+	if err != nil || !reflect.DeepEqual(exists, []bool{true, true, false}) {
+		t.Errorf("expected MExists to return [true true false]; got %v (%v)", exists, err)
+	}
+
+	info, err := b.Info()
+	if err != nil {
+		t.Errorf("could not get Info: %v", err)
+	}
+	if info.Capacity != 1000 || info.ErrorRate != 0.001 || info.Items != 2 || info.Filters != 1 {
+		t.Errorf("unexpected Info: %+v", info)
+	}
+
+	// check guard for invalid key
+	err = store.Bloom("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/bytes"
+)
+
+// Bytes implements methods for bytes value type.
+type Bytes struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of bytes type.
+func (b *Bytes) Guard() {
+	if err := b.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (b *Bytes) GuardE() error { return b.store.guardValueE(bytes.Type, b.key) }
+
+// Get returns a copy of the bytes.
+func (b *Bytes) Get() ([]byte, error) {
+	return b.doBytes(bytes.Get)
+}
+
+// Set sets the bytes to a copy of data.
+func (b *Bytes) Set(data []byte) error {
+	_, err := b.store.Do(b.key, bytes.Set, data)
+	return err
+}
+
+// Append appends data to the bytes, returning the new length.
+func (b *Bytes) Append(data []byte) (int, error) {
+	return b.doInt(bytes.Append, data)
+}
+
+// GetRange returns a copy of the bytes from start to end, both included.
+// Negative indices count from the end.
+func (b *Bytes) GetRange(start, end int) ([]byte, error) {
+	return b.doBytes(bytes.GetRange, start, end)
+}
+
+// SetRange overwrites the bytes from the offset with data, returning the new
+// length.
+func (b *Bytes) SetRange(offset int, data []byte) (int, error) {
+	return b.doInt(bytes.SetRange, offset, data)
+}
+
+// Len returns the number of bytes.
+func (b *Bytes) Len() (int, error) {
+	return b.doInt(bytes.Len)
+}
+
+// doBytes does the action returning bytes.
+func (b *Bytes) doBytes(action kiwi.Action, params ...interface{}) ([]byte, error) {
+	v, err := b.store.Do(b.key, action, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, newTypeErr(data, v)
+	}
+
+	return data, nil
+}
+
+// doInt does the action returning an int.
+func (b *Bytes) doInt(action kiwi.Action, params ...interface{}) (int, error) {
+	v, err := b.store.Do(b.key, action, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// Interface guard.
+var _ Value = (*Bytes)(nil)
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/bytes"
+)
+
+func TestBytes(t *testing.T) {
+	store := newTestStore(t, bytes.Type)
+	b := store.Bytes(testKey)
+
+	// check that it does not panic
+	b.Guard()
+
+	// and the same should work with GuardE as well
+	if err := b.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if err := b.Set([]byte{0xff, 0x00}); err != nil {
+		t.Errorf("could not Set: %v", err)
+	}
+
+	n, err := b.Append([]byte("ab"))
+	if err != nil || n != 4 {
+		t.Errorf("expected Append to return 4; got %d (%v)", n, err)
+	}
+
+	n, err = b.SetRange(1, []byte{0x01})
+	if err != nil || n != 4 {
+		t.Errorf("expected SetRange to return 4; got %d (%v)", n, err)
+	}
+
+	data, err := b.Get()
+	if expected := []byte{0xff, 0x01, 'a', 'b'}; err != nil || !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected Get to return %v; got %v (%v)", expected, data, err)
+	}
+
+	data, err = b.GetRange(-2, -1)
+	if err != nil || string(data) != "ab" {
+		t.Errorf("expected GetRange to return ab; got %q (%v)", data, err)
+	}
+
+	n, err = b.Len()
+	if err != nil || n != 4 {
+		t.Errorf("expected Len to return 4; got %d (%v)", n, err)
+	}
+
+	// check guard for invalid key
+	err = store.Bytes("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi/values/cms"
+)
+
+// Cms implements methods for cms value type.
+type Cms struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of cms type.
+func (c *Cms) Guard() {
+	if err := c.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (c *Cms) GuardE() error { return c.store.guardValueE(cms.Type, c.key) }
+
+// InitByDim sets the width and the depth of the sketch, which should be empty.
+func (c *Cms) InitByDim(width, depth int) error {
+	_, err := c.store.Do(c.key, cms.InitByDim, width, depth)
+	return err
+}
+
+// InitByProb sizes the sketch, which should be empty, so that the estimates
+// overcount by at most errorRate times the total count with the probability
+// of 1 - uncertainty.
+func (c *Cms) InitByProb(errorRate, uncertainty float64) error {
+	_, err := c.store.Do(c.key, cms.InitByProb, errorRate, uncertainty)
+	return err
+}
+
+// IncrBy increments the count of the item, returning its new estimate.
+func (c *Cms) IncrBy(item string, incr int) (int64, error) {
+	v, err := c.store.Do(c.key, cms.IncrBy, item, incr)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int64)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// Query returns the estimated counts of the items.
+func (c *Cms) Query(items ...string) ([]int64, error) {
+	params := make([]interface{}, len(items))
+	for i := range items {
+		params[i] = items[i]
+	}
+
+	v, err := c.store.Do(c.key, cms.Query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, ok := v.([]int64)
+	if !ok {
+		return nil, newTypeErr(counts, v)
+	}
+
+	return counts, nil
+}
+
+// Info returns the dimensions and the total count of the sketch.
+func (c *Cms) Info() (cms.SketchInfo, error) {
+	v, err := c.store.Do(c.key, cms.Info)
+	if err != nil {
+		return cms.SketchInfo{}, err
+	}
+
+	info, ok := v.(cms.SketchInfo)
+	if !ok {
+		return cms.SketchInfo{}, newTypeErr(info, v)
+	}
+
+	return info, nil
+}
+
+// CmsMerge merges the cms values of the keys into the dest key, which is added
+// if it does not exist.
+func (s *Store) CmsMerge(dest string, keys ...string) error {
+	return cms.Merge(s.Store, dest, keys...)
+}
+
+// Interface guard.
+var _ Value = (*Cms)(nil)
@@ -0,0 +1,63 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/cms"
+)
+
+func TestCms(t *testing.T) {
+	store := newTestStore(t, cms.Type)
+	c := store.Cms(testKey)
+
+	// check that it does not panic
+	c.Guard()
+
+	// and the same should work with GuardE as well
+	if err := c.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if err := c.InitByProb(0.01, 0.01); err != nil {
+		t.Errorf("could not InitByProb: %v", err)
+	}
+	if err := c.InitByDim(100, 3); err != nil {
+		t.Errorf("could not InitByDim: %v", err)
+	}
+
+	if n, err := c.IncrBy("a", 3); err != nil || n != 3 {
+		t.Errorf("expected IncrBy to return 3; got %d (%v)", n, err)
+	}
+	if _, err := c.IncrBy("b", 1); err != nil {
+		t.Errorf("could not IncrBy: %v", err)
+	}
+
+	counts, err := c.Query("a", "b", "c")
+	if err != nil || !reflect.DeepEqual(counts, []int64{3, 1, 0}) {
+		t.Errorf("expected Query to return [3 1 0]; got %v (%v)", counts, err)
+	}
+
+	info, err := c.Info()
+	if err != nil || info != (cms.SketchInfo{Width: 100, Depth: 3, Count: 4}) {
+		t.Errorf("unexpected Info: %+v (%v)", info, err)
+	}
+
+	if err := store.CmsMerge("merged", testKey, testKey); err != nil {
+		t.Errorf("could not CmsMerge: %v", err)
+	}
+	counts, err = store.Cms("merged").Query("a")
+	if err != nil || !reflect.DeepEqual(counts, []int64{6}) {
+		t.Errorf("expected merged Query to return [6]; got %v (%v)", counts, err)
+	}
+
+	// check guard for invalid key
+	err = store.Cms("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/counter"
+)
+
+// Counter implements methods for counter value type.
+type Counter struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of counter type.
+func (c *Counter) Guard() {
+	if err := c.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (c *Counter) GuardE() error { return c.store.guardValueE(counter.Type, c.key) }
+
+// Get gets the value of the counter.
+func (c *Counter) Get() (int64, error) {
+	return c.do(counter.Get)
+}
+
+// Set sets the value of the counter.
+func (c *Counter) Set(n int64) error {
+	_, err := c.do(counter.Set, n)
+	return err
+}
+
+// GetSet sets the value of the counter, returning the old value.
+func (c *Counter) GetSet(n int64) (int64, error) {
+	return c.do(counter.GetSet, n)
+}
+
+// Incr increments the counter by 1, returning the updated value.
+func (c *Counter) Incr() (int64, error) {
+	return c.do(counter.Incr)
+}
+
+// Decr decrements the counter by 1, returning the updated value.
+func (c *Counter) Decr() (int64, error) {
+	return c.do(counter.Decr)
+}
+
+// IncrBy increments the counter by delta, returning the updated value.
+func (c *Counter) IncrBy(delta int64) (int64, error) {
+	return c.do(counter.IncrBy, delta)
+}
+
+// do executes the action which returns an int64.
+func (c *Counter) do(action kiwi.Action, params ...interface{}) (int64, error) {
+	v, err := c.store.Do(c.key, action, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int64)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// Interface guard.
+var _ Value = (*Counter)(nil)
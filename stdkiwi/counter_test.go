@@ -0,0 +1,65 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/counter"
+)
+
+func TestCounter(t *testing.T) {
+	store := newTestStore(t, counter.Type)
+	c := store.Counter(testKey)
+
+	// check that it does not panic
+	c.Guard()
+
+	// and the same should work with GuardE as well
+	if err := c.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	check := func(n int64, err error, expected int64) {
+		t.Helper()
+
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if n != expected {
+			t.Errorf("expected %d; got %d", expected, n)
+		}
+	}
+
+	n, err := c.Incr()
+	check(n, err, 1)
+
+	n, err = c.IncrBy(9)
+	check(n, err, 10)
+
+	n, err = c.Decr()
+	check(n, err, 9)
+
+	n, err = c.GetSet(100)
+	check(n, err, 9)
+
+	n, err = c.Get()
+	check(n, err, 100)
+
+	if err := c.Set(math.MaxInt64); err != nil {
+		t.Errorf("could not Set the counter: %v", err)
+	}
+	if _, err := c.IncrBy(1); !errors.Is(err, counter.ErrOverflow) {
+		t.Errorf("expected ErrOverflow; got %v", err)
+	}
+
+	// check guard for invalid key
+	err = store.Counter("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/decimal"
+)
+
+// Decimal implements methods for decimal value type.
+//
+// The numbers are strings like "-12.50", which avoids losing precision.
+type Decimal struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of decimal type.
+func (d *Decimal) Guard() {
+	if err := d.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (d *Decimal) GuardE() error { return d.store.guardValueE(decimal.Type, d.key) }
+
+// Get gets the number.
+func (d *Decimal) Get() (string, error) {
+	return d.do(decimal.Get)
+}
+
+// Set sets the number, rounding it to the scale with the mode.
+func (d *Decimal) Set(n string, mode decimal.RoundingMode) (string, error) {
+	return d.do(decimal.Set, n, mode)
+}
+
+// Add adds n, rounded to the scale with the mode, returning the updated number.
+func (d *Decimal) Add(n string, mode decimal.RoundingMode) (string, error) {
+	return d.do(decimal.Add, n, mode)
+}
+
+// Sub subtracts n, rounded to the scale with the mode, returning the updated
+// number.
+func (d *Decimal) Sub(n string, mode decimal.RoundingMode) (string, error) {
+	return d.do(decimal.Sub, n, mode)
+}
+
+// Mul multiplies by n, rounding the product to the scale with the mode, and
+// returns the updated number.
+func (d *Decimal) Mul(n string, mode decimal.RoundingMode) (string, error) {
+	return d.do(decimal.Mul, n, mode)
+}
+
+// Scale gets the number of digits after the decimal point.
+func (d *Decimal) Scale() (int, error) {
+	v, err := d.store.Do(d.key, decimal.Scale)
+	if err != nil {
+		return 0, err
+	}
+
+	scale, ok := v.(int)
+	if !ok {
+		return 0, newTypeErr(scale, v)
+	}
+
+	return scale, nil
+}
+
+// SetScale sets the number of digits after the decimal point, rounding the
+// number with the mode if the scale is reduced.
+func (d *Decimal) SetScale(scale int, mode decimal.RoundingMode) (string, error) {
+	return d.do(decimal.SetScale, scale, mode)
+}
+
+// do executes the action which returns a string.
+func (d *Decimal) do(action kiwi.Action, params ...interface{}) (string, error) {
+	v, err := d.store.Do(d.key, action, params...)
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", newTypeErr(s, v)
+	}
+
+	return s, nil
+}
+
+// Interface guard.
+var _ Value = (*Decimal)(nil)
@@ -0,0 +1,68 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/decimal"
+)
+
+func TestDecimal(t *testing.T) {
+	store := newTestStore(t, decimal.Type)
+	d := store.Decimal(testKey)
+
+	// check that it does not panic
+	d.Guard()
+
+	// and the same should work with GuardE as well
+	if err := d.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	check := func(n string, err error, expected string) {
+		t.Helper()
+
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if n != expected {
+			t.Errorf("expected %q; got %q", expected, n)
+		}
+	}
+
+	n, err := d.SetScale(2, decimal.RoundHalfEven)
+	check(n, err, "0.00")
+
+	n, err = d.Set("100", decimal.RoundHalfEven)
+	check(n, err, "100.00")
+
+	n, err = d.Add("0.015", decimal.RoundDown)
+	check(n, err, "100.01")
+
+	n, err = d.Sub("200", decimal.RoundHalfEven)
+	check(n, err, "-99.99")
+
+	n, err = d.Mul("1.5", decimal.RoundHalfUp)
+	check(n, err, "-149.99")
+
+	n, err = d.Get()
+	check(n, err, "-149.99")
+
+	scale, err := d.Scale()
+	if err != nil || scale != 2 {
+		t.Errorf("expected scale 2; got %d (%v)", scale, err)
+	}
+
+	if _, err := d.Add("abc", decimal.RoundHalfEven); err == nil {
+		t.Errorf("expected error while adding invalid number; got nil")
+	}
+
+	// check guard for invalid key
+	err = store.Decimal("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/float"
+)
+
+// Float implements methods for float value type.
+type Float struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of float type.
+func (f *Float) Guard() {
+	if err := f.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (f *Float) GuardE() error { return f.store.guardValueE(float.Type, f.key) }
+
+// Get gets the value of the float.
+func (f *Float) Get() (float64, error) {
+	return f.do(float.Get)
+}
+
+// Set sets the value of the float, returning the value clamped between the
+// bounds.
+func (f *Float) Set(v float64) (float64, error) {
+	return f.do(float.Set, v)
+}
+
+// IncrByFloat increments the float by delta, returning the updated value
+// clamped between the bounds.
+func (f *Float) IncrByFloat(delta float64) (float64, error) {
+	return f.do(float.IncrByFloat, delta)
+}
+
+// SetBounds sets the minimum and the maximum of the float, returning the
+// value clamped between them. Use math.Inf for no bound.
+func (f *Float) SetBounds(min, max float64) (float64, error) {
+	return f.do(float.SetBounds, min, max)
+}
+
+// Bounds returns the minimum and the maximum of the float.
+func (f *Float) Bounds() (min, max float64, err error) {
+	v, err := f.store.Do(f.key, float.Bounds)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bounds, ok := v.([2]float64)
+	if !ok {
+		return 0, 0, newTypeErr(bounds, v)
+	}
+
+	return bounds[0], bounds[1], nil
+}
+
+// do executes the action which returns a float64.
+func (f *Float) do(action kiwi.Action, params ...interface{}) (float64, error) {
+	v, err := f.store.Do(f.key, action, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(float64)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// Interface guard.
+var _ Value = (*Float)(nil)
@@ -0,0 +1,69 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/float"
+)
+
+func TestFloat(t *testing.T) {
+	store := newTestStore(t, float.Type)
+	f := store.Float(testKey)
+
+	// check that it does not panic
+	f.Guard()
+
+	// and the same should work with GuardE as well
+	if err := f.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	check := func(v float64, err error, expected float64) {
+		t.Helper()
+
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if v != expected {
+			t.Errorf("expected %v; got %v", expected, v)
+		}
+	}
+
+	v, err := f.Set(1.5)
+	check(v, err, 1.5)
+
+	v, err = f.IncrByFloat(2.25)
+	check(v, err, 3.75)
+
+	v, err = f.SetBounds(0, 2)
+	check(v, err, 2)
+
+	v, err = f.IncrByFloat(-5)
+	check(v, err, 0)
+
+	v, err = f.Get()
+	check(v, err, 0)
+
+	min, max, err := f.Bounds()
+	if err != nil || min != 0 || max != 2 {
+		t.Errorf("expected bounds 0 and 2; got %v and %v (%v)", min, max, err)
+	}
+
+	if _, err := f.SetBounds(math.Inf(-1), math.Inf(1)); err != nil {
+		t.Errorf("could not remove the bounds: %v", err)
+	}
+
+	v, err = f.IncrByFloat(-5)
+	check(v, err, -5)
+
+	// check guard for invalid key
+	err = store.Float("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
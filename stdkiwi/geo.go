@@ -0,0 +1,129 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/geo"
+)
+
+// Geo implements methods for geo value type.
+type Geo struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of geo type.
+func (g *Geo) Guard() {
+	if err := g.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (g *Geo) GuardE() error { return g.store.guardValueE(geo.Type, g.key) }
+
+// Add adds the member at the longitude and latitude, or moves it there if it
+// exists. It returns true if the member was added.
+func (g *Geo) Add(name string, lon, lat float64) (bool, error) {
+	n, err := g.doInt(geo.Add, name, lon, lat)
+	return n == 1, err
+}
+
+// Remove removes the members, returning the number of members removed.
+func (g *Geo) Remove(names ...string) (int, error) {
+	params := make([]interface{}, len(names))
+	for i := range names {
+		params[i] = names[i]
+	}
+
+	return g.doInt(geo.Remove, params...)
+}
+
+// Pos returns the positions of the members, with nil for the members which do
+// not exist.
+func (g *Geo) Pos(names ...string) ([]*geo.Position, error) {
+	params := make([]interface{}, len(names))
+	for i := range names {
+		params[i] = names[i]
+	}
+
+	v, err := g.store.Do(g.key, geo.Pos, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	positions, ok := v.([]*geo.Position)
+	if !ok {
+		return nil, newTypeErr(positions, v)
+	}
+
+	return positions, nil
+}
+
+// Dist returns the distance between the members in the unit.
+func (g *Geo) Dist(name1, name2 string, unit geo.Unit) (float64, error) {
+	v, err := g.store.Do(g.key, geo.Dist, name1, name2, unit)
+	if err != nil {
+		return 0, err
+	}
+
+	d, ok := v.(float64)
+	if !ok {
+		return 0, newTypeErr(d, v)
+	}
+
+	return d, nil
+}
+
+// SearchRadius returns the members within the radius of the center, the
+// nearest first, with the distances in the unit.
+func (g *Geo) SearchRadius(center geo.Position, radius float64, unit geo.Unit) ([]geo.Result, error) {
+	return g.doResults(geo.Search, geo.Radius{Center: center, Radius: radius, Unit: unit})
+}
+
+// SearchBox returns the members within the box, sorted by name.
+func (g *Geo) SearchBox(box geo.Box) ([]geo.Result, error) {
+	return g.doResults(geo.Search, box)
+}
+
+// Nearest returns the k members nearest to the center, the nearest first,
+// with the distances in the unit.
+func (g *Geo) Nearest(center geo.Position, k int, unit geo.Unit) ([]geo.Result, error) {
+	return g.doResults(geo.Nearest, center, k, unit)
+}
+
+// doInt executes the action which returns an int.
+func (g *Geo) doInt(action kiwi.Action, params ...interface{}) (int, error) {
+	v, err := g.store.Do(g.key, action, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// doResults executes the action which returns a []geo.Result.
+func (g *Geo) doResults(action kiwi.Action, params ...interface{}) ([]geo.Result, error) {
+	v, err := g.store.Do(g.key, action, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	results, ok := v.([]geo.Result)
+	if !ok {
+		return nil, newTypeErr(results, v)
+	}
+
+	return results, nil
+}
+
+// Interface guard.
+var _ Value = (*Geo)(nil)
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/geo"
+)
+
+func TestGeo(t *testing.T) {
+	store := newTestStore(t, geo.Type)
+	g := store.Geo(testKey)
+
+	// check that it does not panic
+	g.Guard()
+
+	// and the same should work with GuardE as well
+	if err := g.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if added, err := g.Add("Palermo", 13.361389, 38.115556); err != nil || !added {
+		t.Errorf("expected Add to return true; got %v (%v)", added, err)
+	}
+	if _, err := g.Add("Catania", 15.087269, 37.502669); err != nil {
+		t.Errorf("could not Add: %v", err)
+	}
+	if added, err := g.Add("Catania", 15.087269, 37.502669); err != nil || added {
+		t.Errorf("expected Add to return false; got %v (%v)", added, err)
+	}
+
+	pos, err := g.Pos("Palermo", "missing")
+	if err != nil || !reflect.DeepEqual(pos, []*geo.Position{{Lon: 13.361389, Lat: 38.115556}, nil}) {
+		t.Errorf("unexpected Pos: %v (%v)", pos, err)
+	}
+
+	d, err := g.Dist("Palermo", "Catania", geo.Meters)
+	if err != nil || math.Abs(d-166274.26) > 0.01 {
+		t.Errorf("expected Dist to return 166274.26; got %v (%v)", d, err)
+	}
+
+	results, err := g.SearchRadius(geo.Position{Lon: 15, Lat: 37}, 100, geo.Kilometers)
+	if err != nil || len(results) != 1 || results[0].Name != "Catania" {
+		t.Errorf("expected SearchRadius to find Catania; got %v (%v)", results, err)
+	}
+
+	results, err = g.SearchBox(geo.Box{MinLon: 13, MinLat: 37, MaxLon: 16, MaxLat: 39})
+	if err != nil || len(results) != 2 {
+		t.Errorf("expected SearchBox to find 2 members; got %v (%v)", results, err)
+	}
+
+	results, err = g.Nearest(geo.Position{Lon: 13, Lat: 38}, 1, geo.Miles)
+	if err != nil || len(results) != 1 || results[0].Name != "Palermo" {
+		t.Errorf("expected Nearest to find Palermo; got %v (%v)", results, err)
+	}
+
+	if n, err := g.Remove("Palermo", "missing"); err != nil || n != 1 {
+		t.Errorf("expected Remove to return 1; got %d (%v)", n, err)
+	}
+
+	// check guard for invalid key
+	err = store.Geo("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import "github.com/sdslabs/kiwi/values/hll"
+
+// Hll implements methods for hll value type.
+type Hll struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of hll type.
+func (h *Hll) Guard() {
+	if err := h.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (h *Hll) GuardE() error { return h.store.guardValueE(hll.Type, h.key) }
+
+// Add adds the elements, returning true if the estimate may have changed.
+func (h *Hll) Add(elements ...string) (bool, error) {
+	params := make([]interface{}, len(elements))
+	for i := range elements {
+		params[i] = elements[i]
+	}
+
+	v, err := h.store.Do(h.key, hll.Add, params...)
+	if err != nil {
+		return false, err
+	}
+
+	changed, ok := v.(bool)
+	if !ok {
+		return false, newTypeErr(changed, v)
+	}
+
+	return changed, nil
+}
+
+// Count estimates the number of distinct elements added.
+func (h *Hll) Count() (int64, error) {
+	v, err := h.store.Do(h.key, hll.Count)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int64)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// HllMerge merges the hll values of the keys into the dest key, which is added
+// if it does not exist.
+func (s *Store) HllMerge(dest string, keys ...string) error {
+	return hll.Merge(s.Store, dest, keys...)
+}
+
+// HllCount estimates the number of distinct elements added to any of the hll
+// values of the keys.
+func (s *Store) HllCount(keys ...string) (int64, error) {
+	return hll.CountKeys(s.Store, keys...)
+}
+
+// Interface guard.
+var _ Value = (*Hll)(nil)
@@ -0,0 +1,59 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/hll"
+)
+
+func TestHll(t *testing.T) {
+	store := newTestStore(t, hll.Type)
+	h := store.Hll(testKey)
+
+	// check that it does not panic
+	h.Guard()
+
+	// and the same should work with GuardE as well
+	if err := h.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if changed, err := h.Add("a", "b", "c"); err != nil || !changed {
+		t.Errorf("expected Add to return true; got %v (%v)", changed, err)
+	}
+	if changed, err := h.Add("a"); err != nil || changed {
+		t.Errorf("expected Add to return false; got %v (%v)", changed, err)
+	}
+
+	if n, err := h.Count(); err != nil || n != 3 {
+		t.Errorf("expected Count 3; got %d (%v)", n, err)
+	}
+
+	if err := store.AddKey("other", hll.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+	if _, err := store.Hll("other").Add("c", "d"); err != nil {
+		t.Errorf("could not Add: %v", err)
+	}
+
+	if n, err := store.HllCount(testKey, "other"); err != nil || n != 4 {
+		t.Errorf("expected HllCount 4; got %d (%v)", n, err)
+	}
+
+	if err := store.HllMerge("dest", testKey, "other"); err != nil {
+		t.Errorf("could not HllMerge: %v", err)
+	}
+	if n, err := store.Hll("dest").Count(); err != nil || n != 4 {
+		t.Errorf("expected merged Count 4; got %d (%v)", n, err)
+	}
+
+	// check guard for invalid key
+	err := store.Hll("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
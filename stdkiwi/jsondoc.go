@@ -0,0 +1,130 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"encoding/json"
+
+	"github.com/sdslabs/kiwi/values/jsondoc"
+)
+
+// JSONDoc implements methods for jsondoc value type.
+type JSONDoc struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of jsondoc type.
+func (j *JSONDoc) Guard() {
+	if err := j.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (j *JSONDoc) GuardE() error { return j.store.guardValueE(jsondoc.Type, j.key) }
+
+// Get gets the raw JSON at the gjson path, or nil if nothing matches it.
+func (j *JSONDoc) Get(path string) (json.RawMessage, error) {
+	v, err := j.store.Do(j.key, jsondoc.Get, path)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	raw, ok := v.(json.RawMessage)
+	if !ok {
+		return nil, newTypeErr(raw, v)
+	}
+
+	return raw, nil
+}
+
+// Set sets the value at the path. The value is raw JSON if it is a
+// json.RawMessage, or else it is encoded to JSON.
+func (j *JSONDoc) Set(path string, value interface{}) error {
+	_, err := j.store.Do(j.key, jsondoc.Set, path, value)
+	return err
+}
+
+// Del deletes the value at the path, returning false if it does not exist.
+func (j *JSONDoc) Del(path string) (bool, error) {
+	v, err := j.store.Do(j.key, jsondoc.Del, path)
+	if err != nil {
+		return false, err
+	}
+
+	n, ok := v.(int)
+	if !ok {
+		return false, newTypeErr(n, v)
+	}
+
+	return n == 1, nil
+}
+
+// ArrAppend appends the values to the array at the path, returning the length
+// of the array.
+func (j *JSONDoc) ArrAppend(path string, values ...interface{}) (int, error) {
+	v, err := j.store.Do(j.key, jsondoc.ArrAppend, append([]interface{}{path}, values...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// NumIncrBy increments the number at the path, returning the new number.
+func (j *JSONDoc) NumIncrBy(path string, incr int) (json.Number, error) {
+	return j.numIncrBy(path, incr)
+}
+
+// NumIncrByFloat is same as NumIncrBy but with a float increment.
+func (j *JSONDoc) NumIncrByFloat(path string, incr float64) (json.Number, error) {
+	return j.numIncrBy(path, incr)
+}
+
+// Type gets the JSON type of the value at the gjson path, or "" if nothing
+// matches it.
+func (j *JSONDoc) Type(path string) (string, error) {
+	v, err := j.store.Do(j.key, jsondoc.TypeOf, path)
+	if err != nil || v == nil {
+		return "", err
+	}
+
+	typ, ok := v.(string)
+	if !ok {
+		return "", newTypeErr(typ, v)
+	}
+
+	return typ, nil
+}
+
+// Merge merges the patch into the value at the path as in RFC 7386.
+func (j *JSONDoc) Merge(path string, patch interface{}) error {
+	_, err := j.store.Do(j.key, jsondoc.Merge, path, patch)
+	return err
+}
+
+// numIncrBy executes the NUMINCRBY action with the increment.
+func (j *JSONDoc) numIncrBy(path string, incr interface{}) (json.Number, error) {
+	v, err := j.store.Do(j.key, jsondoc.NumIncrBy, path, incr)
+	if err != nil {
+		return "", err
+	}
+
+	n, ok := v.(json.Number)
+	if !ok {
+		return "", newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// Interface guard.
+var _ Value = (*JSONDoc)(nil)
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/jsondoc"
+)
+
+func TestJSONDoc(t *testing.T) {
+	store := newTestStore(t, jsondoc.Type)
+	j := store.JSONDoc(testKey)
+
+	// check that it does not panic
+	j.Guard()
+
+	// and the same should work with GuardE as well
+	if err := j.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if err := j.Set("", json.RawMessage(`{"a":{"b":1},"list":[]}`)); err != nil {
+		t.Errorf("could not Set: %v", err)
+	}
+	if err := j.Set("a.c", "x"); err != nil {
+		t.Errorf("could not Set: %v", err)
+	}
+
+	raw, err := j.Get("a")
+	if err != nil || string(raw) != `{"b":1,"c":"x"}` {
+		t.Errorf("unexpected Get: %s (%v)", raw, err)
+	}
+	if raw, err := j.Get("missing"); err != nil || raw != nil {
+		t.Errorf("expected Get to return nil; got %s (%v)", raw, err)
+	}
+
+	if n, err := j.ArrAppend("list", 1, "two"); err != nil || n != 2 {
+		t.Errorf("expected ArrAppend to return 2; got %d (%v)", n, err)
+	}
+
+	if n, err := j.NumIncrBy("a.b", 2); err != nil || n != "3" {
+		t.Errorf("expected NumIncrBy to return 3; got %s (%v)", n, err)
+	}
+	if n, err := j.NumIncrByFloat("a.b", 0.5); err != nil || n != "3.5" {
+		t.Errorf("expected NumIncrByFloat to return 3.5; got %s (%v)", n, err)
+	}
+
+	if typ, err := j.Type("list"); err != nil || typ != "array" {
+		t.Errorf("expected Type to return array; got %q (%v)", typ, err)
+	}
+
+	if err := j.Merge("a", map[string]interface{}{"c": nil, "d": true}); err != nil {
+		t.Errorf("could not Merge: %v", err)
+	}
+	if raw, err := j.Get("a"); err != nil || string(raw) != `{"b":3.5,"d":true}` {
+		t.Errorf("unexpected Get after Merge: %s (%v)", raw, err)
+	}
+
+	if ok, err := j.Del("list.0"); err != nil || !ok {
+		t.Errorf("expected Del to return true; got %v (%v)", ok, err)
+	}
+	if raw, err := j.Get("list"); err != nil || string(raw) != `["two"]` {
+		t.Errorf("unexpected Get after Del: %s (%v)", raw, err)
+	}
+
+	// check guard for invalid key
+	err = store.JSONDoc("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
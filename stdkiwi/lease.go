@@ -0,0 +1,125 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"context"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/lease"
+)
+
+// Lease implements methods for lease value type.
+type Lease struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of lease type.
+func (l *Lease) Guard() {
+	if err := l.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (l *Lease) GuardE() error { return l.store.guardValueE(lease.Type, l.key) }
+
+// Acquire acquires the lease for the owner, renewing it if already held by the
+// owner, and returns its fencing token.
+func (l *Lease) Acquire(owner string, ttl time.Duration) (uint64, error) {
+	h, err := l.doHolder(lease.Acquire, owner, ttl)
+	return h.Token, err
+}
+
+// Renew renews the lease held by the owner.
+func (l *Lease) Renew(owner string, ttl time.Duration) error {
+	_, err := l.doHolder(lease.Renew, owner, ttl)
+	return err
+}
+
+// Release releases the lease if held by the owner, returning false if not.
+func (l *Lease) Release(owner string) (bool, error) {
+	v, err := l.store.Do(l.key, lease.Release, owner)
+	if err != nil {
+		return false, err
+	}
+
+	ok, isBool := v.(bool)
+	if !isBool {
+		return false, newTypeErr(ok, v)
+	}
+
+	return ok, nil
+}
+
+// Holder returns the holder of the lease, or nil if it is not held.
+func (l *Lease) Holder() (*lease.Holder, error) {
+	v, err := l.store.Do(l.key, lease.GetHolder)
+	if err != nil {
+		return nil, err
+	}
+
+	h, ok := v.(*lease.Holder)
+	if !ok {
+		return nil, newTypeErr(h, v)
+	}
+
+	return h, nil
+}
+
+// SetClock sets the clock the lease expires by, or time.Now if nil. It is
+// useful to test the expiry without waiting.
+func (l *Lease) SetClock(clock lease.Clock) error {
+	_, err := l.store.Do(l.key, lease.SetClock, clock)
+	return err
+}
+
+// KeepAlive renews the lease held by the owner at once and then every third of
+// the ttl until the context is done, returning nil, or until renewing fails,
+// returning the error. An error means that the lease may have been lost.
+func (l *Lease) KeepAlive(ctx context.Context, owner string, ttl time.Duration) error {
+	if err := l.Renew(owner, ttl); err != nil {
+		return err
+	}
+
+	interval := ttl / 3
+	if interval <= 0 {
+		interval = ttl
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := l.Renew(owner, ttl); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// doHolder does the action for the owner with the ttl.
+func (l *Lease) doHolder(action kiwi.Action, owner string, ttl time.Duration) (lease.Holder, error) {
+	v, err := l.store.Do(l.key, action, owner, ttl)
+	if err != nil {
+		return lease.Holder{}, err
+	}
+
+	h, ok := v.(lease.Holder)
+	if !ok {
+		return lease.Holder{}, newTypeErr(h, v)
+	}
+
+	return h, nil
+}
+
+// Interface guard.
+var _ Value = (*Lease)(nil)
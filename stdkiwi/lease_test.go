@@ -0,0 +1,108 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi/values/lease"
+)
+
+func TestLease(t *testing.T) {
+	store := newTestStore(t, lease.Type)
+	l := store.Lease(testKey)
+
+	// check that it does not panic
+	l.Guard()
+
+	// and the same should work with GuardE as well
+	if err := l.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	token, err := l.Acquire("a", time.Hour)
+	if err != nil || token != 1 {
+		t.Errorf("expected Acquire to return token 1; got %d (%v)", token, err)
+	}
+
+	if _, err := l.Acquire("b", time.Hour); !errors.Is(err, lease.ErrHeld) {
+		t.Errorf("expected ErrHeld; got %v", err)
+	}
+
+	if err := l.Renew("a", time.Hour); err != nil {
+		t.Errorf("could not Renew: %v", err)
+	}
+
+	h, err := l.Holder()
+	if err != nil || h == nil || h.Owner != "a" || h.Token != 1 {
+		t.Errorf("expected Holder a with token 1; got %+v (%v)", h, err)
+	}
+
+	if ok, err := l.Release("a"); err != nil || !ok {
+		t.Errorf("expected Release to return true; got %v (%v)", ok, err)
+	}
+
+	h, err = l.Holder()
+	if err != nil || h != nil {
+		t.Errorf("expected no Holder; got %+v (%v)", h, err)
+	}
+
+	// the lease expires by its clock
+	now := time.Now()
+	if err := l.SetClock(func() time.Time { return now }); err != nil {
+		t.Errorf("could not SetClock: %v", err)
+	}
+	if _, err := l.Acquire("a", time.Hour); err != nil {
+		t.Errorf("could not Acquire: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	h, err = l.Holder()
+	if err != nil || h != nil {
+		t.Errorf("expected lease to expire; got %+v (%v)", h, err)
+	}
+
+	if err := l.SetClock(nil); err != nil {
+		t.Errorf("could not SetClock to nil: %v", err)
+	}
+
+	// check guard for invalid key
+	err = store.Lease("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
+
+func TestLease_KeepAlive(t *testing.T) {
+	store := newTestStore(t, lease.Type)
+	l := store.Lease(testKey)
+
+	ttl := 60 * time.Millisecond
+	if _, err := l.Acquire("a", ttl); err != nil {
+		t.Fatalf("could not Acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.KeepAlive(ctx, "a", ttl) }()
+
+	time.Sleep(3 * ttl)
+	if h, err := l.Holder(); err != nil || h == nil || h.Owner != "a" {
+		t.Errorf("expected lease to be kept alive; got %+v (%v)", h, err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected KeepAlive to return nil when done; got %v", err)
+	}
+
+	// keeping alive a lease not held fails
+	if err := l.KeepAlive(context.Background(), "b", ttl); !errors.Is(err, lease.ErrNotHolder) {
+		t.Errorf("expected ErrNotHolder; got %v", err)
+	}
+}
@@ -4,7 +4,12 @@
 
 package stdkiwi
 
-import "github.com/sdslabs/kiwi/values/list"
+import (
+	"context"
+	"time"
+
+	"github.com/sdslabs/kiwi/values/list"
+)
 
 // List implements methods for list value type.
 type List struct {
@@ -108,6 +113,13 @@ func (l *List) Pop(n int) error {
 	return nil
 }
 
+// BlockingPop pops off the last element of the list, waiting until an element
+// is appended if the list is empty or the context is done.
+func (l *List) BlockingPop(ctx context.Context) (string, error) {
+	_, elem, err := list.BRPop(ctx, l.store.Store, 0, l.key)
+	return elem, err
+}
+
 // Remove removes the elem with the given index.
 func (l *List) Remove(index int) error {
 	if _, err := l.store.Do(l.key, list.Remove, index); err != nil {
@@ -141,5 +153,17 @@ func (l *List) Find(value string) (int, error) {
 	return idx, nil
 }
 
+// BLPop pops the first element of the first non-empty list of the keys,
+// waiting until an element is appended to any of them for at most timeout,
+// if not 0. It returns the key and the element popped.
+func (s *Store) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return list.BLPop(ctx, s.Store, timeout, keys...)
+}
+
+// BRPop is same as BLPop but pops the last element of the list.
+func (s *Store) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (string, string, error) {
+	return list.BRPop(ctx, s.Store, timeout, keys...)
+}
+
 // Interface guard.
 var _ Value = (*List)(nil)
@@ -5,7 +5,9 @@
 package stdkiwi
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/sdslabs/kiwi/values/list"
 )
@@ -114,3 +116,42 @@ func TestList(t *testing.T) {
 		t.Errorf("expected error while GuardE for invalid key; got nil")
 	}
 }
+
+func TestList_BlockingPop(t *testing.T) {
+	store := newTestStore(t, list.Type)
+	l := store.List(testKey)
+
+	popped := make(chan string, 1)
+	go func() {
+		elem, err := l.BlockingPop(context.Background())
+		if err != nil {
+			t.Errorf("could not BlockingPop: %v", err)
+		}
+		popped <- elem
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := l.Append("a", "b"); err != nil {
+		t.Fatalf("could not Append: %v", err)
+	}
+
+	select {
+	case elem := <-popped:
+		if elem != "b" {
+			t.Errorf("expected BlockingPop to pop b; got %q", elem)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("BlockingPop was not woken by Append")
+	}
+
+	key, elem, err := store.BLPop(context.Background(), 0, "randomKey", testKey)
+	if err != nil || key != testKey || elem != "a" {
+		t.Errorf("expected BLPop to pop a; got %q from %q (%v)", elem, key, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := store.BRPop(ctx, 0, testKey); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded; got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"time"
+
+	"github.com/sdslabs/kiwi/values/ratelimit"
+)
+
+// RateLimiter implements methods for ratelimit value type.
+type RateLimiter struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of ratelimit type.
+func (r *RateLimiter) Guard() {
+	if err := r.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (r *RateLimiter) GuardE() error { return r.store.guardValueE(ratelimit.Type, r.key) }
+
+// Configure configures the limiter to allow limit requests every period with
+// the algorithm.
+func (r *RateLimiter) Configure(algo ratelimit.Algorithm, limit int, period time.Duration) error {
+	_, err := r.store.Do(r.key, ratelimit.Configure, algo, limit, period)
+	return err
+}
+
+// Allow decides whether n requests are allowed.
+func (r *RateLimiter) Allow(n int) (ratelimit.Result, error) {
+	v, err := r.store.Do(r.key, ratelimit.Allow, n)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	res, ok := v.(ratelimit.Result)
+	if !ok {
+		return ratelimit.Result{}, newTypeErr(res, v)
+	}
+
+	return res, nil
+}
+
+// Reset forgets the requests allowed.
+func (r *RateLimiter) Reset() error {
+	_, err := r.store.Do(r.key, ratelimit.Reset)
+	return err
+}
+
+// SetClock sets the clock the requests are timed by, or time.Now if nil. It is
+// useful to test the limits without waiting.
+func (r *RateLimiter) SetClock(clock ratelimit.Clock) error {
+	_, err := r.store.Do(r.key, ratelimit.SetClock, clock)
+	return err
+}
+
+// Config returns the configuration of the limiter.
+func (r *RateLimiter) Config() (ratelimit.Config, error) {
+	v, err := r.store.Do(r.key, ratelimit.GetConfig)
+	if err != nil {
+		return ratelimit.Config{}, err
+	}
+
+	config, ok := v.(ratelimit.Config)
+	if !ok {
+		return ratelimit.Config{}, newTypeErr(config, v)
+	}
+
+	return config, nil
+}
+
+// Interface guard.
+var _ Value = (*RateLimiter)(nil)
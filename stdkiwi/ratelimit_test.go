@@ -0,0 +1,78 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi/values/ratelimit"
+)
+
+func TestRateLimiter(t *testing.T) {
+	store := newTestStore(t, ratelimit.Type)
+	r := store.RateLimiter(testKey)
+
+	// check that it does not panic
+	r.Guard()
+
+	// and the same should work with GuardE as well
+	if err := r.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if err := r.Configure(ratelimit.SlidingWindow, 3, time.Hour); err != nil {
+		t.Errorf("could not Configure: %v", err)
+	}
+
+	config, err := r.Config()
+	if expected := (ratelimit.Config{Algorithm: ratelimit.SlidingWindow, Limit: 3, Period: time.Hour}); err != nil || config != expected {
+		t.Errorf("expected Config %+v; got %+v (%v)", expected, config, err)
+	}
+
+	res, err := r.Allow(3)
+	if err != nil || !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected Allow to allow; got %+v (%v)", res, err)
+	}
+
+	res, err = r.Allow(1)
+	if err != nil || res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Hour {
+		t.Errorf("expected Allow to deny with retry after; got %+v (%v)", res, err)
+	}
+
+	if err := r.Reset(); err != nil {
+		t.Errorf("could not Reset: %v", err)
+	}
+
+	res, err = r.Allow(1)
+	if err != nil || !res.Allowed || res.Remaining != 2 {
+		t.Errorf("expected Allow to allow after Reset; got %+v (%v)", res, err)
+	}
+
+	// the window slides with the clock of the limiter
+	now := time.Now()
+	if err := r.SetClock(func() time.Time { return now }); err != nil {
+		t.Errorf("could not SetClock: %v", err)
+	}
+	if res, err := r.Allow(2); err != nil || !res.Allowed {
+		t.Errorf("expected Allow to allow; got %+v (%v)", res, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	res, err = r.Allow(3)
+	if err != nil || !res.Allowed {
+		t.Errorf("expected Allow to allow after the period; got %+v (%v)", res, err)
+	}
+
+	if err := r.SetClock(nil); err != nil {
+		t.Errorf("could not SetClock to nil: %v", err)
+	}
+
+	// check guard for invalid key
+	err = store.RateLimiter("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
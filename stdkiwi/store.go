@@ -121,6 +121,198 @@ func (s *Store) Zhash(key string) *Zhash {
 	}
 }
 
+// Counter returns a "Counter" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Counter(key string) *Counter {
+	return &Counter{
+		store: s,
+		key:   key,
+	}
+}
+
+// Float returns a "Float" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Float(key string) *Float {
+	return &Float{
+		store: s,
+		key:   key,
+	}
+}
+
+// Decimal returns a "Decimal" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Decimal(key string) *Decimal {
+	return &Decimal{
+		store: s,
+		key:   key,
+	}
+}
+
+// Bitmap returns a "Bitmap" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Bitmap(key string) *Bitmap {
+	return &Bitmap{
+		store: s,
+		key:   key,
+	}
+}
+
+// Hll returns a "Hll" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Hll(key string) *Hll {
+	return &Hll{
+		store: s,
+		key:   key,
+	}
+}
+
+// Bloom returns a "Bloom" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Bloom(key string) *Bloom {
+	return &Bloom{
+		store: s,
+		key:   key,
+	}
+}
+
+// Cms returns a "Cms" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Cms(key string) *Cms {
+	return &Cms{
+		store: s,
+		key:   key,
+	}
+}
+
+// Topk returns a "Topk" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Topk(key string) *Topk {
+	return &Topk{
+		store: s,
+		key:   key,
+	}
+}
+
+// Geo returns a "Geo" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Geo(key string) *Geo {
+	return &Geo{
+		store: s,
+		key:   key,
+	}
+}
+
+// Stream returns a "Stream" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Stream(key string) *Stream {
+	return &Stream{
+		store: s,
+		key:   key,
+	}
+}
+
+// JSONDoc returns a "JSONDoc" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) JSONDoc(key string) *JSONDoc {
+	return &JSONDoc{
+		store: s,
+		key:   key,
+	}
+}
+
+// TimeSeries returns a "TimeSeries" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) TimeSeries(key string) *TimeSeries {
+	return &TimeSeries{
+		store: s,
+		key:   key,
+	}
+}
+
+// RateLimiter returns a "RateLimiter" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) RateLimiter(key string) *RateLimiter {
+	return &RateLimiter{
+		store: s,
+		key:   key,
+	}
+}
+
+// Lease returns a "Lease" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Lease(key string) *Lease {
+	return &Lease{
+		store: s,
+		key:   key,
+	}
+}
+
+// Bytes returns a "Bytes" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Bytes(key string) *Bytes {
+	return &Bytes{
+		store: s,
+		key:   key,
+	}
+}
+
+// Trie returns a "Trie" with the key set as "key".
+//
+// This does not verify if the key and value type pair is correct.
+// If this does not work, "Do" will eventually throw an error.
+// To avoid this use "GuardE" method or "Guard" if schema is pre-defined.
+func (s *Store) Trie(key string) *Trie {
+	return &Trie{
+		store: s,
+		key:   key,
+	}
+}
+
 // Value can be used to access the methods for standard value types.
 type Value interface {
 	// Guard should panic if the key does not correspond to the correct type.
@@ -0,0 +1,198 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/stream"
+)
+
+// Stream implements methods for stream value type.
+type Stream struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of stream type.
+func (s *Stream) Guard() {
+	if err := s.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (s *Stream) GuardE() error { return s.store.guardValueE(stream.Type, s.key) }
+
+// Add adds an entry with the ID, or a generated ID if it is "*", trimming the
+// stream to maxLen entries if it is not 0. It returns the ID of the entry.
+func (s *Stream) Add(id string, fields map[string]string, maxLen int) (string, error) {
+	v, err := s.store.Do(s.key, stream.Add, id, fields, maxLen)
+	if err != nil {
+		return "", err
+	}
+
+	res, ok := v.(string)
+	if !ok {
+		return "", newTypeErr(res, v)
+	}
+
+	return res, nil
+}
+
+// Range returns at most count entries, or all if it is 0, from start to end,
+// the oldest first.
+func (s *Stream) Range(start, end string, count int) ([]stream.Entry, error) {
+	return s.doEntries(stream.Range, start, end, count)
+}
+
+// RevRange returns at most count entries, or all if it is 0, from end to
+// start, the newest first.
+func (s *Stream) RevRange(end, start string, count int) ([]stream.Entry, error) {
+	return s.doEntries(stream.RevRange, end, start, count)
+}
+
+// Len returns the number of entries.
+func (s *Stream) Len() (int, error) {
+	v, err := s.store.Do(s.key, stream.Len)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// GroupCreate creates the consumer group which delivers the entries after the
+// ID, or the entries added from now on if it is "$".
+func (s *Stream) GroupCreate(group, id string) error {
+	_, err := s.store.Do(s.key, stream.GroupCreate, group, id)
+	return err
+}
+
+// GroupDestroy destroys the consumer group, returning true if it existed.
+func (s *Stream) GroupDestroy(group string) (bool, error) {
+	v, err := s.store.Do(s.key, stream.GroupDestroy, group)
+	if err != nil {
+		return false, err
+	}
+
+	ok, isBool := v.(bool)
+	if !isBool {
+		return false, newTypeErr(ok, v)
+	}
+
+	return ok, nil
+}
+
+// ReadGroup delivers at most count entries, or all if it is 0, never delivered
+// to the group to the consumer if the ID is ">". Otherwise it reads the
+// entries after the ID which are pending for the consumer.
+func (s *Stream) ReadGroup(group, consumer, id string, count int) ([]stream.Entry, error) {
+	v, err := s.store.Do(s.key, stream.ReadGroup, group, consumer, id, count)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, ok := v.([]stream.Entry)
+	if !ok {
+		return nil, newTypeErr(entries, v)
+	}
+
+	return entries, nil
+}
+
+// Ack acknowledges the entries for the group, returning the number of entries
+// which were pending.
+func (s *Stream) Ack(group string, ids ...string) (int, error) {
+	params := make([]interface{}, 0, len(ids)+1)
+	params = append(params, group)
+	for i := range ids {
+		params = append(params, ids[i])
+	}
+
+	v, err := s.store.Do(s.key, stream.Ack, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// Pending returns the entries pending for the group.
+func (s *Stream) Pending(group string) ([]stream.PendingEntry, error) {
+	return s.doPending(group)
+}
+
+// PendingFor returns the entries of the group pending for the consumer.
+func (s *Stream) PendingFor(group, consumer string) ([]stream.PendingEntry, error) {
+	return s.doPending(group, consumer)
+}
+
+// Claim transfers the entries pending for the group to the consumer if they
+// have been idle for at least minIdle, returning the entries claimed.
+func (s *Stream) Claim(group, consumer string, minIdle time.Duration, ids ...string) ([]stream.Entry, error) {
+	params := make([]interface{}, 0, len(ids)+3)
+	params = append(params, group, consumer, minIdle)
+	for i := range ids {
+		params = append(params, ids[i])
+	}
+
+	v, err := s.store.Do(s.key, stream.Claim, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, ok := v.([]stream.Entry)
+	if !ok {
+		return nil, newTypeErr(entries, v)
+	}
+
+	return entries, nil
+}
+
+// doEntries executes the range action which returns a []stream.Entry.
+func (s *Stream) doEntries(action kiwi.Action, first, second string, count int) ([]stream.Entry, error) {
+	v, err := s.store.Do(s.key, action, first, second, count)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, ok := v.([]stream.Entry)
+	if !ok {
+		return nil, newTypeErr(entries, v)
+	}
+
+	return entries, nil
+}
+
+// doPending executes the pending action for the group, and the consumer if
+// given.
+func (s *Stream) doPending(params ...interface{}) ([]stream.PendingEntry, error) {
+	v, err := s.store.Do(s.key, stream.Pending, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, ok := v.([]stream.PendingEntry)
+	if !ok {
+		return nil, newTypeErr(entries, v)
+	}
+
+	return entries, nil
+}
+
+// Interface guard.
+var _ Value = (*Stream)(nil)
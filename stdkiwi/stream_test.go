@@ -0,0 +1,86 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/stream"
+)
+
+func TestStream(t *testing.T) {
+	store := newTestStore(t, stream.Type)
+	s := store.Stream(testKey)
+
+	// check that it does not panic
+	s.Guard()
+
+	// and the same should work with GuardE as well
+	if err := s.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		if _, err := s.Add(id, map[string]string{"n": id}, 0); err != nil {
+			t.Errorf("could not Add: %v", err)
+		}
+	}
+
+	if id, err := s.Add("4-0", map[string]string{}, 3); err != nil || id != "4-0" {
+		t.Errorf("expected Add to return 4-0; got %q (%v)", id, err)
+	}
+	if n, err := s.Len(); err != nil || n != 3 {
+		t.Errorf("expected Len to return 3; got %d (%v)", n, err)
+	}
+
+	entries, err := s.Range("-", "+", 2)
+	if err != nil || len(entries) != 2 || entries[0].ID != "2-0" || entries[0].Fields["n"] != "2-0" {
+		t.Errorf("unexpected Range: %v (%v)", entries, err)
+	}
+
+	entries, err = s.RevRange("+", "-", 1)
+	if err != nil || len(entries) != 1 || entries[0].ID != "4-0" {
+		t.Errorf("unexpected RevRange: %v (%v)", entries, err)
+	}
+
+	if err := s.GroupCreate("g", "2-0"); err != nil {
+		t.Errorf("could not GroupCreate: %v", err)
+	}
+
+	entries, err = s.ReadGroup("g", "c1", ">", 0)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("expected ReadGroup to deliver 2 entries; got %v (%v)", entries, err)
+	}
+
+	if n, err := s.Ack("g", "3-0"); err != nil || n != 1 {
+		t.Errorf("expected Ack to return 1; got %d (%v)", n, err)
+	}
+
+	claimed, err := s.Claim("g", "c2", 0, "4-0")
+	if err != nil || len(claimed) != 1 {
+		t.Errorf("expected Claim to claim 1 entry; got %v (%v)", claimed, err)
+	}
+
+	pending, err := s.Pending("g")
+	if err != nil || len(pending) != 1 || pending[0].Consumer != "c2" {
+		t.Errorf("unexpected Pending: %+v (%v)", pending, err)
+	}
+
+	pending, err = s.PendingFor("g", "c1")
+	if err != nil || !reflect.DeepEqual(pending, []stream.PendingEntry{}) {
+		t.Errorf("expected PendingFor to return nothing; got %+v (%v)", pending, err)
+	}
+
+	if ok, err := s.GroupDestroy("g"); err != nil || !ok {
+		t.Errorf("expected GroupDestroy to return true; got %v (%v)", ok, err)
+	}
+
+	// check guard for invalid key
+	err = store.Stream("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
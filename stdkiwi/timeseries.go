@@ -0,0 +1,121 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"time"
+
+	"github.com/sdslabs/kiwi/values/timeseries"
+)
+
+// TimeSeries implements methods for timeseries value type.
+type TimeSeries struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of timeseries type.
+func (t *TimeSeries) Guard() {
+	if err := t.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (t *TimeSeries) GuardE() error { return t.store.guardValueE(timeseries.Type, t.key) }
+
+// Add adds the value at the timestamp in milliseconds, and adds the samples
+// compacted by the rules of the series to their destinations.
+func (t *TimeSeries) Add(timestamp int64, value float64) error {
+	return timeseries.Record(t.store.Store, t.key, timestamp, value)
+}
+
+// Get returns the newest sample, or nil if the series is empty.
+func (t *TimeSeries) Get() (*timeseries.Sample, error) {
+	v, err := t.store.Do(t.key, timeseries.Get)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := v.(*timeseries.Sample)
+	if !ok {
+		return nil, newTypeErr(s, v)
+	}
+
+	return s, nil
+}
+
+// Range returns the samples from the timestamp to the timestamp, both
+// included.
+func (t *TimeSeries) Range(from, to int64) ([]timeseries.Sample, error) {
+	return t.xrange(from, to)
+}
+
+// RangeAgg returns the samples from the timestamp to the timestamp aggregated
+// into buckets, timestamped with their start.
+func (t *TimeSeries) RangeAgg(from, to int64, agg timeseries.Aggregation, bucket time.Duration) ([]timeseries.Sample, error) {
+	return t.xrange(from, to, agg, bucket)
+}
+
+// Alter sets the retention and the duplicate policy of the series.
+func (t *TimeSeries) Alter(retention time.Duration, policy timeseries.DuplicatePolicy) error {
+	_, err := t.store.Do(t.key, timeseries.Alter, retention, policy)
+	return err
+}
+
+// CreateRule creates a rule compacting the series into the dest key. It fails
+// if the series would be compacted into itself.
+func (t *TimeSeries) CreateRule(dest string, agg timeseries.Aggregation, bucket time.Duration) error {
+	return timeseries.AddRule(t.store.Store, t.key, dest, agg, bucket)
+}
+
+// DeleteRule deletes the rule for the dest key, returning false if there is
+// none.
+func (t *TimeSeries) DeleteRule(dest string) (bool, error) {
+	v, err := t.store.Do(t.key, timeseries.DeleteRule, dest)
+	if err != nil {
+		return false, err
+	}
+
+	ok, isBool := v.(bool)
+	if !isBool {
+		return false, newTypeErr(ok, v)
+	}
+
+	return ok, nil
+}
+
+// Info returns the information about the series.
+func (t *TimeSeries) Info() (timeseries.SeriesInfo, error) {
+	v, err := t.store.Do(t.key, timeseries.Info)
+	if err != nil {
+		return timeseries.SeriesInfo{}, err
+	}
+
+	info, ok := v.(timeseries.SeriesInfo)
+	if !ok {
+		return timeseries.SeriesInfo{}, newTypeErr(info, v)
+	}
+
+	return info, nil
+}
+
+// xrange does the RANGE action with the params.
+func (t *TimeSeries) xrange(params ...interface{}) ([]timeseries.Sample, error) {
+	v, err := t.store.Do(t.key, timeseries.Range, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	samples, ok := v.([]timeseries.Sample)
+	if !ok {
+		return nil, newTypeErr(samples, v)
+	}
+
+	return samples, nil
+}
+
+// Interface guard.
+var _ Value = (*TimeSeries)(nil)
@@ -0,0 +1,78 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi/values/timeseries"
+)
+
+func TestTimeSeries(t *testing.T) {
+	store := newTestStore(t, timeseries.Type)
+	ts := store.TimeSeries(testKey)
+
+	// check that it does not panic
+	ts.Guard()
+
+	// and the same should work with GuardE as well
+	if err := ts.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if err := ts.Alter(time.Hour, timeseries.DupSum); err != nil {
+		t.Errorf("could not Alter: %v", err)
+	}
+	if err := ts.CreateRule("compacted", timeseries.AggSum, time.Second); err != nil {
+		t.Errorf("could not CreateRule: %v", err)
+	}
+	if err := ts.CreateRule(testKey, timeseries.AggSum, time.Second); !errors.Is(err, timeseries.ErrRuleCycle) {
+		t.Errorf("expected ErrRuleCycle for rule into itself; got %v", err)
+	}
+
+	for _, ms := range []int64{0, 500, 500, 1000, 2000} {
+		if err := ts.Add(ms, 1); err != nil {
+			t.Errorf("could not Add: %v", err)
+		}
+	}
+
+	s, err := ts.Get()
+	if err != nil || s == nil || *s != (timeseries.Sample{Time: 2000, Value: 1}) {
+		t.Errorf("unexpected Get: %v (%v)", s, err)
+	}
+
+	samples, err := ts.Range(0, 500)
+	if expected := []timeseries.Sample{{Time: 0, Value: 1}, {Time: 500, Value: 2}}; err != nil || !reflect.DeepEqual(samples, expected) {
+		t.Errorf("expected Range %v; got %v (%v)", expected, samples, err)
+	}
+
+	samples, err = ts.RangeAgg(0, 2000, timeseries.AggCount, time.Second)
+	if expected := []timeseries.Sample{{Time: 0, Value: 2}, {Time: 1000, Value: 1}, {Time: 2000, Value: 1}}; err != nil || !reflect.DeepEqual(samples, expected) {
+		t.Errorf("expected RangeAgg %v; got %v (%v)", expected, samples, err)
+	}
+
+	samples, err = store.TimeSeries("compacted").Range(0, 2000)
+	if expected := []timeseries.Sample{{Time: 0, Value: 3}, {Time: 1000, Value: 1}}; err != nil || !reflect.DeepEqual(samples, expected) {
+		t.Errorf("expected compacted samples %v; got %v (%v)", expected, samples, err)
+	}
+
+	info, err := ts.Info()
+	if err != nil || info.Samples != 4 || info.DuplicatePolicy != timeseries.DupSum || len(info.Rules) != 1 {
+		t.Errorf("unexpected Info: %+v (%v)", info, err)
+	}
+
+	if ok, err := ts.DeleteRule("compacted"); err != nil || !ok {
+		t.Errorf("expected DeleteRule to return true; got %v (%v)", ok, err)
+	}
+
+	// check guard for invalid key
+	err = store.TimeSeries("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
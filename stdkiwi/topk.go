@@ -0,0 +1,106 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/topk"
+)
+
+// Topk implements methods for topk value type.
+type Topk struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of topk type.
+func (t *Topk) Guard() {
+	if err := t.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (t *Topk) GuardE() error { return t.store.guardValueE(topk.Type, t.key) }
+
+// Reserve sets the number of items to keep track of, the dimensions of the
+// buckets and the decay. The value should be empty.
+func (t *Topk) Reserve(k, width, depth int, decay float64) error {
+	_, err := t.store.Do(t.key, topk.Reserve, k, width, depth, decay)
+	return err
+}
+
+// Add adds the items, returning the items removed from the top k.
+func (t *Topk) Add(items ...string) ([]string, error) {
+	return t.doStrings(topk.Add, items...)
+}
+
+// List returns the top k items, the most frequent first.
+func (t *Topk) List() ([]string, error) {
+	return t.doStrings(topk.List)
+}
+
+// Count returns the estimated counts of the items.
+func (t *Topk) Count(items ...string) ([]int64, error) {
+	params := make([]interface{}, len(items))
+	for i := range items {
+		params[i] = items[i]
+	}
+
+	v, err := t.store.Do(t.key, topk.Count, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, ok := v.([]int64)
+	if !ok {
+		return nil, newTypeErr(counts, v)
+	}
+
+	return counts, nil
+}
+
+// Query returns for each of the items if it is in the top k.
+func (t *Topk) Query(items ...string) ([]bool, error) {
+	params := make([]interface{}, len(items))
+	for i := range items {
+		params[i] = items[i]
+	}
+
+	v, err := t.store.Do(t.key, topk.Query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	in, ok := v.([]bool)
+	if !ok {
+		return nil, newTypeErr(in, v)
+	}
+
+	return in, nil
+}
+
+// doStrings executes the action for the items which returns a []string.
+func (t *Topk) doStrings(action kiwi.Action, items ...string) ([]string, error) {
+	params := make([]interface{}, len(items))
+	for i := range items {
+		params[i] = items[i]
+	}
+
+	v, err := t.store.Do(t.key, action, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, ok := v.([]string)
+	if !ok {
+		return nil, newTypeErr(res, v)
+	}
+
+	return res, nil
+}
+
+// Interface guard.
+var _ Value = (*Topk)(nil)
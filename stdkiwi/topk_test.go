@@ -0,0 +1,60 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/topk"
+)
+
+func TestTopk(t *testing.T) {
+	store := newTestStore(t, topk.Type)
+	k := store.Topk(testKey)
+
+	// check that it does not panic
+	k.Guard()
+
+	// and the same should work with GuardE as well
+	if err := k.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	if err := k.Reserve(2, 100, 3, 0.9); err != nil {
+		t.Errorf("could not Reserve: %v", err)
+	}
+
+	removed, err := k.Add("a", "a", "a", "b", "b", "c")
+	if err != nil || !reflect.DeepEqual(removed, []string(nil)) {
+		t.Errorf("expected Add to remove nothing; got %v (%v)", removed, err)
+	}
+
+	list, err := k.List()
+	if err != nil || !reflect.DeepEqual(list, []string{"a", "b"}) {
+		t.Errorf("expected List to return [a b]; got %v (%v)", list, err)
+	}
+
+	removed, err = k.Add("c", "c", "c")
+	if err != nil || !reflect.DeepEqual(removed, []string{"b"}) {
+		t.Errorf("expected Add to remove [b]; got %v (%v)", removed, err)
+	}
+
+	counts, err := k.Count("a", "c", "d")
+	if err != nil || !reflect.DeepEqual(counts, []int64{3, 4, 0}) {
+		t.Errorf("expected Count to return [3 4 0]; got %v (%v)", counts, err)
+	}
+
+	in, err := k.Query("a", "b", "c")
+	if err != nil || !reflect.DeepEqual(in, []bool{true, false, true}) {
+		t.Errorf("expected Query to return [true false true]; got %v (%v)", in, err)
+	}
+
+	// check guard for invalid key
+	err = store.Topk("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
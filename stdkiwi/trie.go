@@ -0,0 +1,122 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/trie"
+)
+
+// Trie implements methods for trie value type.
+type Trie struct {
+	store *Store
+	key   string
+}
+
+// Guard guards the keys with values of trie type.
+func (t *Trie) Guard() {
+	if err := t.GuardE(); err != nil {
+		panic(err)
+	}
+}
+
+// GuardE is same as Guard but does not panic, instead returns the error.
+func (t *Trie) GuardE() error { return t.store.guardValueE(trie.Type, t.key) }
+
+// Insert inserts the word with the weight, or sets the weight if the word
+// exists. It returns true if the word was inserted.
+func (t *Trie) Insert(word string, weight float64) (bool, error) {
+	return t.doBool(trie.Insert, word, weight)
+}
+
+// Remove removes the words, returning the number of words removed.
+func (t *Trie) Remove(words ...string) (int, error) {
+	params := make([]interface{}, len(words))
+	for i := range words {
+		params[i] = words[i]
+	}
+
+	return t.doInt(trie.Remove, params...)
+}
+
+// Has checks if the word exists.
+func (t *Trie) Has(word string) (bool, error) {
+	return t.doBool(trie.Has, word)
+}
+
+// Prefix returns at most limit of the heaviest words with the prefix, or all
+// of them if the limit is 0.
+func (t *Trie) Prefix(prefix string, limit int) ([]trie.Completion, error) {
+	v, err := t.store.Do(t.key, trie.Prefix, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	completions, ok := v.([]trie.Completion)
+	if !ok {
+		return nil, newTypeErr(completions, v)
+	}
+
+	return completions, nil
+}
+
+// CountPrefix returns the number of words with the prefix.
+func (t *Trie) CountPrefix(prefix string) (int, error) {
+	return t.doInt(trie.CountPrefix, prefix)
+}
+
+// Fuzzy returns at most limit of the words within the edit distance of the
+// word, or all of them if the limit is 0, the closest first.
+func (t *Trie) Fuzzy(word string, distance, limit int) ([]trie.Match, error) {
+	v, err := t.store.Do(t.key, trie.Fuzzy, word, distance, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, ok := v.([]trie.Match)
+	if !ok {
+		return nil, newTypeErr(matches, v)
+	}
+
+	return matches, nil
+}
+
+// Len returns the number of words.
+func (t *Trie) Len() (int, error) {
+	return t.doInt(trie.Len)
+}
+
+// doBool does the action returning a bool.
+func (t *Trie) doBool(action kiwi.Action, params ...interface{}) (bool, error) {
+	v, err := t.store.Do(t.key, action, params...)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, newTypeErr(b, v)
+	}
+
+	return b, nil
+}
+
+// doInt does the action returning an int.
+func (t *Trie) doInt(action kiwi.Action, params ...interface{}) (int, error) {
+	v, err := t.store.Do(t.key, action, params...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := v.(int)
+	if !ok {
+		return 0, newTypeErr(n, v)
+	}
+
+	return n, nil
+}
+
+// Interface guard.
+var _ Value = (*Trie)(nil)
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stdkiwi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi/values/trie"
+)
+
+func TestTrie(t *testing.T) {
+	store := newTestStore(t, trie.Type)
+	tr := store.Trie(testKey)
+
+	// check that it does not panic
+	tr.Guard()
+
+	// and the same should work with GuardE as well
+	if err := tr.GuardE(); err != nil {
+		t.Errorf("GuardE threw an error for default testKey: %v", err)
+	}
+
+	for word, weight := range map[string]float64{"kiwi": 3, "kite": 5, "kit": 1, "apple": 2} {
+		if ok, err := tr.Insert(word, weight); err != nil || !ok {
+			t.Errorf("expected Insert to return true; got %v (%v)", ok, err)
+		}
+	}
+
+	completions, err := tr.Prefix("ki", 2)
+	if expected := []trie.Completion{{Word: "kite", Weight: 5}, {Word: "kiwi", Weight: 3}}; err != nil || !reflect.DeepEqual(completions, expected) {
+		t.Errorf("expected Prefix %v; got %v (%v)", expected, completions, err)
+	}
+
+	n, err := tr.CountPrefix("kit")
+	if err != nil || n != 2 {
+		t.Errorf("expected CountPrefix to return 2; got %d (%v)", n, err)
+	}
+
+	matches, err := tr.Fuzzy("kiti", 1, 0)
+	if expected := []trie.Match{{Word: "kite", Weight: 5, Distance: 1}, {Word: "kiwi", Weight: 3, Distance: 1}, {Word: "kit", Weight: 1, Distance: 1}}; err != nil || !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected Fuzzy %v; got %v (%v)", expected, matches, err)
+	}
+
+	n, err = tr.Remove("kit", "pear")
+	if err != nil || n != 1 {
+		t.Errorf("expected Remove to return 1; got %d (%v)", n, err)
+	}
+
+	if ok, err := tr.Has("kit"); err != nil || ok {
+		t.Errorf("expected Has to return false; got %v (%v)", ok, err)
+	}
+
+	n, err = tr.Len()
+	if err != nil || n != 3 {
+		t.Errorf("expected Len to return 3; got %d (%v)", n, err)
+	}
+
+	// check guard for invalid key
+	err = store.Trie("randomKey").GuardE()
+	if err == nil {
+		t.Errorf("expected error while GuardE for invalid key; got nil")
+	}
+}
@@ -24,15 +24,20 @@ func newKeyErr(err error, key string) error {
 
 // Store is the main element that contains and manages all the key value pairs.
 type Store struct {
-	kv map[string]valWrapper
-	mu sync.RWMutex
+	kv      map[string]valWrapper
+	mu      sync.RWMutex
+	hooks   hookList
+	pubsub  pubsub
+	blocked blocked
 }
 
 // NewStore creates an empty store without any key value pairs initialized.
 func NewStore() *Store {
 	return &Store{
-		kv: make(map[string]valWrapper),
-		mu: sync.RWMutex{},
+		kv:      make(map[string]valWrapper),
+		mu:      sync.RWMutex{},
+		pubsub:  newPubsub(),
+		blocked: newBlocked(),
 	}
 }
 
@@ -59,6 +64,12 @@ func (s *Store) KeyExists(key string) bool {
 
 // AddKey adds a new key to the store. It throws an error if the key already exists.
 func (s *Store) AddKey(key string, typ ValueType) error {
+	return s.AddKeyAs("", key, typ)
+}
+
+// AddKeyAs adds the key like AddKey on behalf of the caller, which is passed on
+// to the hooks and monitors of the store.
+func (s *Store) AddKeyAs(caller, key string, typ ValueType) error {
 	s.mu.Lock()
 
 	if err := s.keyNotExist(key); err != nil {
@@ -74,6 +85,8 @@ func (s *Store) AddKey(key string, typ ValueType) error {
 
 	s.setValWrapper(key, v)
 	s.mu.Unlock()
+
+	s.emit(Event{Op: EventAddKey, Key: key, Type: typ, Caller: caller})
 	return nil
 }
 
@@ -99,20 +112,31 @@ func (s *Store) UpdateKey(key string, typ ValueType) error {
 	old.mu.Unlock()
 
 	s.mu.Unlock()
+
+	s.emit(Event{Op: EventUpdateKey, Key: key, Type: typ})
 	return nil
 }
 
 // setValWrapper sets the value for given key in store.
 func (s *Store) setValWrapper(key string, val Value) {
+	_, concurrent := val.(ConcurrentValue)
+
 	s.kv[key] = valWrapper{
 		val:         val,
 		mu:          &sync.RWMutex{},
 		doMapCached: val.DoMap(),
+		concurrent:  concurrent,
 	}
 }
 
 // DeleteKey deletes the key if it exists. Throws an error if it doesn't.
 func (s *Store) DeleteKey(key string) error {
+	return s.DeleteKeyAs("", key)
+}
+
+// DeleteKeyAs deletes the key like DeleteKey on behalf of the caller, which is
+// passed on to the hooks and monitors of the store.
+func (s *Store) DeleteKeyAs(caller, key string) error {
 	s.mu.Lock()
 
 	if err := s.keyExists(key); err != nil {
@@ -123,10 +147,13 @@ func (s *Store) DeleteKey(key string) error {
 	old := s.kv[key]
 
 	old.mu.Lock()
+	typ := old.val.Type()
 	delete(s.kv, key)
 	old.mu.Unlock()
 
 	s.mu.Unlock()
+
+	s.emit(Event{Op: EventDeleteKey, Key: key, Type: typ, Caller: caller})
 	return nil
 }
 
@@ -167,6 +194,12 @@ func (s *Store) getSchema() Schema {
 
 // Do executes the action for the value associated with the key.
 func (s *Store) Do(key string, action Action, params ...interface{}) (interface{}, error) {
+	return s.DoAs("", key, action, params...)
+}
+
+// DoAs executes the action like Do on behalf of the caller, e.g., a user or a
+// connection, which is passed on to the hooks and monitors of the store.
+func (s *Store) DoAs(caller, key string, action Action, params ...interface{}) (interface{}, error) {
 	s.mu.RLock()
 	if err := s.keyExists(key); err != nil {
 		s.mu.RUnlock()
@@ -177,12 +210,32 @@ func (s *Store) Do(key string, action Action, params ...interface{}) (interface{
 
 	doFunc, ok := v.doMapCached[action]
 	if !ok {
-		return nil, fmt.Errorf("%w: %v", ErrInvalidAction, action)
+		err := fmt.Errorf("%w: %v", ErrInvalidAction, action)
+		if s.listening() {
+			s.emit(Event{Op: EventDo, Key: key, Type: v.val.Type(), Action: action, Params: params, Caller: caller, Err: err})
+		}
+		return nil, err
 	}
 
-	v.mu.Lock()
-	res, err := doFunc(params...)
-	v.mu.Unlock()
+	var res interface{}
+	var err error
+	if v.concurrent {
+		res, err = doFunc(params...)
+	} else {
+		v.mu.Lock()
+		res, err = doFunc(params...)
+		v.mu.Unlock()
+	}
+
+	// the event is only built if a hook or a caller of Block can receive it
+	if !s.listening() {
+		return res, err
+	}
+
+	typ := v.val.Type()
+	ro, ok := v.val.(ReadOnlyValue)
+	readOnly := ok && ro.ReadOnly(action)
+	s.emit(Event{Op: EventDo, Key: key, Type: typ, Action: action, Params: params, Caller: caller, ReadOnly: readOnly, Err: err})
 
 	return res, err
 }
@@ -225,7 +278,13 @@ func (s *Store) FromJSON(key string, rawmessage json.RawMessage) error {
 
 	v.mu.Lock()
 	err := s.fromJSON(&v, rawmessage)
+	typ := v.val.Type()
 	v.mu.Unlock()
+
+	if err == nil {
+		s.emit(Event{Op: EventFromJSON, Key: key, Type: typ})
+	}
+
 	return err
 }
 
@@ -251,20 +310,19 @@ type StoreJSON = map[string]ValJSON
 //
 // The data is in the format (StoreJSON):
 //
-// 	{
-// 		"key_1": {
-// 			"type": "str",
-// 			"data": "hello"
-// 		},
-// 		"key_2": {
-// 			"type": "hash",
-// 			"data": {
-// 				"a": "b",
-// 				"c": "d"
-// 			}
-// 		}
-// 	}
-//
+//	{
+//		"key_1": {
+//			"type": "str",
+//			"data": "hello"
+//		},
+//		"key_2": {
+//			"type": "hash",
+//			"data": {
+//				"a": "b",
+//				"c": "d"
+//			}
+//		}
+//	}
 func (s *Store) Export() (json.RawMessage, error) {
 	s.mu.RLock()
 
@@ -276,7 +334,7 @@ func (s *Store) Export() (json.RawMessage, error) {
 
 		vw.mu.RLock()
 		data, err := s.toJSON(&vw)
-		vw.mu.Unlock()
+		vw.mu.RUnlock()
 		if err != nil {
 			s.mu.RUnlock()
 			return nil, fmt.Errorf("error exporting for %q key: %v", k, err)
@@ -322,20 +380,19 @@ type ImportOpts struct {
 //
 // The data is in the format (StoreJSON):
 //
-// 	{
-// 		"key_1": {
-// 			"type": "str",
-// 			"data": "hello"
-// 		},
-// 		"key_2": {
-// 			"type": "hash",
-// 			"data": {
-// 				"a": "b",
-// 				"c": "d"
-// 			}
-// 		}
-// 	}
-//
+//	{
+//		"key_1": {
+//			"type": "str",
+//			"data": "hello"
+//		},
+//		"key_2": {
+//			"type": "hash",
+//			"data": {
+//				"a": "b",
+//				"c": "d"
+//			}
+//		}
+//	}
 func (s *Store) Import(rawmessage json.RawMessage, opts ImportOpts) error {
 	var sjson StoreJSON
 	if err := json.Unmarshal(rawmessage, &sjson); err != nil {
@@ -345,7 +402,18 @@ func (s *Store) Import(rawmessage json.RawMessage, opts ImportOpts) error {
 	// Lock the store for whole of the process now.
 	s.mu.Lock()
 
+	// Events are emitted for the loaded values even if the import fails, and
+	// only after the store is unlocked.
+	events := make([]Event, 0, len(sjson))
+	defer func() {
+		for _, e := range events {
+			s.emit(e)
+		}
+	}()
+
 	for k := range sjson {
+		typ := ValueType(sjson[k].Type)
+
 		if err := s.keyExists(k); err != nil {
 			if !opts.AddKeys && !opts.ErrOnInvalidKey {
 				continue
@@ -354,39 +422,46 @@ func (s *Store) Import(rawmessage json.RawMessage, opts ImportOpts) error {
 				s.mu.Unlock()
 				return err
 			}
-			if opts.AddKeys {
-				if er := s.AddKey(k, ValueType(sjson[k].Type)); er != nil {
-					s.mu.Unlock()
-					return er
-				}
+
+			val, er := newValue(typ)
+			if er != nil {
+				s.mu.Unlock()
+				return er
 			}
+			s.setValWrapper(k, val)
 		}
 
 		// now that the key exists
 		v := s.kv[k]
 
-		v.mu.Lock()
-
-		if sjson[k].Type != string(v.val.Type()) {
+		if typ != v.val.Type() {
 			if !opts.UpdateTypes {
-				v.mu.Unlock()
 				s.mu.Unlock()
 				return fmt.Errorf("value type in JSON and store schema do not match")
 			}
-			if er := s.UpdateKey(k, ValueType(sjson[k].Type)); er != nil {
-				v.mu.Unlock()
+
+			val, er := newValue(typ)
+			if er != nil {
 				s.mu.Unlock()
 				return er
 			}
-		}
 
-		if err := s.FromJSON(k, sjson[k].Data); err != nil {
+			v.mu.Lock()
+			s.setValWrapper(k, val)
 			v.mu.Unlock()
+
+			v = s.kv[k]
+		}
+
+		v.mu.Lock()
+		err := s.fromJSON(&v, sjson[k].Data)
+		v.mu.Unlock()
+		if err != nil {
 			s.mu.Unlock()
 			return err
 		}
 
-		v.mu.Unlock()
+		events = append(events, Event{Op: EventFromJSON, Key: k, Type: typ})
 	}
 
 	s.mu.Unlock()
@@ -436,6 +511,10 @@ type valWrapper struct {
 	// caching do map avoids allocation for the map each time an action is
 	// executed, hence, improving the performance.
 	doMapCached map[Action]DoFunc
+
+	// concurrent tells if the actions can be executed without locking, i.e.,
+	// the value is a ConcurrentValue.
+	concurrent bool
 }
 
 // Interface guard.
@@ -0,0 +1,190 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package tracking
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// Cache is a bounded near-cache of the JSON of the keys of a store, which
+// evicts the keys when they change.
+//
+// The least recently used key is evicted when the cache is full.
+type Cache struct {
+	client *Client
+	size   int
+	ttl    time.Duration
+	now    func() time.Time
+
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// pending has a token for each key being loaded, which is removed when
+	// the key is invalidated so that the stale value is not cached.
+	pending map[string]uint64
+	token   uint64
+
+	hits   uint64
+	misses uint64
+	mu     sync.Mutex
+}
+
+// cacheEntry is an entry in the cache.
+type cacheEntry struct {
+	key     string
+	value   json.RawMessage
+	expires time.Time // zero if the entry does not expire
+}
+
+// NewCache creates a cache of at most size keys, with the ID for the tracker.
+// The keys are also evicted after the TTL, unless it is 0.
+func NewCache(t *Tracker, id string, size int, ttl time.Duration) *Cache {
+	c := &Cache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		pending: make(map[string]uint64),
+		mu:      sync.Mutex{},
+	}
+
+	c.client = t.NewClient(id, Options{Mode: ModeDefault}, c.invalidate)
+	return c
+}
+
+// Get returns the JSON of the value associated with the key from the cache,
+// loading it from the store if it is not cached. The returned JSON should not
+// be modified.
+func (c *Cache) Get(key string) (json.RawMessage, error) {
+	c.mu.Lock()
+
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*cacheEntry) //nolint:errcheck
+		if entry.expires.IsZero() || c.now().Before(entry.expires) {
+			c.lru.MoveToFront(e)
+			c.hits++
+			c.mu.Unlock()
+			return entry.value, nil
+		}
+
+		c.evict(e)
+	}
+
+	c.misses++
+	c.token++
+	token := c.token
+	c.pending[key] = token
+	c.mu.Unlock()
+
+	value, err := c.client.ToJSON(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending[key] != token {
+		// invalidated while loading, or loaded again meanwhile
+		return value, err
+	}
+	delete(c.pending, key)
+
+	if err != nil {
+		// missing keys are not cached, so they need not be tracked
+		c.client.Untrack(key)
+		return nil, err
+	}
+
+	c.add(key, value)
+	return value, nil
+}
+
+// Do executes the action for the value associated with the key. If the action
+// changes the value, the key is evicted.
+func (c *Cache) Do(key string, action kiwi.Action, params ...interface{}) (interface{}, error) {
+	return c.client.Do(key, action, params...)
+}
+
+// Len returns the number of keys in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Stats returns the number of hits and misses of the cache.
+func (c *Cache) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+// Close empties the cache and stops tracking the keys.
+func (c *Cache) Close() {
+	c.client.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.pending = make(map[string]uint64)
+}
+
+// invalidate evicts the key from the cache.
+func (c *Cache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, key)
+	if e, ok := c.entries[key]; ok {
+		c.evict(e)
+	}
+}
+
+// add adds the key to the cache, evicting the least recently used key if the
+// cache is full. The keys which are not cached are no longer tracked.
+//
+// It should be called with the cache locked.
+func (c *Cache) add(key string, value json.RawMessage) {
+	entry := &cacheEntry{key: key, value: value}
+	if c.ttl > 0 {
+		entry.expires = c.now().Add(c.ttl)
+	}
+
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	if c.size <= 0 {
+		c.client.Untrack(key)
+		return
+	}
+
+	if c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		c.evict(oldest)
+		c.client.Untrack(oldest.Value.(*cacheEntry).key) //nolint:errcheck
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+}
+
+// evict removes the entry from the cache.
+//
+// It should be called with the cache locked.
+func (c *Cache) evict(e *list.Element) {
+	entry := e.Value.(*cacheEntry) //nolint:errcheck
+	delete(c.entries, entry.key)
+	c.lru.Remove(e)
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package tracking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+func TestCache(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{
+		"a": str.Type,
+		"b": str.Type,
+		"c": str.Type,
+	})
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+
+	tracker := NewTracker(store)
+	defer tracker.Close()
+
+	now := time.Unix(1600000000, 0)
+
+	cache := NewCache(tracker, "cache", 2, time.Minute)
+	cache.now = func() time.Time { return now }
+	defer cache.Close()
+
+	get := func(key, expected string) {
+		t.Helper()
+
+		data, err := cache.Get(key)
+		if err != nil {
+			t.Fatalf("could not get %q: %v", key, err)
+		}
+		if string(data) != expected {
+			t.Errorf("expected %s for %q; got %s", expected, key, data)
+		}
+	}
+
+	checkStats := func(hits, misses uint64) {
+		t.Helper()
+
+		if h, m := cache.Stats(); h != hits || m != misses {
+			t.Errorf("expected %d hits and %d misses; got %d and %d", hits, misses, h, m)
+		}
+	}
+
+	get("a", `""`)
+	get("a", `""`)
+	checkStats(1, 1)
+
+	if _, err := store.Do("a", str.Update, "x"); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+	get("a", `"x"`)
+	checkStats(1, 2)
+
+	// updating through the cache evicts the key as well
+	if _, err := cache.Do("a", str.Update, "y"); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+	get("a", `"y"`)
+	checkStats(1, 3)
+
+	// the least recently used key is evicted
+	get("b", `""`)
+	get("a", `"y"`)
+	get("c", `""`)
+	if n := cache.Len(); n != 2 {
+		t.Errorf("expected 2 keys in the cache; got %d", n)
+	}
+	if n := len(tracker.keys); n != 2 {
+		t.Errorf("expected the evicted key not to be tracked; got %d tracked keys", n)
+	}
+	get("a", `"y"`)
+	get("b", `""`)
+	checkStats(3, 6)
+
+	// keys expire after the TTL
+	now = now.Add(time.Minute)
+	get("b", `""`)
+	checkStats(3, 7)
+
+	if _, err := cache.Get("missing"); err == nil {
+		t.Errorf("expected error for missing key")
+	}
+	if _, ok := tracker.keys["missing"]; ok {
+		t.Errorf("expected missing key not to be tracked")
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package tracking implements client-side caching of the keys of a kiwi store
+// with invalidation driven by the store.
+//
+// A Tracker watches the store for its clients. In the default mode, a client
+// is told when a key it has accessed changes, after which it has to access the
+// key again to be told about the next change. In the broadcast mode, a client
+// is told about every change of the keys starting with one of its prefixes,
+// whether it has accessed them or not. Clients with the NoLoop option are not
+// told about the changes made by themselves.
+//
+// A client tracks at most MaxKeys keys in the default mode, invalidating the
+// least recently accessed one to track another, and Cache stops tracking the
+// keys it evicts, so that the tracker does not grow without bound.
+//
+// A server exposes the tracker to its connections by creating a Client for
+// each of them and pushing the invalidated keys to it. Cache is such a client
+// used in the same process: a bounded near-cache of the JSON of the keys.
+//
+// Every action which can modify the value is considered to change the key,
+// even if it leaves the value as it was, so a client can be told about a key
+// which has not changed but is never left with a stale value. The actions the
+// value tells to be read-only, like "GET", never invalidate the key.
+//
+//
+// Get Started
+//
+//	store := kiwi.NewStore()
+//
+//	tracker := tracking.NewTracker(store)
+//	defer tracker.Close()
+//
+//	cache := tracking.NewCache(tracker, "service-a", 1024, time.Minute)
+//	defer cache.Close()
+//
+//	// loads the key from the store the first time and from the cache until
+//	// the key is changed
+//	data, err := cache.Get("config")
+//	if err != nil {
+//	  // handle error
+//	}
+package tracking
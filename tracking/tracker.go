@@ -0,0 +1,307 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package tracking
+
+import (
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// Mode is the tracking mode of a client.
+type Mode int
+
+const (
+	// ModeDefault tracks the keys accessed by the client. A key is not
+	// tracked after it is invalidated until it is accessed again.
+	ModeDefault Mode = iota
+
+	// ModeBroadcast tracks all the keys starting with the prefixes of the
+	// client, whether accessed or not.
+	ModeBroadcast
+)
+
+// DefaultMaxKeys is the maximum number of keys tracked for a client in the
+// default mode if its options do not set it, like tracking-table-max-keys of
+// Redis.
+const DefaultMaxKeys = 1000000
+
+// Options configure the tracking of a client.
+type Options struct {
+	// Mode is the tracking mode.
+	Mode Mode
+
+	// Prefixes are the prefixes of the keys tracked in the broadcast mode.
+	// All the keys are tracked if empty.
+	Prefixes []string
+
+	// NoLoop stops the client from being told about the changes made by
+	// itself, i.e., the actions executed through it.
+	NoLoop bool
+
+	// MaxKeys is the maximum number of keys tracked in the default mode.
+	// When reached, the least recently accessed key is invalidated to track
+	// a new one. Defaults to DefaultMaxKeys if not positive.
+	MaxKeys int
+}
+
+// Tracker tells its clients when the keys they track change.
+type Tracker struct {
+	store  *kiwi.Store
+	remove func()
+
+	keys      map[string]map[*Client]struct{}
+	broadcast map[*Client]struct{}
+	mu        sync.Mutex
+}
+
+// NewTracker creates a tracker for the store.
+func NewTracker(store *kiwi.Store) *Tracker {
+	t := &Tracker{
+		store:     store,
+		keys:      make(map[string]map[*Client]struct{}),
+		broadcast: make(map[*Client]struct{}),
+		mu:        sync.Mutex{},
+	}
+
+	t.remove = store.AddHook(t.handle)
+	return t
+}
+
+// Close stops tracking the store. The clients are not told about any further
+// changes.
+func (t *Tracker) Close() {
+	t.remove()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for c := range t.broadcast {
+		c.closed = true
+	}
+	for _, clients := range t.keys {
+		for c := range clients {
+			c.closed = true
+		}
+	}
+
+	t.keys = make(map[string]map[*Client]struct{})
+	t.broadcast = make(map[*Client]struct{})
+}
+
+// NewClient creates a client with the ID, which is the caller of the actions
+// executed through it, that calls invalidate with the keys that change.
+//
+// The invalidate function is called synchronously by the store after the key
+// changes, so it should return quickly.
+func (t *Tracker) NewClient(id string, opts Options, invalidate func(key string)) *Client {
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = DefaultMaxKeys
+	}
+
+	c := &Client{
+		id:         id,
+		opts:       opts,
+		invalidate: invalidate,
+		tracker:    t,
+		keys:       make(map[string]*list.Element),
+		order:      list.New(),
+	}
+
+	if opts.Mode == ModeBroadcast {
+		t.mu.Lock()
+		t.broadcast[c] = struct{}{}
+		t.mu.Unlock()
+	}
+
+	return c
+}
+
+// track tracks the key for the client before it is accessed. If the client
+// tracks too many keys, the least recently accessed one is invalidated.
+func (t *Tracker) track(c *Client, key string) {
+	if c.opts.Mode != ModeDefault {
+		return
+	}
+
+	// called after unlocking so that the client can access the tracker
+	if evicted, ok := t.trackKey(c, key); ok {
+		c.invalidate(evicted)
+	}
+}
+
+// trackKey tracks the key for the client in the default mode, returning the
+// key which is no longer tracked to make room for it, if any.
+func (t *Tracker) trackKey(c *Client, key string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if c.closed {
+		return "", false
+	}
+
+	if e, ok := c.keys[key]; ok {
+		c.order.MoveToBack(e)
+		return "", false
+	}
+
+	var evicted string
+	ok := c.order.Len() >= c.opts.MaxKeys
+	if ok {
+		evicted = c.order.Front().Value.(string) //nolint:errcheck
+		t.untrackKey(c, evicted)
+	}
+
+	clients, exists := t.keys[key]
+	if !exists {
+		clients = make(map[*Client]struct{})
+		t.keys[key] = clients
+	}
+
+	clients[c] = struct{}{}
+	c.keys[key] = c.order.PushBack(key)
+
+	return evicted, ok
+}
+
+// untrack stops tracking the client.
+func (t *Tracker) untrack(c *Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c.closed = true
+	delete(t.broadcast, c)
+
+	for key := range c.keys {
+		t.untrackKey(c, key)
+	}
+}
+
+// untrackKey stops tracking the key for the client in the default mode.
+//
+// It should be called with the tracker locked.
+func (t *Tracker) untrackKey(c *Client, key string) {
+	if e, ok := c.keys[key]; ok {
+		c.order.Remove(e)
+		delete(c.keys, key)
+	}
+
+	clients := t.keys[key]
+	delete(clients, c)
+	if len(clients) == 0 {
+		delete(t.keys, key)
+	}
+}
+
+// handle is the hook which tells the clients tracking the key about the event.
+func (t *Tracker) handle(e kiwi.Event) {
+	notify := t.changed(e)
+
+	// called after unlocking so that the clients can access the tracker
+	for _, c := range notify {
+		c.invalidate(e.Key)
+	}
+}
+
+// changed returns the clients to be told that the key of the event changed.
+func (t *Tracker) changed(e kiwi.Event) []*Client {
+	if e.Op == kiwi.EventDo && (e.ReadOnly || e.Err != nil) {
+		// the action did not change the value
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var tracking []*Client
+	for c := range t.keys[e.Key] {
+		tracking = append(tracking, c)
+	}
+
+	for c := range t.broadcast {
+		if c.matches(e.Key) {
+			tracking = append(tracking, c)
+		}
+	}
+
+	notify := make([]*Client, 0, len(tracking))
+	for _, c := range tracking {
+		if c.opts.NoLoop && e.Caller == c.id {
+			continue
+		}
+
+		if c.opts.Mode == ModeDefault {
+			t.untrackKey(c, e.Key)
+		}
+		notify = append(notify, c)
+	}
+
+	return notify
+}
+
+// Client accesses the store through a tracker which tells it when the keys
+// change.
+type Client struct {
+	id         string
+	opts       Options
+	invalidate func(key string)
+	tracker    *Tracker
+
+	// keys are the keys tracked in the default mode, whose elements in order
+	// go from the least to the most recently accessed, guarded by the lock
+	// of the tracker
+	keys   map[string]*list.Element
+	order  *list.List
+	closed bool
+}
+
+// ID returns the ID of the client.
+func (c *Client) ID() string { return c.id }
+
+// Do executes the action for the value associated with the key as the client,
+// tracking the key.
+func (c *Client) Do(key string, action kiwi.Action, params ...interface{}) (interface{}, error) {
+	c.tracker.track(c, key)
+	return c.tracker.store.DoAs(c.id, key, action, params...)
+}
+
+// ToJSON returns the JSON of the value associated with the key, tracking the
+// key.
+func (c *Client) ToJSON(key string) (json.RawMessage, error) {
+	c.tracker.track(c, key)
+	return c.tracker.store.ToJSON(key)
+}
+
+// Untrack stops tracking the key for the client in the default mode, e.g.,
+// when the key is evicted from the cache of the client.
+func (c *Client) Untrack(key string) {
+	c.tracker.mu.Lock()
+	defer c.tracker.mu.Unlock()
+
+	c.tracker.untrackKey(c, key)
+}
+
+// Close stops tracking the keys for the client.
+func (c *Client) Close() {
+	c.tracker.untrack(c)
+}
+
+// matches tells if the key starts with one of the prefixes of the client.
+func (c *Client) matches(key string) bool {
+	if len(c.opts.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range c.opts.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
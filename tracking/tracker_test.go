@@ -0,0 +1,163 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package tracking
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// invalidations records the keys invalidated for a client.
+type invalidations struct {
+	keys []string
+	mu   sync.Mutex
+}
+
+func (i *invalidations) add(key string) {
+	i.mu.Lock()
+	i.keys = append(i.keys, key)
+	i.mu.Unlock()
+}
+
+// take returns the recorded keys and forgets them.
+func (i *invalidations) take() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	keys := i.keys
+	i.keys = nil
+	return keys
+}
+
+func TestTracker(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{
+		"user:1":  str.Type,
+		"user:2":  str.Type,
+		"order:1": str.Type,
+	})
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+
+	tracker := NewTracker(store)
+	defer tracker.Close()
+
+	var def, bcast, noloop invalidations
+	defClient := tracker.NewClient("def", Options{}, def.add)
+	tracker.NewClient("bcast", Options{Mode: ModeBroadcast, Prefixes: []string{"user:"}}, bcast.add)
+	noloopClient := tracker.NewClient("noloop", Options{NoLoop: true}, noloop.add)
+
+	if _, err := defClient.Do("user:1", str.Get); err != nil {
+		t.Fatalf("could not get: %v", err)
+	}
+	if _, err := noloopClient.ToJSON("user:1"); err != nil {
+		t.Fatalf("could not get JSON: %v", err)
+	}
+
+	// accessing the value does not change it
+	if _, err := store.Do("user:1", str.Get); err != nil {
+		t.Fatalf("could not get: %v", err)
+	}
+	if keys := def.take(); keys != nil {
+		t.Errorf("expected no invalidations; got %v", keys)
+	}
+	if keys := bcast.take(); keys != nil {
+		t.Errorf("expected no broadcast invalidations; got %v", keys)
+	}
+
+	if _, err := noloopClient.Do("user:1", str.Update, "alice"); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+	if keys := def.take(); !reflect.DeepEqual(keys, []string{"user:1"}) {
+		t.Errorf("expected user:1 to be invalidated; got %v", keys)
+	}
+	if keys := noloop.take(); keys != nil {
+		t.Errorf("expected no invalidations for own change; got %v", keys)
+	}
+
+	// the key is not tracked after it is invalidated
+	if _, err := store.Do("user:1", str.Update, "bob"); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+	if keys := def.take(); keys != nil {
+		t.Errorf("expected no invalidations after invalidated; got %v", keys)
+	}
+	if keys := noloop.take(); !reflect.DeepEqual(keys, []string{"user:1"}) {
+		t.Errorf("expected user:1 to be invalidated; got %v", keys)
+	}
+
+	// broadcast gets all the changes of the prefix
+	if _, err := store.Do("user:2", str.Update, "carol"); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+	if _, err := store.Do("order:1", str.Update, "x"); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+	if err := store.DeleteKey("user:2"); err != nil {
+		t.Fatalf("could not delete key: %v", err)
+	}
+	if keys := bcast.take(); !reflect.DeepEqual(keys, []string{"user:1", "user:1", "user:2", "user:2"}) {
+		t.Errorf("unexpected broadcast invalidations: %v", keys)
+	}
+
+	defClient.Close()
+	if _, err := defClient.Do("order:1", str.Get); err != nil {
+		t.Fatalf("could not get: %v", err)
+	}
+	if err := store.DeleteKey("order:1"); err != nil {
+		t.Fatalf("could not delete key: %v", err)
+	}
+	if keys := def.take(); keys != nil {
+		t.Errorf("expected no invalidations after closing; got %v", keys)
+	}
+}
+
+func TestTracker_MaxKeys(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{
+		"a": str.Type,
+		"b": str.Type,
+		"c": str.Type,
+	})
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+
+	tracker := NewTracker(store)
+	defer tracker.Close()
+
+	var inv invalidations
+	client := tracker.NewClient("client", Options{MaxKeys: 2}, inv.add)
+
+	for _, key := range []string{"a", "b", "a", "c"} {
+		if _, err := client.Do(key, str.Get); err != nil {
+			t.Fatalf("could not get %q: %v", key, err)
+		}
+	}
+
+	// the least recently accessed key is invalidated to make room
+	if keys := inv.take(); !reflect.DeepEqual(keys, []string{"b"}) {
+		t.Errorf("expected b to be invalidated; got %v", keys)
+	}
+	if n := len(tracker.keys); n != 2 {
+		t.Errorf("expected 2 tracked keys; got %d", n)
+	}
+
+	client.Untrack("a")
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := store.Do(key, str.Update, "x"); err != nil {
+			t.Fatalf("could not update %q: %v", key, err)
+		}
+	}
+	if keys := inv.take(); !reflect.DeepEqual(keys, []string{"c"}) {
+		t.Errorf("expected only c to be invalidated; got %v", keys)
+	}
+	if n := len(tracker.keys); n != 0 {
+		t.Errorf("expected no tracked keys; got %d", n)
+	}
+}
@@ -58,6 +58,31 @@ type Value interface {
 	FromJSON(json.RawMessage) error
 }
 
+// ConcurrentValue is a value whose actions are safe to execute concurrently,
+// e.g., because they only use atomic operations.
+//
+// The store executes the actions of such values without locking them, which
+// avoids contention on frequently updated keys. ToJSON and FromJSON are still
+// called with the value locked.
+type ConcurrentValue interface {
+	Value
+
+	// Concurrent only marks the value as safe for concurrent actions.
+	Concurrent()
+}
+
+// ReadOnlyValue is a value which tells the actions that do not modify it.
+//
+// The events of such actions are marked as read-only, so that hooks which
+// are only interested in changes can skip them without reading the value.
+// Actions of values which do not implement it are taken as modifying them.
+type ReadOnlyValue interface {
+	Value
+
+	// ReadOnly tells if the action does not modify the value.
+	ReadOnly(action Action) bool
+}
+
 // RegisterValue registers a new value type with the package.
 //
 // It takes in two params: the type of the value and a function to create a new value.
@@ -0,0 +1,145 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package bitmap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// Op is a bitwise operation combining bitmaps.
+type Op string
+
+// Bitwise operations.
+const (
+	OpAnd Op = "AND"
+	OpOr  Op = "OR"
+	OpXor Op = "XOR"
+
+	// OpNot inverts the bits of a single bitmap.
+	OpNot Op = "NOT"
+)
+
+// Errors related to BitOp.
+var (
+	ErrInvalidOp = fmt.Errorf("invalid bit operation")
+	ErrNotBitmap = fmt.Errorf("value is not a bitmap")
+)
+
+// BitOp combines the bitmaps of the keys with the operation and stores the
+// result in the bitmap of the dest key, which is added to the store if it
+// does not exist. The shorter bitmaps are padded with 0s to the length of the
+// longest one, and the keys which do not exist are taken as empty bitmaps.
+//
+// All the keys are checked before dest is added, so dest is not added if any
+// of them has another type.
+//
+// The keys are read one by one, so the result may not reflect a single point
+// in time if the bitmaps are being changed meanwhile.
+//
+// Returns the length of the result in bytes.
+func BitOp(store *kiwi.Store, op Op, dest string, keys ...string) (int, error) {
+	switch {
+	case op != OpAnd && op != OpOr && op != OpXor && op != OpNot:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidOp, op)
+	case len(keys) == 0:
+		return 0, fmt.Errorf("%w: no keys", ErrInvalidOp)
+	case op == OpNot && len(keys) != 1:
+		return 0, fmt.Errorf("%w: NOT takes exactly one key", ErrInvalidOp)
+	}
+
+	srcs := make([][]byte, len(keys))
+	size := 0
+	for i, key := range keys {
+		src, err := get(store, key)
+		if err != nil {
+			return 0, err
+		}
+
+		srcs[i] = src
+		if len(src) > size {
+			size = len(src)
+		}
+	}
+
+	if err := guard(store, dest); err != nil {
+		if !errors.Is(err, kiwi.ErrKeyNotExist) {
+			return 0, err
+		}
+		if err := store.AddKey(dest, Type); err != nil && !errors.Is(err, kiwi.ErrKeyExists) {
+			return 0, err
+		}
+	}
+
+	res := make([]byte, size)
+	copy(res, srcs[0])
+
+	for _, src := range srcs[1:] {
+		for i := range res {
+			var b byte
+			if i < len(src) {
+				b = src[i]
+			}
+
+			switch op {
+			case OpAnd:
+				res[i] &= b
+			case OpOr:
+				res[i] |= b
+			case OpXor:
+				res[i] ^= b
+			}
+		}
+	}
+
+	if op == OpNot {
+		for i := range res {
+			res[i] = ^res[i]
+		}
+	}
+
+	if _, err := store.Do(dest, Set, res); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// get returns the bitmap of the key, which is empty if the key does not exist.
+func get(store *kiwi.Store, key string) ([]byte, error) {
+	if err := guard(store, key); err != nil {
+		if errors.Is(err, kiwi.ErrKeyNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	v, err := store.Do(key, Get)
+	if err != nil {
+		if errors.Is(err, kiwi.ErrKeyNotExist) {
+			// deleted meanwhile
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return v.([]byte), nil //nolint:errcheck
+}
+
+// guard returns an error if the key does not have a bitmap.
+func guard(store *kiwi.Store, key string) error {
+	typ, err := store.GetValueType(key)
+	if err != nil {
+		return err
+	}
+
+	if typ != Type {
+		return fmt.Errorf("%w: %q has %q", ErrNotBitmap, key, typ)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package bitmap implements a kiwi.Value which can store a compact array of
+// bits, like a set of flags for each day or each user ID.
+//
+// The bitmap grows as bits are set, and bits beyond its end are 0. Like in
+// Redis, the bit at offset 0 is the most significant bit of the first byte.
+//
+// BitOp combines the bitmaps of several keys of a store into another key with
+// AND, OR, XOR or NOT, taking the keys which do not exist as empty bitmaps.
+// The JSON of a bitmap is its bytes encoded in base64.
+package bitmap
@@ -0,0 +1,359 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package bitmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value { return new(Value) })
+}
+
+// Type of bitmap value.
+const Type kiwi.ValueType = "bitmap"
+
+// MaxOffset is the maximum offset of a bit, which limits a bitmap to 512 MiB.
+const MaxOffset = 1<<32 - 1
+
+// Value can store an array of bits.
+//
+// It implements the kiwi.Value interface.
+type Value []byte
+
+// Various errors for bitmap value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// SetBit sets the bit at the offset to the bit, which is 0 or 1. The
+	// bitmap grows if the offset is beyond its end.
+	//
+	// Returns the old bit.
+	SetBit kiwi.Action = "SETBIT"
+
+	// GetBit gets the bit at the offset.
+	//
+	// Returns 0 or 1.
+	GetBit kiwi.Action = "GETBIT"
+
+	// BitCount counts the bits set to 1, optionally between the start and
+	// the end offsets, both inclusive. Negative offsets count from the end of
+	// the bitmap, -1 being the last bit.
+	//
+	// Returns an integer.
+	BitCount kiwi.Action = "BITCOUNT"
+
+	// BitPos finds the first bit set to the given bit, 0 or 1, optionally
+	// between the start and the end offsets like BitCount.
+	//
+	// When looking for 0 without an end offset, the bits beyond the end are
+	// considered as well.
+	//
+	// Returns the offset of the bit, or -1 if there is none.
+	BitPos kiwi.Action = "BITPOS"
+
+	// Len gets the length of the bitmap in bits, which is always a multiple
+	// of 8.
+	//
+	// Returns an integer.
+	Len kiwi.Action = "LEN"
+
+	// Get gets the bytes of the bitmap.
+	//
+	// Returns a []byte.
+	Get kiwi.Action = "GET"
+
+	// Set replaces the bitmap with the bytes.
+	//
+	// Returns the length of the bitmap in bits.
+	Set kiwi.Action = "SET"
+)
+
+// Type returns v's type, i.e., "bitmap".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		SetBit:   v.setBit,
+		GetBit:   v.getBit,
+		BitCount: v.bitCount,
+		BitPos:   v.bitPos,
+		Len:      v.length,
+		Get:      v.get,
+		Set:      v.set,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case GetBit, BitCount, BitPos, Len, Get:
+		return true
+	}
+
+	return false
+}
+
+// setBit implements the SETBIT action.
+func (v *Value) setBit(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	offset, err := offsetParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	bit, err := bitParam(params[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if n := offset/8 + 1; n > len(*v) {
+		*v = append(*v, make([]byte, n-len(*v))...)
+	}
+
+	old := v.bit(offset)
+
+	mask := byte(0x80) >> (offset % 8)
+	if bit == 1 {
+		(*v)[offset/8] |= mask
+	} else {
+		(*v)[offset/8] &^= mask
+	}
+
+	return old, nil
+}
+
+// getBit implements the GETBIT action.
+func (v *Value) getBit(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	offset, err := offsetParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return v.bit(offset), nil
+}
+
+// bitCount implements the BITCOUNT action.
+func (v *Value) bitCount(params ...interface{}) (interface{}, error) {
+	start, end, err := v.rangeParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for i := start; i <= end; {
+		if i%8 == 0 && i+7 <= end {
+			// whole bytes are counted at once
+			count += bits.OnesCount8((*v)[i/8])
+			i += 8
+			continue
+		}
+
+		count += v.bit(i)
+		i++
+	}
+
+	return count, nil
+}
+
+// bitPos implements the BITPOS action.
+func (v *Value) bitPos(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	bit, err := bitParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := v.rangeParams(params[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	// a byte without the bit is skipped at once
+	skip := byte(0x00)
+	if bit == 0 {
+		skip = 0xff
+	}
+
+	for i := start; i <= end; {
+		if i%8 == 0 && i+7 <= end && (*v)[i/8] == skip {
+			i += 8
+			continue
+		}
+
+		if v.bit(i) == bit {
+			return i, nil
+		}
+		i++
+	}
+
+	if bit == 0 && len(params) < 3 {
+		return len(*v) * 8, nil
+	}
+
+	return -1, nil
+}
+
+// length implements the LEN action.
+func (v *Value) length(params ...interface{}) (interface{}, error) {
+	return len(*v) * 8, nil
+}
+
+// get implements the GET action.
+func (v *Value) get(params ...interface{}) (interface{}, error) {
+	return append([]byte(nil), *v...), nil
+}
+
+// set implements the SET action.
+func (v *Value) set(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	b, ok := params[0].([]byte)
+	if !ok {
+		return nil, newParamTypeErr(params[0], b)
+	}
+
+	*v = append(Value(nil), b...)
+	return len(*v) * 8, nil
+}
+
+// bit returns the bit at the offset, which is 0 beyond the end.
+func (v *Value) bit(offset int) int {
+	if offset/8 >= len(*v) {
+		return 0
+	}
+
+	return int((*v)[offset/8]>>(7-offset%8)) & 1
+}
+
+// rangeParams returns the range of offsets from the optional start and end
+// parameters, which is empty (end < start) if there are no bits in it.
+func (v *Value) rangeParams(params []interface{}) (start, end int, err error) {
+	n := len(*v) * 8
+	start, end = 0, n-1
+
+	if len(params) > 0 {
+		if start, err = intParam(params[0]); err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(params) > 1 {
+		if end, err = intParam(params[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= n {
+		end = n - 1
+	}
+
+	return start, end, nil
+}
+
+// intParam returns the parameter which should be an int.
+func intParam(p interface{}) (int, error) {
+	i, ok := p.(int)
+	if !ok {
+		return 0, newParamTypeErr(p, i)
+	}
+
+	return i, nil
+}
+
+// offsetParam returns the parameter which should be a valid offset.
+func offsetParam(p interface{}) (int, error) {
+	offset, err := intParam(p)
+	if err != nil {
+		return 0, err
+	}
+
+	if offset < 0 || offset > MaxOffset {
+		return 0, newParamValueErr(fmt.Sprintf("offset %d not between 0 and %d", offset, MaxOffset))
+	}
+
+	return offset, nil
+}
+
+// bitParam returns the parameter which should be 0 or 1.
+func bitParam(p interface{}) (int, error) {
+	bit, err := intParam(p)
+	if err != nil {
+		return 0, err
+	}
+
+	if bit != 0 && bit != 1 {
+		return 0, newParamValueErr(fmt.Sprintf("bit %d is not 0 or 1", bit))
+	}
+
+	return bit, nil
+}
+
+// ToJSON returns the raw byte array of v's data, which is the bytes encoded in
+// base64.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	return json.Marshal([]byte(*v))
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var b []byte
+	if err := json.Unmarshal(rawmessage, &b); err != nil {
+		return err
+	}
+
+	*v = b
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
@@ -0,0 +1,177 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package bitmap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	do := func(action kiwi.Action, expected int, params ...interface{}) {
+		t.Helper()
+
+		ret, err := store.Do(key, action, params...)
+		if err != nil {
+			t.Fatalf("error while %s%v: %v", action, params, err)
+		}
+		if n, ok := ret.(int); !ok || n != expected {
+			t.Errorf("expected %s%v to return %d; got %#v", action, params, expected, ret)
+		}
+	}
+
+	do(GetBit, 0, 100)
+	do(BitCount, 0)
+	do(BitPos, 0, 0)
+	do(BitPos, -1, 1)
+
+	do(SetBit, 0, 1, 1)
+	do(SetBit, 0, 7, 1)
+	do(SetBit, 0, 19, 1)
+	do(SetBit, 1, 19, 1)
+	do(Len, 24)
+
+	do(GetBit, 1, 1)
+	do(GetBit, 0, 2)
+	do(GetBit, 1, 19)
+
+	do(BitCount, 3)
+	do(BitCount, 2, 0, 7)
+	do(BitCount, 1, 8)
+	do(BitCount, 1, -5, -1)
+	do(BitCount, 0, 2, 6)
+
+	do(BitPos, 1, 1)
+	do(BitPos, 7, 1, 2)
+	do(BitPos, 19, 1, 8)
+	do(BitPos, 0, 0)
+	do(BitPos, -1, 1, 20)
+
+	// bits beyond the end are 0 unless the end is given
+	do(Set, 16, []byte{0xff, 0xff})
+	do(BitPos, 16, 0)
+	do(BitPos, -1, 0, 0, 15)
+
+	if _, err := store.Do(key, SetBit, -1, 1); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for offset; got %v", err)
+	}
+	if _, err := store.Do(key, SetBit, 1, 2); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for bit; got %v", err)
+	}
+	if _, err := store.Do(key, GetBit, "1"); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+
+	do(SetBit, 1, 9, 0)
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Errorf("ToJSON returned unexpected error: %v", err)
+	}
+
+	expectedJSON := `"/78="` // 0xff 0xbf
+	if string(obj) != expectedJSON {
+		t.Errorf("expected JSON %s; got %s", expectedJSON, obj)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Errorf("FromJSON returned unexpected error: %v", err)
+	}
+
+	v, err := store.Do(newKey, Get)
+	if err != nil {
+		t.Fatalf("cannot GET from the store: %v", err)
+	}
+	if b, ok := v.([]byte); !ok || !bytes.Equal(b, []byte{0xff, 0xbf}) {
+		t.Errorf("expected bytes FromJSON ff bf; got %#v", v)
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{
+		"a": Type,
+		"b": Type,
+		"s": str.Type,
+	})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	if _, err := store.Do("a", Set, []byte{0xf0, 0x0f}); err != nil {
+		t.Fatalf("cannot SET: %v", err)
+	}
+	if _, err := store.Do("b", Set, []byte{0x3c}); err != nil {
+		t.Fatalf("cannot SET: %v", err)
+	}
+
+	tests := []struct {
+		op       Op
+		keys     []string
+		expected []byte
+	}{
+		{OpAnd, []string{"a", "b"}, []byte{0x30, 0x00}},
+		{OpOr, []string{"a", "b"}, []byte{0xfc, 0x0f}},
+		{OpXor, []string{"a", "b"}, []byte{0xcc, 0x0f}},
+		{OpNot, []string{"b"}, []byte{0xc3}},
+		{OpAnd, []string{"a", "dest"}, []byte{0xc0, 0x00}}, // dest is NOT b
+	}
+
+	for _, tt := range tests {
+		n, err := BitOp(store, tt.op, "dest", tt.keys...)
+		if err != nil {
+			t.Fatalf("error while BITOP %s %v: %v", tt.op, tt.keys, err)
+		}
+		if n != len(tt.expected) {
+			t.Errorf("expected BITOP %s %v to return %d; got %d", tt.op, tt.keys, len(tt.expected), n)
+		}
+
+		v, err := store.Do("dest", Get)
+		if err != nil {
+			t.Fatalf("cannot GET from the store: %v", err)
+		}
+		if b := v.([]byte); !bytes.Equal(b, tt.expected) {
+			t.Errorf("expected BITOP %s %v to be %x; got %x", tt.op, tt.keys, tt.expected, b)
+		}
+	}
+
+	if _, err := BitOp(store, OpNot, "dest", "a", "b"); !errors.Is(err, ErrInvalidOp) {
+		t.Errorf("expected ErrInvalidOp for NOT with two keys; got %v", err)
+	}
+	if _, err := BitOp(store, Op("NAND"), "dest", "a"); !errors.Is(err, ErrInvalidOp) {
+		t.Errorf("expected ErrInvalidOp; got %v", err)
+	}
+	if _, err := BitOp(store, OpOr, "new", "a", "s"); !errors.Is(err, ErrNotBitmap) {
+		t.Errorf("expected ErrNotBitmap for source; got %v", err)
+	}
+	if store.KeyExists("new") {
+		t.Errorf("expected dest to not be added when a source is invalid")
+	}
+	if _, err := BitOp(store, OpOr, "s", "a"); !errors.Is(err, ErrNotBitmap) {
+		t.Errorf("expected ErrNotBitmap for dest; got %v", err)
+	}
+
+	// missing keys are empty bitmaps
+	if n, err := BitOp(store, OpAnd, "dest", "a", "missing"); err != nil || n != 2 {
+		t.Errorf("expected BITOP AND with missing key to return 2; got %d, %v", n, err)
+	}
+	if v, err := store.Do("dest", Get); err != nil || !bytes.Equal(v.([]byte), []byte{0, 0}) {
+		t.Errorf("expected BITOP AND with missing key to be zeroed; got %v, %v", v, err)
+	}
+}
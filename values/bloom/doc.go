@@ -0,0 +1,17 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package bloom implements a kiwi.Value which is a scalable Bloom filter,
+// telling if an element has definitely not been added or may have been.
+//
+// The filter is sized for the expected capacity and false-positive rate given
+// with the Reserve action, 100 and 1% by default. When more elements are
+// added, a new filter with twice the capacity and half the false-positive rate
+// is stacked on top, which keeps the overall rate close to the configured one.
+// The bits of each filter are limited to MaxFilterSize bytes, so adding fails
+// once the filter would have to scale out beyond it.
+//
+// The hashes do not depend on the platform or the process, so the JSON of a
+// filter can be imported anywhere.
+package bloom
@@ -0,0 +1,497 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package bloom
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return &Value{capacity: DefaultCapacity, errorRate: DefaultErrorRate}
+	})
+}
+
+// Type of bloom value.
+const Type kiwi.ValueType = "bloom"
+
+// Defaults used unless the filter is reserved.
+const (
+	DefaultCapacity  = 100
+	DefaultErrorRate = 0.01
+)
+
+// MaxFilterSize is the maximum size in bytes of the bits of each stacked
+// filter. It limits the capacity and the false-positive rate of the filter, and
+// how far it can scale out.
+const MaxFilterSize = 1 << 28
+
+// maxInt is the maximum value of an int.
+const maxInt = int(^uint(0) >> 1)
+
+const (
+	// expansion is the growth of the capacity of each stacked filter.
+	expansion = 2
+
+	// tightening is the ratio of the false-positive rates of each stacked
+	// filter, so that the sum of the rates converges.
+	tightening = 0.5
+)
+
+// Value can store a scalable Bloom filter.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	capacity  int
+	errorRate float64
+
+	// filters are created as elements are added
+	filters []*filter
+}
+
+// filter is a single Bloom filter of the stack.
+type filter struct {
+	capacity  int
+	errorRate float64
+	hashes    int
+	size      uint64 // in bits
+	count     int
+	bits      []byte
+}
+
+// FilterInfo is the information about a filter returned by the Info action.
+type FilterInfo struct {
+	// Capacity and ErrorRate are the configured capacity and false-positive
+	// rate of the first filter.
+	Capacity  int
+	ErrorRate float64
+
+	// Items is the number of elements added.
+	Items int
+
+	// Filters is the number of stacked filters.
+	Filters int
+
+	// Size is the memory used by the bits of the filters in bytes.
+	Size int
+}
+
+// Various errors for bloom value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+	ErrNotEmpty          = fmt.Errorf("filter is not empty")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Reserve sets the expected capacity (int) and the false-positive rate
+	// (float64) of the filter. It fails with ErrNotEmpty once elements are
+	// added.
+	//
+	// Returns nil.
+	Reserve kiwi.Action = "RESERVE"
+
+	// Add adds the element. It fails with ErrInvalidParamValue if the filter
+	// is full and cannot scale out without exceeding MaxFilterSize.
+	//
+	// Returns true if the element was definitely not added before.
+	Add kiwi.Action = "ADD"
+
+	// MAdd adds the element(s), failing like Add. The elements before the
+	// one which failed are still added.
+	//
+	// Returns a []bool telling, for each element, if it was definitely not
+	// added before.
+	MAdd kiwi.Action = "MADD"
+
+	// Exists checks if the element may have been added.
+	//
+	// Returns false if the element was definitely not added.
+	Exists kiwi.Action = "EXISTS"
+
+	// MExists checks if the element(s) may have been added.
+	//
+	// Returns a []bool with the result for each element.
+	MExists kiwi.Action = "MEXISTS"
+
+	// Info gets the information about the filter.
+	//
+	// Returns a FilterInfo.
+	Info kiwi.Action = "INFO"
+)
+
+// Type returns v's type, i.e., "bloom".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Reserve: v.reserve,
+		Add: func(params ...interface{}) (interface{}, error) {
+			elem, err := elemParam(params)
+			if err != nil {
+				return nil, err
+			}
+
+			return v.add(elem)
+		},
+		MAdd: func(params ...interface{}) (interface{}, error) {
+			elems, err := elemParams(params)
+			if err != nil {
+				return nil, err
+			}
+
+			added := make([]bool, len(elems))
+			for i, elem := range elems {
+				if added[i], err = v.add(elem); err != nil {
+					return nil, err
+				}
+			}
+
+			return added, nil
+		},
+		Exists: func(params ...interface{}) (interface{}, error) {
+			elem, err := elemParam(params)
+			if err != nil {
+				return nil, err
+			}
+
+			return v.exists(newHash(elem)), nil
+		},
+		MExists: func(params ...interface{}) (interface{}, error) {
+			elems, err := elemParams(params)
+			if err != nil {
+				return nil, err
+			}
+
+			exists := make([]bool, len(elems))
+			for i, elem := range elems {
+				exists[i] = v.exists(newHash(elem))
+			}
+
+			return exists, nil
+		},
+		Info: func(params ...interface{}) (interface{}, error) {
+			info := FilterInfo{
+				Capacity:  v.capacity,
+				ErrorRate: v.errorRate,
+				Filters:   len(v.filters),
+			}
+
+			for _, f := range v.filters {
+				info.Items += f.count
+				info.Size += len(f.bits)
+			}
+
+			return info, nil
+		},
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Exists, MExists, Info:
+		return true
+	}
+
+	return false
+}
+
+// reserve implements the RESERVE action.
+func (v *Value) reserve(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	capacity, ok := params[0].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[0], capacity)
+	}
+
+	errorRate, ok := params[1].(float64)
+	if !ok {
+		return nil, newParamTypeErr(params[1], errorRate)
+	}
+
+	if err := validate(capacity, errorRate); err != nil {
+		return nil, newParamValueErr(err.Error())
+	}
+
+	if len(v.filters) > 0 {
+		return nil, ErrNotEmpty
+	}
+
+	v.capacity, v.errorRate = capacity, errorRate
+	return nil, nil
+}
+
+// validate checks the capacity and the false-positive rate, including that
+// the bits of a filter for them do not exceed MaxFilterSize.
+func validate(capacity int, errorRate float64) error {
+	if capacity <= 0 {
+		return fmt.Errorf("capacity %d should be positive", capacity)
+	}
+
+	if !(errorRate > 0 && errorRate < 1) {
+		return fmt.Errorf("error rate %v should be between 0 and 1", errorRate)
+	}
+
+	if size := filterSize(capacity, errorRate); size > MaxFilterSize*8 {
+		return fmt.Errorf("capacity %d with error rate %v needs more than %d bytes",
+			capacity, errorRate, MaxFilterSize)
+	}
+
+	return nil
+}
+
+// add adds the element, returning false if it may have been added before.
+func (v *Value) add(elem string) (bool, error) {
+	h := newHash(elem)
+	if v.exists(h) {
+		return false, nil
+	}
+
+	var f *filter
+	if n := len(v.filters); n > 0 {
+		f = v.filters[n-1]
+	}
+
+	switch {
+	case f == nil:
+		f = newFilter(v.capacity, v.errorRate)
+		v.filters = append(v.filters, f)
+	case f.count >= f.capacity:
+		if f.capacity > maxInt/expansion {
+			return false, newParamValueErr(fmt.Sprintf("filter of capacity %d cannot scale out", f.capacity))
+		}
+
+		capacity, errorRate := f.capacity*expansion, f.errorRate*tightening
+		if err := validate(capacity, errorRate); err != nil {
+			return false, newParamValueErr(fmt.Sprintf("filter cannot scale out: %v", err))
+		}
+
+		f = newFilter(capacity, errorRate)
+		v.filters = append(v.filters, f)
+	}
+
+	f.add(h)
+	return true, nil
+}
+
+// exists tells if the element with the hash may be in any of the filters.
+func (v *Value) exists(h hash) bool {
+	for _, f := range v.filters {
+		if f.has(h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// elemParams returns the parameters as strings, requiring at least one.
+func elemParams(params []interface{}) ([]string, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	elems := make([]string, len(params))
+	for i, p := range params {
+		elem, ok := p.(string)
+		if !ok {
+			return nil, newParamTypeErr(p, elem)
+		}
+		elems[i] = elem
+	}
+
+	return elems, nil
+}
+
+// elemParam returns the first parameter as a string.
+func elemParam(params []interface{}) (string, error) {
+	if len(params) < 1 {
+		return "", newParamLenErr(len(params), 1)
+	}
+
+	elem, ok := params[0].(string)
+	if !ok {
+		return "", newParamTypeErr(params[0], elem)
+	}
+
+	return elem, nil
+}
+
+// newFilter creates a filter with the optimal size and number of hashes for
+// the capacity and the false-positive rate, which should be validated.
+func newFilter(capacity int, errorRate float64) *filter {
+	size := uint64(filterSize(capacity, errorRate))
+	hashes := int(math.Ceil(-math.Log2(errorRate)))
+
+	return &filter{
+		capacity:  capacity,
+		errorRate: errorRate,
+		hashes:    hashes,
+		size:      size,
+		bits:      make([]byte, (size+7)/8),
+	}
+}
+
+// filterSize returns the optimal size in bits of a filter for the capacity and
+// the false-positive rate. It is a float so that it cannot overflow.
+func filterSize(capacity int, errorRate float64) float64 {
+	return math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+}
+
+// add sets the bits for the element with the hash.
+func (f *filter) add(h hash) {
+	for i := 0; i < f.hashes; i++ {
+		bit := h.bit(i, f.size)
+		f.bits[bit/8] |= 1 << (bit % 8)
+	}
+
+	f.count++
+}
+
+// has tells if all the bits for the element with the hash are set.
+func (f *filter) has(h hash) bool {
+	for i := 0; i < f.hashes; i++ {
+		bit := h.bit(i, f.size)
+		if f.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hash is the pair of hashes of an element from which the bits of each
+// filter are derived by double hashing.
+type hash struct{ h1, h2 uint64 }
+
+// newHash hashes the element.
+func newHash(elem string) hash {
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(elem))
+	h1 := mix(f.Sum64())
+
+	// h2 should be odd so that it never cycles through only a few bits
+	return hash{h1: h1, h2: mix(h1^0x9e3779b97f4a7c15) | 1}
+}
+
+// bit returns the i-th bit for the element in a filter of the size.
+func (h hash) bit(i int, size uint64) uint64 {
+	return (h.h1 + uint64(i)*h.h2) % size
+}
+
+// mix is the finalizer of MurmurHash3, which spreads the bits of the FNV hash.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// valueJSON is the JSON form of the value.
+type valueJSON struct {
+	Capacity  int          `json:"capacity"`
+	ErrorRate float64      `json:"error_rate"`
+	Filters   []filterJSON `json:"filters"`
+}
+
+// filterJSON is the JSON form of a filter, with the bits encoded in base64.
+type filterJSON struct {
+	Capacity  int     `json:"capacity"`
+	ErrorRate float64 `json:"error_rate"`
+	Hashes    int     `json:"hashes"`
+	Size      uint64  `json:"size"`
+	Count     int     `json:"count"`
+	Bits      []byte  `json:"bits"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	vj := valueJSON{
+		Capacity:  v.capacity,
+		ErrorRate: v.errorRate,
+		Filters:   make([]filterJSON, len(v.filters)),
+	}
+
+	for i, f := range v.filters {
+		vj.Filters[i] = filterJSON{
+			Capacity:  f.capacity,
+			ErrorRate: f.errorRate,
+			Hashes:    f.hashes,
+			Size:      f.size,
+			Count:     f.count,
+			Bits:      f.bits,
+		}
+	}
+
+	return json.Marshal(vj)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	if err := validate(vj.Capacity, vj.ErrorRate); err != nil {
+		return err
+	}
+
+	filters := make([]*filter, len(vj.Filters))
+	for i, fj := range vj.Filters {
+		if err := validate(fj.Capacity, fj.ErrorRate); err != nil {
+			return fmt.Errorf("filter %d: %v", i, err)
+		}
+		if fj.Hashes <= 0 || fj.Size == 0 || uint64(len(fj.Bits)) != (fj.Size+7)/8 {
+			return fmt.Errorf("filter %d: invalid hashes or bits", i)
+		}
+
+		filters[i] = &filter{
+			capacity:  fj.Capacity,
+			errorRate: fj.ErrorRate,
+			hashes:    fj.Hashes,
+			size:      fj.Size,
+			count:     fj.Count,
+			bits:      fj.Bits,
+		}
+	}
+
+	v.capacity, v.errorRate, v.filters = vj.Capacity, vj.ErrorRate, filters
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
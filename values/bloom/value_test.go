@@ -0,0 +1,207 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package bloom
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	if _, err := store.Do(key, Reserve, 10000, 0.01); err != nil {
+		t.Fatalf("error while reserving: %v", err)
+	}
+
+	if v, err := store.Do(key, Exists, "a"); err != nil || v != false {
+		t.Errorf("expected EXISTS in empty filter to return false; got %v (%v)", v, err)
+	}
+	if v, err := store.Do(key, Add, "a"); err != nil || v != true {
+		t.Errorf("expected ADD to return true; got %v (%v)", v, err)
+	}
+	if v, err := store.Do(key, Add, "a"); err != nil || v != false {
+		t.Errorf("expected ADD of existing element to return false; got %v (%v)", v, err)
+	}
+
+	v, err := store.Do(key, MAdd, "a", "b", "c")
+	if err != nil || !reflect.DeepEqual(v, []bool{false, true, true}) {
+		t.Errorf("expected MADD to return [false true true]; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, MExists, "a", "c", "d")
+	if err != nil || !reflect.DeepEqual(v, []bool{true, true, false}) {
+		t.Errorf("expected MEXISTS to return [true true false]; got %v (%v)", v, err)
+	}
+
+	if _, err := store.Do(key, Reserve, 100, 0.1); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty; got %v", err)
+	}
+	if _, err := store.Do(key, Add, 1); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+	if _, err := store.Do(key, MExists); !errors.Is(err, ErrInvalidParamLen) {
+		t.Errorf("expected ErrInvalidParamLen; got %v", err)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if _, err := store.Do(newKey, Reserve, 0, 0.1); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for capacity; got %v", err)
+	}
+	if _, err := store.Do(newKey, Reserve, 10, 1.0); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for error rate; got %v", err)
+	}
+	if _, err := store.Do(newKey, Reserve, maxInt, 0.01); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for size; got %v", err)
+	}
+	if _, err := store.Do(newKey, Reserve, 10, 1e-300); err != nil {
+		t.Errorf("expected small error rate with small capacity to be reserved; got %v", err)
+	}
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Fatalf("ToJSON returned unexpected error: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Fatalf("FromJSON returned unexpected error: %v", err)
+	}
+
+	v, err = store.Do(newKey, MExists, "a", "b", "c", "d")
+	if err != nil || !reflect.DeepEqual(v, []bool{true, true, true, false}) {
+		t.Errorf("expected MEXISTS FromJSON to return [true true true false]; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(newKey, Info)
+	if err != nil {
+		t.Fatalf("error while getting info: %v", err)
+	}
+
+	expectedInfo := FilterInfo{Capacity: 10000, ErrorRate: 0.01, Items: 3, Filters: 1, Size: 11982}
+	if v != expectedInfo {
+		t.Errorf("expected INFO %+v; got %+v", expectedInfo, v)
+	}
+}
+
+// TestValue_portable checks that the hashes do not change, as the exported
+// filters would not work anymore.
+func TestValue_portable(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{"a": Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	if _, err := store.Do("a", Reserve, 4, 0.25); err != nil {
+		t.Fatalf("error while reserving: %v", err)
+	}
+	if _, err := store.Do("a", MAdd, "kiwi", "bloom"); err != nil {
+		t.Fatalf("error while adding: %v", err)
+	}
+
+	obj, err := store.ToJSON("a")
+	if err != nil {
+		t.Fatalf("ToJSON returned unexpected error: %v", err)
+	}
+
+	expectedJSON := `{"capacity":4,"error_rate":0.25,"filters":[` +
+		`{"capacity":4,"error_rate":0.25,"hashes":2,"size":12,"count":2,"bits":"JQI="}]}`
+	if string(obj) != expectedJSON {
+		t.Errorf("expected JSON:\n%s; got:\n%s", expectedJSON, obj)
+	}
+}
+
+func TestValue_errorRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		capacity  int
+		added     int
+		errorRate float64
+		filters   int
+	}{
+		{"reserved", 10000, 10000, 0.01, 1},
+		{"scaled", 100, 10000, 0.01, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := kiwi.NewStoreFromSchema(kiwi.Schema{"a": Type})
+			if err != nil {
+				t.Fatalf("error while creating store: %v", err)
+			}
+
+			if _, err := store.Do("a", Reserve, tt.capacity, tt.errorRate); err != nil {
+				t.Fatalf("error while reserving: %v", err)
+			}
+
+			for i := 0; i < tt.added; i++ {
+				if _, err := store.Do("a", Add, "in:"+strconv.Itoa(i)); err != nil {
+					t.Fatalf("error while adding: %v", err)
+				}
+			}
+
+			// there are never false negatives
+			for i := 0; i < tt.added; i++ {
+				if v, _ := store.Do("a", Exists, "in:"+strconv.Itoa(i)); v != true {
+					t.Fatalf("expected added element %d to exist", i)
+				}
+			}
+
+			const tries = 100000
+
+			positives := 0
+			for i := 0; i < tries; i++ {
+				if v, _ := store.Do("a", Exists, "out:"+strconv.Itoa(i)); v == true {
+					positives++
+				}
+			}
+
+			// the rates of the stacked filters add up to about twice the rate,
+			// with some room for the variance of the small filters
+			if rate := float64(positives) / tries; rate > 2.5*tt.errorRate {
+				t.Errorf("false-positive rate %.4f; expected at most %.4f", rate, 2.5*tt.errorRate)
+			}
+
+			v, err := store.Do("a", Info)
+			if err != nil {
+				t.Fatalf("error while getting info: %v", err)
+			}
+			if info := v.(FilterInfo); info.Filters != tt.filters {
+				t.Errorf("expected %d filters; got %d", tt.filters, info.Filters)
+			}
+		})
+	}
+}
+
+func TestValue_scaleOut(t *testing.T) {
+	for _, capacity := range []int{maxInt/expansion + 1, MaxFilterSize} {
+		// a full filter whose next filter is too large
+		v := &Value{capacity: capacity, errorRate: 0.01, filters: []*filter{{
+			capacity:  capacity,
+			errorRate: 0.01,
+			hashes:    1,
+			size:      8,
+			count:     capacity,
+			bits:      make([]byte, 1),
+		}}}
+
+		if _, err := v.add("a"); !errors.Is(err, ErrInvalidParamValue) {
+			t.Errorf("expected ErrInvalidParamValue scaling out capacity %d; got %v", capacity, err)
+		}
+		if len(v.filters) != 1 {
+			t.Errorf("expected no filter to be stacked for capacity %d; got %d", capacity, len(v.filters))
+		}
+	}
+}
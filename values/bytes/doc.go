@@ -0,0 +1,10 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package bytes implements a kiwi.Value which can store arbitrary bytes.
+//
+// Unlike a str, the bytes need not be valid UTF-8, and they are encoded in
+// JSON as a base64 string. The bytes are copied in and out of the value, so
+// the slices passed to or returned by the actions can be changed freely.
+package bytes
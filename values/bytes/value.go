@@ -0,0 +1,267 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package bytes
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value { return new(Value) })
+}
+
+// Type of bytes value.
+const Type kiwi.ValueType = "bytes"
+
+// maxLen is the maximum length of the bytes, which keeps large offsets from
+// allocating arbitrary memory.
+const maxLen = 512 << 20
+
+// Value can store bytes.
+//
+// It implements the kiwi.Value interface.
+type Value []byte
+
+// Various errors for bytes value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Get gets the bytes.
+	//
+	// Returns a []byte.
+	Get kiwi.Action = "GET"
+
+	// Set sets the bytes ([]byte).
+	//
+	// Returns nil.
+	Set kiwi.Action = "SET"
+
+	// Append appends the bytes ([]byte).
+	//
+	// Returns the new length as an int.
+	Append kiwi.Action = "APPEND"
+
+	// GetRange gets the bytes from the start to the end index (int), both
+	// included. Negative indices count from the end, -1 being the last byte,
+	// and indices out of the bytes are limited to them.
+	//
+	// Returns a []byte, which is empty if the range is.
+	GetRange kiwi.Action = "GETRANGE"
+
+	// SetRange overwrites the bytes from the offset (int) with the bytes
+	// ([]byte), padding with zero bytes if the offset is after the end.
+	//
+	// Returns the new length as an int.
+	SetRange kiwi.Action = "SETRANGE"
+
+	// Len gets the number of bytes.
+	//
+	// Returns an int.
+	Len kiwi.Action = "LEN"
+)
+
+// Type returns v's type, i.e., "bytes".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Get:      v.get,
+		Set:      v.set,
+		Append:   v.append,
+		GetRange: v.getRange,
+		SetRange: v.setRange,
+		Len:      v.len,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Get, GetRange, Len:
+		return true
+	}
+
+	return false
+}
+
+// get implements the GET action.
+func (v *Value) get(params ...interface{}) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, newParamLenErr(len(params), 0)
+	}
+
+	return append([]byte{}, *v...), nil
+}
+
+// set implements the SET action.
+func (v *Value) set(params ...interface{}) (interface{}, error) {
+	b, err := bytesParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > maxLen {
+		return nil, newParamValueErr(fmt.Sprintf("length %d exceeds %d", len(b), maxLen))
+	}
+
+	*v = append(Value{}, b...)
+	return nil, nil
+}
+
+// append implements the APPEND action.
+func (v *Value) append(params ...interface{}) (interface{}, error) {
+	b, err := bytesParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*v)+len(b) > maxLen {
+		return nil, newParamValueErr(fmt.Sprintf("length %d exceeds %d", len(*v)+len(b), maxLen))
+	}
+
+	*v = append(*v, b...)
+	return len(*v), nil
+}
+
+// getRange implements the GETRANGE action.
+func (v *Value) getRange(params ...interface{}) (interface{}, error) {
+	if len(params) != 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	var idx [2]int
+	for i := range idx {
+		n, ok := params[i].(int)
+		if !ok {
+			return nil, newParamTypeErr(params[i], n)
+		}
+
+		if n < 0 {
+			n += len(*v)
+		}
+		idx[i] = n
+	}
+
+	start, end := idx[0], idx[1]
+	if start < 0 {
+		start = 0
+	}
+	if end >= len(*v) {
+		end = len(*v) - 1
+	}
+
+	if start > end {
+		return []byte{}, nil
+	}
+
+	return append([]byte{}, (*v)[start:end+1]...), nil
+}
+
+// setRange implements the SETRANGE action.
+func (v *Value) setRange(params ...interface{}) (interface{}, error) {
+	if len(params) != 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	offset, ok := params[0].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[0], offset)
+	}
+
+	b, err := bytesParam(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset < 0 {
+		return nil, newParamValueErr(fmt.Sprintf("offset %d should not be negative", offset))
+	}
+
+	if offset > maxLen-len(b) {
+		return nil, newParamValueErr(fmt.Sprintf("length %d exceeds %d", offset+len(b), maxLen))
+	}
+
+	if end := offset + len(b); end > len(*v) {
+		*v = append(*v, make([]byte, end-len(*v))...)
+	}
+
+	copy((*v)[offset:], b)
+	return len(*v), nil
+}
+
+// len implements the LEN action.
+func (v *Value) len(params ...interface{}) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, newParamLenErr(len(params), 0)
+	}
+
+	return len(*v), nil
+}
+
+// bytesParam returns the parameter at the index, which should be the last, as
+// bytes.
+func bytesParam(params []interface{}, i int) ([]byte, error) {
+	if len(params) != i+1 {
+		return nil, newParamLenErr(len(params), i+1)
+	}
+
+	b, ok := params[i].([]byte)
+	if !ok {
+		return nil, newParamTypeErr(params[i], b)
+	}
+
+	return b, nil
+}
+
+// ToJSON returns the raw byte array of v's data, which is the bytes encoded as
+// a base64 string.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	b := []byte(*v)
+	if b == nil {
+		b = []byte{}
+	}
+
+	return json.Marshal(b)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var b []byte
+	if err := json.Unmarshal(rawmessage, &b); err != nil {
+		return err
+	}
+
+	*v = b
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
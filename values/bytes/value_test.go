@@ -0,0 +1,117 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package bytes
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	// not valid UTF-8
+	b := []byte{0xff, 0x00, 0xfe}
+	if _, err := store.Do(key, Set, b); err != nil {
+		t.Fatalf("error while setting: %v", err)
+	}
+
+	// the value does not share the slices
+	b[0] = 'x'
+	v, err := store.Do(key, Get)
+	if err != nil || !reflect.DeepEqual(v, []byte{0xff, 0x00, 0xfe}) {
+		t.Errorf("unexpected GET after changing set bytes: %v (%v)", v, err)
+	}
+	v.([]byte)[0] = 'x'
+	v, err = store.Do(key, Get)
+	if err != nil || !reflect.DeepEqual(v, []byte{0xff, 0x00, 0xfe}) {
+		t.Errorf("unexpected GET after changing got bytes: %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Append, []byte("ab"))
+	if err != nil || v != 5 {
+		t.Errorf("expected APPEND to return 5; got %v (%v)", v, err)
+	}
+
+	ranges := []struct {
+		start, end int
+		expected   []byte
+	}{
+		{0, -1, []byte{0xff, 0x00, 0xfe, 'a', 'b'}},
+		{-2, 10, []byte("ab")},
+		{1, 1, []byte{0x00}},
+		{3, 1, []byte{}},
+		{-10, 0, []byte{0xff}},
+		{7, 9, []byte{}},
+	}
+	for _, r := range ranges {
+		v, err := store.Do(key, GetRange, r.start, r.end)
+		if err != nil || !reflect.DeepEqual(v, r.expected) {
+			t.Errorf("expected GETRANGE %d %d to return %v; got %v (%v)", r.start, r.end, r.expected, v, err)
+		}
+	}
+
+	v, err = store.Do(key, SetRange, 4, []byte("cd"))
+	if err != nil || v != 6 {
+		t.Errorf("expected SETRANGE to return 6; got %v (%v)", v, err)
+	}
+	v, err = store.Do(key, SetRange, 8, []byte("e"))
+	if err != nil || v != 9 {
+		t.Errorf("expected SETRANGE to return 9; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Get)
+	if expected := []byte{0xff, 0x00, 0xfe, 'a', 'c', 'd', 0, 0, 'e'}; err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected GET %v; got %v (%v)", expected, v, err)
+	}
+
+	v, err = store.Do(key, Len)
+	if err != nil || v != 9 {
+		t.Errorf("expected LEN to return 9; got %v (%v)", v, err)
+	}
+
+	if _, err := store.Do(key, SetRange, -1, []byte("a")); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for negative offset; got %v", err)
+	}
+	if _, err := store.Do(key, SetRange, maxLen, []byte("a")); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for large offset; got %v", err)
+	}
+	if _, err := store.Do(key, Set, "abc"); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+}
+
+func TestValue_JSON(t *testing.T) {
+	v := Value{0xff, 0x00, '"', '\\'}
+
+	raw, err := v.ToJSON()
+	if err != nil {
+		t.Fatalf("error while converting to JSON: %v", err)
+	}
+	if string(raw) != `"/wAiXA=="` {
+		t.Errorf("expected base64 JSON; got %s", raw)
+	}
+
+	var nv Value
+	if err := nv.FromJSON(raw); err != nil {
+		t.Fatalf("error while converting from JSON: %v", err)
+	}
+	if !reflect.DeepEqual(v, nv) {
+		t.Errorf("expected %v; got %v", v, nv)
+	}
+
+	raw, err = new(Value).ToJSON()
+	if err != nil || string(raw) != `""` {
+		t.Errorf("expected empty JSON string; got %s (%v)", raw, err)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package cms implements a kiwi.Value which is a Count-Min Sketch, estimating
+// how many times each item was counted in a fixed amount of memory.
+//
+// The estimates are never lower than the actual counts. With a width of w and
+// a depth of d, an estimate exceeds the count by more than e/w times the total
+// of all the counts with a probability of at most 1/e^d. The dimensions are
+// set with InitByDim or derived from the error and the probability with
+// InitByProb; a sketch of width 2000 and depth 5 is used otherwise.
+//
+// Merge adds the counts of the sketches of several keys of a store into
+// another key, taking the keys which do not exist as empty sketches.
+package cms
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package cms
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// ErrNotCMS is returned when a key does not have a Count-Min Sketch.
+var ErrNotCMS = fmt.Errorf("value is not a cms")
+
+// Merge adds the counts of the sketches of the keys into the sketch of the
+// dest key, which is added to the store if it does not exist. The sketches
+// should have the same dimensions, except dest which takes the dimensions of
+// the others if it is empty.
+//
+// The keys which do not exist are taken as empty sketches. All the keys are
+// checked before dest is added, so dest is not added if any of them has
+// another type or other dimensions.
+func Merge(store *kiwi.Store, dest string, keys ...string) error {
+	var sum Counters
+	var first string
+	found := false
+	for _, key := range keys {
+		if err := guard(store, key); err != nil {
+			if errors.Is(err, kiwi.ErrKeyNotExist) {
+				continue
+			}
+			return err
+		}
+
+		v, err := store.Do(key, GetCounters)
+		if err != nil {
+			if errors.Is(err, kiwi.ErrKeyNotExist) {
+				// deleted meanwhile
+				continue
+			}
+			return err
+		}
+
+		c := v.(Counters) //nolint:errcheck
+		if !found {
+			sum, first, found = c, key, true
+			continue
+		}
+
+		if c.Width != sum.Width || c.Depth != sum.Depth {
+			return fmt.Errorf("%w: %q and %q", ErrDimMismatch, first, key)
+		}
+
+		for j := range sum.Counters {
+			sum.Counters[j] += c.Counters[j]
+		}
+		sum.Count += c.Count
+	}
+
+	if err := guard(store, dest); err != nil {
+		if !errors.Is(err, kiwi.ErrKeyNotExist) {
+			return err
+		}
+		if err := store.AddKey(dest, Type); err != nil && !errors.Is(err, kiwi.ErrKeyExists) {
+			return err
+		}
+	}
+
+	if !found {
+		// no sketch to merge
+		return nil
+	}
+
+	// the counts are added to dest at once
+	_, err := store.Do(dest, MergeCounters, sum)
+	return err
+}
+
+// guard returns an error if the key does not have a sketch.
+func guard(store *kiwi.Store, key string) error {
+	typ, err := store.GetValueType(key)
+	if err != nil {
+		return err
+	}
+
+	if typ != Type {
+		return fmt.Errorf("%w: %q has %q", ErrNotCMS, key, typ)
+	}
+
+	return nil
+}
@@ -0,0 +1,398 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package cms
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value { return newValue(DefaultWidth, DefaultDepth) })
+}
+
+// Type of cms value.
+const Type kiwi.ValueType = "cms"
+
+// Dimensions used unless the sketch is initialized.
+const (
+	DefaultWidth = 2000
+	DefaultDepth = 5
+)
+
+// maxCounters limits the size of a sketch to 512 MiB.
+const maxCounters = 1 << 26
+
+// Value can store a Count-Min Sketch.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	width int
+	depth int
+	count int64
+
+	// counters has depth rows of width counters each
+	counters []int64
+}
+
+// newValue creates an empty sketch of the dimensions.
+func newValue(width, depth int) *Value {
+	return &Value{
+		width:    width,
+		depth:    depth,
+		counters: make([]int64, width*depth),
+	}
+}
+
+// SketchInfo is the information about a sketch returned by the Info action.
+type SketchInfo struct {
+	Width int
+	Depth int
+
+	// Count is the total of all the counts.
+	Count int64
+}
+
+// Counters are the dimensions and the counters of a sketch, returned by the
+// Counters action.
+type Counters struct {
+	Width    int
+	Depth    int
+	Count    int64
+	Counters []int64
+}
+
+// Various errors for cms value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+	ErrNotEmpty          = fmt.Errorf("sketch is not empty")
+	ErrDimMismatch       = fmt.Errorf("sketch dimensions do not match")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// InitByDim sets the width and the depth (both int) of the sketch. It
+	// fails with ErrNotEmpty once items are counted.
+	//
+	// Returns nil.
+	InitByDim kiwi.Action = "INITBYDIM"
+
+	// InitByProb sets the dimensions of the sketch so that an estimate
+	// exceeds the count by more than the error (float64), as a fraction of
+	// the total of the counts, with at most the probability (float64). It
+	// fails with ErrNotEmpty once items are counted.
+	//
+	// Returns nil.
+	InitByProb kiwi.Action = "INITBYPROB"
+
+	// IncrBy increments the count of the item (string) by the increment,
+	// which is a positive int.
+	//
+	// Returns the estimated count (int64) of the item.
+	IncrBy kiwi.Action = "INCRBY"
+
+	// Query estimates the count of the item(s).
+	//
+	// Returns an []int64 with the estimate for each item.
+	Query kiwi.Action = "QUERY"
+
+	// Info gets the information about the sketch.
+	//
+	// Returns a SketchInfo.
+	Info kiwi.Action = "INFO"
+
+	// GetCounters gets a copy of the counters of the sketch.
+	//
+	// Returns a Counters.
+	GetCounters kiwi.Action = "GETCOUNTERS"
+
+	// MergeCounters adds the Counters of another sketch, which should have
+	// the same dimensions unless this sketch is empty.
+	//
+	// Returns nil.
+	MergeCounters kiwi.Action = "MERGECOUNTERS"
+)
+
+// Type returns v's type, i.e., "cms".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		InitByDim:     v.initByDim,
+		InitByProb:    v.initByProb,
+		IncrBy:        v.incrBy,
+		Query:         v.query,
+		MergeCounters: v.mergeCounters,
+		Info: func(params ...interface{}) (interface{}, error) {
+			return SketchInfo{Width: v.width, Depth: v.depth, Count: v.count}, nil
+		},
+		GetCounters: func(params ...interface{}) (interface{}, error) {
+			return Counters{
+				Width:    v.width,
+				Depth:    v.depth,
+				Count:    v.count,
+				Counters: append([]int64(nil), v.counters...),
+			}, nil
+		},
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Query, Info, GetCounters:
+		return true
+	}
+
+	return false
+}
+
+// initByDim implements the INITBYDIM action.
+func (v *Value) initByDim(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	width, ok := params[0].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[0], width)
+	}
+
+	depth, ok := params[1].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[1], depth)
+	}
+
+	return nil, v.init(width, depth)
+}
+
+// initByProb implements the INITBYPROB action.
+func (v *Value) initByProb(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	epsilon, ok := params[0].(float64)
+	if !ok {
+		return nil, newParamTypeErr(params[0], epsilon)
+	}
+
+	delta, ok := params[1].(float64)
+	if !ok {
+		return nil, newParamTypeErr(params[1], delta)
+	}
+
+	if !(epsilon > 0 && epsilon < 1) || !(delta > 0 && delta < 1) {
+		return nil, newParamValueErr("error and probability should be between 0 and 1")
+	}
+
+	width := int(math.Ceil(math.E / epsilon))
+	depth := int(math.Ceil(math.Log(1 / delta)))
+	return nil, v.init(width, depth)
+}
+
+// init resets the empty sketch to the dimensions.
+func (v *Value) init(width, depth int) error {
+	if err := validate(width, depth); err != nil {
+		return newParamValueErr(err.Error())
+	}
+
+	if v.count > 0 {
+		return ErrNotEmpty
+	}
+
+	*v = *newValue(width, depth)
+	return nil
+}
+
+// validate checks the dimensions of a sketch.
+func validate(width, depth int) error {
+	if width <= 0 || depth <= 0 || width > maxCounters/depth {
+		return fmt.Errorf("invalid dimensions %dx%d", width, depth)
+	}
+
+	return nil
+}
+
+// incrBy implements the INCRBY action.
+func (v *Value) incrBy(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	item, ok := params[0].(string)
+	if !ok {
+		return nil, newParamTypeErr(params[0], item)
+	}
+
+	incr, ok := params[1].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[1], incr)
+	}
+	if incr <= 0 {
+		return nil, newParamValueErr(fmt.Sprintf("increment %d should be positive", incr))
+	}
+
+	h := newHash(item)
+	estimate := int64(math.MaxInt64)
+	for i := 0; i < v.depth; i++ {
+		c := &v.counters[v.index(h, i)]
+		*c += int64(incr)
+		if *c < estimate {
+			estimate = *c
+		}
+	}
+
+	v.count += int64(incr)
+	return estimate, nil
+}
+
+// query implements the QUERY action.
+func (v *Value) query(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	estimates := make([]int64, len(params))
+	for j, p := range params {
+		item, ok := p.(string)
+		if !ok {
+			return nil, newParamTypeErr(p, item)
+		}
+
+		h := newHash(item)
+		estimates[j] = math.MaxInt64
+		for i := 0; i < v.depth; i++ {
+			if c := v.counters[v.index(h, i)]; c < estimates[j] {
+				estimates[j] = c
+			}
+		}
+	}
+
+	return estimates, nil
+}
+
+// mergeCounters implements the MERGECOUNTERS action.
+func (v *Value) mergeCounters(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	c, ok := params[0].(Counters)
+	if !ok {
+		return nil, newParamTypeErr(params[0], c)
+	}
+
+	if c.Width != v.width || c.Depth != v.depth {
+		if v.count > 0 {
+			return nil, fmt.Errorf("%w: %dx%d and %dx%d", ErrDimMismatch, v.width, v.depth, c.Width, c.Depth)
+		}
+		if err := v.init(c.Width, c.Depth); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.Counters) != len(v.counters) {
+		return nil, newParamValueErr("counters do not match the dimensions")
+	}
+
+	for i := range v.counters {
+		v.counters[i] += c.Counters[i]
+	}
+	v.count += c.Count
+
+	return nil, nil
+}
+
+// index returns the index of the counter for the hash in the row.
+func (v *Value) index(h hash, row int) int {
+	return row*v.width + int((h.h1+uint64(row)*h.h2)%uint64(v.width))
+}
+
+// hash is the pair of hashes of an item from which its counter in each row is
+// derived by double hashing.
+type hash struct{ h1, h2 uint64 }
+
+// newHash hashes the item.
+func newHash(item string) hash {
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(item))
+	h1 := mix(f.Sum64())
+
+	return hash{h1: h1, h2: mix(h1^0x9e3779b97f4a7c15) | 1}
+}
+
+// mix is the finalizer of MurmurHash3, which spreads the bits of the FNV hash.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// valueJSON is the JSON form of the value.
+type valueJSON struct {
+	Width    int     `json:"width"`
+	Depth    int     `json:"depth"`
+	Count    int64   `json:"count"`
+	Counters []int64 `json:"counters"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	return json.Marshal(valueJSON{
+		Width:    v.width,
+		Depth:    v.depth,
+		Count:    v.count,
+		Counters: v.counters,
+	})
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	if err := validate(vj.Width, vj.Depth); err != nil {
+		return err
+	}
+	if len(vj.Counters) != vj.Width*vj.Depth {
+		return fmt.Errorf("%d counters do not match the dimensions %dx%d", len(vj.Counters), vj.Width, vj.Depth)
+	}
+
+	*v = Value{width: vj.Width, depth: vj.Depth, count: vj.Count, counters: vj.Counters}
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
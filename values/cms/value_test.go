@@ -0,0 +1,163 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package cms
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	if _, err := store.Do(key, InitByProb, 0.001, 0.01); err != nil {
+		t.Fatalf("error while initializing: %v", err)
+	}
+
+	v, err := store.Do(key, Info)
+	if err != nil || v != (SketchInfo{Width: 2719, Depth: 5}) {
+		t.Errorf("expected 2719x5 sketch; got %+v (%v)", v, err)
+	}
+
+	if v, err := store.Do(key, IncrBy, "a", 5); err != nil || v != int64(5) {
+		t.Errorf("expected INCRBY to return 5; got %v (%v)", v, err)
+	}
+	if v, err := store.Do(key, IncrBy, "a", 2); err != nil || v != int64(7) {
+		t.Errorf("expected INCRBY to return 7; got %v (%v)", v, err)
+	}
+
+	// item i is counted i times
+	total := int64(7)
+	for i := 1; i <= 1000; i++ {
+		if _, err := store.Do(key, IncrBy, "item:"+strconv.Itoa(i), i); err != nil {
+			t.Fatalf("error while incrementing: %v", err)
+		}
+		total += int64(i)
+	}
+
+	// the estimates are never lower, and are within 0.1% of the total
+	for i := 1; i <= 1000; i++ {
+		v, err := store.Do(key, Query, "item:"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("error while querying: %v", err)
+		}
+
+		estimate := v.([]int64)[0]
+		if estimate < int64(i) || estimate > int64(i)+total/1000 {
+			t.Errorf("estimate %d for count %d is out of bounds", estimate, i)
+		}
+	}
+
+	if _, err := store.Do(key, InitByDim, 10, 2); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty; got %v", err)
+	}
+	if _, err := store.Do(key, IncrBy, "a", 0); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue; got %v", err)
+	}
+	if _, err := store.Do(key, Query, 1); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Fatalf("ToJSON returned unexpected error: %v", err)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Fatalf("FromJSON returned unexpected error: %v", err)
+	}
+
+	v1, _ := store.Do(key, Query, "a", "item:10", "missing")
+	v2, err := store.Do(newKey, Query, "a", "item:10", "missing")
+	if err != nil || !reflect.DeepEqual(v1, v2) {
+		t.Errorf("expected estimates %v FromJSON; got %v (%v)", v1, v2, err)
+	}
+
+	if err := store.FromJSON(newKey, []byte(`{"width":2,"depth":2,"counters":[1]}`)); err == nil {
+		t.Errorf("expected error FromJSON for wrong number of counters")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{
+		"a":     Type,
+		"b":     Type,
+		"small": Type,
+		"s":     str.Type,
+	})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := store.Do(key, IncrBy, "x", 3); err != nil {
+			t.Fatalf("error while incrementing: %v", err)
+		}
+	}
+	if _, err := store.Do("b", IncrBy, "y", 4); err != nil {
+		t.Fatalf("error while incrementing: %v", err)
+	}
+
+	if err := Merge(store, "dest", "a", "b"); err != nil {
+		t.Fatalf("error while merging: %v", err)
+	}
+
+	v, err := store.Do("dest", Query, "x", "y", "z")
+	if err != nil || !reflect.DeepEqual(v, []int64{6, 4, 0}) {
+		t.Errorf("expected merged estimates [6 4 0]; got %v (%v)", v, err)
+	}
+
+	if v, err := store.Do("dest", Info); err != nil || v.(SketchInfo).Count != 10 {
+		t.Errorf("expected merged count 10; got %v (%v)", v, err)
+	}
+
+	if _, err := store.Do("small", InitByDim, 10, 2); err != nil {
+		t.Fatalf("error while initializing: %v", err)
+	}
+
+	if err := Merge(store, "new", "a", "small"); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("expected ErrDimMismatch for sources; got %v", err)
+	}
+	if err := Merge(store, "new", "a", "s"); !errors.Is(err, ErrNotCMS) {
+		t.Errorf("expected ErrNotCMS for source; got %v", err)
+	}
+	if store.KeyExists("new") {
+		t.Errorf("expected dest to not be added when a source is invalid")
+	}
+	if err := Merge(store, "dest", "small"); !errors.Is(err, ErrDimMismatch) {
+		t.Errorf("expected ErrDimMismatch for dest; got %v", err)
+	}
+	if err := Merge(store, "dest", "s"); !errors.Is(err, ErrNotCMS) {
+		t.Errorf("expected ErrNotCMS; got %v", err)
+	}
+
+	// missing keys are empty sketches
+	if err := Merge(store, "dest", "missing", "a"); err != nil {
+		t.Fatalf("error while merging missing key: %v", err)
+	}
+	if v, err := store.Do("dest", Query, "x"); err != nil || !reflect.DeepEqual(v, []int64{9}) {
+		t.Errorf("expected merged estimates [9]; got %v (%v)", v, err)
+	}
+	if err := Merge(store, "new", "missing"); err != nil {
+		t.Fatalf("error while merging missing key: %v", err)
+	}
+	if v, err := store.Do("new", Info); err != nil || v.(SketchInfo).Count != 0 {
+		t.Errorf("expected empty merged sketch; got %v (%v)", v, err)
+	}
+}
@@ -0,0 +1,10 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package counter implements a kiwi.Value which can store a 64-bit integer.
+//
+// The actions use atomic operations, so the store executes them without
+// locking the key, and the arithmetic fails with ErrOverflow instead of
+// wrapping around.
+package counter
@@ -0,0 +1,210 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package counter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value { return new(Value) })
+}
+
+// Type of counter value.
+const Type kiwi.ValueType = "counter"
+
+// Value can store a 64-bit integer.
+//
+// It implements the kiwi.ConcurrentValue interface.
+type Value struct{ n int64 }
+
+// Various errors for counter value type.
+var (
+	ErrInvalidParamLen  = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType = fmt.Errorf("invalid paramater type")
+	ErrOverflow         = fmt.Errorf("counter overflow")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p interface{}) error {
+	return fmt.Errorf("%w: %#v not an \"int64\"", ErrInvalidParamType, p)
+}
+
+// newOverflowErr creates an error where adding the delta overflows.
+func newOverflowErr(n, delta int64) error {
+	return fmt.Errorf("%w: %d + %d", ErrOverflow, n, delta)
+}
+
+const (
+	// Get gets the value of the counter.
+	//
+	// Returns an int64.
+	Get kiwi.Action = "GET"
+
+	// Set sets the value of the counter.
+	//
+	// Returns the updated value.
+	Set kiwi.Action = "SET"
+
+	// GetSet sets the value of the counter.
+	//
+	// Returns the old value.
+	GetSet kiwi.Action = "GETSET"
+
+	// Incr increments the counter by 1.
+	//
+	// Returns the updated value.
+	Incr kiwi.Action = "INCR"
+
+	// Decr decrements the counter by 1.
+	//
+	// Returns the updated value.
+	Decr kiwi.Action = "DECR"
+
+	// IncrBy increments the counter by the given value, which can be
+	// negative.
+	//
+	// Returns the updated value.
+	IncrBy kiwi.Action = "INCRBY"
+)
+
+// Type returns v's type, i.e., "counter".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// Concurrent marks v's actions as safe to execute concurrently.
+func (v *Value) Concurrent() {}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Get:    v.get,
+		Set:    v.set,
+		GetSet: v.getSet,
+		Incr:   v.incr,
+		Decr:   v.decr,
+		IncrBy: v.incrBy,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Get:
+		return true
+	}
+
+	return false
+}
+
+// get implements the GET action.
+func (v *Value) get(params ...interface{}) (interface{}, error) {
+	return atomic.LoadInt64(&v.n), nil
+}
+
+// set implements the SET action.
+func (v *Value) set(params ...interface{}) (interface{}, error) {
+	n, err := intParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.StoreInt64(&v.n, n)
+	return n, nil
+}
+
+// getSet implements the GETSET action.
+func (v *Value) getSet(params ...interface{}) (interface{}, error) {
+	n, err := intParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return atomic.SwapInt64(&v.n, n), nil
+}
+
+// incr implements the INCR action.
+func (v *Value) incr(params ...interface{}) (interface{}, error) {
+	return v.add(1)
+}
+
+// decr implements the DECR action.
+func (v *Value) decr(params ...interface{}) (interface{}, error) {
+	return v.add(-1)
+}
+
+// incrBy implements the INCRBY action.
+func (v *Value) incrBy(params ...interface{}) (interface{}, error) {
+	delta, err := intParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.add(delta)
+}
+
+// add adds the delta to the counter unless it overflows.
+func (v *Value) add(delta int64) (interface{}, error) {
+	for {
+		old := atomic.LoadInt64(&v.n)
+
+		n := old + delta
+		if (delta > 0 && n < old) || (delta < 0 && n > old) {
+			return nil, newOverflowErr(old, delta)
+		}
+
+		if atomic.CompareAndSwapInt64(&v.n, old, n) {
+			return n, nil
+		}
+	}
+}
+
+// intParam returns the only parameter, which is an int64 or an int.
+func intParam(params []interface{}) (int64, error) {
+	if len(params) < 1 {
+		return 0, newParamLenErr(len(params), 1)
+	}
+
+	switch n := params[0].(type) {
+	case int64:
+		return n, nil
+	case int:
+		return int64(n), nil
+	default:
+		return 0, newParamTypeErr(params[0])
+	}
+}
+
+// ToJSON returns the raw byte array of v's data, which is a number.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	return json.Marshal(atomic.LoadInt64(&v.n))
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var n int64
+	if err := json.Unmarshal(rawmessage, &n); err != nil {
+		return err
+	}
+
+	atomic.StoreInt64(&v.n, n)
+	return nil
+}
+
+// Interface guards.
+var (
+	_ kiwi.ConcurrentValue = (*Value)(nil)
+	_ kiwi.ReadOnlyValue   = (*Value)(nil)
+)
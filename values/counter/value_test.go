@@ -0,0 +1,104 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package counter
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	do := func(action kiwi.Action, expected int64, params ...interface{}) {
+		t.Helper()
+
+		ret, err := store.Do(key, action, params...)
+		if err != nil {
+			t.Fatalf("error while %s: %v", action, err)
+		}
+		if n, ok := ret.(int64); !ok || n != expected {
+			t.Errorf("expected %s to return %d; got %#v", action, expected, ret)
+		}
+	}
+
+	do(Get, 0)
+	do(Incr, 1)
+	do(IncrBy, 11, 10)
+	do(IncrBy, 6, int64(-5))
+	do(Decr, 5)
+	do(GetSet, 5, 42)
+	do(Get, 42)
+	do(Set, 7, int64(7))
+
+	if _, err := store.Do(key, IncrBy, "1"); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+	if _, err := store.Do(key, Set); !errors.Is(err, ErrInvalidParamLen) {
+		t.Errorf("expected ErrInvalidParamLen; got %v", err)
+	}
+
+	do(Set, math.MaxInt64, int64(math.MaxInt64))
+	if _, err := store.Do(key, Incr); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected ErrOverflow; got %v", err)
+	}
+
+	do(Set, math.MinInt64, int64(math.MinInt64))
+	if _, err := store.Do(key, IncrBy, -1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected ErrOverflow; got %v", err)
+	}
+	do(Get, math.MinInt64)
+
+	// actions are executed concurrently without the key being locked
+	do(Set, 0, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if _, err := store.Do(key, Incr); err != nil {
+					t.Errorf("error while incrementing: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	do(Get, 8000)
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Errorf("ToJSON returned unexpected error: %v", err)
+	}
+	if string(obj) != "8000" {
+		t.Errorf("expected JSON 8000; got %s", obj)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Errorf("FromJSON returned unexpected error: %v", err)
+	}
+	if v, err := store.Do(newKey, Get); err != nil || v != int64(8000) {
+		t.Errorf("expected 8000 FromJSON; got %v (%v)", v, err)
+	}
+
+	if err := store.FromJSON(newKey, []byte("1.5")); err == nil {
+		t.Errorf("expected error FromJSON for non-integer")
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package decimal implements a kiwi.Value which can store a fixed-point
+// decimal number, like a monetary amount, without losing precision.
+//
+// The value keeps a fixed number of digits after the decimal point, called the
+// scale, which is 0 by default. Results with more digits are rounded to the
+// scale using one of the rounding modes. The numbers are passed as strings,
+// e.g., "-12.50", and the JSON keeps them as strings as well.
+package decimal
@@ -0,0 +1,400 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package decimal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value { return new(Value) })
+}
+
+// Type of decimal value.
+const Type kiwi.ValueType = "decimal"
+
+// MaxScale is the maximum number of digits after the decimal point.
+const MaxScale = 64
+
+// Value can store a fixed-point decimal number.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	// the number is unscaled / 10^scale
+	unscaled big.Int
+	scale    int
+}
+
+// Various errors for decimal value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+// RoundingMode decides how a number is rounded to the scale.
+type RoundingMode string
+
+// Rounding modes.
+const (
+	// RoundHalfEven rounds to the nearest number, and to the even one if both
+	// are equally near. It is the default rounding mode.
+	RoundHalfEven RoundingMode = "HALF_EVEN"
+
+	// RoundHalfUp rounds to the nearest number, and away from zero if both are
+	// equally near.
+	RoundHalfUp RoundingMode = "HALF_UP"
+
+	// RoundDown rounds towards zero.
+	RoundDown RoundingMode = "DOWN"
+
+	// RoundUp rounds away from zero.
+	RoundUp RoundingMode = "UP"
+
+	// RoundFloor rounds towards negative infinity.
+	RoundFloor RoundingMode = "FLOOR"
+
+	// RoundCeiling rounds towards positive infinity.
+	RoundCeiling RoundingMode = "CEILING"
+)
+
+const (
+	// Get gets the number.
+	//
+	// Returns a string.
+	Get kiwi.Action = "GET"
+
+	// Set sets the number, given as a string. An optional rounding mode is
+	// used if the number has more digits than the scale.
+	//
+	// Returns the updated number.
+	Set kiwi.Action = "SET"
+
+	// Add adds the number, given as a string. An optional rounding mode is
+	// used if the number has more digits than the scale.
+	//
+	// Returns the updated number.
+	Add kiwi.Action = "ADD"
+
+	// Sub subtracts the number, given as a string. An optional rounding mode
+	// is used if the number has more digits than the scale.
+	//
+	// Returns the updated number.
+	Sub kiwi.Action = "SUB"
+
+	// Mul multiplies by the number, given as a string. An optional rounding
+	// mode is used to round the product to the scale.
+	//
+	// Returns the updated number.
+	Mul kiwi.Action = "MUL"
+
+	// Scale gets the scale.
+	//
+	// Returns an integer.
+	Scale kiwi.Action = "SCALE"
+
+	// SetScale sets the scale, given as an int between 0 and MaxScale. An
+	// optional rounding mode is used if the scale is reduced.
+	//
+	// Returns the updated number.
+	SetScale kiwi.Action = "SETSCALE"
+)
+
+// Type returns v's type, i.e., "decimal".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Get:      v.get,
+		Set:      v.arith(func(_, b *big.Int) *big.Int { return b }),
+		Add:      v.arith(func(a, b *big.Int) *big.Int { return new(big.Int).Add(a, b) }),
+		Sub:      v.arith(func(a, b *big.Int) *big.Int { return new(big.Int).Sub(a, b) }),
+		Mul:      v.mul,
+		Scale:    v.getScale,
+		SetScale: v.setScale,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Get, Scale:
+		return true
+	}
+
+	return false
+}
+
+// get implements the GET action.
+func (v *Value) get(params ...interface{}) (interface{}, error) {
+	return format(&v.unscaled, v.scale), nil
+}
+
+// getScale implements the SCALE action.
+func (v *Value) getScale(params ...interface{}) (interface{}, error) {
+	return v.scale, nil
+}
+
+// arith returns the do function for an action computing the new number from
+// the current one and the parameter, both at the scale of the value.
+func (v *Value) arith(fn func(a, b *big.Int) *big.Int) kiwi.DoFunc {
+	return func(params ...interface{}) (interface{}, error) {
+		n, scale, mode, err := numberParams(params)
+		if err != nil {
+			return nil, err
+		}
+
+		b := rescale(n, scale, v.scale, mode)
+		v.unscaled.Set(fn(&v.unscaled, b))
+		return format(&v.unscaled, v.scale), nil
+	}
+}
+
+// mul implements the MUL action.
+func (v *Value) mul(params ...interface{}) (interface{}, error) {
+	n, scale, mode, err := numberParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	product := new(big.Int).Mul(&v.unscaled, n)
+	v.unscaled.Set(rescale(product, v.scale+scale, v.scale, mode))
+	return format(&v.unscaled, v.scale), nil
+}
+
+// setScale implements the SETSCALE action.
+func (v *Value) setScale(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	scale, ok := params[0].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[0], scale)
+	}
+	if scale < 0 || scale > MaxScale {
+		return nil, newParamValueErr(fmt.Sprintf("scale %d not between 0 and %d", scale, MaxScale))
+	}
+
+	mode, err := modeParam(params[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	v.unscaled.Set(rescale(&v.unscaled, v.scale, scale, mode))
+	v.scale = scale
+	return format(&v.unscaled, v.scale), nil
+}
+
+// numberParams returns the number and the optional rounding mode from the
+// parameters.
+func numberParams(params []interface{}) (*big.Int, int, RoundingMode, error) {
+	if len(params) < 1 {
+		return nil, 0, "", newParamLenErr(len(params), 1)
+	}
+
+	s, ok := params[0].(string)
+	if !ok {
+		return nil, 0, "", newParamTypeErr(params[0], s)
+	}
+
+	n, scale, err := parse(s)
+	if err != nil {
+		return nil, 0, "", newParamValueErr(err.Error())
+	}
+
+	mode, err := modeParam(params[1:])
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return n, scale, mode, nil
+}
+
+// modeParam returns the rounding mode if it is in the parameters, else the
+// default mode.
+func modeParam(params []interface{}) (RoundingMode, error) {
+	if len(params) < 1 {
+		return RoundHalfEven, nil
+	}
+
+	mode, ok := params[0].(RoundingMode)
+	if !ok {
+		return "", newParamTypeErr(params[0], mode)
+	}
+
+	switch mode {
+	case RoundHalfEven, RoundHalfUp, RoundDown, RoundUp, RoundFloor, RoundCeiling:
+		return mode, nil
+	default:
+		return "", newParamValueErr(fmt.Sprintf("unknown rounding mode %q", mode))
+	}
+}
+
+// parse parses the decimal number, returning the unscaled number and the
+// number of digits after the decimal point.
+func parse(s string) (*big.Int, int, error) {
+	digits := s
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+
+	intPart, fracPart := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intPart, fracPart = digits[:i], digits[i+1:]
+	}
+
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return nil, 0, fmt.Errorf("%q is not a decimal number", s)
+	}
+	if len(fracPart) > MaxScale {
+		return nil, 0, fmt.Errorf("%q has more than %d digits after the decimal point", s, MaxScale)
+	}
+
+	// cannot fail since there is at least one digit
+	n, _ := new(big.Int).SetString(intPart+fracPart, 10)
+	if s[0] == '-' {
+		n.Neg(n)
+	}
+
+	return n, len(fracPart), nil
+}
+
+// isDigits tells if the string only has decimal digits.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// format formats the unscaled number with the scale.
+func format(n *big.Int, scale int) string {
+	digits := new(big.Int).Abs(n).String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+
+	if n.Sign() < 0 {
+		return "-" + digits
+	}
+
+	return digits
+}
+
+// pow10 returns 10^n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// rescale converts the unscaled number from one scale to another, rounding
+// it with the mode if the scale is reduced.
+func rescale(n *big.Int, from, to int, mode RoundingMode) *big.Int {
+	if to >= from {
+		return new(big.Int).Mul(n, pow10(to-from))
+	}
+
+	d := pow10(from - to)
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	// the quotient is truncated towards zero, so moving away from zero is
+	// in the direction of the sign of n
+	sign := int64(n.Sign())
+	away := false
+
+	switch mode {
+	case RoundDown:
+	case RoundUp:
+		away = true
+	case RoundFloor:
+		away = sign < 0
+	case RoundCeiling:
+		away = sign > 0
+	case RoundHalfUp, RoundHalfEven:
+		half := new(big.Int).Lsh(new(big.Int).Abs(r), 1).Cmp(d)
+		away = half > 0 || half == 0 && (mode == RoundHalfUp || q.Bit(0) == 1)
+	}
+
+	if away {
+		q.Add(q, big.NewInt(sign))
+	}
+
+	return q
+}
+
+// valueJSON is the JSON form of the value.
+type valueJSON struct {
+	Value string `json:"value"`
+	Scale int    `json:"scale"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	return json.Marshal(valueJSON{
+		Value: format(&v.unscaled, v.scale),
+		Scale: v.scale,
+	})
+}
+
+// FromJSON populates v with the data from RawMessage. The number should not
+// have more digits than the scale.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	if vj.Scale < 0 || vj.Scale > MaxScale {
+		return fmt.Errorf("scale %d not between 0 and %d", vj.Scale, MaxScale)
+	}
+
+	n, scale, err := parse(vj.Value)
+	if err != nil {
+		return err
+	}
+	if scale > vj.Scale {
+		return fmt.Errorf("%q has more digits than the scale %d", vj.Value, vj.Scale)
+	}
+
+	v.unscaled.Set(rescale(n, scale, vj.Scale, RoundHalfEven))
+	v.scale = vj.Scale
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
@@ -0,0 +1,125 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package decimal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	do := func(action kiwi.Action, expected string, params ...interface{}) {
+		t.Helper()
+
+		ret, err := store.Do(key, action, params...)
+		if err != nil {
+			t.Fatalf("error while %s: %v", action, err)
+		}
+		if s, ok := ret.(string); !ok || s != expected {
+			t.Errorf("expected %s to return %q; got %#v", action, expected, ret)
+		}
+	}
+
+	do(Get, "0")
+	do(SetScale, "0.00", 2)
+	do(Set, "10.10", "10.1")
+	do(Add, "10.30", "0.2")
+	do(Sub, "-0.70", "11")
+
+	// 0.1 + 0.2 is exact
+	do(Set, "0.10", "0.1")
+	do(Add, "0.30", "0.2")
+
+	do(Set, "19.99", "19.99")
+	do(Mul, "59.97", "3")
+	do(Mul, "29.98", "0.5") // 29.985 rounds to even
+	do(Set, "0.25", "0.25")
+	do(Mul, "0.13", "0.5", RoundHalfUp) // 0.125
+	do(Set, "0.13", "0.125", RoundCeiling)
+	do(SetScale, "0.1", 1, RoundDown)
+
+	ret, err := store.Do(key, Scale)
+	if err != nil || ret != 1 {
+		t.Errorf("expected scale 1; got %v (%v)", ret, err)
+	}
+
+	if _, err := store.Do(key, Add, "1e5"); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for exponent; got %v", err)
+	}
+	if _, err := store.Do(key, Add, 1.5); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+	if _, err := store.Do(key, Add, "1", RoundingMode("NEAREST")); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for rounding mode; got %v", err)
+	}
+	if _, err := store.Do(key, SetScale, MaxScale+1); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for scale; got %v", err)
+	}
+
+	do(SetScale, "0.100", 3)
+	do(Set, "-123456789012345678901234567890.005", "-123456789012345678901234567890.005")
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Errorf("ToJSON returned unexpected error: %v", err)
+	}
+
+	expectedJSON := `{"value":"-123456789012345678901234567890.005","scale":3}`
+	if string(obj) != expectedJSON {
+		t.Errorf("expected JSON %s; got %s", expectedJSON, obj)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Errorf("FromJSON returned unexpected error: %v", err)
+	}
+	if err := store.FromJSON(newKey, []byte(`{"value":"1.25","scale":1}`)); err == nil {
+		t.Errorf("expected error FromJSON for value with more digits than the scale")
+	}
+
+	key = newKey
+	do(Get, "-123456789012345678901234567890.005")
+}
+
+func TestRescale(t *testing.T) {
+	modes := []RoundingMode{RoundHalfEven, RoundHalfUp, RoundDown, RoundUp, RoundFloor, RoundCeiling}
+
+	tests := []struct {
+		number   string
+		expected [6]string // in the order of the modes
+	}{
+		{"2.5", [6]string{"2", "3", "2", "3", "2", "3"}},
+		{"3.5", [6]string{"4", "4", "3", "4", "3", "4"}},
+		{"-2.5", [6]string{"-2", "-3", "-2", "-3", "-3", "-2"}},
+		{"1.49", [6]string{"1", "1", "1", "2", "1", "2"}},
+		{"-1.51", [6]string{"-2", "-2", "-1", "-2", "-2", "-1"}},
+		{"7", [6]string{"7", "7", "7", "7", "7", "7"}},
+	}
+
+	for _, tt := range tests {
+		n, scale, err := parse(tt.number)
+		if err != nil {
+			t.Fatalf("could not parse %q: %v", tt.number, err)
+		}
+
+		for i, mode := range modes {
+			if got := format(rescale(n, scale, 0, mode), 0); got != tt.expected[i] {
+				t.Errorf("expected %s rounded %s to be %s; got %s", tt.number, mode, tt.expected[i], got)
+			}
+		}
+	}
+}
@@ -0,0 +1,7 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package float implements a kiwi.Value which can store a float64, which is
+// optionally clamped between a minimum and a maximum.
+package float
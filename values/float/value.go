@@ -0,0 +1,268 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package float
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return &Value{min: math.Inf(-1), max: math.Inf(1)}
+	})
+}
+
+// Type of float value.
+const Type kiwi.ValueType = "float"
+
+// Value can store a float64 within bounds, which are infinite by default.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	val float64
+	min float64
+	max float64
+}
+
+// Various errors for float value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p interface{}) error {
+	return fmt.Errorf("%w: %#v not a \"float64\"", ErrInvalidParamType, p)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Get gets the value.
+	//
+	// Returns a float64.
+	Get kiwi.Action = "GET"
+
+	// Set sets the value, clamping it between the bounds.
+	//
+	// Returns the updated value.
+	Set kiwi.Action = "SET"
+
+	// IncrByFloat increments the value by the given float, which can be
+	// negative, clamping it between the bounds.
+	//
+	// Returns the updated value.
+	IncrByFloat kiwi.Action = "INCRBYFLOAT"
+
+	// SetBounds sets the minimum and the maximum of the value, and clamps it
+	// between them. Use math.Inf(-1) for no minimum and math.Inf(1) for no
+	// maximum; other infinite bounds are invalid.
+	//
+	// Returns the updated value.
+	SetBounds kiwi.Action = "SETBOUNDS"
+
+	// Bounds gets the minimum and the maximum of the value.
+	//
+	// Returns a [2]float64.
+	Bounds kiwi.Action = "BOUNDS"
+)
+
+// Type returns v's type, i.e., "float".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Get:         v.get,
+		Set:         v.set,
+		IncrByFloat: v.incrByFloat,
+		SetBounds:   v.setBounds,
+		Bounds:      v.bounds,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Get, Bounds:
+		return true
+	}
+
+	return false
+}
+
+// get implements the GET action.
+func (v *Value) get(params ...interface{}) (interface{}, error) {
+	return v.val, nil
+}
+
+// set implements the SET action.
+func (v *Value) set(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	f, err := finiteParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	v.val = v.clamp(f)
+	return v.val, nil
+}
+
+// incrByFloat implements the INCRBYFLOAT action.
+func (v *Value) incrByFloat(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	delta, err := finiteParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	f := v.val + delta
+	if math.IsInf(f, 0) {
+		return nil, newParamValueErr("increment would produce an infinite value")
+	}
+
+	v.val = v.clamp(f)
+	return v.val, nil
+}
+
+// setBounds implements the SETBOUNDS action.
+func (v *Value) setBounds(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	lo, err := floatParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	hi, err := floatParam(params[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if !validBounds(lo, hi) {
+		return nil, newParamValueErr(fmt.Sprintf("invalid bounds [%v, %v]", lo, hi))
+	}
+
+	v.min, v.max = lo, hi
+	v.val = v.clamp(v.val)
+	return v.val, nil
+}
+
+// bounds implements the BOUNDS action.
+func (v *Value) bounds(params ...interface{}) (interface{}, error) {
+	return [2]float64{v.min, v.max}, nil
+}
+
+// validBounds tells if the value can be clamped between the bounds. The
+// minimum can be -Inf and the maximum +Inf for no bound, but no other bound
+// can be infinite since the value would be clamped to it.
+func validBounds(lo, hi float64) bool {
+	if math.IsNaN(lo) || math.IsNaN(hi) || math.IsInf(lo, 1) || math.IsInf(hi, -1) {
+		return false
+	}
+
+	return lo <= hi
+}
+
+// clamp returns f clamped between the bounds.
+func (v *Value) clamp(f float64) float64 {
+	return math.Max(v.min, math.Min(v.max, f))
+}
+
+// floatParam converts the parameter, which is a float64 or an int, to float64.
+func floatParam(p interface{}) (float64, error) {
+	switch f := p.(type) {
+	case float64:
+		return f, nil
+	case int:
+		return float64(f), nil
+	default:
+		return 0, newParamTypeErr(p)
+	}
+}
+
+// finiteParam is the same as floatParam but the float should be finite.
+func finiteParam(p interface{}) (float64, error) {
+	f, err := floatParam(p)
+	if err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, newParamValueErr(fmt.Sprintf("%v is not finite", f))
+	}
+
+	return f, nil
+}
+
+// valueJSON is the JSON form of the value. Infinite bounds are omitted.
+type valueJSON struct {
+	Value float64  `json:"value"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	vj := valueJSON{Value: v.val}
+	if !math.IsInf(v.min, -1) {
+		vj.Min = &v.min
+	}
+	if !math.IsInf(v.max, 1) {
+		vj.Max = &v.max
+	}
+
+	return json.Marshal(vj)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	lo, hi := math.Inf(-1), math.Inf(1)
+	if vj.Min != nil {
+		lo = *vj.Min
+	}
+	if vj.Max != nil {
+		hi = *vj.Max
+	}
+
+	if !validBounds(lo, hi) {
+		return fmt.Errorf("invalid bounds [%v, %v]", lo, hi)
+	}
+
+	v.min, v.max = lo, hi
+	v.val = v.clamp(vj.Value)
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
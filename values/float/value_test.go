@@ -0,0 +1,105 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package float
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	do := func(action kiwi.Action, expected float64, params ...interface{}) {
+		t.Helper()
+
+		ret, err := store.Do(key, action, params...)
+		if err != nil {
+			t.Fatalf("error while %s: %v", action, err)
+		}
+		if f, ok := ret.(float64); !ok || f != expected {
+			t.Errorf("expected %s to return %v; got %#v", action, expected, ret)
+		}
+	}
+
+	do(Get, 0)
+	do(Set, 2.5, 2.5)
+	do(IncrByFloat, 3, 0.5)
+	do(IncrByFloat, -1, -4)
+
+	// the value is clamped between the bounds
+	do(SetBounds, 0, 0, 10)
+	do(IncrByFloat, 10, 12.5)
+	do(Set, 0, -3)
+
+	ret, err := store.Do(key, Bounds)
+	if err != nil {
+		t.Fatalf("error while getting bounds: %v", err)
+	}
+	if b, ok := ret.([2]float64); !ok || b != [2]float64{0, 10} {
+		t.Errorf("expected bounds [0 10]; got %#v", ret)
+	}
+
+	for _, b := range [][2]float64{{5, 1}, {math.Inf(1), math.Inf(1)}, {math.Inf(-1), math.Inf(-1)}, {0, math.NaN()}} {
+		if _, err := store.Do(key, SetBounds, b[0], b[1]); !errors.Is(err, ErrInvalidParamValue) {
+			t.Errorf("expected ErrInvalidParamValue for bounds %v; got %v", b, err)
+		}
+	}
+	if _, err := store.Do(key, Set, math.NaN()); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for NaN; got %v", err)
+	}
+	if _, err := store.Do(key, Set, "1"); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+	if _, err := store.Do(key, IncrByFloat); !errors.Is(err, ErrInvalidParamLen) {
+		t.Errorf("expected ErrInvalidParamLen; got %v", err)
+	}
+
+	do(SetBounds, 0, math.Inf(-1), 10)
+	do(Set, 7.25, 7.25)
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Errorf("ToJSON returned unexpected error: %v", err)
+	}
+
+	expectedJSON := `{"value":7.25,"max":10}`
+	if string(obj) != expectedJSON {
+		t.Errorf("expected JSON %s; got %s", expectedJSON, obj)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Errorf("FromJSON returned unexpected error: %v", err)
+	}
+
+	key = newKey
+	do(Get, 7.25)
+	do(IncrByFloat, 10, 5)
+
+	// finite minimums round-trip without a maximum
+	do(SetBounds, 10, 5, math.Inf(1))
+
+	obj, err = store.ToJSON(key)
+	if err != nil {
+		t.Errorf("ToJSON returned unexpected error: %v", err)
+	}
+
+	expectedJSON = `{"value":10,"min":5}`
+	if string(obj) != expectedJSON {
+		t.Errorf("expected JSON %s; got %s", expectedJSON, obj)
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package geo implements a kiwi.Value which stores named points on the Earth,
+// given by their longitude and latitude, and searches them by distance.
+//
+// Distances are computed with the haversine formula, treating the Earth as a
+// sphere, so they can be off by up to 0.5%.
+//
+// The points are indexed in two R-trees: one of the longitudes and latitudes
+// for searching within a bounding box, and one of the points on the unit
+// sphere for searching within a radius and for the nearest points. The
+// straight-line distance between the points on the sphere increases with
+// their distance along the surface, so the nearest points in the R-tree are
+// the nearest on the Earth as well.
+package geo
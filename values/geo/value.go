@@ -0,0 +1,611 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sdslabs/kiwi"
+
+	"github.com/tidwall/rtree"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return newValue()
+	})
+}
+
+// Type of geo value.
+const Type kiwi.ValueType = "geo"
+
+// Value can store named points on the Earth.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	members map[string]*member
+
+	// planar indexes the longitudes and latitudes of the members, while
+	// spherical indexes their points on the unit sphere.
+	planar    *rtree.RTree
+	spherical *rtree.RTree
+}
+
+// newValue creates an empty value.
+func newValue() *Value {
+	return &Value{
+		members:   make(map[string]*member),
+		planar:    rtree.New(planarIndex),
+		spherical: rtree.New(sphericalIndex),
+	}
+}
+
+// Position is the location of a point on the Earth, in degrees.
+type Position struct {
+	Lon float64 `json:"lon"`
+	Lat float64 `json:"lat"`
+}
+
+// Result is a member found by a search.
+type Result struct {
+	Name string
+	Position
+
+	// Dist is the distance from the center of the search in its unit. It is
+	// 0 for searches within a bounding box.
+	Dist float64
+}
+
+// Radius searches the members within the radius of a center.
+type Radius struct {
+	Center Position
+	Radius float64
+	Unit   Unit
+}
+
+// Box searches the members within a bounding box. The box crosses the
+// antimeridian if MinLon is greater than MaxLon.
+type Box struct {
+	MinLon, MinLat float64
+	MaxLon, MaxLat float64
+}
+
+// Unit is the unit of a distance.
+type Unit string
+
+// Units of distance.
+const (
+	Meters     Unit = "m"
+	Kilometers Unit = "km"
+	Miles      Unit = "mi"
+	Feet       Unit = "ft"
+)
+
+// meters returns the number of meters in the unit.
+func (u Unit) meters() (float64, bool) {
+	switch u {
+	case Meters:
+		return 1, true
+	case Kilometers:
+		return 1000, true
+	case Miles:
+		return 1609.34, true
+	case Feet:
+		return 0.3048, true
+	}
+
+	return 0, false
+}
+
+// Various errors for geo value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Add adds the member(s), each given by its name (string), longitude and
+	// latitude (float64). A member which exists is moved.
+	//
+	// Returns the number of members added.
+	Add kiwi.Action = "GEOADD"
+
+	// Remove removes the member(s).
+	//
+	// Returns the number of members removed.
+	Remove kiwi.Action = "GEOREM"
+
+	// Pos gets the positions of the member(s).
+	//
+	// Returns a []*Position with nil for the members which do not exist.
+	Pos kiwi.Action = "GEOPOS"
+
+	// Dist gets the distance between two members, in meters or in the unit
+	// (Unit) if given.
+	//
+	// Returns a float64.
+	Dist kiwi.Action = "GEODIST"
+
+	// Search searches the members within a Radius or a Box.
+	//
+	// Returns a []Result, the nearest first for a Radius and sorted by name
+	// for a Box.
+	Search kiwi.Action = "GEOSEARCH"
+
+	// Nearest searches the k (int) members nearest to the position
+	// (Position), with the distances in meters or in the unit (Unit) if
+	// given.
+	//
+	// Returns a []Result, the nearest first.
+	Nearest kiwi.Action = "GEONEAREST"
+)
+
+// Type returns v's type, i.e., "geo".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Add:     v.add,
+		Remove:  v.remove,
+		Pos:     v.pos,
+		Dist:    v.dist,
+		Search:  v.search,
+		Nearest: v.nearest,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Pos, Dist, Search, Nearest:
+		return true
+	}
+
+	return false
+}
+
+// add implements the GEOADD action.
+func (v *Value) add(params ...interface{}) (interface{}, error) {
+	if len(params) < 3 || len(params)%3 != 0 {
+		return nil, newParamLenErr(len(params), len(params)/3*3+3)
+	}
+
+	members := make([]*member, 0, len(params)/3)
+	for i := 0; i < len(params); i += 3 {
+		name, ok := params[i].(string)
+		if !ok {
+			return nil, newParamTypeErr(params[i], name)
+		}
+
+		lon, ok := params[i+1].(float64)
+		if !ok {
+			return nil, newParamTypeErr(params[i+1], lon)
+		}
+
+		lat, ok := params[i+2].(float64)
+		if !ok {
+			return nil, newParamTypeErr(params[i+2], lat)
+		}
+
+		pos := Position{Lon: lon, Lat: lat}
+		if err := pos.validate(); err != nil {
+			return nil, newParamValueErr(err.Error())
+		}
+
+		members = append(members, newMember(name, pos))
+	}
+
+	added := 0
+	for _, m := range members {
+		if v.remove1(m.name) {
+			added--
+		}
+		v.insert(m)
+		added++
+	}
+
+	return added, nil
+}
+
+// remove implements the GEOREM action.
+func (v *Value) remove(params ...interface{}) (interface{}, error) {
+	names, err := nameParams(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := 0
+	for _, name := range names {
+		if v.remove1(name) {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// pos implements the GEOPOS action.
+func (v *Value) pos(params ...interface{}) (interface{}, error) {
+	names, err := nameParams(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]*Position, len(names))
+	for i, name := range names {
+		if m, ok := v.members[name]; ok {
+			pos := m.pos
+			positions[i] = &pos
+		}
+	}
+
+	return positions, nil
+}
+
+// dist implements the GEODIST action.
+func (v *Value) dist(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	names, err := nameParams(params[:2], 2)
+	if err != nil {
+		return nil, err
+	}
+
+	perUnit, err := unitParam(params[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	var pos [2]Position
+	for i, name := range names {
+		m, ok := v.members[name]
+		if !ok {
+			return nil, newParamValueErr(fmt.Sprintf("no member %q", name))
+		}
+		pos[i] = m.pos
+	}
+
+	return haversine(pos[0], pos[1]) / perUnit, nil
+}
+
+// search implements the GEOSEARCH action.
+func (v *Value) search(params ...interface{}) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	switch shape := params[0].(type) {
+	case Radius:
+		return v.searchRadius(shape)
+	case Box:
+		return v.searchBox(shape)
+	default:
+		return nil, newParamTypeErr(params[0], Radius{})
+	}
+}
+
+// searchRadius searches the members within the radius.
+func (v *Value) searchRadius(r Radius) ([]Result, error) {
+	if err := r.Center.validate(); err != nil {
+		return nil, newParamValueErr(err.Error())
+	}
+
+	perUnit, ok := r.Unit.meters()
+	if !ok {
+		return nil, newParamValueErr(fmt.Sprintf("unknown unit %q", r.Unit))
+	}
+
+	if !(r.Radius >= 0) {
+		return nil, newParamValueErr(fmt.Sprintf("radius %v should not be negative", r.Radius))
+	}
+
+	// the members within the radius are within a cube around the center on
+	// the unit sphere, whose half side is the chord of the radius
+	angle := math.Min(r.Radius*perUnit/earthRadius, math.Pi)
+	chord := 2 * math.Sin(angle/2)
+
+	center := r.Center.point()
+	bounds := &rect{min: make([]float64, 3), max: make([]float64, 3)}
+	for i := range center {
+		bounds.min[i], bounds.max[i] = center[i]-chord, center[i]+chord
+	}
+
+	results := []Result{}
+	v.spherical.Search(bounds, func(item rtree.Item) bool {
+		m := item.(*member) //nolint:errcheck
+		if d := haversine(r.Center, m.pos); d <= r.Radius*perUnit {
+			results = append(results, Result{Name: m.name, Position: m.pos, Dist: d / perUnit})
+		}
+		return true
+	})
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Dist != results[j].Dist {
+			return results[i].Dist < results[j].Dist
+		}
+		return results[i].Name < results[j].Name
+	})
+
+	return results, nil
+}
+
+// searchBox searches the members within the box.
+func (v *Value) searchBox(b Box) ([]Result, error) {
+	for _, pos := range []Position{{b.MinLon, b.MinLat}, {b.MaxLon, b.MaxLat}} {
+		if err := pos.validate(); err != nil {
+			return nil, newParamValueErr(err.Error())
+		}
+	}
+
+	if b.MinLat > b.MaxLat {
+		return nil, newParamValueErr(fmt.Sprintf("min latitude %v above max %v", b.MinLat, b.MaxLat))
+	}
+
+	// a box crossing the antimeridian is searched in two halves
+	boxes := []Box{b}
+	if b.MinLon > b.MaxLon {
+		east, west := b, b
+		east.MaxLon, west.MinLon = 180, -180
+		boxes = []Box{east, west}
+	}
+
+	results := []Result{}
+	for _, box := range boxes {
+		bounds := &rect{
+			min: []float64{box.MinLon, box.MinLat},
+			max: []float64{box.MaxLon, box.MaxLat},
+		}
+
+		v.planar.Search(bounds, func(item rtree.Item) bool {
+			m := item.(*member) //nolint:errcheck
+			results = append(results, Result{Name: m.name, Position: m.pos})
+			return true
+		})
+	}
+
+	// a member at ±180° can be found in both halves
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	unique := results[:0]
+	for i := range results {
+		if i == 0 || results[i].Name != results[i-1].Name {
+			unique = append(unique, results[i])
+		}
+	}
+
+	return unique, nil
+}
+
+// nearest implements the GEONEAREST action.
+func (v *Value) nearest(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	center, ok := params[0].(Position)
+	if !ok {
+		return nil, newParamTypeErr(params[0], center)
+	}
+
+	k, ok := params[1].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[1], k)
+	}
+
+	perUnit, err := unitParam(params[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := center.validate(); err != nil {
+		return nil, newParamValueErr(err.Error())
+	}
+	if k < 0 {
+		return nil, newParamValueErr(fmt.Sprintf("k %d should not be negative", k))
+	}
+
+	results := []Result{}
+	if k == 0 {
+		return results, nil
+	}
+
+	p := center.point()
+	v.spherical.KNN(&rect{min: p[:], max: p[:]}, false, func(item rtree.Item, _ float64) bool {
+		m := item.(*member) //nolint:errcheck
+		results = append(results, Result{Name: m.name, Position: m.pos, Dist: haversine(center, m.pos) / perUnit})
+		return len(results) < k
+	})
+
+	return results, nil
+}
+
+// insert adds the member, which should not exist, to v.
+func (v *Value) insert(m *member) {
+	v.members[m.name] = m
+	v.planar.Insert(m)
+	v.spherical.Insert(m)
+}
+
+// remove1 removes the member with the name, returning false if it does not
+// exist.
+func (v *Value) remove1(name string) bool {
+	m, ok := v.members[name]
+	if !ok {
+		return false
+	}
+
+	delete(v.members, name)
+	v.planar.Remove(m)
+	v.spherical.Remove(m)
+	return true
+}
+
+// nameParams returns the parameters as names, requiring at least min.
+func nameParams(params []interface{}, min int) ([]string, error) {
+	if len(params) < min {
+		return nil, newParamLenErr(len(params), min)
+	}
+
+	names := make([]string, len(params))
+	for i, p := range params {
+		name, ok := p.(string)
+		if !ok {
+			return nil, newParamTypeErr(p, name)
+		}
+		names[i] = name
+	}
+
+	return names, nil
+}
+
+// unitParam returns the meters in the optional unit parameter, defaulting to
+// meters.
+func unitParam(params []interface{}) (float64, error) {
+	if len(params) == 0 {
+		return 1, nil
+	}
+
+	unit, ok := params[0].(Unit)
+	if !ok {
+		return 0, newParamTypeErr(params[0], unit)
+	}
+
+	perUnit, ok := unit.meters()
+	if !ok {
+		return 0, newParamValueErr(fmt.Sprintf("unknown unit %q", unit))
+	}
+
+	return perUnit, nil
+}
+
+// earthRadius is the radius of the Earth in meters.
+const earthRadius = 6372797.560856
+
+// validate checks that the position is on the Earth.
+func (p Position) validate() error {
+	if !(p.Lon >= -180 && p.Lon <= 180) {
+		return fmt.Errorf("longitude %v out of range", p.Lon)
+	}
+	if !(p.Lat >= -90 && p.Lat <= 90) {
+		return fmt.Errorf("latitude %v out of range", p.Lat)
+	}
+
+	return nil
+}
+
+// point returns the point of the position on the unit sphere.
+func (p Position) point() [3]float64 {
+	lon, lat := p.Lon*math.Pi/180, p.Lat*math.Pi/180
+	return [3]float64{
+		math.Cos(lat) * math.Cos(lon),
+		math.Cos(lat) * math.Sin(lon),
+		math.Sin(lat),
+	}
+}
+
+// haversine returns the distance between the positions in meters.
+func haversine(p, q Position) float64 {
+	lat1, lat2 := p.Lat*math.Pi/180, q.Lat*math.Pi/180
+	dlat, dlon := lat2-lat1, (q.Lon-p.Lon)*math.Pi/180
+
+	a := math.Pow(math.Sin(dlat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dlon/2), 2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// index tells an R-tree which coordinates of the members it indexes.
+type index int
+
+const (
+	planarIndex index = iota
+	sphericalIndex
+)
+
+// member is a named point, indexed in the R-trees.
+type member struct {
+	name  string
+	pos   Position
+	point [3]float64
+}
+
+// newMember creates a member at the position.
+func newMember(name string, pos Position) *member {
+	return &member{name: name, pos: pos, point: pos.point()}
+}
+
+// Rect implements rtree.Item.
+func (m *member) Rect(ctx interface{}) (min, max []float64) {
+	if ctx == planarIndex {
+		p := []float64{m.pos.Lon, m.pos.Lat}
+		return p, p
+	}
+
+	return m.point[:], m.point[:]
+}
+
+// rect is the bounds of a search in an R-tree.
+type rect struct{ min, max []float64 }
+
+// Rect implements rtree.Item.
+func (r *rect) Rect(ctx interface{}) (min, max []float64) {
+	return r.min, r.max
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	members := make(map[string][2]float64, len(v.members))
+	for name, m := range v.members {
+		members[name] = [2]float64{m.pos.Lon, m.pos.Lat}
+	}
+
+	return json.Marshal(members)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var members map[string][2]float64
+	if err := json.Unmarshal(rawmessage, &members); err != nil {
+		return err
+	}
+
+	nv := newValue()
+	for name, lonlat := range members {
+		pos := Position{Lon: lonlat[0], Lat: lonlat[1]}
+		if err := pos.validate(); err != nil {
+			return fmt.Errorf("member %q: %v", name, err)
+		}
+
+		nv.insert(newMember(name, pos))
+	}
+
+	*v = *nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
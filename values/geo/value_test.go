@@ -0,0 +1,133 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package geo
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// names returns the names of the results.
+func names(results []Result) []string {
+	n := make([]string, len(results))
+	for i := range results {
+		n[i] = results[i].Name
+	}
+	return n
+}
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	v, err := store.Do(key, Add,
+		"Palermo", 13.361389, 38.115556,
+		"Catania", 15.087269, 37.502669,
+		"Rome", 12.496366, 41.902782,
+		"Fiji", 179.9, -17.7,
+		"Samoa", -172.1, -13.8,
+	)
+	if err != nil || v != 5 {
+		t.Errorf("expected GEOADD to add 5 members; got %v (%v)", v, err)
+	}
+
+	// moving a member does not add it
+	if v, err := store.Do(key, Add, "Rome", 12.4964, 41.9028, "Paris", 2.3522, 48.8566); err != nil || v != 1 {
+		t.Errorf("expected GEOADD to add 1 member; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Pos, "Rome", "missing")
+	if err != nil || !reflect.DeepEqual(v, []*Position{{Lon: 12.4964, Lat: 41.9028}, nil}) {
+		t.Errorf("expected GEOPOS to return Rome and nil; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Dist, "Palermo", "Catania", Kilometers)
+	if err != nil || math.Abs(v.(float64)-166.2742) > 0.001 {
+		t.Errorf("expected distance 166.2742 km; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Search, Radius{Center: Position{Lon: 15, Lat: 37}, Radius: 200, Unit: Kilometers})
+	if err != nil || !reflect.DeepEqual(names(v.([]Result)), []string{"Catania", "Palermo"}) {
+		t.Errorf("expected Catania and Palermo within 200 km; got %v (%v)", v, err)
+	}
+	if d := v.([]Result)[0].Dist; math.Abs(d-56.4413) > 0.001 {
+		t.Errorf("expected Catania at 56.4413 km; got %v", d)
+	}
+
+	v, err = store.Do(key, Search, Box{MinLon: 10, MinLat: 38, MaxLon: 20, MaxLat: 45})
+	if err != nil || !reflect.DeepEqual(names(v.([]Result)), []string{"Palermo", "Rome"}) {
+		t.Errorf("expected Palermo and Rome within the box; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Search, Box{MinLon: 170, MinLat: -20, MaxLon: -170, MaxLat: -10})
+	if err != nil || !reflect.DeepEqual(names(v.([]Result)), []string{"Fiji", "Samoa"}) {
+		t.Errorf("expected Fiji and Samoa within the box across the antimeridian; got %v (%v)", v, err)
+	}
+
+	// Samoa is nearer to Fiji across the antimeridian
+	v, err = store.Do(key, Nearest, Position{Lon: 179, Lat: -15}, 2)
+	if err != nil || !reflect.DeepEqual(names(v.([]Result)), []string{"Fiji", "Samoa"}) {
+		t.Errorf("expected Fiji and Samoa nearest; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Nearest, Position{Lon: 13, Lat: 40}, 3, Kilometers)
+	if err != nil || !reflect.DeepEqual(names(v.([]Result)), []string{"Palermo", "Rome", "Catania"}) {
+		t.Errorf("expected Palermo, Rome and Catania nearest; got %v (%v)", v, err)
+	}
+
+	if v, err := store.Do(key, Remove, "Paris", "missing"); err != nil || v != 1 {
+		t.Errorf("expected GEOREM to remove 1 member; got %v (%v)", v, err)
+	}
+	if v, _ := store.Do(key, Nearest, Position{Lon: 2, Lat: 48}, 1); names(v.([]Result))[0] == "Paris" {
+		t.Errorf("expected Paris to be removed from the index")
+	}
+
+	if _, err := store.Do(key, Add, "Nowhere", 0.0, 91.0); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue; got %v", err)
+	}
+	if _, err := store.Do(key, Add, "Nowhere", 0, 0); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+	if _, err := store.Do(key, Add, "Nowhere", 0.0); !errors.Is(err, ErrInvalidParamLen) {
+		t.Errorf("expected ErrInvalidParamLen; got %v", err)
+	}
+	if _, err := store.Do(key, Dist, "Rome", "missing"); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue; got %v", err)
+	}
+	if _, err := store.Do(key, Dist, "Rome", "Fiji", Unit("yd")); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue; got %v", err)
+	}
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Fatalf("ToJSON returned unexpected error: %v", err)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Fatalf("FromJSON returned unexpected error: %v", err)
+	}
+
+	v1, _ := store.Do(key, Nearest, Position{Lon: 0, Lat: 0}, 10)
+	v2, err := store.Do(newKey, Nearest, Position{Lon: 0, Lat: 0}, 10)
+	if err != nil || !reflect.DeepEqual(v1, v2) {
+		t.Errorf("expected members %v FromJSON; got %v (%v)", v1, v2, err)
+	}
+
+	if err := store.FromJSON(newKey, []byte(`{"a":[181,0]}`)); err == nil {
+		t.Errorf("expected error FromJSON for invalid longitude")
+	}
+}
@@ -96,6 +96,16 @@ func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
 	}
 }
 
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Has, Len, Get, Keys, Map:
+		return true
+	}
+
+	return false
+}
+
 // ToJSON returns the raw byte array of s's data
 func (v *Value) ToJSON() (json.RawMessage, error) {
 	c, err := json.Marshal(v)
@@ -234,4 +244,4 @@ func (v *Value) copymap(params ...interface{}) (interface{}, error) {
 }
 
 // Interface guard.
-var _ kiwi.Value = (*Value)(nil)
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
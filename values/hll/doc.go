@@ -0,0 +1,15 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package hll implements a kiwi.Value which estimates the number of distinct
+// elements added to it using HyperLogLog, in at most 16 KiB of memory.
+//
+// The standard error of the estimate is about 0.81%. Small sketches keep only
+// the registers which are set, in a sparse representation, and switch to the
+// dense array of all the registers as they grow.
+//
+// Merge and CountKeys combine the sketches of several keys of a store, estimating
+// the number of distinct elements added to any of them. The keys which do not
+// exist are taken as empty sketches.
+package hll
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package hll
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// ErrNotHLL is returned when a key does not have a HyperLogLog sketch.
+var ErrNotHLL = fmt.Errorf("value is not an hll")
+
+// Merge merges the sketches of the keys into the sketch of the dest key, which
+// is added to the store if it does not exist. Afterwards, dest estimates the
+// number of distinct elements added to any of the keys or to itself.
+//
+// The keys which do not exist are taken as empty sketches. All the keys are
+// checked before dest is added, so dest is not added if any of them has
+// another type.
+func Merge(store *kiwi.Store, dest string, keys ...string) error {
+	regs, err := union(store, keys)
+	if err != nil {
+		return err
+	}
+
+	if err := guard(store, dest); err != nil {
+		if !errors.Is(err, kiwi.ErrKeyNotExist) {
+			return err
+		}
+		if err := store.AddKey(dest, Type); err != nil && !errors.Is(err, kiwi.ErrKeyExists) {
+			return err
+		}
+	}
+
+	_, err = store.Do(dest, MergeRegisters, regs)
+	return err
+}
+
+// CountKeys estimates the number of distinct elements added to any of the keys,
+// without changing them. The keys which do not exist are taken as empty
+// sketches.
+func CountKeys(store *kiwi.Store, keys ...string) (int64, error) {
+	regs, err := union(store, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	c := make([]int, maxRank+1)
+	for _, rank := range regs {
+		c[rank]++
+	}
+
+	return estimate(c), nil
+}
+
+// union returns the maximum of each register across the sketches of the keys,
+// skipping the keys which do not exist.
+func union(store *kiwi.Store, keys []string) ([]byte, error) {
+	regs := make([]byte, registers)
+	for _, key := range keys {
+		if err := guard(store, key); err != nil {
+			if errors.Is(err, kiwi.ErrKeyNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		v, err := store.Do(key, Registers)
+		if err != nil {
+			if errors.Is(err, kiwi.ErrKeyNotExist) {
+				// deleted meanwhile
+				continue
+			}
+			return nil, err
+		}
+
+		for i, rank := range v.([]byte) { //nolint:errcheck
+			if rank > regs[i] {
+				regs[i] = rank
+			}
+		}
+	}
+
+	return regs, nil
+}
+
+// guard returns an error if the key does not have a sketch.
+func guard(store *kiwi.Store, key string) error {
+	typ, err := store.GetValueType(key)
+	if err != nil {
+		return err
+	}
+
+	if typ != Type {
+		return fmt.Errorf("%w: %q has %q", ErrNotHLL, key, typ)
+	}
+
+	return nil
+}
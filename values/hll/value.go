@@ -0,0 +1,389 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package hll
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value { return new(Value) })
+}
+
+// Type of hll value.
+const Type kiwi.ValueType = "hll"
+
+const (
+	// precision is the number of bits of the hash which select a register.
+	precision = 14
+
+	// registers is the number of registers of a sketch.
+	registers = 1 << precision
+
+	// maxRank is the maximum value of a register.
+	maxRank = 64 - precision + 1
+
+	// sparseMax is the number of registers set after which the sparse
+	// representation uses more memory than it is worth.
+	sparseMax = registers / 8
+)
+
+// Value can store a HyperLogLog sketch.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	// sparse has the registers which are set as index<<8 | value, sorted by
+	// index, until dense is allocated.
+	sparse []uint32
+	dense  []uint8
+}
+
+// Various errors for hll value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is invalid.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Add adds the element(s) to the sketch.
+	//
+	// Returns true if the estimate may have changed.
+	Add kiwi.Action = "ADD"
+
+	// Count estimates the number of distinct elements added.
+	//
+	// Returns an int64.
+	Count kiwi.Action = "COUNT"
+
+	// Registers gets the registers of the sketch, one byte each.
+	//
+	// Returns a []byte.
+	Registers kiwi.Action = "REGISTERS"
+
+	// MergeRegisters merges the registers, like the ones returned by
+	// Registers, into the sketch, so that it counts the elements of both.
+	// The sketch is not changed if any of the registers is out of range.
+	//
+	// Returns true if the estimate may have changed.
+	MergeRegisters kiwi.Action = "MERGEREGISTERS"
+)
+
+// Type returns v's type, i.e., "hll".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Add:            v.add,
+		Count:          v.count,
+		Registers:      v.getRegisters,
+		MergeRegisters: v.mergeRegisters,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Count, Registers:
+		return true
+	}
+
+	return false
+}
+
+// add implements the ADD action.
+func (v *Value) add(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	changed := false
+	for _, p := range params {
+		elem, ok := p.(string)
+		if !ok {
+			return nil, newParamTypeErr(p, elem)
+		}
+
+		idx, rank := hash(elem)
+		if v.set(idx, rank) {
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// count implements the COUNT action.
+func (v *Value) count(params ...interface{}) (interface{}, error) {
+	return estimate(v.histogram()), nil
+}
+
+// getRegisters implements the REGISTERS action.
+func (v *Value) getRegisters(params ...interface{}) (interface{}, error) {
+	return v.registers(), nil
+}
+
+// mergeRegisters implements the MERGEREGISTERS action.
+func (v *Value) mergeRegisters(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	regs, ok := params[0].([]byte)
+	if !ok || len(regs) != registers {
+		return nil, newParamTypeErr(params[0], regs)
+	}
+
+	for idx, rank := range regs {
+		if rank > maxRank {
+			return nil, newParamValueErr(fmt.Sprintf("register %d has value %d; maximum is %d", idx, rank, maxRank))
+		}
+	}
+
+	changed := false
+	for idx, rank := range regs {
+		if rank != 0 && v.set(uint32(idx), rank) {
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// hash returns the register and the rank for the element.
+func hash(elem string) (idx uint32, rank uint8) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(elem))
+	x := mix(h.Sum64())
+
+	idx = uint32(x >> (64 - precision))
+	rank = uint8(bits.LeadingZeros64(x<<precision)) + 1
+	if rank > maxRank {
+		rank = maxRank
+	}
+
+	return idx, rank
+}
+
+// mix is the finalizer of MurmurHash3, which spreads the bits of the FNV hash
+// across all the bits as HyperLogLog requires.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// set raises the register to the rank. It returns false if the register was
+// at least the rank already.
+func (v *Value) set(idx uint32, rank uint8) bool {
+	if v.dense != nil {
+		if rank <= v.dense[idx] {
+			return false
+		}
+
+		v.dense[idx] = rank
+		return true
+	}
+
+	i := sort.Search(len(v.sparse), func(i int) bool { return v.sparse[i]>>8 >= idx })
+	if i < len(v.sparse) && v.sparse[i]>>8 == idx {
+		if rank <= uint8(v.sparse[i]) {
+			return false
+		}
+
+		v.sparse[i] = idx<<8 | uint32(rank)
+		return true
+	}
+
+	v.sparse = append(v.sparse, 0)
+	copy(v.sparse[i+1:], v.sparse[i:])
+	v.sparse[i] = idx<<8 | uint32(rank)
+
+	if len(v.sparse) > sparseMax {
+		v.dense = v.registers()
+		v.sparse = nil
+	}
+
+	return true
+}
+
+// registers returns a copy of all the registers.
+func (v *Value) registers() []byte {
+	regs := make([]byte, registers)
+	if v.dense != nil {
+		copy(regs, v.dense)
+		return regs
+	}
+
+	for _, r := range v.sparse {
+		regs[r>>8] = uint8(r)
+	}
+
+	return regs
+}
+
+// histogram counts the registers having each value.
+func (v *Value) histogram() []int {
+	c := make([]int, maxRank+1)
+	if v.dense != nil {
+		for _, rank := range v.dense {
+			c[rank]++
+		}
+		return c
+	}
+
+	c[0] = registers - len(v.sparse)
+	for _, r := range v.sparse {
+		c[uint8(r)]++
+	}
+
+	return c
+}
+
+// estimate estimates the cardinality from the histogram of the registers
+// using the improved estimator by Otmar Ertl, which does not need any
+// empirical bias correction.
+func estimate(c []int) int64 {
+	const m = float64(registers)
+	q := maxRank - 1
+
+	z := m * tau(1-float64(c[q+1])/m)
+	for k := q; k >= 1; k-- {
+		z = 0.5 * (z + float64(c[k]))
+	}
+	z += m * sigma(float64(c[0])/m)
+
+	alpha := 1 / (2 * math.Ln2)
+	return int64(math.Round(alpha * m * m / z))
+}
+
+// sigma is the series used to correct the estimate for the empty registers.
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if z == prev {
+			return z
+		}
+	}
+}
+
+// tau is the series used to correct the estimate for the full registers.
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == prev {
+			return z / 3
+		}
+	}
+}
+
+// valueJSON is the JSON form of the value, having either the sparse registers
+// as a map of index to value or the dense registers encoded in base64.
+type valueJSON struct {
+	Precision int              `json:"precision"`
+	Sparse    map[uint32]uint8 `json:"sparse,omitempty"`
+	Dense     []byte           `json:"dense,omitempty"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	vj := valueJSON{Precision: precision, Dense: v.dense}
+	if v.dense == nil {
+		vj.Sparse = make(map[uint32]uint8, len(v.sparse))
+		for _, r := range v.sparse {
+			vj.Sparse[r>>8] = uint8(r)
+		}
+	}
+
+	return json.Marshal(vj)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	if vj.Precision != precision {
+		return fmt.Errorf("precision %d is not supported; only %d is", vj.Precision, precision)
+	}
+
+	var nv Value
+	switch {
+	case vj.Dense != nil:
+		if len(vj.Dense) != registers {
+			return fmt.Errorf("dense registers have length %d; expected %d", len(vj.Dense), registers)
+		}
+		nv.dense = vj.Dense
+
+	default:
+		for idx, rank := range vj.Sparse {
+			if idx >= registers {
+				return fmt.Errorf("register %d out of range", idx)
+			}
+			if rank != 0 {
+				nv.set(idx, rank)
+			}
+		}
+	}
+
+	for _, rank := range nv.registers() {
+		if rank > maxRank {
+			return fmt.Errorf("register value %d out of range", rank)
+		}
+	}
+
+	*v = nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
@@ -0,0 +1,201 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package hll
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// add adds the elements "<prefix><i>" for i in [from, to) to the key.
+func add(t *testing.T, store *kiwi.Store, key, prefix string, from, to int) {
+	t.Helper()
+
+	const batch = 1000
+
+	params := make([]interface{}, 0, batch)
+	for i := from; i < to; i++ {
+		params = append(params, prefix+strconv.Itoa(i))
+		if len(params) == batch || i == to-1 {
+			if _, err := store.Do(key, Add, params...); err != nil {
+				t.Fatalf("error while adding: %v", err)
+			}
+			params = params[:0]
+		}
+	}
+}
+
+// count returns the estimate for the key.
+func count(t *testing.T, store *kiwi.Store, key string) int64 {
+	t.Helper()
+
+	v, err := store.Do(key, Count)
+	if err != nil {
+		t.Fatalf("error while counting: %v", err)
+	}
+
+	return v.(int64)
+}
+
+// checkError checks that the estimate is within the relative error.
+func checkError(t *testing.T, estimate int64, actual int, maxErr float64) {
+	t.Helper()
+
+	if actual == 0 {
+		if estimate != 0 {
+			t.Errorf("expected estimate 0; got %d", estimate)
+		}
+		return
+	}
+
+	if e := math.Abs(float64(estimate)-float64(actual)) / float64(actual); e > maxErr {
+		t.Errorf("estimate %d for %d is off by %.2f%%; expected at most %.2f%%", estimate, actual, e*100, maxErr*100)
+	}
+}
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	// the standard error is 0.81%, so the estimates are well within 3%
+	added := 0
+	for _, n := range []int{0, 1, 10, 100, 1000, 2000, 5000, 10000, 50000, 100000, 1000000} {
+		add(t, store, key, "elem:", added, n)
+		added = n
+
+		checkError(t, count(t, store, key), n, 0.03)
+	}
+
+	// adding the same elements again changes nothing
+	if v, err := store.Do(key, Add, "elem:1", "elem:2"); err != nil || v != false {
+		t.Errorf("expected ADD of existing elements to return false; got %v (%v)", v, err)
+	}
+
+	if _, err := store.Do(key, Add); !errors.Is(err, ErrInvalidParamLen) {
+		t.Errorf("expected ErrInvalidParamLen; got %v", err)
+	}
+	if _, err := store.Do(key, Add, 1); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+
+	// registers out of range are rejected without changing the sketch
+	before := count(t, store, key)
+	regs := make([]byte, registers)
+	regs[0], regs[1] = maxRank, maxRank+1
+	if _, err := store.Do(key, MergeRegisters, regs); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue; got %v", err)
+	}
+	if after := count(t, store, key); after != before {
+		t.Errorf("expected count %d after invalid merge; got %d", before, after)
+	}
+}
+
+func TestValue_JSON(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{
+		"sparse":  Type,
+		"dense":   Type,
+		"sparse2": Type,
+		"dense2":  Type,
+	})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	add(t, store, "sparse", "a", 0, 100)
+	add(t, store, "dense", "a", 0, 10000)
+
+	for _, key := range []string{"sparse", "dense"} {
+		obj, err := store.ToJSON(key)
+		if err != nil {
+			t.Fatalf("ToJSON returned unexpected error: %v", err)
+		}
+
+		if err := store.FromJSON(key+"2", obj); err != nil {
+			t.Fatalf("FromJSON returned unexpected error: %v", err)
+		}
+
+		if c, c2 := count(t, store, key), count(t, store, key+"2"); c != c2 {
+			t.Errorf("expected %s estimate %d FromJSON; got %d", key, c, c2)
+		}
+	}
+
+	for _, data := range []string{
+		`{"precision":12,"sparse":{"1":1}}`,
+		`{"precision":14,"sparse":{"16384":1}}`,
+		`{"precision":14,"sparse":{"1":60}}`,
+		`{"precision":14,"dense":"AAAA"}`,
+	} {
+		if err := store.FromJSON("sparse2", []byte(data)); err == nil {
+			t.Errorf("expected error FromJSON for %s", data)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{
+		"a": Type,
+		"b": Type,
+		"s": str.Type,
+	})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	// 30000 distinct elements with 10000 in both
+	add(t, store, "a", "e", 0, 20000)
+	add(t, store, "b", "e", 10000, 30000)
+
+	n, err := CountKeys(store, "a", "b")
+	if err != nil {
+		t.Fatalf("error while counting: %v", err)
+	}
+	checkError(t, n, 30000, 0.03)
+
+	if err := Merge(store, "dest", "a", "b"); err != nil {
+		t.Fatalf("error while merging: %v", err)
+	}
+	checkError(t, count(t, store, "dest"), 30000, 0.03)
+
+	// the sources are not changed
+	checkError(t, count(t, store, "a"), 20000, 0.03)
+
+	// merging into a source keeps its own elements
+	if err := Merge(store, "a", "b"); err != nil {
+		t.Fatalf("error while merging: %v", err)
+	}
+	if c, d := count(t, store, "a"), count(t, store, "dest"); c != d {
+		t.Errorf("expected merged estimate %d; got %d", d, c)
+	}
+
+	if err := Merge(store, "dest", "s"); !errors.Is(err, ErrNotHLL) {
+		t.Errorf("expected ErrNotHLL; got %v", err)
+	}
+	if err := Merge(store, "new", "a", "s"); !errors.Is(err, ErrNotHLL) {
+		t.Errorf("expected ErrNotHLL; got %v", err)
+	}
+	if store.KeyExists("new") {
+		t.Errorf("expected dest to not be added when a source is invalid")
+	}
+
+	// missing keys are empty sketches
+	if n, err := CountKeys(store, "b", "missing"); err != nil || n != count(t, store, "b") {
+		t.Errorf("expected CountKeys with missing key to count b; got %d, %v", n, err)
+	}
+	if err := Merge(store, "new", "missing"); err != nil {
+		t.Fatalf("error while merging missing key: %v", err)
+	}
+	if c := count(t, store, "new"); c != 0 {
+		t.Errorf("expected empty merged sketch; got %d", c)
+	}
+}
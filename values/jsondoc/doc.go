@@ -0,0 +1,17 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package jsondoc implements a kiwi.Value which stores a JSON document and
+// queries and modifies the values at paths within it.
+//
+// Values are read with the path syntax of gjson
+// (https://github.com/tidwall/gjson), which supports wildcards, queries and
+// modifiers. Values are modified at simple paths: the keys of objects and
+// the indexes of arrays separated by ".", where "." and the special
+// characters of gjson can be escaped with "\". The index -1 appends to an
+// array. The empty path is the whole document.
+//
+// Numbers keep their precision, but the keys of objects are sorted when the
+// document is modified.
+package jsondoc
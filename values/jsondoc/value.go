@@ -0,0 +1,617 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package jsondoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sdslabs/kiwi"
+
+	"github.com/tidwall/gjson"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return &Value{raw: []byte("null")}
+	})
+}
+
+// Type of jsondoc value.
+const Type kiwi.ValueType = "jsondoc"
+
+// Value can store a JSON document.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	// doc is the decoded document, with json.Number for numbers, which is
+	// modified, while raw is its encoding which is queried.
+	doc interface{}
+	raw []byte
+}
+
+// Various errors for jsondoc value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+	ErrNoPath            = fmt.Errorf("path does not exist")
+	ErrWrongType         = fmt.Errorf("wrong type of value at path")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Get gets the value at the gjson path (string).
+	//
+	// Returns a json.RawMessage, or nil if nothing matches the path.
+	Get kiwi.Action = "GET"
+
+	// Set sets the value at the path (string), creating the objects on the
+	// way if they do not exist. The value is raw JSON if it is a
+	// json.RawMessage, or else it is encoded to JSON.
+	//
+	// Returns nil.
+	Set kiwi.Action = "SET"
+
+	// Del deletes the value at the path (string).
+	//
+	// Returns the number of values deleted, 0 or 1.
+	Del kiwi.Action = "DEL"
+
+	// ArrAppend appends the value(s) to the array at the path (string). The
+	// values are raw JSON or encoded like the value of Set.
+	//
+	// Returns the length of the array as an int.
+	ArrAppend kiwi.Action = "ARRAPPEND"
+
+	// NumIncrBy increments the number at the path (string) by the increment
+	// (int or float64). Integers stay integers when incremented by an int.
+	//
+	// Returns the number as a json.Number.
+	NumIncrBy kiwi.Action = "NUMINCRBY"
+
+	// TypeOf gets the type of the value at the gjson path (string): "object",
+	// "array", "string", "number", "boolean" or "null".
+	//
+	// Returns a string, or nil if nothing matches the path.
+	TypeOf kiwi.Action = "TYPE"
+
+	// Merge merges the patch into the value at the path (string) as in
+	// RFC 7386, i.e., the null values of the patch delete the keys of the
+	// value. The patch is raw JSON or encoded like the value of Set.
+	//
+	// Returns nil.
+	Merge kiwi.Action = "MERGE"
+)
+
+// Type returns v's type, i.e., "jsondoc".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Get:       v.get,
+		Set:       v.set,
+		Del:       v.del,
+		ArrAppend: v.arrAppend,
+		NumIncrBy: v.numIncrBy,
+		TypeOf:    v.typeOf,
+		Merge:     v.merge,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Get, TypeOf:
+		return true
+	}
+
+	return false
+}
+
+// get implements the GET action.
+func (v *Value) get(params ...interface{}) (interface{}, error) {
+	res, err := v.query(params)
+	if err != nil || !res.Exists() {
+		return nil, err
+	}
+
+	return json.RawMessage(res.Raw), nil
+}
+
+// typeOf implements the TYPE action.
+func (v *Value) typeOf(params ...interface{}) (interface{}, error) {
+	res, err := v.query(params)
+	if err != nil || !res.Exists() {
+		return nil, err
+	}
+
+	switch res.Type {
+	case gjson.Null:
+		return "null", nil
+	case gjson.False, gjson.True:
+		return "boolean", nil
+	case gjson.Number:
+		return "number", nil
+	case gjson.String:
+		return "string", nil
+	}
+
+	if strings.HasPrefix(res.Raw, "[") {
+		return "array", nil
+	}
+
+	return "object", nil
+}
+
+// query queries the document with the gjson path in the parameters.
+func (v *Value) query(params []interface{}) (gjson.Result, error) {
+	if len(params) != 1 {
+		return gjson.Result{}, newParamLenErr(len(params), 1)
+	}
+
+	path, ok := params[0].(string)
+	if !ok {
+		return gjson.Result{}, newParamTypeErr(params[0], path)
+	}
+
+	if path == "" {
+		return gjson.ParseBytes(v.raw), nil
+	}
+
+	return gjson.GetBytes(v.raw, path), nil
+}
+
+// set implements the SET action.
+func (v *Value) set(params ...interface{}) (interface{}, error) {
+	if len(params) != 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	val, err := toNode(params[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, v.update(params[0], func(interface{}, bool) (interface{}, error) {
+		return val, nil
+	})
+}
+
+// del implements the DEL action.
+func (v *Value) del(params ...interface{}) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	segs, err := pathParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segs) == 0 {
+		v.doc, v.raw = nil, []byte("null")
+		return 1, nil
+	}
+
+	doc, ok := deletePath(v.doc, segs)
+	if !ok {
+		return 0, nil
+	}
+
+	v.doc = doc
+	return 1, v.encode()
+}
+
+// arrAppend implements the ARRAPPEND action.
+func (v *Value) arrAppend(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	vals := make([]interface{}, len(params)-1)
+	for i, p := range params[1:] {
+		val, err := toNode(p)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+
+	var length int
+	err := v.update(params[0], func(old interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			return nil, ErrNoPath
+		}
+
+		arr, ok := old.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: not an array", ErrWrongType)
+		}
+
+		arr = append(arr, vals...)
+		length = len(arr)
+		return arr, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return length, nil
+}
+
+// numIncrBy implements the NUMINCRBY action.
+func (v *Value) numIncrBy(params ...interface{}) (interface{}, error) {
+	if len(params) != 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	switch params[1].(type) {
+	case int, float64:
+	default:
+		return nil, newParamTypeErr(params[1], 0)
+	}
+
+	var res json.Number
+	err := v.update(params[0], func(old interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			return nil, ErrNoPath
+		}
+
+		n, ok := old.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%w: not a number", ErrWrongType)
+		}
+
+		var err error
+		res, err = add(n, params[1])
+		return res, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// add adds the increment to the number.
+func add(n json.Number, incr interface{}) (json.Number, error) {
+	if i, ok := incr.(int); ok {
+		if a, err := n.Int64(); err == nil {
+			sum := a + int64(i)
+			if (i > 0 && sum < a) || (i < 0 && sum > a) {
+				return "", newParamValueErr(fmt.Sprintf("%s + %d overflows", n, i))
+			}
+			return json.Number(strconv.FormatInt(sum, 10)), nil
+		}
+		incr = float64(i)
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrWrongType, err)
+	}
+
+	sum := f + incr.(float64) //nolint:errcheck
+	if math.IsInf(sum, 0) || math.IsNaN(sum) {
+		return "", newParamValueErr(fmt.Sprintf("%s + %v is not a number", n, incr))
+	}
+
+	return json.Number(strconv.FormatFloat(sum, 'g', -1, 64)), nil
+}
+
+// merge implements the MERGE action.
+func (v *Value) merge(params ...interface{}) (interface{}, error) {
+	if len(params) != 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	patch, err := toNode(params[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if patch == nil {
+		_, err := v.del(params[0])
+		return nil, err
+	}
+
+	return nil, v.update(params[0], func(old interface{}, _ bool) (interface{}, error) {
+		return mergePatch(old, patch), nil
+	})
+}
+
+// mergePatch merges the patch into the target as in RFC 7386.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+
+	for key, val := range p {
+		if val == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], val)
+		}
+	}
+
+	return t
+}
+
+// update replaces the value at the path parameter with the one returned by
+// fn, which is called with the value at the path and if it exists. The
+// document is not changed if it returns an error.
+func (v *Value) update(path interface{}, fn updateFunc) error {
+	segs, err := pathParam(path)
+	if err != nil {
+		return err
+	}
+
+	doc, err := updatePath(v.doc, v.doc != nil, segs, fn)
+	if err != nil {
+		return err
+	}
+
+	v.doc = doc
+	return v.encode()
+}
+
+// updateFunc returns the new value in place of the old one.
+type updateFunc func(old interface{}, exists bool) (interface{}, error)
+
+// updatePath returns the node with the value at the path updated by fn. The
+// nodes are changed only once fn succeeds.
+func updatePath(n interface{}, exists bool, segs []string, fn updateFunc) (interface{}, error) {
+	if len(segs) == 0 {
+		return fn(n, exists)
+	}
+
+	seg, rest := segs[0], segs[1:]
+	switch c := n.(type) {
+	case nil:
+		// objects on the way are created
+		child, err := updatePath(nil, false, rest, fn)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{seg: child}, nil
+
+	case map[string]interface{}:
+		old, ok := c[seg]
+		child, err := updatePath(old, ok, rest, fn)
+		if err != nil {
+			return nil, err
+		}
+		c[seg] = child
+		return c, nil
+
+	case []interface{}:
+		i, err := arrayIndex(seg, len(c))
+		if err != nil {
+			return nil, err
+		}
+
+		if i == len(c) {
+			child, err := updatePath(nil, false, rest, fn)
+			if err != nil {
+				return nil, err
+			}
+			return append(c, child), nil
+		}
+
+		child, err := updatePath(c[i], true, rest, fn)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = child
+		return c, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %q is not in an object or array", ErrWrongType, seg)
+	}
+}
+
+// deletePath returns the node with the value at the path, which is not
+// empty, deleted, and false if it does not exist.
+func deletePath(n interface{}, segs []string) (interface{}, bool) {
+	seg, rest := segs[0], segs[1:]
+	switch c := n.(type) {
+	case map[string]interface{}:
+		old, ok := c[seg]
+		if !ok {
+			return c, false
+		}
+
+		if len(rest) == 0 {
+			delete(c, seg)
+			return c, true
+		}
+
+		child, ok := deletePath(old, rest)
+		if ok {
+			c[seg] = child
+		}
+		return c, ok
+
+	case []interface{}:
+		i, err := arrayIndex(seg, len(c))
+		if err != nil || i == len(c) {
+			return c, false
+		}
+
+		if len(rest) == 0 {
+			return append(c[:i], c[i+1:]...), true
+		}
+
+		child, ok := deletePath(c[i], rest)
+		if ok {
+			c[i] = child
+		}
+		return c, ok
+	}
+
+	return n, false
+}
+
+// arrayIndex parses the segment of a path as an index of an array of the
+// length, where -1 or the length appends to the array.
+func arrayIndex(seg string, length int) (int, error) {
+	i, err := strconv.Atoi(seg)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrWrongType, seg)
+	}
+
+	if i == -1 {
+		return length, nil
+	}
+
+	if i < 0 || i > length {
+		return 0, fmt.Errorf("%w: index %d out of range", ErrNoPath, i)
+	}
+
+	return i, nil
+}
+
+// pathParam parses the path parameter into its segments.
+func pathParam(p interface{}) ([]string, error) {
+	path, ok := p.(string)
+	if !ok {
+		return nil, newParamTypeErr(p, path)
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	var segs []string
+	var seg strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			i++
+			if i == len(path) {
+				return nil, newParamValueErr(fmt.Sprintf("path %q ends with an escape", path))
+			}
+			seg.WriteByte(path[i])
+		case '.':
+			segs = append(segs, seg.String())
+			seg.Reset()
+		case '*', '?', '#', '|', '@':
+			return nil, newParamValueErr(fmt.Sprintf("path %q should not have %q unescaped", path, c))
+		default:
+			seg.WriteByte(c)
+		}
+	}
+	segs = append(segs, seg.String())
+
+	for _, s := range segs {
+		if s == "" {
+			return nil, newParamValueErr(fmt.Sprintf("path %q has an empty key", path))
+		}
+	}
+
+	return segs, nil
+}
+
+// toNode converts the parameter to a node of the document.
+func toNode(p interface{}) (interface{}, error) {
+	raw, ok := p.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(p); err != nil {
+			return nil, newParamValueErr(err.Error())
+		}
+	}
+
+	n, err := decode(raw)
+	if err != nil {
+		return nil, newParamValueErr(err.Error())
+	}
+
+	return n, nil
+}
+
+// decode decodes the JSON into a node, with json.Number for numbers.
+func decode(raw []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var n interface{}
+	if err := dec.Decode(&n); err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid character after JSON value")
+	}
+
+	return n, nil
+}
+
+// encode updates the encoding of the document.
+func (v *Value) encode() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v.doc); err != nil {
+		return err
+	}
+
+	v.raw = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return nil
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	return append(json.RawMessage(nil), v.raw...), nil
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	doc, err := decode(rawmessage)
+	if err != nil {
+		return err
+	}
+
+	nv := Value{doc: doc}
+	if err := nv.encode(); err != nil {
+		return err
+	}
+
+	*v = nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
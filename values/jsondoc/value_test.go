@@ -0,0 +1,158 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package jsondoc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	// expect checks the raw JSON of the value at the path
+	expect := func(path, raw string) {
+		t.Helper()
+
+		v, err := store.Do(key, Get, path)
+		if err != nil {
+			t.Fatalf("error while getting %q: %v", path, err)
+		}
+		if got, _ := v.(json.RawMessage); string(got) != raw {
+			t.Errorf("expected %s at %q; got %s", raw, path, got)
+		}
+	}
+
+	expect("", "null")
+
+	doc := `{"name":"kiwi","version":1.5,"tags":["kv","go"],"limits":{"conns":18446744073709551615,"max":9223372036854775807}}`
+	if _, err := store.Do(key, Set, "", json.RawMessage(doc)); err != nil {
+		t.Fatalf("error while setting document: %v", err)
+	}
+
+	// numbers keep their precision
+	expect("limits.conns", "18446744073709551615")
+	expect("tags.#", "2")
+	expect("tags.1", `"go"`)
+	expect(`tags.#(=="kv")`, `"kv"`)
+	expect("missing", "")
+
+	if _, err := store.Do(key, Set, "owner.name", "sds <labs>"); err != nil {
+		t.Fatalf("error while setting: %v", err)
+	}
+	expect("owner", `{"name":"sds <labs>"}`)
+
+	if _, err := store.Do(key, Set, "tags.-1", "db"); err != nil {
+		t.Fatalf("error while setting: %v", err)
+	}
+	if v, err := store.Do(key, ArrAppend, "tags", "x", json.RawMessage(`{"y":null}`)); err != nil || v != 5 {
+		t.Errorf("expected ARRAPPEND to return 5; got %v (%v)", v, err)
+	}
+	expect("tags", `["kv","go","db","x",{"y":null}]`)
+
+	if v, err := store.Do(key, Del, "tags.1"); err != nil || v != 1 {
+		t.Errorf("expected DEL to return 1; got %v (%v)", v, err)
+	}
+	if v, err := store.Do(key, Del, "tags.9"); err != nil || v != 0 {
+		t.Errorf("expected DEL to return 0; got %v (%v)", v, err)
+	}
+	expect("tags", `["kv","db","x",{"y":null}]`)
+
+	if v, err := store.Do(key, NumIncrBy, "version", 2); err != nil || v != json.Number("3.5") {
+		t.Errorf("expected NUMINCRBY to return 3.5; got %v (%v)", v, err)
+	}
+	if v, err := store.Do(key, NumIncrBy, "owner.id", 1); !errors.Is(err, ErrNoPath) {
+		t.Errorf("expected ErrNoPath; got %v (%v)", v, err)
+	}
+	if _, err := store.Do(key, Set, "owner.id", 41); err != nil {
+		t.Fatalf("error while setting: %v", err)
+	}
+	if v, err := store.Do(key, NumIncrBy, "owner.id", 1); err != nil || v != json.Number("42") {
+		t.Errorf("expected NUMINCRBY to return 42; got %v (%v)", v, err)
+	}
+	if _, err := store.Do(key, NumIncrBy, "limits.max", 1); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for overflow; got %v", err)
+	}
+
+	for path, typ := range map[string]interface{}{
+		"":          "object",
+		"tags":      "array",
+		"name":      "string",
+		"version":   "number",
+		"tags.3.y":  "null",
+		"tags.#":    "number",
+		"missing":   nil,
+		"owner.id":  "number",
+		"owner":     "object",
+		"tags.0":    "string",
+		"limits.*":  "number",
+		"owner.nam": nil,
+	} {
+		if v, err := store.Do(key, TypeOf, path); err != nil || v != typ {
+			t.Errorf("expected TYPE %v for %q; got %v (%v)", typ, path, v, err)
+		}
+	}
+
+	patch := json.RawMessage(`{"name":"kiwi2","owner":{"id":null,"team":"core"},"limits":null}`)
+	if _, err := store.Do(key, Merge, "", patch); err != nil {
+		t.Fatalf("error while merging: %v", err)
+	}
+	expect("name", `"kiwi2"`)
+	expect("owner", `{"name":"sds <labs>","team":"core"}`)
+	expect("limits", "")
+
+	// setting through a scalar fails without changing the document
+	if _, err := store.Do(key, Set, "name.first", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("expected ErrWrongType; got %v", err)
+	}
+	if _, err := store.Do(key, ArrAppend, "name", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("expected ErrWrongType; got %v", err)
+	}
+	if _, err := store.Do(key, Set, "tags.#", "a"); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for query in path; got %v", err)
+	}
+	if _, err := store.Do(key, Set, "a..b", "a"); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for empty key; got %v", err)
+	}
+	if _, err := store.Do(key, Set, "a", json.RawMessage(`{`)); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for invalid JSON; got %v", err)
+	}
+
+	// escaped keys
+	if _, err := store.Do(key, Set, `dotted\.key`, true); err != nil {
+		t.Fatalf("error while setting: %v", err)
+	}
+	expect(`dotted\.key`, "true")
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Fatalf("ToJSON returned unexpected error: %v", err)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Fatalf("FromJSON returned unexpected error: %v", err)
+	}
+
+	newObj, err := store.ToJSON(newKey)
+	if err != nil || string(newObj) != string(obj) {
+		t.Errorf("expected %s FromJSON; got %s (%v)", obj, newObj, err)
+	}
+
+	if err := store.FromJSON(newKey, []byte(`{} {}`)); err == nil {
+		t.Errorf("expected error FromJSON for trailing data")
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package lease implements a kiwi.Value which is a lock held by an owner for
+// a time to live, after which it expires unless renewed.
+//
+// Each time the lease is acquired afresh it issues a fencing token, greater
+// than the ones issued before. Holders can pass the token along with their
+// writes to other services, which can reject the writes with a token smaller
+// than the greatest they have seen, i.e., from holders whose lease expired.
+//
+// Leases expire by the clock of the store, hence they are not replicated
+// identically by the raft package. The clock of a lease can be replaced with
+// the SetClock action, e.g., to test the expiry without waiting.
+package lease
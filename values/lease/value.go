@@ -0,0 +1,320 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package lease
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return newValue()
+	})
+}
+
+// Type of lease value.
+const Type kiwi.ValueType = "lease"
+
+// Value can store a lease.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	owner   string
+	expires time.Time
+
+	// token is the last fencing token issued.
+	token uint64
+
+	now Clock
+}
+
+// Clock returns the current time, which the leases expire by.
+type Clock func() time.Time
+
+// newValue creates a lease which is not held.
+func newValue() *Value {
+	return &Value{now: time.Now}
+}
+
+// Holder is the holder of a lease.
+type Holder struct {
+	Owner string
+
+	// Token is the fencing token issued when the lease was acquired.
+	Token   uint64
+	Expires time.Time
+}
+
+// Various errors for lease value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+	ErrHeld              = fmt.Errorf("lease is held")
+	ErrNotHolder         = fmt.Errorf("not the holder of lease")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Acquire acquires the lease for the owner (string) with the time to live
+	// (time.Duration). If the owner holds the lease, it is renewed. It fails
+	// with ErrHeld if another owner holds the lease.
+	//
+	// Returns a Holder, with a new fencing token unless renewed.
+	Acquire kiwi.Action = "ACQUIRE"
+
+	// Renew renews the lease for the owner (string) with the time to live
+	// (time.Duration). It fails with ErrNotHolder if the owner does not hold
+	// the lease, which includes the lease having expired.
+	//
+	// Returns a Holder.
+	Renew kiwi.Action = "RENEW"
+
+	// Release releases the lease if held by the owner (string).
+	//
+	// Returns true if the owner held the lease.
+	Release kiwi.Action = "RELEASE"
+
+	// GetHolder gets the holder of the lease.
+	//
+	// Returns a *Holder, or nil if the lease is not held.
+	GetHolder kiwi.Action = "HOLDER"
+
+	// SetClock sets the Clock (or a func() time.Time) of the lease, or
+	// time.Now if nil. The clock is not part of the JSON, so it is kept
+	// while loading the value from JSON but not when the key is added again.
+	//
+	// Returns nil.
+	SetClock kiwi.Action = "SETCLOCK"
+)
+
+// Type returns v's type, i.e., "lease".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Acquire:   v.acquire,
+		Renew:     v.renew,
+		Release:   v.release,
+		GetHolder: v.getHolder,
+		SetClock:  v.setClock,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case GetHolder:
+		return true
+	}
+
+	return false
+}
+
+// setClock implements the SETCLOCK action.
+func (v *Value) setClock(params ...interface{}) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	var clock Clock
+	switch c := params[0].(type) {
+	case Clock:
+		clock = c
+	case func() time.Time:
+		clock = c
+	case nil:
+	default:
+		return nil, newParamTypeErr(params[0], clock)
+	}
+
+	if clock == nil {
+		clock = time.Now
+	}
+
+	v.now = clock
+	return nil, nil
+}
+
+// acquire implements the ACQUIRE action.
+func (v *Value) acquire(params ...interface{}) (interface{}, error) {
+	owner, ttl, err := ownerTTL(params)
+	if err != nil {
+		return nil, err
+	}
+
+	v.expire()
+
+	switch v.owner {
+	case owner:
+	case "":
+		v.owner = owner
+		v.token++
+	default:
+		return nil, fmt.Errorf("%w: by %q", ErrHeld, v.owner)
+	}
+
+	v.expires = v.now().Add(ttl)
+	return v.holder(), nil
+}
+
+// renew implements the RENEW action.
+func (v *Value) renew(params ...interface{}) (interface{}, error) {
+	owner, ttl, err := ownerTTL(params)
+	if err != nil {
+		return nil, err
+	}
+
+	v.expire()
+
+	if v.owner != owner {
+		return nil, fmt.Errorf("%w: %q", ErrNotHolder, owner)
+	}
+
+	v.expires = v.now().Add(ttl)
+	return v.holder(), nil
+}
+
+// release implements the RELEASE action.
+func (v *Value) release(params ...interface{}) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	owner, ok := params[0].(string)
+	if !ok {
+		return nil, newParamTypeErr(params[0], owner)
+	}
+
+	v.expire()
+
+	if owner == "" || v.owner != owner {
+		return false, nil
+	}
+
+	v.owner, v.expires = "", time.Time{}
+	return true, nil
+}
+
+// getHolder implements the HOLDER action.
+func (v *Value) getHolder(params ...interface{}) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, newParamLenErr(len(params), 0)
+	}
+
+	// the lease is not released here since the action is read-only
+	if !v.held() {
+		return (*Holder)(nil), nil
+	}
+
+	h := v.holder()
+	return &h, nil
+}
+
+// held tells if the lease is held and has not expired.
+func (v *Value) held() bool {
+	return v.owner != "" && v.now().Before(v.expires)
+}
+
+// expire releases the lease if it has expired.
+func (v *Value) expire() {
+	if v.owner != "" && !v.held() {
+		v.owner, v.expires = "", time.Time{}
+	}
+}
+
+// holder returns the current holder.
+func (v *Value) holder() Holder {
+	return Holder{Owner: v.owner, Token: v.token, Expires: v.expires}
+}
+
+// ownerTTL parses the owner and the time to live.
+func ownerTTL(params []interface{}) (string, time.Duration, error) {
+	if len(params) != 2 {
+		return "", 0, newParamLenErr(len(params), 2)
+	}
+
+	owner, ok := params[0].(string)
+	if !ok {
+		return "", 0, newParamTypeErr(params[0], owner)
+	}
+
+	ttl, ok := params[1].(time.Duration)
+	if !ok {
+		return "", 0, newParamTypeErr(params[1], ttl)
+	}
+
+	if owner == "" {
+		return "", 0, newParamValueErr("owner should not be empty")
+	}
+
+	if ttl <= 0 {
+		return "", 0, newParamValueErr(fmt.Sprintf("ttl %v should be positive", ttl))
+	}
+
+	return owner, ttl, nil
+}
+
+// valueJSON is the JSON form of the value.
+type valueJSON struct {
+	Owner   string     `json:"owner,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Token   uint64     `json:"token"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	vj := valueJSON{Owner: v.owner, Token: v.token}
+	if v.owner != "" {
+		expires := v.expires
+		vj.Expires = &expires
+	}
+
+	return json.Marshal(vj)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	nv := Value{owner: vj.Owner, token: vj.Token, now: v.now}
+	if vj.Owner != "" {
+		if vj.Expires == nil {
+			return fmt.Errorf("lease held by %q without expiry", vj.Owner)
+		}
+		nv.expires = *vj.Expires
+	}
+
+	*v = nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package lease
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	v, err := store.Do(key, GetHolder)
+	if err != nil || v.(*Holder) != nil {
+		t.Errorf("expected HOLDER to be nil; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Acquire, "a", time.Hour)
+	if err != nil || v.(Holder).Owner != "a" || v.(Holder).Token != 1 {
+		t.Errorf("expected ACQUIRE to grant token 1 to a; got %+v (%v)", v, err)
+	}
+
+	if _, err := store.Do(key, Acquire, "b", time.Hour); !errors.Is(err, ErrHeld) {
+		t.Errorf("expected ErrHeld; got %v", err)
+	}
+	if _, err := store.Do(key, Renew, "b", time.Hour); !errors.Is(err, ErrNotHolder) {
+		t.Errorf("expected ErrNotHolder; got %v", err)
+	}
+	if _, err := store.Do(key, Acquire, "", time.Hour); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for empty owner; got %v", err)
+	}
+	if _, err := store.Do(key, Acquire, "a", 10); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType for ttl; got %v", err)
+	}
+
+	v, err = store.Do(key, Release, "b")
+	if err != nil || v != false {
+		t.Errorf("expected RELEASE by b to return false; got %v (%v)", v, err)
+	}
+	v, err = store.Do(key, Release, "a")
+	if err != nil || v != true {
+		t.Errorf("expected RELEASE by a to return true; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Acquire, "b", time.Hour)
+	if err != nil || v.(Holder).Token != 2 {
+		t.Errorf("expected ACQUIRE to grant token 2; got %+v (%v)", v, err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	v := newValue()
+	if _, err := v.setClock(func() time.Time { return now }); err != nil {
+		t.Fatalf("error while setting clock: %v", err)
+	}
+
+	h, err := v.acquire("a", time.Minute)
+	if expected := (Holder{"a", 1, now.Add(time.Minute)}); err != nil || h != expected {
+		t.Errorf("expected ACQUIRE %+v; got %+v (%v)", expected, h, err)
+	}
+
+	// acquiring again renews with the same token
+	now = now.Add(30 * time.Second)
+	h, err = v.acquire("a", time.Minute)
+	if expected := (Holder{"a", 1, now.Add(time.Minute)}); err != nil || h != expected {
+		t.Errorf("expected ACQUIRE %+v; got %+v (%v)", expected, h, err)
+	}
+
+	now = now.Add(59 * time.Second)
+	if _, err := v.renew("a", time.Minute); err != nil {
+		t.Errorf("error while renewing: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if h, err := v.getHolder(); err != nil || h.(*Holder) != nil {
+		t.Errorf("expected lease to expire; got %+v (%v)", h, err)
+	}
+	if v.owner != "a" {
+		t.Errorf("expected HOLDER not to release the lease; got owner %q", v.owner)
+	}
+	if _, err := v.renew("a", time.Minute); !errors.Is(err, ErrNotHolder) {
+		t.Errorf("expected ErrNotHolder after expiry; got %v", err)
+	}
+
+	h, err = v.acquire("b", time.Minute)
+	if err != nil || h.(Holder).Token != 2 {
+		t.Errorf("expected ACQUIRE to grant token 2 after expiry; got %+v (%v)", h, err)
+	}
+
+	raw, err := v.ToJSON()
+	if err != nil {
+		t.Fatalf("error while converting to JSON: %v", err)
+	}
+
+	nv := newValue()
+	nv.now = v.now
+	if err := nv.FromJSON(raw); err != nil {
+		t.Fatalf("error while converting from JSON: %v", err)
+	}
+
+	expected, _ := v.getHolder()
+	got, err := nv.getHolder()
+	if err != nil {
+		t.Fatalf("error while getting holder: %v", err)
+	}
+
+	// times are compared with Equal as JSON does not keep the location
+	e, g := expected.(*Holder), got.(*Holder)
+	if g == nil || g.Owner != e.Owner || g.Token != e.Token || !g.Expires.Equal(e.Expires) {
+		t.Errorf("expected HOLDER %+v after JSON; got %+v", e, g)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package list
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// ErrNotList is returned by the blocking pops for a key which is not a list.
+var ErrNotList = fmt.Errorf("value is not a list")
+
+// BLPop pops the first element of the first non-empty list of the keys. If
+// all of them are empty or do not exist, it waits until an element is
+// appended to any of them.
+//
+// The waiters are served in the order they started waiting. It returns
+// kiwi.ErrTimeout if the timeout, if not 0, expires, or the error of the
+// context if it is done before.
+//
+// Returns the key and the element popped.
+func BLPop(ctx context.Context, store *kiwi.Store, timeout time.Duration, keys ...string) (string, string, error) {
+	return blockingPop(ctx, store, timeout, keys, func(key string) (string, error) {
+		v, err := store.Do(key, Remove, 0)
+		if err != nil {
+			return "", err
+		}
+
+		return v.(string), nil //nolint:errcheck
+	})
+}
+
+// BRPop is the same as BLPop but pops the last element of the list.
+//
+// Returns the key and the element popped.
+func BRPop(ctx context.Context, store *kiwi.Store, timeout time.Duration, keys ...string) (string, string, error) {
+	return blockingPop(ctx, store, timeout, keys, func(key string) (string, error) {
+		v, err := store.Do(key, Pop, 1)
+		if err != nil {
+			return "", err
+		}
+
+		return v.([]string)[0], nil //nolint:errcheck
+	})
+}
+
+// blockingPop blocks until pop pops an element from one of the keys.
+func blockingPop(ctx context.Context, store *kiwi.Store, timeout time.Duration, keys []string,
+	pop func(key string) (string, error)) (string, string, error) {
+	if len(keys) == 0 {
+		return "", "", newParamLenErr(0, 1)
+	}
+
+	var elem string
+	key, err := store.Block(ctx, timeout, keys, []kiwi.Action{Append}, func(key string) (bool, error) {
+		if err := guard(store, key); err != nil {
+			if errors.Is(err, kiwi.ErrKeyNotExist) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		var err error
+		elem, err = pop(key)
+		if errors.Is(err, ErrInvalidIndex) || errors.Is(err, kiwi.ErrKeyNotExist) {
+			// the list is empty or has been deleted
+			return false, nil
+		}
+
+		return err == nil, err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, elem, nil
+}
+
+// guard returns an error if the key does not have a list.
+func guard(store *kiwi.Store, key string) error {
+	typ, err := store.GetValueType(key)
+	if err != nil {
+		return err
+	}
+
+	if typ != Type {
+		return fmt.Errorf("%w: %q has %q", ErrNotList, key, typ)
+	}
+
+	return nil
+}
@@ -6,13 +6,16 @@ package list
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/str"
 )
 
 func TestList_Append(t *testing.T) {
@@ -574,6 +577,66 @@ func TestList_JSON(t *testing.T) {
 	}
 }
 
+func TestBlockingPop(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{"a": Type, "b": Type, "s": str.Type})
+	if err != nil {
+		t.Fatalf("couldn't create store: %v", err)
+	}
+
+	if _, err := store.Do("b", Append, "x", "y"); err != nil {
+		t.Fatalf("couldn't append: %v", err)
+	}
+
+	// the first non-empty list is popped immediately
+	key, elem, err := BLPop(context.Background(), store, 0, "a", "b")
+	if err != nil || key != "b" || elem != "x" {
+		t.Errorf("expected BLPop to pop x from b; got %q from %q (%v)", elem, key, err)
+	}
+	key, elem, err = BRPop(context.Background(), store, 0, "a", "b")
+	if err != nil || key != "b" || elem != "y" {
+		t.Errorf("expected BRPop to pop y from b; got %q from %q (%v)", elem, key, err)
+	}
+
+	type popped struct {
+		key, elem string
+		err       error
+	}
+	res := make(chan popped, 1)
+	go func() {
+		key, elem, err := BRPop(context.Background(), store, 0, "missing", "a")
+		res <- popped{key, elem, err}
+	}()
+
+	// the waiter is woken when an element is appended
+	time.Sleep(10 * time.Millisecond)
+	if _, err := store.Do("a", Append, "z"); err != nil {
+		t.Fatalf("couldn't append: %v", err)
+	}
+
+	select {
+	case p := <-res:
+		if p.err != nil || p.key != "a" || p.elem != "z" {
+			t.Errorf("expected BRPop to pop z from a; got %q from %q (%v)", p.elem, p.key, p.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("BRPop was not woken by APPEND")
+	}
+
+	if _, _, err := BLPop(context.Background(), store, 10*time.Millisecond, "a"); !errors.Is(err, kiwi.ErrTimeout) {
+		t.Errorf("expected kiwi.ErrTimeout; got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := BLPop(ctx, store, 0, "a"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled; got %v", err)
+	}
+
+	if _, _, err := BLPop(context.Background(), store, 0, "a", "s"); !errors.Is(err, ErrNotList) {
+		t.Errorf("expected ErrNotList; got %v", err)
+	}
+}
+
 // testKey to test the value.
 const testKey = "testList"
 
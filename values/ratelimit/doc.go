@@ -0,0 +1,20 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package ratelimit implements a kiwi.Value which limits the rate of
+// requests, deciding atomically whether each of them is allowed.
+//
+// The limiter is configured with an algorithm, which is stored with its
+// state:
+//
+//   - TokenBucket allows bursts of up to limit requests, refilling the
+//     bucket at the rate of limit tokens per period.
+//   - SlidingWindow logs the requests allowed, and allows at most limit
+//     requests in any window of the period.
+//
+// The requests are timed by the clock of the store, hence limiters are not
+// replicated identically by the raft package. The clock of a limiter can be
+// replaced with the SetClock action, e.g., to test the limits without
+// waiting.
+package ratelimit
@@ -0,0 +1,441 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return newValue()
+	})
+}
+
+// Type of ratelimit value.
+const Type kiwi.ValueType = "ratelimit"
+
+// Value can store a rate limiter.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	config Config
+
+	// tokens are the tokens in the bucket when last refilled.
+	tokens float64
+	last   time.Time
+
+	// log are the requests allowed in the window, the oldest first.
+	log []hit
+
+	now Clock
+}
+
+// Clock returns the current time, which the requests are timed by.
+type Clock func() time.Time
+
+// newValue creates a limiter which is not configured.
+func newValue() *Value {
+	return &Value{now: time.Now}
+}
+
+// Algorithm is the algorithm used to limit the rate.
+type Algorithm string
+
+// Algorithms.
+const (
+	TokenBucket   Algorithm = "token-bucket"
+	SlidingWindow Algorithm = "sliding-window"
+)
+
+// Config is the configuration of a limiter, allowing limit requests every
+// period.
+type Config struct {
+	Algorithm Algorithm
+	Limit     int
+	Period    time.Duration
+}
+
+// Result is the result of a request.
+type Result struct {
+	Allowed bool
+
+	// Remaining is the number of requests which would be allowed now.
+	Remaining int
+
+	// RetryAfter is the time after which the request would be allowed, or 0
+	// if it has been allowed.
+	RetryAfter time.Duration
+}
+
+// hit is a number of requests allowed at a time.
+type hit struct {
+	at time.Time
+	n  int
+}
+
+// Various errors for ratelimit value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+	ErrNotConfigured     = fmt.Errorf("limiter is not configured")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Configure configures the limiter with the Algorithm, the limit (int)
+	// and the period (time.Duration). The state is kept if the algorithm
+	// does not change, otherwise the limiter starts afresh.
+	//
+	// Returns nil.
+	Configure kiwi.Action = "CONFIGURE"
+
+	// Allow decides whether n (int) requests, 1 if not given, are allowed,
+	// counting them if they are.
+	//
+	// Returns a Result.
+	Allow kiwi.Action = "ALLOW"
+
+	// Reset forgets the requests allowed.
+	//
+	// Returns nil.
+	Reset kiwi.Action = "RESET"
+
+	// GetConfig gets the configuration of the limiter.
+	//
+	// Returns a Config.
+	GetConfig kiwi.Action = "CONFIG"
+
+	// SetClock sets the Clock (or a func() time.Time) of the limiter, or
+	// time.Now if nil. The clock is not part of the JSON, so it is kept
+	// while loading the value from JSON but not when the key is added again.
+	//
+	// Returns nil.
+	SetClock kiwi.Action = "SETCLOCK"
+)
+
+// Type returns v's type, i.e., "ratelimit".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Configure: v.configure,
+		Allow:     v.allow,
+		Reset:     v.reset,
+		GetConfig: v.getConfig,
+		SetClock:  v.setClock,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case GetConfig:
+		return true
+	}
+
+	return false
+}
+
+// setClock implements the SETCLOCK action.
+func (v *Value) setClock(params ...interface{}) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	var clock Clock
+	switch c := params[0].(type) {
+	case Clock:
+		clock = c
+	case func() time.Time:
+		clock = c
+	case nil:
+	default:
+		return nil, newParamTypeErr(params[0], clock)
+	}
+
+	if clock == nil {
+		clock = time.Now
+	}
+
+	v.now = clock
+	return nil, nil
+}
+
+// configure implements the CONFIGURE action.
+func (v *Value) configure(params ...interface{}) (interface{}, error) {
+	if len(params) != 3 {
+		return nil, newParamLenErr(len(params), 3)
+	}
+
+	algo, ok := params[0].(Algorithm)
+	if !ok {
+		return nil, newParamTypeErr(params[0], algo)
+	}
+
+	limit, ok := params[1].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[1], limit)
+	}
+
+	period, ok := params[2].(time.Duration)
+	if !ok {
+		return nil, newParamTypeErr(params[2], period)
+	}
+
+	config := Config{Algorithm: algo, Limit: limit, Period: period}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	if algo == v.config.Algorithm {
+		v.refill()
+		v.tokens = math.Min(v.tokens, float64(limit))
+		v.config = config
+	} else {
+		v.config = config
+		v.clear()
+	}
+
+	return nil, nil
+}
+
+// allow implements the ALLOW action.
+func (v *Value) allow(params ...interface{}) (interface{}, error) {
+	if len(params) > 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	n := 1
+	if len(params) == 1 {
+		var ok bool
+		if n, ok = params[0].(int); !ok {
+			return nil, newParamTypeErr(params[0], n)
+		}
+	}
+
+	if v.config.Algorithm == "" {
+		return nil, ErrNotConfigured
+	}
+
+	if n < 1 || n > v.config.Limit {
+		return nil, newParamValueErr(fmt.Sprintf("requests %d should be between 1 and limit %d", n, v.config.Limit))
+	}
+
+	if v.config.Algorithm == TokenBucket {
+		return v.takeTokens(n), nil
+	}
+
+	return v.logHits(n), nil
+}
+
+// takeTokens takes n tokens from the bucket if there are enough.
+func (v *Value) takeTokens(n int) Result {
+	v.refill()
+
+	if v.tokens >= float64(n) {
+		v.tokens -= float64(n)
+		return Result{Allowed: true, Remaining: int(v.tokens)}
+	}
+
+	// time to refill the missing tokens, rounded up
+	missing := (float64(n) - v.tokens) * float64(v.config.Period) / float64(v.config.Limit)
+	return Result{Remaining: int(v.tokens), RetryAfter: time.Duration(math.Ceil(missing))}
+}
+
+// refill adds the tokens for the time since the bucket was last refilled.
+func (v *Value) refill() {
+	now := v.now()
+	if elapsed := now.Sub(v.last); elapsed > 0 && v.config.Period > 0 {
+		v.tokens += float64(elapsed) * float64(v.config.Limit) / float64(v.config.Period)
+		v.tokens = math.Min(v.tokens, float64(v.config.Limit))
+	}
+
+	// the clock going back does not take tokens
+	if now.After(v.last) {
+		v.last = now
+	}
+}
+
+// logHits logs n requests if there is space for them in the window.
+func (v *Value) logHits(n int) Result {
+	now := v.now()
+
+	i := 0
+	for i < len(v.log) && !v.log[i].at.Add(v.config.Period).After(now) {
+		i++
+	}
+	v.log = append(v.log[:0], v.log[i:]...)
+
+	count := 0
+	for _, h := range v.log {
+		count += h.n
+	}
+
+	if count+n <= v.config.Limit {
+		if l := len(v.log); l > 0 && v.log[l-1].at.Equal(now) {
+			v.log[l-1].n += n
+		} else {
+			v.log = append(v.log, hit{at: now, n: n})
+		}
+		return Result{Allowed: true, Remaining: v.config.Limit - count - n}
+	}
+
+	// wait for the oldest requests to leave the window until there is space
+	res := Result{Remaining: v.config.Limit - count}
+	for _, h := range v.log {
+		count -= h.n
+		if count+n <= v.config.Limit {
+			res.RetryAfter = h.at.Add(v.config.Period).Sub(now)
+			break
+		}
+	}
+
+	return res
+}
+
+// reset implements the RESET action.
+func (v *Value) reset(params ...interface{}) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, newParamLenErr(len(params), 0)
+	}
+
+	v.clear()
+	return nil, nil
+}
+
+// clear fills the bucket and empties the log.
+func (v *Value) clear() {
+	v.tokens = float64(v.config.Limit)
+	v.last = v.now()
+	v.log = nil
+}
+
+// getConfig implements the CONFIG action.
+func (v *Value) getConfig(params ...interface{}) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, newParamLenErr(len(params), 0)
+	}
+
+	return v.config, nil
+}
+
+// validate checks that c is a valid configuration.
+func (c Config) validate() error {
+	switch c.Algorithm {
+	case TokenBucket, SlidingWindow:
+	default:
+		return newParamValueErr(fmt.Sprintf("unknown algorithm %q", c.Algorithm))
+	}
+
+	if c.Limit < 1 {
+		return newParamValueErr(fmt.Sprintf("limit %d should be positive", c.Limit))
+	}
+
+	if c.Period <= 0 {
+		return newParamValueErr(fmt.Sprintf("period %v should be positive", c.Period))
+	}
+
+	return nil
+}
+
+// hitJSON is the JSON form of a hit.
+type hitJSON struct {
+	At time.Time `json:"at"`
+	N  int       `json:"n"`
+}
+
+// valueJSON is the JSON form of the value.
+type valueJSON struct {
+	Algorithm Algorithm `json:"algorithm,omitempty"`
+	Limit     int       `json:"limit,omitempty"`
+	Period    string    `json:"period,omitempty"`
+	Tokens    float64   `json:"tokens,omitempty"`
+	Last      time.Time `json:"last"`
+	Log       []hitJSON `json:"log,omitempty"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	vj := valueJSON{
+		Algorithm: v.config.Algorithm,
+		Limit:     v.config.Limit,
+		Tokens:    v.tokens,
+		Last:      v.last,
+	}
+
+	if v.config.Period > 0 {
+		vj.Period = v.config.Period.String()
+	}
+
+	for _, h := range v.log {
+		vj.Log = append(vj.Log, hitJSON{At: h.at, N: h.n})
+	}
+
+	return json.Marshal(vj)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	nv := Value{
+		tokens: vj.Tokens,
+		last:   vj.Last,
+		now:    v.now,
+	}
+
+	if vj.Algorithm != "" {
+		period, err := time.ParseDuration(vj.Period)
+		if err != nil {
+			return err
+		}
+
+		nv.config = Config{Algorithm: vj.Algorithm, Limit: vj.Limit, Period: period}
+		if err := nv.config.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, hj := range vj.Log {
+		if hj.N < 1 {
+			return fmt.Errorf("invalid number of requests %d in log", hj.N)
+		}
+		nv.log = append(nv.log, hit{at: hj.At, n: hj.N})
+	}
+
+	*v = nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
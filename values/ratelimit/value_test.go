@@ -0,0 +1,163 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// newTestValue creates a limiter with a fake clock.
+func newTestValue(t *testing.T, algo Algorithm, limit int, period time.Duration) (*Value, *time.Time) {
+	now := time.Unix(1600000000, 0)
+
+	v := newValue()
+	if _, err := v.setClock(func() time.Time { return now }); err != nil {
+		t.Fatalf("error while setting clock: %v", err)
+	}
+
+	if _, err := v.configure(algo, limit, period); err != nil {
+		t.Fatalf("error while configuring: %v", err)
+	}
+
+	return v, &now
+}
+
+// allow requests n, failing the test if the result is not expected.
+func allow(t *testing.T, v *Value, n int, expected Result) {
+	t.Helper()
+
+	res, err := v.allow(n)
+	if err != nil {
+		t.Fatalf("error while allowing: %v", err)
+	}
+
+	if res != expected {
+		t.Errorf("expected ALLOW %d to return %+v; got %+v", n, expected, res)
+	}
+}
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	if _, err := store.Do(key, Allow); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("expected ErrNotConfigured; got %v", err)
+	}
+
+	if _, err := store.Do(key, Configure, Algorithm("leaky"), 1, time.Second); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for unknown algorithm; got %v", err)
+	}
+	if _, err := store.Do(key, Configure, TokenBucket, 0, time.Second); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for zero limit; got %v", err)
+	}
+	if _, err := store.Do(key, Configure, TokenBucket, 10, 1); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType for period; got %v", err)
+	}
+
+	if _, err := store.Do(key, Configure, SlidingWindow, 10, time.Hour); err != nil {
+		t.Fatalf("error while configuring: %v", err)
+	}
+
+	v, err := store.Do(key, GetConfig)
+	if expected := (Config{SlidingWindow, 10, time.Hour}); err != nil || v != expected {
+		t.Errorf("expected CONFIG %+v; got %+v (%v)", expected, v, err)
+	}
+
+	v, err = store.Do(key, Allow)
+	if expected := (Result{Allowed: true, Remaining: 9}); err != nil || v != expected {
+		t.Errorf("expected ALLOW %+v; got %+v (%v)", expected, v, err)
+	}
+
+	if _, err := store.Do(key, Allow, 11); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for more than limit; got %v", err)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	v, now := newTestValue(t, TokenBucket, 10, 10*time.Second)
+
+	allow(t, v, 8, Result{Allowed: true, Remaining: 2})
+	allow(t, v, 3, Result{Remaining: 2, RetryAfter: time.Second})
+
+	// a token every second
+	*now = now.Add(1500 * time.Millisecond)
+	allow(t, v, 3, Result{Allowed: true, Remaining: 0})
+	allow(t, v, 1, Result{RetryAfter: 500 * time.Millisecond})
+
+	// the bucket does not overflow
+	*now = now.Add(time.Hour)
+	allow(t, v, 1, Result{Allowed: true, Remaining: 9})
+
+	// lowering the limit keeps the tokens within it
+	if _, err := v.configure(TokenBucket, 5, 10*time.Second); err != nil {
+		t.Fatalf("error while configuring: %v", err)
+	}
+	allow(t, v, 1, Result{Allowed: true, Remaining: 4})
+
+	if _, err := v.reset(); err != nil {
+		t.Fatalf("error while resetting: %v", err)
+	}
+	allow(t, v, 5, Result{Allowed: true, Remaining: 0})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	v, now := newTestValue(t, SlidingWindow, 5, time.Minute)
+
+	allow(t, v, 2, Result{Allowed: true, Remaining: 3})
+	*now = now.Add(20 * time.Second)
+	allow(t, v, 2, Result{Allowed: true, Remaining: 1})
+	*now = now.Add(20 * time.Second)
+	allow(t, v, 1, Result{Allowed: true, Remaining: 0})
+
+	// the first requests leave the window after 20 seconds, the next after 40
+	allow(t, v, 2, Result{RetryAfter: 20 * time.Second})
+	allow(t, v, 3, Result{RetryAfter: 40 * time.Second})
+
+	*now = now.Add(20 * time.Second)
+	allow(t, v, 2, Result{Allowed: true, Remaining: 0})
+
+	// changing the algorithm starts afresh
+	if _, err := v.configure(TokenBucket, 5, time.Minute); err != nil {
+		t.Fatalf("error while configuring: %v", err)
+	}
+	allow(t, v, 5, Result{Allowed: true, Remaining: 0})
+}
+
+func TestValue_JSON(t *testing.T) {
+	for _, algo := range []Algorithm{TokenBucket, SlidingWindow} {
+		v, now := newTestValue(t, algo, 5, time.Minute)
+		allow(t, v, 3, Result{Allowed: true, Remaining: 2})
+
+		raw, err := v.ToJSON()
+		if err != nil {
+			t.Fatalf("error while converting to JSON: %v", err)
+		}
+
+		nv := newValue()
+		nv.now = v.now
+		if err := nv.FromJSON(raw); err != nil {
+			t.Fatalf("error while converting from JSON: %v", err)
+		}
+
+		*now = now.Add(time.Second)
+		expected, _ := v.allow(2)
+		if res, _ := nv.allow(2); !reflect.DeepEqual(res, expected) {
+			t.Errorf("%s: expected %+v after JSON; got %+v", algo, expected, res)
+		}
+	}
+
+	if err := newValue().FromJSON([]byte(`{"algorithm":"token-bucket","limit":5,"period":"x"}`)); err == nil {
+		t.Errorf("expected error for invalid period")
+	}
+}
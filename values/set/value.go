@@ -83,6 +83,16 @@ func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
 	}
 }
 
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Has, Len, Get:
+		return true
+	}
+
+	return false
+}
+
 // ToJSON returns the raw byte array of value
 func (v *Value) ToJSON() (json.RawMessage, error) {
 	vals := make([]string, len(*v))
@@ -200,4 +210,4 @@ func (v *Value) get(params ...interface{}) (interface{}, error) {
 }
 
 // Interface guard.
-var _ kiwi.Value = (*Value)(nil)
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
@@ -64,6 +64,16 @@ func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
 	}
 }
 
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Get:
+		return true
+	}
+
+	return false
+}
+
 // ToJSON returns the raw byte array of v's data
 func (v *Value) ToJSON() (json.RawMessage, error) {
 	c, err := json.Marshal(v)
@@ -79,4 +89,4 @@ func (v *Value) FromJSON(rawmessage json.RawMessage) error {
 }
 
 // Interface guard.
-var _ kiwi.Value = (*Value)(nil)
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
@@ -0,0 +1,19 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package stream implements a kiwi.Value which is an append-only log of
+// entries, each a map of fields identified by an increasing ID.
+//
+// IDs are of the form "<ms>-<seq>", where ms is the time in milliseconds when
+// the entry was added and seq orders the entries added in the same
+// millisecond. They are generated when an entry is added with the ID "*".
+//
+// Entries are read by consumer groups, which deliver each entry to one of the
+// consumers of the group. A delivered entry is pending until the consumer
+// acknowledges it, so that the entries of a consumer which crashes can be
+// claimed by another consumer once they have been idle long enough.
+//
+// Generated IDs and the idle times of pending entries depend on the clock of
+// the store, hence they are not replicated identically by the raft package.
+package stream
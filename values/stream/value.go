@@ -0,0 +1,833 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return newValue()
+	})
+}
+
+// Type of stream value.
+const Type kiwi.ValueType = "stream"
+
+// Value can store a log of entries and the state of its consumer groups.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	// entries are sorted by their IDs
+	entries []entry
+
+	// lastID is the ID of the last entry added, which may have been trimmed.
+	lastID id
+
+	groups map[string]*group
+
+	now func() time.Time
+}
+
+// newValue creates an empty stream.
+func newValue() *Value {
+	return &Value{
+		groups: make(map[string]*group),
+		now:    time.Now,
+	}
+}
+
+// Entry is an entry of the stream.
+type Entry struct {
+	ID     string
+	Fields map[string]string
+}
+
+// PendingEntry is an entry delivered to a consumer which it has not
+// acknowledged.
+type PendingEntry struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int
+}
+
+// Various errors for stream value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+	ErrNoGroup           = fmt.Errorf("no such consumer group")
+	ErrGroupExists       = fmt.Errorf("consumer group already exists")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Add adds an entry with the ID (string), or a generated ID if it is "*",
+	// and the fields (map[string]string). The ID should be greater than the
+	// IDs of all the entries added before.
+	//
+	// If the max length (int) is given and not 0, the oldest entries are
+	// trimmed so that the stream has at most max length entries.
+	//
+	// Returns the ID of the entry as a string.
+	Add kiwi.Action = "XADD"
+
+	// Range gets the entries from the start ID to the end ID (strings), both
+	// included, at most count (int) if it is given and not 0.
+	//
+	// The start ID can be "-" for the first entry and the end ID "+" for the
+	// last. An ID with only the milliseconds includes all its entries.
+	//
+	// Returns an []Entry, the oldest first.
+	Range kiwi.Action = "XRANGE"
+
+	// RevRange is the same as Range with the end ID before the start ID.
+	//
+	// Returns an []Entry, the newest first.
+	RevRange kiwi.Action = "XREVRANGE"
+
+	// Len gets the number of entries.
+	//
+	// Returns an int.
+	Len kiwi.Action = "XLEN"
+
+	// GroupCreate creates a consumer group (string) which delivers the
+	// entries after the ID (string), or the entries added from now on if it
+	// is "$". It fails with ErrGroupExists if the group exists.
+	//
+	// Returns nil.
+	GroupCreate kiwi.Action = "XGROUPCREATE"
+
+	// GroupDestroy destroys the consumer group (string).
+	//
+	// Returns true if the group existed.
+	GroupDestroy kiwi.Action = "XGROUPDESTROY"
+
+	// ReadGroup reads the entries for the group and the consumer (strings)
+	// after the ID (string), at most count (int) if it is given and not 0.
+	//
+	// If the ID is ">", the entries never delivered to the group are
+	// delivered to the consumer and are pending until acknowledged.
+	// Otherwise, the entries pending for the consumer are read again. Pending
+	// entries which have been trimmed are not read.
+	//
+	// Returns an []Entry, the oldest first.
+	ReadGroup kiwi.Action = "XREADGROUP"
+
+	// Ack acknowledges the entries with the ID(s) (string) for the group
+	// (string), so that they are no longer pending.
+	//
+	// Returns the number of entries acknowledged.
+	Ack kiwi.Action = "XACK"
+
+	// Pending gets the pending entries of the group (string), only of the
+	// consumer (string) if it is given.
+	//
+	// Returns a []PendingEntry, the oldest first.
+	Pending kiwi.Action = "XPENDING"
+
+	// Claim transfers the entries with the ID(s) (string) pending for the
+	// group (string) to the consumer (string) if they have been idle for at
+	// least the duration (time.Duration). The entries are delivered again,
+	// and the pending entries which have been trimmed are acknowledged.
+	//
+	// Returns an []Entry of the entries claimed, the oldest first.
+	Claim kiwi.Action = "XCLAIM"
+)
+
+// Type returns v's type, i.e., "stream".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Add:          v.add,
+		Range:        v.xrange,
+		RevRange:     v.xrevrange,
+		Len:          v.len,
+		GroupCreate:  v.groupCreate,
+		GroupDestroy: v.groupDestroy,
+		ReadGroup:    v.readGroup,
+		Ack:          v.ack,
+		Pending:      v.pending,
+		Claim:        v.claim,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Range, RevRange, Len, Pending:
+		return true
+	}
+
+	return false
+}
+
+// add implements the XADD action.
+func (v *Value) add(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	idStr, ok := params[0].(string)
+	if !ok {
+		return nil, newParamTypeErr(params[0], idStr)
+	}
+
+	fields, ok := params[1].(map[string]string)
+	if !ok {
+		return nil, newParamTypeErr(params[1], fields)
+	}
+
+	maxLen, err := optionalInt(params, 2, "max length")
+	if err != nil {
+		return nil, err
+	}
+
+	var newID id
+	if idStr == "*" {
+		if v.lastID == maxID {
+			return nil, newParamValueErr("no ids left")
+		}
+		newID = v.nextID()
+	} else {
+		newID, err = parseID(idStr, false)
+		if err != nil {
+			return nil, newParamValueErr(err.Error())
+		}
+
+		if !v.lastID.less(newID) {
+			return nil, newParamValueErr(fmt.Sprintf("id %s not greater than last id %s", newID, v.lastID))
+		}
+	}
+
+	e := entry{id: newID, fields: make(map[string]string, len(fields))}
+	for f, val := range fields {
+		e.fields[f] = val
+	}
+
+	v.entries = append(v.entries, e)
+	v.lastID = newID
+
+	if maxLen > 0 && len(v.entries) > maxLen {
+		n := len(v.entries) - maxLen
+		for i := 0; i < n; i++ {
+			v.entries[i] = entry{}
+		}
+		v.entries = v.entries[n:]
+	}
+
+	return newID.String(), nil
+}
+
+// nextID generates the ID for a new entry from the current time.
+func (v *Value) nextID() id {
+	ms := uint64(v.now().UnixNano() / int64(time.Millisecond))
+	if ms > v.lastID.ms {
+		return id{ms: ms}
+	}
+
+	if v.lastID.seq == math.MaxUint64 {
+		return id{ms: v.lastID.ms + 1}
+	}
+
+	return id{ms: v.lastID.ms, seq: v.lastID.seq + 1}
+}
+
+// xrange implements the XRANGE action.
+func (v *Value) xrange(params ...interface{}) (interface{}, error) {
+	start, end, count, err := rangeParams(params, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for i := v.search(start); i < len(v.entries) && !end.less(v.entries[i].id); i++ {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		entries = append(entries, v.entries[i].export())
+	}
+
+	return entries, nil
+}
+
+// xrevrange implements the XREVRANGE action.
+func (v *Value) xrevrange(params ...interface{}) (interface{}, error) {
+	start, end, count, err := rangeParams(params, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for i := v.searchAfter(end) - 1; i >= 0 && !v.entries[i].id.less(start); i-- {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		entries = append(entries, v.entries[i].export())
+	}
+
+	return entries, nil
+}
+
+// rangeParams parses the parameters of XRANGE and XREVRANGE, with the start
+// and the end IDs at the indexes.
+func rangeParams(params []interface{}, startIdx, endIdx int) (start, end id, count int, err error) {
+	if len(params) < 2 {
+		return id{}, id{}, 0, newParamLenErr(len(params), 2)
+	}
+
+	var ids [2]id
+	for i, isEnd := range []bool{startIdx > endIdx, startIdx < endIdx} {
+		s, ok := params[i].(string)
+		if !ok {
+			return id{}, id{}, 0, newParamTypeErr(params[i], s)
+		}
+
+		ids[i], err = parseRangeID(s, isEnd)
+		if err != nil {
+			return id{}, id{}, 0, newParamValueErr(err.Error())
+		}
+	}
+
+	count, err = optionalInt(params, 2, "count")
+	if err != nil {
+		return id{}, id{}, 0, err
+	}
+
+	return ids[startIdx], ids[endIdx], count, nil
+}
+
+// len implements the XLEN action.
+func (v *Value) len(params ...interface{}) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, newParamLenErr(len(params), 0)
+	}
+
+	return len(v.entries), nil
+}
+
+// groupCreate implements the XGROUPCREATE action.
+func (v *Value) groupCreate(params ...interface{}) (interface{}, error) {
+	strs, err := stringParams(params, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	name, idStr := strs[0], strs[1]
+	if _, ok := v.groups[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
+
+	last := v.lastID
+	if idStr != "$" {
+		if last, err = parseID(idStr, false); err != nil {
+			return nil, newParamValueErr(err.Error())
+		}
+	}
+
+	v.groups[name] = &group{lastDelivered: last, pending: make(map[id]*pending)}
+	return nil, nil
+}
+
+// groupDestroy implements the XGROUPDESTROY action.
+func (v *Value) groupDestroy(params ...interface{}) (interface{}, error) {
+	strs, err := stringParams(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	_, ok := v.groups[strs[0]]
+	delete(v.groups, strs[0])
+	return ok, nil
+}
+
+// readGroup implements the XREADGROUP action.
+func (v *Value) readGroup(params ...interface{}) (interface{}, error) {
+	if len(params) < 3 {
+		return nil, newParamLenErr(len(params), 3)
+	}
+
+	strs, err := stringParams(params[:3], 3)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := optionalInt(params, 3, "count")
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := v.group(strs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, idStr := strs[1], strs[2]
+	entries := []Entry{}
+
+	if idStr == ">" {
+		now := v.now()
+		for i := v.searchAfter(g.lastDelivered); i < len(v.entries); i++ {
+			if count > 0 && len(entries) == count {
+				break
+			}
+
+			e := v.entries[i]
+			g.pending[e.id] = &pending{consumer: consumer, delivered: now, deliveries: 1}
+			g.lastDelivered = e.id
+			entries = append(entries, e.export())
+		}
+
+		return entries, nil
+	}
+
+	after, err := parseID(idStr, false)
+	if err != nil {
+		return nil, newParamValueErr(err.Error())
+	}
+
+	for _, pid := range g.sortedPending() {
+		if count > 0 && len(entries) == count {
+			break
+		}
+
+		if p := g.pending[pid]; p.consumer != consumer || !after.less(pid) {
+			continue
+		}
+
+		if i := v.search(pid); i < len(v.entries) && v.entries[i].id == pid {
+			entries = append(entries, v.entries[i].export())
+		}
+	}
+
+	return entries, nil
+}
+
+// ack implements the XACK action.
+func (v *Value) ack(params ...interface{}) (interface{}, error) {
+	strs, err := stringParams(params, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := v.group(strs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := parseIDs(strs[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	acked := 0
+	for _, pid := range ids {
+		if _, ok := g.pending[pid]; ok {
+			delete(g.pending, pid)
+			acked++
+		}
+	}
+
+	return acked, nil
+}
+
+// pending implements the XPENDING action.
+func (v *Value) pending(params ...interface{}) (interface{}, error) {
+	strs, err := stringParams(params, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := v.group(strs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	now := v.now()
+	entries := []PendingEntry{}
+	for _, pid := range g.sortedPending() {
+		p := g.pending[pid]
+		if len(strs) > 1 && p.consumer != strs[1] {
+			continue
+		}
+
+		entries = append(entries, PendingEntry{
+			ID:         pid.String(),
+			Consumer:   p.consumer,
+			Idle:       now.Sub(p.delivered),
+			Deliveries: p.deliveries,
+		})
+	}
+
+	return entries, nil
+}
+
+// claim implements the XCLAIM action.
+func (v *Value) claim(params ...interface{}) (interface{}, error) {
+	if len(params) < 4 {
+		return nil, newParamLenErr(len(params), 4)
+	}
+
+	minIdle, ok := params[2].(time.Duration)
+	if !ok {
+		return nil, newParamTypeErr(params[2], minIdle)
+	}
+
+	strs, err := stringParams(append(params[:2:2], params[3:]...), 3)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := v.group(strs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := parseIDs(strs[2:])
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].less(ids[j]) })
+
+	now := v.now()
+	entries := []Entry{}
+	for _, pid := range ids {
+		p, ok := g.pending[pid]
+		if !ok || now.Sub(p.delivered) < minIdle {
+			continue
+		}
+
+		i := v.search(pid)
+		if i == len(v.entries) || v.entries[i].id != pid {
+			delete(g.pending, pid)
+			continue
+		}
+
+		p.consumer, p.delivered = strs[1], now
+		p.deliveries++
+		entries = append(entries, v.entries[i].export())
+	}
+
+	return entries, nil
+}
+
+// group returns the consumer group with the name.
+func (v *Value) group(name string) (*group, error) {
+	g, ok := v.groups[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoGroup, name)
+	}
+
+	return g, nil
+}
+
+// search returns the index of the first entry with an ID not less than i.
+func (v *Value) search(i id) int {
+	return sort.Search(len(v.entries), func(j int) bool { return !v.entries[j].id.less(i) })
+}
+
+// searchAfter returns the index of the first entry with an ID greater than i.
+func (v *Value) searchAfter(i id) int {
+	return sort.Search(len(v.entries), func(j int) bool { return i.less(v.entries[j].id) })
+}
+
+// stringParams returns the parameters as strings, requiring at least min.
+func stringParams(params []interface{}, min int) ([]string, error) {
+	if len(params) < min {
+		return nil, newParamLenErr(len(params), min)
+	}
+
+	strs := make([]string, len(params))
+	for i, p := range params {
+		s, ok := p.(string)
+		if !ok {
+			return nil, newParamTypeErr(p, s)
+		}
+		strs[i] = s
+	}
+
+	return strs, nil
+}
+
+// optionalInt returns the non-negative int parameter at the index, or 0 if
+// it is not given.
+func optionalInt(params []interface{}, i int, name string) (int, error) {
+	if len(params) <= i {
+		return 0, nil
+	}
+
+	n, ok := params[i].(int)
+	if !ok {
+		return 0, newParamTypeErr(params[i], n)
+	}
+
+	if n < 0 {
+		return 0, newParamValueErr(fmt.Sprintf("%s %d should not be negative", name, n))
+	}
+
+	return n, nil
+}
+
+// parseIDs parses the IDs.
+func parseIDs(strs []string) ([]id, error) {
+	ids := make([]id, len(strs))
+	for i, s := range strs {
+		var err error
+		if ids[i], err = parseID(s, false); err != nil {
+			return nil, newParamValueErr(err.Error())
+		}
+	}
+
+	return ids, nil
+}
+
+// entry is an entry of the stream.
+type entry struct {
+	id     id
+	fields map[string]string
+}
+
+// export returns the entry as an Entry, with a copy of its fields.
+func (e entry) export() Entry {
+	fields := make(map[string]string, len(e.fields))
+	for f, val := range e.fields {
+		fields[f] = val
+	}
+
+	return Entry{ID: e.id.String(), Fields: fields}
+}
+
+// group is a consumer group.
+type group struct {
+	lastDelivered id
+	pending       map[id]*pending
+}
+
+// pending is the state of a pending entry.
+type pending struct {
+	consumer   string
+	delivered  time.Time
+	deliveries int
+}
+
+// sortedPending returns the IDs of the pending entries in order.
+func (g *group) sortedPending() []id {
+	ids := make([]id, 0, len(g.pending))
+	for pid := range g.pending {
+		ids = append(ids, pid)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i].less(ids[j]) })
+	return ids
+}
+
+// id is the ID of an entry.
+type id struct{ ms, seq uint64 }
+
+// maxID is greater than all the other IDs.
+var maxID = id{ms: math.MaxUint64, seq: math.MaxUint64}
+
+// String returns the ID as "<ms>-<seq>".
+func (i id) String() string {
+	return fmt.Sprintf("%d-%d", i.ms, i.seq)
+}
+
+// less tells if i is before j.
+func (i id) less(j id) bool {
+	return i.ms < j.ms || (i.ms == j.ms && i.seq < j.seq)
+}
+
+// parseID parses an ID. If the sequence is left out, it is 0 or the max
+// sequence at the end of a range.
+func parseID(s string, end bool) (id, error) {
+	msStr, seqStr := s, ""
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		msStr, seqStr = s[:i], s[i+1:]
+	}
+
+	ms, err := strconv.ParseUint(msStr, 10, 64)
+	if err != nil {
+		return id{}, fmt.Errorf("invalid id %q", s)
+	}
+
+	if seqStr == "" {
+		if strings.HasSuffix(s, "-") {
+			return id{}, fmt.Errorf("invalid id %q", s)
+		}
+		if end {
+			return id{ms: ms, seq: math.MaxUint64}, nil
+		}
+		return id{ms: ms}, nil
+	}
+
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return id{}, fmt.Errorf("invalid id %q", s)
+	}
+
+	return id{ms: ms, seq: seq}, nil
+}
+
+// parseRangeID parses an ID of a range, which can also be "-" or "+".
+func parseRangeID(s string, end bool) (id, error) {
+	switch s {
+	case "-":
+		return id{}, nil
+	case "+":
+		return maxID, nil
+	}
+
+	return parseID(s, end)
+}
+
+// entryJSON is the JSON form of an entry.
+type entryJSON struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+// pendingJSON is the JSON form of a pending entry.
+type pendingJSON struct {
+	ID         string    `json:"id"`
+	Consumer   string    `json:"consumer"`
+	Delivered  time.Time `json:"delivered"`
+	Deliveries int       `json:"deliveries"`
+}
+
+// groupJSON is the JSON form of a consumer group.
+type groupJSON struct {
+	LastDelivered string        `json:"last_delivered"`
+	Pending       []pendingJSON `json:"pending"`
+}
+
+// valueJSON is the JSON form of the value.
+type valueJSON struct {
+	LastID  string               `json:"last_id"`
+	Entries []entryJSON          `json:"entries"`
+	Groups  map[string]groupJSON `json:"groups"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	vj := valueJSON{
+		LastID:  v.lastID.String(),
+		Entries: make([]entryJSON, len(v.entries)),
+		Groups:  make(map[string]groupJSON, len(v.groups)),
+	}
+
+	for i, e := range v.entries {
+		vj.Entries[i] = entryJSON{ID: e.id.String(), Fields: e.fields}
+	}
+
+	for name, g := range v.groups {
+		gj := groupJSON{
+			LastDelivered: g.lastDelivered.String(),
+			Pending:       make([]pendingJSON, 0, len(g.pending)),
+		}
+
+		for _, pid := range g.sortedPending() {
+			p := g.pending[pid]
+			gj.Pending = append(gj.Pending, pendingJSON{
+				ID:         pid.String(),
+				Consumer:   p.consumer,
+				Delivered:  p.delivered,
+				Deliveries: p.deliveries,
+			})
+		}
+
+		vj.Groups[name] = gj
+	}
+
+	return json.Marshal(vj)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	nv := newValue()
+	nv.now = v.now
+
+	var err error
+	if nv.lastID, err = parseID(vj.LastID, false); err != nil {
+		return err
+	}
+
+	nv.entries = make([]entry, len(vj.Entries))
+	for i, ej := range vj.Entries {
+		e := entry{fields: ej.Fields}
+		if e.id, err = parseID(ej.ID, false); err != nil {
+			return err
+		}
+
+		if i > 0 && !nv.entries[i-1].id.less(e.id) {
+			return fmt.Errorf("entry %s out of order", e.id)
+		}
+		if nv.lastID.less(e.id) {
+			return fmt.Errorf("entry %s after last id %s", e.id, nv.lastID)
+		}
+
+		if e.fields == nil {
+			e.fields = map[string]string{}
+		}
+		nv.entries[i] = e
+	}
+
+	for name, gj := range vj.Groups {
+		g := &group{pending: make(map[id]*pending, len(gj.Pending))}
+		if g.lastDelivered, err = parseID(gj.LastDelivered, false); err != nil {
+			return fmt.Errorf("group %q: %v", name, err)
+		}
+
+		for _, pj := range gj.Pending {
+			pid, err := parseID(pj.ID, false)
+			if err != nil {
+				return fmt.Errorf("group %q: %v", name, err)
+			}
+
+			g.pending[pid] = &pending{consumer: pj.Consumer, delivered: pj.Delivered, deliveries: pj.Deliveries}
+		}
+
+		nv.groups[name] = g
+	}
+
+	*v = *nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
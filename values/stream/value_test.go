@@ -0,0 +1,246 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package stream
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// ids returns the IDs of the entries.
+func ids(entries interface{}) []string {
+	var res []string
+	for _, e := range entries.([]Entry) {
+		res = append(res, e.ID)
+	}
+	return res
+}
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	for _, id := range []string{"1-1", "1-2", "2-0", "3"} {
+		v, err := store.Do(key, Add, id, map[string]string{"id": id})
+		if err != nil {
+			t.Fatalf("error while adding: %v", err)
+		}
+		if id == "3" && v != "3-0" {
+			t.Errorf("expected XADD to return 3-0; got %v", v)
+		}
+	}
+
+	if _, err := store.Do(key, Add, "2-5", map[string]string{}); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for smaller id; got %v", err)
+	}
+	if _, err := store.Do(key, Add, "x-1", map[string]string{}); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for invalid id; got %v", err)
+	}
+	if _, err := store.Do(key, Add, "*", "a"); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+
+	// generated ids are in the current millisecond, after the last id
+	v, err := store.Do(key, Add, "*", map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatalf("error while adding: %v", err)
+	}
+	last := v.(string)
+
+	v, err = store.Do(key, Range, "-", "+")
+	if err != nil || !reflect.DeepEqual(ids(v), []string{"1-1", "1-2", "2-0", "3-0", last}) {
+		t.Errorf("unexpected XRANGE: %v (%v)", v, err)
+	}
+	if fields := v.([]Entry)[4].Fields; !reflect.DeepEqual(fields, map[string]string{"a": "b"}) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	v, err = store.Do(key, Range, "1", "2", 2)
+	if err != nil || !reflect.DeepEqual(ids(v), []string{"1-1", "1-2"}) {
+		t.Errorf("unexpected XRANGE with count: %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, RevRange, "3", "1-2")
+	if err != nil || !reflect.DeepEqual(ids(v), []string{"3-0", "2-0", "1-2"}) {
+		t.Errorf("unexpected XREVRANGE: %v (%v)", v, err)
+	}
+
+	// trimming keeps the newest entries
+	if _, err := store.Do(key, Add, "*", map[string]string{}, 3); err != nil {
+		t.Fatalf("error while adding: %v", err)
+	}
+	if v, err := store.Do(key, Len); err != nil || v != 3 {
+		t.Errorf("expected XLEN to return 3; got %v (%v)", v, err)
+	}
+	if v, _ := store.Do(key, Range, "-", "+", 1); ids(v)[0] != "3-0" {
+		t.Errorf("expected the first entry after trimming to be 3-0; got %v", v)
+	}
+
+	if _, err := store.Do(key, GroupCreate, "g", "0"); err != nil {
+		t.Fatalf("error while creating group: %v", err)
+	}
+	if _, err := store.Do(key, GroupCreate, "g", "$"); !errors.Is(err, ErrGroupExists) {
+		t.Errorf("expected ErrGroupExists; got %v", err)
+	}
+	if _, err := store.Do(key, ReadGroup, "missing", "c", ">"); !errors.Is(err, ErrNoGroup) {
+		t.Errorf("expected ErrNoGroup; got %v", err)
+	}
+
+	v, err = store.Do(key, ReadGroup, "g", "c1", ">", 2)
+	if err != nil || !reflect.DeepEqual(ids(v), []string{"3-0", last}) {
+		t.Errorf("unexpected XREADGROUP for c1: %v (%v)", v, err)
+	}
+	v, err = store.Do(key, ReadGroup, "g", "c2", ">")
+	if err != nil || len(v.([]Entry)) != 1 {
+		t.Errorf("expected XREADGROUP to deliver 1 entry to c2; got %v (%v)", v, err)
+	}
+	if v, err := store.Do(key, ReadGroup, "g", "c2", ">"); err != nil || len(v.([]Entry)) != 0 {
+		t.Errorf("expected XREADGROUP to deliver nothing; got %v (%v)", v, err)
+	}
+
+	// the history of c1 is its pending entries
+	v, err = store.Do(key, ReadGroup, "g", "c1", "0")
+	if err != nil || !reflect.DeepEqual(ids(v), []string{"3-0", last}) {
+		t.Errorf("unexpected XREADGROUP history for c1: %v (%v)", v, err)
+	}
+
+	if v, err := store.Do(key, Ack, "g", "3-0", "3-0", "9-9"); err != nil || v != 1 {
+		t.Errorf("expected XACK to acknowledge 1 entry; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Pending, "g", "c1")
+	if err != nil || len(v.([]PendingEntry)) != 1 || v.([]PendingEntry)[0].ID != last {
+		t.Errorf("expected 1 entry pending for c1; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Claim, "g", "c2", time.Duration(0), last)
+	if err != nil || !reflect.DeepEqual(ids(v), []string{last}) {
+		t.Errorf("expected XCLAIM to claim %s; got %v (%v)", last, v, err)
+	}
+
+	v, err = store.Do(key, Pending, "g")
+	if err != nil {
+		t.Fatalf("error while getting pending entries: %v", err)
+	}
+	for _, p := range v.([]PendingEntry) {
+		if p.Consumer != "c2" {
+			t.Errorf("expected all entries pending for c2; got %+v", p)
+		}
+		if p.ID == last && p.Deliveries != 2 {
+			t.Errorf("expected claimed entry to be delivered twice; got %+v", p)
+		}
+	}
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Fatalf("ToJSON returned unexpected error: %v", err)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Fatalf("FromJSON returned unexpected error: %v", err)
+	}
+
+	v1, _ := store.Do(key, Range, "-", "+")
+	v2, err := store.Do(newKey, Range, "-", "+")
+	if err != nil || !reflect.DeepEqual(v1, v2) {
+		t.Errorf("expected entries %v FromJSON; got %v (%v)", v1, v2, err)
+	}
+
+	// the idle times differ since time passes
+	v1, _ = store.Do(key, Pending, "g")
+	v2, err = store.Do(newKey, Pending, "g")
+	p1, p2 := v1.([]PendingEntry), v2.([]PendingEntry)
+	for i := range p1 {
+		p1[i].Idle = 0
+		if i < len(p2) {
+			p2[i].Idle = 0
+		}
+	}
+	if err != nil || !reflect.DeepEqual(p1, p2) {
+		t.Errorf("expected pending entries %v FromJSON; got %v (%v)", p1, p2, err)
+	}
+
+	// the group continues after the last delivered entry
+	if _, err := store.Do(newKey, Add, "*", map[string]string{}); err != nil {
+		t.Fatalf("error while adding: %v", err)
+	}
+	if v, err := store.Do(newKey, ReadGroup, "g", "c3", ">"); err != nil || len(v.([]Entry)) != 1 {
+		t.Errorf("expected XREADGROUP to deliver 1 new entry FromJSON; got %v (%v)", v, err)
+	}
+
+	if err := store.FromJSON(newKey, []byte(`{"last_id":"1-0","entries":[{"id":"2-0"}]}`)); err == nil {
+		t.Errorf("expected error FromJSON for entry after last id")
+	}
+}
+
+func TestClaim(t *testing.T) {
+	v := newValue()
+
+	now := time.Unix(1600000000, 0)
+	v.now = func() time.Time { return now }
+
+	do := func(action kiwi.Action, params ...interface{}) interface{} {
+		t.Helper()
+
+		res, err := v.DoMap()[action](params...)
+		if err != nil {
+			t.Fatalf("error while doing %s: %v", action, err)
+		}
+		return res
+	}
+
+	if id := do(Add, "*", map[string]string{}); id != "1600000000000-0" {
+		t.Errorf("expected generated id 1600000000000-0; got %v", id)
+	}
+	if id := do(Add, "*", map[string]string{}); id != "1600000000000-1" {
+		t.Errorf("expected generated id 1600000000000-1; got %v", id)
+	}
+
+	do(GroupCreate, "g", "0")
+	do(ReadGroup, "g", "c1", ">")
+
+	now = now.Add(time.Minute)
+	do(Ack, "g", "1600000000000-1")
+
+	pending := do(Pending, "g").([]PendingEntry)
+	if len(pending) != 1 || pending[0].Idle != time.Minute {
+		t.Errorf("expected 1 entry pending for a minute; got %+v", pending)
+	}
+
+	if claimed := do(Claim, "g", "c2", 2*time.Minute, "1600000000000-0"); len(claimed.([]Entry)) != 0 {
+		t.Errorf("expected nothing claimed before 2 minutes; got %v", claimed)
+	}
+
+	now = now.Add(time.Minute)
+	if claimed := do(Claim, "g", "c2", 2*time.Minute, "1600000000000-0"); len(claimed.([]Entry)) != 1 {
+		t.Errorf("expected the entry claimed after 2 minutes; got %v", claimed)
+	}
+
+	pending = do(Pending, "g", "c2").([]PendingEntry)
+	if len(pending) != 1 || pending[0].Idle != 0 || pending[0].Deliveries != 2 {
+		t.Errorf("expected entry pending for c2 again; got %+v", pending)
+	}
+
+	// a pending entry which has been trimmed is acknowledged when claimed
+	do(Add, "*", map[string]string{}, 1)
+	if claimed := do(Claim, "g", "c1", time.Duration(0), "1600000000000-0"); len(claimed.([]Entry)) != 0 {
+		t.Errorf("expected trimmed entry not to be claimed; got %v", claimed)
+	}
+	if pending := do(Pending, "g").([]PendingEntry); len(pending) != 0 {
+		t.Errorf("expected no pending entries; got %+v", pending)
+	}
+}
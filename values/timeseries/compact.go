@@ -0,0 +1,142 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package timeseries
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// Errors related to the rules of the keys.
+var (
+	ErrNotTimeSeries = fmt.Errorf("value is not a timeseries")
+	ErrRuleCycle     = fmt.Errorf("rules form a cycle")
+)
+
+// AddRule creates a rule compacting the series of the key into the series of
+// the dest key, like the CreateRule action, after checking that the rule does
+// not compact the series into itself either directly or through the rules of
+// the destinations.
+//
+// The rules are read one by one, so concurrent calls can still form a cycle,
+// which is then reported by Record.
+func AddRule(store *kiwi.Store, key, dest string, agg Aggregation, bucket time.Duration) error {
+	if err := guard(store, key); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	next := []string{dest}
+	for len(next) > 0 {
+		k := next[len(next)-1]
+		next = next[:len(next)-1]
+
+		if k == key {
+			return fmt.Errorf("%w: %q compacts into %q", ErrRuleCycle, dest, key)
+		}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		if err := guard(store, k); err != nil {
+			if errors.Is(err, kiwi.ErrKeyNotExist) {
+				continue
+			}
+			return err
+		}
+
+		v, err := store.Do(k, Info)
+		if err != nil {
+			if errors.Is(err, kiwi.ErrKeyNotExist) {
+				// deleted meanwhile
+				continue
+			}
+			return err
+		}
+
+		for _, r := range v.(SeriesInfo).Rules { //nolint:errcheck
+			next = append(next, r.Dest)
+		}
+	}
+
+	_, err := store.Do(key, CreateRule, dest, agg, bucket)
+	return err
+}
+
+// Record adds a sample to the series of the key, and adds the samples
+// compacted by its rules to the series of their destinations, which are added
+// to the store if they do not exist. The rules of the destinations are
+// followed in turn.
+//
+// Samples compacted while adding with the Add action directly are added by
+// the next Record on the key.
+func Record(store *kiwi.Store, key string, timestamp int64, value float64) error {
+	if err := guard(store, key); err != nil {
+		return err
+	}
+
+	if _, err := store.Do(key, Add, timestamp, value); err != nil {
+		return err
+	}
+
+	return compact(store, []string{key})
+}
+
+// compact adds the samples compacted by the rules of the last key of the path
+// to their destinations. The path has the keys compacted into one another, so
+// that a sample is not added to a key it is compacted from.
+func compact(store *kiwi.Store, path []string) error {
+	key := path[len(path)-1]
+
+	v, err := store.Do(key, TakeCompacted)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range v.([]Compacted) { //nolint:errcheck
+		for _, k := range path {
+			if k == c.Dest {
+				return fmt.Errorf("%w: %q compacts into %q", ErrRuleCycle, key, c.Dest)
+			}
+		}
+
+		if err := guard(store, c.Dest); err != nil {
+			if !errors.Is(err, kiwi.ErrKeyNotExist) {
+				return err
+			}
+			if err := store.AddKey(c.Dest, Type); err != nil && !errors.Is(err, kiwi.ErrKeyExists) {
+				return err
+			}
+		}
+
+		if _, err := store.Do(c.Dest, Add, c.Time, c.Value, DupLast); err != nil {
+			return fmt.Errorf("could not compact %q into %q: %w", key, c.Dest, err)
+		}
+
+		if err := compact(store, append(path[:len(path):len(path)], c.Dest)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// guard returns an error if the key does not have a time series.
+func guard(store *kiwi.Store, key string) error {
+	typ, err := store.GetValueType(key)
+	if err != nil {
+		return err
+	}
+
+	if typ != Type {
+		return fmt.Errorf("%w: %q has %q", ErrNotTimeSeries, key, typ)
+	}
+
+	return nil
+}
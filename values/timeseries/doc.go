@@ -0,0 +1,18 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package timeseries implements a kiwi.Value which stores samples, each a
+// float value at a timestamp in milliseconds, and queries them aggregated
+// into buckets of time.
+//
+// Samples older than the retention window, relative to the newest sample, are
+// dropped. Adding a sample at the timestamp of an existing one is handled by
+// the duplicate policy of the series.
+//
+// Compaction rules downsample the series into the series of other keys: once
+// a sample is added after a bucket of a rule, the samples in the bucket are
+// aggregated and added to the destination key by Record. Samples added to a
+// bucket which has been compacted are not compacted again. Rules should be
+// created with AddRule, which does not let a series be compacted into itself.
+package timeseries
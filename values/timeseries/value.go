@@ -0,0 +1,708 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package timeseries
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return &Value{policy: DupBlock}
+	})
+}
+
+// Type of timeseries value.
+const Type kiwi.ValueType = "timeseries"
+
+// maxCompacted is the number of compacted samples kept until they are taken.
+// The oldest are dropped beyond it, which only happens when the series is
+// added to without Record.
+const maxCompacted = 1024
+
+// Value can store the samples of a time series.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	// samples are sorted by their timestamps
+	samples []Sample
+
+	// retention is in milliseconds, and 0 keeps the samples forever.
+	retention int64
+	policy    DuplicatePolicy
+
+	rules []*rule
+
+	// compacted are the samples compacted by the rules which have not been
+	// taken to be added to their destinations.
+	compacted []Compacted
+}
+
+// Sample is a value at a timestamp in milliseconds.
+type Sample struct {
+	Time  int64
+	Value float64
+}
+
+// DuplicatePolicy decides the value of a sample added at the timestamp of an
+// existing sample.
+type DuplicatePolicy string
+
+// Duplicate policies.
+const (
+	// DupBlock fails to add the sample with ErrDuplicate.
+	DupBlock DuplicatePolicy = "BLOCK"
+
+	// DupFirst keeps the existing value.
+	DupFirst DuplicatePolicy = "FIRST"
+
+	// DupLast replaces the existing value.
+	DupLast DuplicatePolicy = "LAST"
+
+	// DupMin keeps the minimum of the values.
+	DupMin DuplicatePolicy = "MIN"
+
+	// DupMax keeps the maximum of the values.
+	DupMax DuplicatePolicy = "MAX"
+
+	// DupSum adds the values.
+	DupSum DuplicatePolicy = "SUM"
+)
+
+// Aggregation aggregates the samples in a bucket.
+type Aggregation string
+
+// Aggregations.
+const (
+	AggAvg   Aggregation = "avg"
+	AggSum   Aggregation = "sum"
+	AggMin   Aggregation = "min"
+	AggMax   Aggregation = "max"
+	AggCount Aggregation = "count"
+	AggLast  Aggregation = "last"
+)
+
+// Rule compacts the samples of a series into the series of the destination
+// key, aggregating them into buckets of time.
+type Rule struct {
+	Dest        string
+	Aggregation Aggregation
+	Bucket      time.Duration
+}
+
+// Compacted is a sample compacted by a rule.
+type Compacted struct {
+	Dest string
+	Sample
+}
+
+// SeriesInfo is the information about a series.
+type SeriesInfo struct {
+	Samples         int
+	First, Last     int64
+	Retention       time.Duration
+	DuplicatePolicy DuplicatePolicy
+	Rules           []Rule
+}
+
+// rule is a Rule with its state.
+type rule struct {
+	Rule
+
+	// current is the start of the bucket being filled, if started.
+	current int64
+	started bool
+}
+
+// Various errors for timeseries value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+	ErrDuplicate         = fmt.Errorf("sample exists at timestamp")
+	ErrTooOld            = fmt.Errorf("timestamp is older than retention")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Add adds a sample at the timestamp (int64) with the value (float64),
+	// which should be finite. A DuplicatePolicy can be given to use instead
+	// of the one of the series. Use Record to write the samples compacted by
+	// the rules.
+	//
+	// Returns the timestamp as an int64.
+	Add kiwi.Action = "ADD"
+
+	// Get gets the newest sample.
+	//
+	// Returns a *Sample, or nil if the series is empty.
+	Get kiwi.Action = "GET"
+
+	// Range gets the samples from the timestamp to the timestamp (int64),
+	// both included. If an Aggregation and a bucket (time.Duration) are
+	// given, the samples are aggregated into buckets which are aligned to
+	// the epoch and start at the timestamps of the results.
+	//
+	// Returns a []Sample, the oldest first.
+	Range kiwi.Action = "RANGE"
+
+	// Alter sets the retention (time.Duration) of the series, 0 keeping the
+	// samples forever, and the DuplicatePolicy if given.
+	//
+	// Returns nil.
+	Alter kiwi.Action = "ALTER"
+
+	// CreateRule creates a rule compacting the series into the destination
+	// key (string) with the Aggregation and the bucket (time.Duration). It
+	// replaces the rule for the destination if there is one. Use AddRule to
+	// check that the rules do not form a cycle.
+	//
+	// Returns nil.
+	CreateRule kiwi.Action = "CREATERULE"
+
+	// DeleteRule deletes the rule for the destination key (string).
+	//
+	// Returns true if the rule existed.
+	DeleteRule kiwi.Action = "DELETERULE"
+
+	// Info gets the information about the series.
+	//
+	// Returns a SeriesInfo.
+	Info kiwi.Action = "INFO"
+
+	// TakeCompacted takes the samples compacted by the rules, which should be
+	// added to their destinations.
+	//
+	// Returns a []Compacted.
+	TakeCompacted kiwi.Action = "TAKECOMPACTED"
+)
+
+// Type returns v's type, i.e., "timeseries".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Add:           v.add,
+		Get:           v.get,
+		Range:         v.xrange,
+		Alter:         v.alter,
+		CreateRule:    v.createRule,
+		DeleteRule:    v.deleteRule,
+		Info:          v.info,
+		TakeCompacted: v.takeCompacted,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Get, Range, Info:
+		return true
+	}
+
+	return false
+}
+
+// add implements the ADD action.
+func (v *Value) add(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	ts, ok := params[0].(int64)
+	if !ok {
+		return nil, newParamTypeErr(params[0], ts)
+	}
+
+	val, ok := params[1].(float64)
+	if !ok {
+		return nil, newParamTypeErr(params[1], val)
+	}
+
+	policy := v.policy
+	if len(params) > 2 {
+		if policy, ok = params[2].(DuplicatePolicy); !ok {
+			return nil, newParamTypeErr(params[2], policy)
+		}
+		if err := policy.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return nil, newParamValueErr(fmt.Sprintf("%v is not finite", val))
+	}
+
+	n := len(v.samples)
+	if n > 0 && v.retention > 0 && ts < v.samples[n-1].Time-v.retention {
+		return nil, fmt.Errorf("%w: %d", ErrTooOld, ts)
+	}
+
+	i := v.search(ts)
+	if i < n && v.samples[i].Time == ts {
+		merged := v.samples[i]
+		if err := merged.merge(val, policy); err != nil {
+			return nil, err
+		}
+		if math.IsInf(merged.Value, 0) {
+			return nil, newParamValueErr(fmt.Sprintf("adding %v at %d would produce an infinite value", val, ts))
+		}
+		v.samples[i] = merged
+	} else {
+		v.samples = append(v.samples, Sample{})
+		copy(v.samples[i+1:], v.samples[i:])
+		v.samples[i] = Sample{Time: ts, Value: val}
+	}
+
+	v.compact(ts)
+	v.trim()
+
+	return ts, nil
+}
+
+// merge merges the value added at the timestamp of s with the policy.
+func (s *Sample) merge(val float64, policy DuplicatePolicy) error {
+	switch policy {
+	case DupBlock:
+		return fmt.Errorf("%w: %d", ErrDuplicate, s.Time)
+	case DupLast:
+		s.Value = val
+	case DupMin:
+		s.Value = math.Min(s.Value, val)
+	case DupMax:
+		s.Value = math.Max(s.Value, val)
+	case DupSum:
+		s.Value += val
+	}
+
+	return nil
+}
+
+// compact closes the buckets of the rules before the bucket of the
+// timestamp, aggregating their samples.
+func (v *Value) compact(ts int64) {
+	for _, r := range v.rules {
+		bucket := r.Bucket.Milliseconds()
+		start := bucketStart(ts, bucket)
+
+		switch {
+		case !r.started:
+			r.current, r.started = start, true
+		case start > r.current:
+			from, to := r.current, r.current+bucket-1
+			if agg, ok := aggregate(v.samples[v.search(from):v.search(to+1)], r.Aggregation); ok {
+				v.compacted = append(v.compacted, Compacted{Dest: r.Dest, Sample: Sample{Time: from, Value: agg}})
+			}
+			r.current = start
+		}
+	}
+
+	if n := len(v.compacted); n > maxCompacted {
+		v.compacted = append(v.compacted[:0], v.compacted[n-maxCompacted:]...)
+	}
+}
+
+// trim drops the samples older than the retention.
+func (v *Value) trim() {
+	n := len(v.samples)
+	if n == 0 || v.retention == 0 {
+		return
+	}
+
+	i := v.search(v.samples[n-1].Time - v.retention)
+	if i > 0 {
+		v.samples = append(v.samples[:0], v.samples[i:]...)
+	}
+}
+
+// get implements the GET action.
+func (v *Value) get(params ...interface{}) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, newParamLenErr(len(params), 0)
+	}
+
+	if len(v.samples) == 0 {
+		return (*Sample)(nil), nil
+	}
+
+	s := v.samples[len(v.samples)-1]
+	return &s, nil
+}
+
+// xrange implements the RANGE action.
+func (v *Value) xrange(params ...interface{}) (interface{}, error) {
+	if len(params) != 2 && len(params) != 4 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	var bounds [2]int64
+	for i := range bounds {
+		ts, ok := params[i].(int64)
+		if !ok {
+			return nil, newParamTypeErr(params[i], ts)
+		}
+		bounds[i] = ts
+	}
+
+	samples := []Sample{}
+	if bounds[0] > bounds[1] {
+		return samples, nil
+	}
+
+	from, to := v.search(bounds[0]), v.search(bounds[1])
+	if to < len(v.samples) && v.samples[to].Time == bounds[1] {
+		to++
+	}
+
+	if len(params) == 2 {
+		return append(samples, v.samples[from:to]...), nil
+	}
+
+	agg, bucket, err := aggParams(params[2], params[3])
+	if err != nil {
+		return nil, err
+	}
+
+	for i := from; i < to; {
+		start := bucketStart(v.samples[i].Time, bucket)
+
+		j := i
+		for j < to && v.samples[j].Time < start+bucket {
+			j++
+		}
+
+		val, _ := aggregate(v.samples[i:j], agg)
+		samples = append(samples, Sample{Time: start, Value: val})
+		i = j
+	}
+
+	return samples, nil
+}
+
+// alter implements the ALTER action.
+func (v *Value) alter(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	retention, ok := params[0].(time.Duration)
+	if !ok {
+		return nil, newParamTypeErr(params[0], retention)
+	}
+
+	if retention < 0 {
+		return nil, newParamValueErr(fmt.Sprintf("retention %v should not be negative", retention))
+	}
+
+	policy := v.policy
+	if len(params) > 1 {
+		if policy, ok = params[1].(DuplicatePolicy); !ok {
+			return nil, newParamTypeErr(params[1], policy)
+		}
+		if err := policy.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	v.retention, v.policy = retention.Milliseconds(), policy
+	v.trim()
+
+	return nil, nil
+}
+
+// createRule implements the CREATERULE action.
+func (v *Value) createRule(params ...interface{}) (interface{}, error) {
+	if len(params) != 3 {
+		return nil, newParamLenErr(len(params), 3)
+	}
+
+	dest, ok := params[0].(string)
+	if !ok {
+		return nil, newParamTypeErr(params[0], dest)
+	}
+
+	agg, bucket, err := aggParams(params[1], params[2])
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rule{Rule: Rule{Dest: dest, Aggregation: agg, Bucket: time.Duration(bucket) * time.Millisecond}}
+	for i := range v.rules {
+		if v.rules[i].Dest == dest {
+			v.rules[i] = r
+			return nil, nil
+		}
+	}
+
+	v.rules = append(v.rules, r)
+	return nil, nil
+}
+
+// deleteRule implements the DELETERULE action.
+func (v *Value) deleteRule(params ...interface{}) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	dest, ok := params[0].(string)
+	if !ok {
+		return nil, newParamTypeErr(params[0], dest)
+	}
+
+	for i := range v.rules {
+		if v.rules[i].Dest == dest {
+			v.rules = append(v.rules[:i], v.rules[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// info implements the INFO action.
+func (v *Value) info(params ...interface{}) (interface{}, error) {
+	info := SeriesInfo{
+		Samples:         len(v.samples),
+		Retention:       time.Duration(v.retention) * time.Millisecond,
+		DuplicatePolicy: v.policy,
+		Rules:           make([]Rule, len(v.rules)),
+	}
+
+	if n := len(v.samples); n > 0 {
+		info.First, info.Last = v.samples[0].Time, v.samples[n-1].Time
+	}
+
+	for i, r := range v.rules {
+		info.Rules[i] = r.Rule
+	}
+
+	return info, nil
+}
+
+// takeCompacted implements the TAKECOMPACTED action.
+func (v *Value) takeCompacted(params ...interface{}) (interface{}, error) {
+	compacted := v.compacted
+	v.compacted = nil
+
+	if compacted == nil {
+		compacted = []Compacted{}
+	}
+
+	return compacted, nil
+}
+
+// search returns the index of the first sample at or after the timestamp.
+func (v *Value) search(ts int64) int {
+	return sort.Search(len(v.samples), func(i int) bool { return v.samples[i].Time >= ts })
+}
+
+// aggParams parses the aggregation and the bucket in milliseconds.
+func aggParams(a, b interface{}) (Aggregation, int64, error) {
+	agg, ok := a.(Aggregation)
+	if !ok {
+		return "", 0, newParamTypeErr(a, agg)
+	}
+
+	switch agg {
+	case AggAvg, AggSum, AggMin, AggMax, AggCount, AggLast:
+	default:
+		return "", 0, newParamValueErr(fmt.Sprintf("unknown aggregation %q", agg))
+	}
+
+	bucket, ok := b.(time.Duration)
+	if !ok {
+		return "", 0, newParamTypeErr(b, bucket)
+	}
+
+	if bucket < time.Millisecond {
+		return "", 0, newParamValueErr(fmt.Sprintf("bucket %v shorter than a millisecond", bucket))
+	}
+
+	return agg, bucket.Milliseconds(), nil
+}
+
+// validate checks that p is a known policy.
+func (p DuplicatePolicy) validate() error {
+	switch p {
+	case DupBlock, DupFirst, DupLast, DupMin, DupMax, DupSum:
+		return nil
+	}
+
+	return newParamValueErr(fmt.Sprintf("unknown duplicate policy %q", p))
+}
+
+// bucketStart returns the start of the bucket of the timestamp, aligned to the
+// epoch.
+func bucketStart(ts, bucket int64) int64 {
+	start := ts - ts%bucket
+	if start > ts {
+		start -= bucket
+	}
+	return start
+}
+
+// aggregate aggregates the samples, returning false if there are none.
+func aggregate(samples []Sample, agg Aggregation) (float64, bool) {
+	if len(samples) == 0 {
+		return 0, false
+	}
+
+	var res float64
+	switch agg {
+	case AggAvg, AggSum:
+		for _, s := range samples {
+			res += s.Value
+		}
+		if agg == AggAvg {
+			res /= float64(len(samples))
+		}
+	case AggMin:
+		res = math.Inf(1)
+		for _, s := range samples {
+			res = math.Min(res, s.Value)
+		}
+	case AggMax:
+		res = math.Inf(-1)
+		for _, s := range samples {
+			res = math.Max(res, s.Value)
+		}
+	case AggCount:
+		res = float64(len(samples))
+	case AggLast:
+		res = samples[len(samples)-1].Value
+	}
+
+	return res, true
+}
+
+// ruleJSON is the JSON form of a rule.
+type ruleJSON struct {
+	Dest        string      `json:"dest"`
+	Aggregation Aggregation `json:"aggregation"`
+	BucketMs    int64       `json:"bucket_ms"`
+	Current     *int64      `json:"current,omitempty"`
+}
+
+// compactedJSON is the JSON form of a compacted sample.
+type compactedJSON struct {
+	Dest  string  `json:"dest"`
+	Time  int64   `json:"time"`
+	Value float64 `json:"value"`
+}
+
+// valueJSON is the JSON form of the value.
+type valueJSON struct {
+	RetentionMs     int64           `json:"retention_ms"`
+	DuplicatePolicy DuplicatePolicy `json:"duplicate_policy"`
+	Samples         [][2]float64    `json:"samples"`
+	Rules           []ruleJSON      `json:"rules,omitempty"`
+	Compacted       []compactedJSON `json:"compacted,omitempty"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	vj := valueJSON{
+		RetentionMs:     v.retention,
+		DuplicatePolicy: v.policy,
+		Samples:         make([][2]float64, len(v.samples)),
+	}
+
+	for i, s := range v.samples {
+		vj.Samples[i] = [2]float64{float64(s.Time), s.Value}
+	}
+
+	for _, r := range v.rules {
+		rj := ruleJSON{Dest: r.Dest, Aggregation: r.Aggregation, BucketMs: r.Bucket.Milliseconds()}
+		if r.started {
+			current := r.current
+			rj.Current = &current
+		}
+		vj.Rules = append(vj.Rules, rj)
+	}
+
+	for _, c := range v.compacted {
+		vj.Compacted = append(vj.Compacted, compactedJSON{Dest: c.Dest, Time: c.Time, Value: c.Value})
+	}
+
+	return json.Marshal(vj)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	if vj.RetentionMs < 0 {
+		return fmt.Errorf("negative retention %d", vj.RetentionMs)
+	}
+	if err := vj.DuplicatePolicy.validate(); err != nil {
+		return err
+	}
+
+	nv := Value{
+		samples:   make([]Sample, len(vj.Samples)),
+		retention: vj.RetentionMs,
+		policy:    vj.DuplicatePolicy,
+	}
+
+	for i, s := range vj.Samples {
+		nv.samples[i] = Sample{Time: int64(s[0]), Value: s[1]}
+		if i > 0 && nv.samples[i-1].Time >= nv.samples[i].Time {
+			return fmt.Errorf("sample at %d out of order", nv.samples[i].Time)
+		}
+	}
+
+	for _, rj := range vj.Rules {
+		agg, bucket, err := aggParams(rj.Aggregation, time.Duration(rj.BucketMs)*time.Millisecond)
+		if err != nil {
+			return fmt.Errorf("rule for %q: %v", rj.Dest, err)
+		}
+
+		r := &rule{Rule: Rule{Dest: rj.Dest, Aggregation: agg, Bucket: time.Duration(bucket) * time.Millisecond}}
+		if rj.Current != nil {
+			r.current, r.started = *rj.Current, true
+		}
+		nv.rules = append(nv.rules, r)
+	}
+
+	if n := len(vj.Compacted); n > maxCompacted {
+		vj.Compacted = vj.Compacted[n-maxCompacted:]
+	}
+	for _, cj := range vj.Compacted {
+		nv.compacted = append(nv.compacted, Compacted{Dest: cj.Dest, Sample: Sample{Time: cj.Time, Value: cj.Value}})
+	}
+
+	*v = nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
@@ -0,0 +1,236 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package timeseries
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	v, err := store.Do(key, Get)
+	if err != nil || v.(*Sample) != nil {
+		t.Errorf("expected GET on empty series to be nil; got %v (%v)", v, err)
+	}
+
+	// added out of order
+	for _, s := range []Sample{{1000, 1}, {3000, 3}, {2000, 2}, {2500, 4}, {4500, 5}} {
+		if _, err := store.Do(key, Add, s.Time, s.Value); err != nil {
+			t.Fatalf("error while adding: %v", err)
+		}
+	}
+
+	if _, err := store.Do(key, Add, int64(1000), 7.0); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate; got %v", err)
+	}
+	if _, err := store.Do(key, Add, 1000, 7.0); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+	if _, err := store.Do(key, Add, int64(1000), 7.0, DupSum); err != nil {
+		t.Errorf("error while adding with SUM: %v", err)
+	}
+	for _, val := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := store.Do(key, Add, int64(6000), val); !errors.Is(err, ErrInvalidParamValue) {
+			t.Errorf("expected ErrInvalidParamValue for %v; got %v", val, err)
+		}
+	}
+
+	v, err = store.Do(key, Get)
+	if err != nil || *v.(*Sample) != (Sample{4500, 5}) {
+		t.Errorf("unexpected GET: %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Range, int64(1000), int64(2500))
+	if expected := []Sample{{1000, 8}, {2000, 2}, {2500, 4}}; err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected RANGE %v; got %v (%v)", expected, v, err)
+	}
+
+	aggs := map[Aggregation][]Sample{
+		AggAvg:   {{0, 8}, {2000, 3}, {4000, 5}},
+		AggSum:   {{0, 8}, {2000, 9}, {4000, 5}},
+		AggMin:   {{0, 8}, {2000, 2}, {4000, 5}},
+		AggMax:   {{0, 8}, {2000, 4}, {4000, 5}},
+		AggCount: {{0, 1}, {2000, 3}, {4000, 1}},
+		AggLast:  {{0, 8}, {2000, 3}, {4000, 5}},
+	}
+	for agg, expected := range aggs {
+		v, err := store.Do(key, Range, int64(0), int64(5000), agg, 2*time.Second)
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("expected RANGE with %s %v; got %v (%v)", agg, expected, v, err)
+		}
+	}
+
+	if _, err := store.Do(key, Range, int64(0), int64(1), Aggregation("median"), time.Second); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for unknown aggregation; got %v", err)
+	}
+
+	// retention drops samples older than the newest by more than it
+	if _, err := store.Do(key, Alter, 2*time.Second, DupMax); err != nil {
+		t.Fatalf("error while altering: %v", err)
+	}
+	if _, err := store.Do(key, Add, int64(1000), 1.0); !errors.Is(err, ErrTooOld) {
+		t.Errorf("expected ErrTooOld; got %v", err)
+	}
+	if _, err := store.Do(key, Add, int64(4500), 4.0); err != nil {
+		t.Errorf("error while adding with MAX: %v", err)
+	}
+
+	v, err = store.Do(key, Info)
+	expected := SeriesInfo{Samples: 3, First: 2500, Last: 4500, Retention: 2 * time.Second, DuplicatePolicy: DupMax, Rules: []Rule{}}
+	if err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected INFO %+v; got %+v (%v)", expected, v, err)
+	}
+
+	// duplicates do not add up to an infinite value
+	if _, err := store.Do(key, Add, int64(4500), math.MaxFloat64, DupSum); err != nil {
+		t.Fatalf("error while adding with SUM: %v", err)
+	}
+	if _, err := store.Do(key, Add, int64(4500), math.MaxFloat64, DupSum); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for infinite sum; got %v", err)
+	}
+	if v, err := store.Do(key, Get); err != nil || v.(*Sample).Value != math.MaxFloat64 {
+		t.Errorf("expected GET to return the maximum float; got %v (%v)", v, err)
+	}
+}
+
+func TestValue_JSON(t *testing.T) {
+	v := &Value{policy: DupBlock}
+	if _, err := v.createRule("dest", AggSum, time.Second); err != nil {
+		t.Fatalf("error while creating rule: %v", err)
+	}
+	if _, err := v.alter(time.Hour, DupFirst); err != nil {
+		t.Fatalf("error while altering: %v", err)
+	}
+	for _, ts := range []int64{100, 900, 1100} {
+		if _, err := v.add(ts, 1.5); err != nil {
+			t.Fatalf("error while adding: %v", err)
+		}
+	}
+
+	raw, err := v.ToJSON()
+	if err != nil {
+		t.Fatalf("error while converting to JSON: %v", err)
+	}
+
+	nv := new(Value)
+	if err := nv.FromJSON(raw); err != nil {
+		t.Fatalf("error while converting from JSON: %v", err)
+	}
+
+	if !reflect.DeepEqual(v, nv) {
+		t.Errorf("expected %+v; got %+v", v, nv)
+	}
+
+	if err := nv.FromJSON([]byte(`{"duplicate_policy":"BLOCK","samples":[[2,1],[1,1]]}`)); err == nil {
+		t.Errorf("expected error for out of order samples")
+	}
+}
+
+func TestRecord(t *testing.T) {
+	key, minutely, hourly := "raw", "raw:1m", "raw:1h"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	if _, err := store.Do(key, CreateRule, minutely, AggAvg, time.Minute); err != nil {
+		t.Fatalf("error while creating rule: %v", err)
+	}
+	if err := store.AddKey(minutely, Type); err != nil {
+		t.Fatalf("error while adding key: %v", err)
+	}
+	if _, err := store.Do(minutely, CreateRule, hourly, AggMax, time.Hour); err != nil {
+		t.Fatalf("error while creating rule: %v", err)
+	}
+
+	// a sample every 20 seconds for two hours
+	for ts := int64(0); ts <= 2*time.Hour.Milliseconds(); ts += 20000 {
+		if err := Record(store, key, ts, float64(ts/60000)); err != nil {
+			t.Fatalf("error while recording: %v", err)
+		}
+	}
+
+	v, err := store.Do(minutely, Info)
+	if err != nil || v.(SeriesInfo).Samples != 120 {
+		t.Errorf("expected 120 minutely samples; got %+v (%v)", v, err)
+	}
+
+	v, err = store.Do(hourly, Range, int64(0), int64(2*time.Hour.Milliseconds()))
+	if expected := []Sample{{0, 59}}; err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected hourly samples %v; got %v (%v)", expected, v, err)
+	}
+
+	if err := Record(store, "none", 0, 0); !errors.Is(err, kiwi.ErrKeyNotExist) {
+		t.Errorf("expected ErrKeyNotExist; got %v", err)
+	}
+}
+
+func TestAddRule(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{"a": Type, "b": Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	if err := AddRule(store, "a", "b", AggAvg, time.Second); err != nil {
+		t.Fatalf("error while adding rule: %v", err)
+	}
+	if err := AddRule(store, "b", "c", AggAvg, time.Second); err != nil {
+		t.Fatalf("error while adding rule: %v", err)
+	}
+
+	for _, r := range [][2]string{{"a", "a"}, {"b", "a"}, {"c", "a"}} {
+		if err := store.AddKey(r[0], Type); err != nil && !errors.Is(err, kiwi.ErrKeyExists) {
+			t.Fatalf("error while adding key: %v", err)
+		}
+		if err := AddRule(store, r[0], r[1], AggAvg, time.Second); !errors.Is(err, ErrRuleCycle) {
+			t.Errorf("expected ErrRuleCycle for %s -> %s; got %v", r[0], r[1], err)
+		}
+	}
+
+	// cycles created with the action are not followed by Record
+	if _, err := store.Do("b", CreateRule, "a", AggAvg, time.Second); err != nil {
+		t.Fatalf("error while creating rule: %v", err)
+	}
+	for _, ts := range []int64{0, 1000, 2000} {
+		err = Record(store, "a", ts, 1)
+	}
+	if !errors.Is(err, ErrRuleCycle) {
+		t.Errorf("expected ErrRuleCycle while recording; got %v", err)
+	}
+}
+
+func TestValue_Compacted(t *testing.T) {
+	v := &Value{policy: DupBlock}
+	if _, err := v.createRule("dest", AggCount, time.Millisecond); err != nil {
+		t.Fatalf("error while creating rule: %v", err)
+	}
+
+	// compacted samples which are never taken are bounded
+	for ts := int64(0); ts < 2*maxCompacted; ts++ {
+		if _, err := v.add(ts, 1.0); err != nil {
+			t.Fatalf("error while adding: %v", err)
+		}
+	}
+
+	if len(v.compacted) != maxCompacted {
+		t.Fatalf("expected %d compacted samples; got %d", maxCompacted, len(v.compacted))
+	}
+	if last := v.compacted[maxCompacted-1]; last.Time != 2*maxCompacted-2 {
+		t.Errorf("expected newest compacted sample to be kept; got %+v", last)
+	}
+}
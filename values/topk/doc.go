@@ -0,0 +1,16 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package topk implements a kiwi.Value which keeps track of the k most
+// frequent items, the heavy hitters, without storing all the items.
+//
+// It uses HeavyKeeper: the items are counted in a fixed number of buckets
+// whose counts decay with a probability when other items collide with them,
+// so that the counts of infrequent items fade away while those of the
+// frequent ones stay. The k items with the highest counts are kept in a list.
+//
+// The dimensions of the buckets and the decay are set with the Reserve
+// action; by default the top 10 items are tracked with 5 rows of 1000
+// buckets and a decay of 0.9.
+package topk
@@ -0,0 +1,486 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package topk
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return newValue(DefaultK, DefaultWidth, DefaultDepth, DefaultDecay)
+	})
+}
+
+// Type of topk value.
+const Type kiwi.ValueType = "topk"
+
+// Parameters used unless the value is reserved.
+const (
+	DefaultK     = 10
+	DefaultWidth = 1000
+	DefaultDepth = 5
+	DefaultDecay = 0.9
+)
+
+// maxBuckets limits the number of buckets of a value.
+const maxBuckets = 1 << 24
+
+// Value can store the top k items.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	k     int
+	width int
+	depth int
+	decay float64
+
+	// buckets has depth rows of width buckets each
+	buckets []bucket
+	top     top
+
+	// rand decides if a count decays. It is not exported, so a value loaded
+	// from JSON decays differently than the original would have.
+	rand *rand.Rand
+}
+
+// bucket counts the item with the fingerprint.
+type bucket struct {
+	fp    uint32
+	count int64
+}
+
+// newValue creates an empty value with the parameters.
+func newValue(k, width, depth int, decay float64) *Value {
+	return &Value{
+		k:       k,
+		width:   width,
+		depth:   depth,
+		decay:   decay,
+		buckets: make([]bucket, width*depth),
+		top:     top{index: make(map[string]int)},
+		rand:    rand.New(rand.NewSource(1)), //nolint:gosec
+	}
+}
+
+// Various errors for topk value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+	ErrNotEmpty          = fmt.Errorf("topk is not empty")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Reserve sets k, the width and the depth of the buckets (all int) and
+	// the decay (float64, between 0 and 1). It fails with ErrNotEmpty once
+	// items are added.
+	//
+	// Returns nil.
+	Reserve kiwi.Action = "RESERVE"
+
+	// Add adds the item(s).
+	//
+	// Returns a []string of the items removed from the top k, if any.
+	Add kiwi.Action = "ADD"
+
+	// List lists the top k items, the most frequent first.
+	//
+	// Returns a []string.
+	List kiwi.Action = "LIST"
+
+	// Count estimates the count of the item(s).
+	//
+	// Returns an []int64 with the estimate for each item.
+	Count kiwi.Action = "COUNT"
+
+	// Query checks if the item(s) are in the top k.
+	//
+	// Returns a []bool with the result for each item.
+	Query kiwi.Action = "QUERY"
+)
+
+// Type returns v's type, i.e., "topk".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Reserve: v.reserve,
+		Add: func(params ...interface{}) (interface{}, error) {
+			items, err := itemParams(params)
+			if err != nil {
+				return nil, err
+			}
+
+			var removed []string
+			for _, item := range items {
+				if r, ok := v.add(item); ok {
+					removed = append(removed, r)
+				}
+			}
+
+			return removed, nil
+		},
+		List: func(params ...interface{}) (interface{}, error) {
+			entries := append([]entry(nil), v.top.entries...)
+			sort.Slice(entries, func(i, j int) bool {
+				if entries[i].Count != entries[j].Count {
+					return entries[i].Count > entries[j].Count
+				}
+				return entries[i].Item < entries[j].Item
+			})
+
+			items := make([]string, len(entries))
+			for i := range entries {
+				items[i] = entries[i].Item
+			}
+
+			return items, nil
+		},
+		Count: func(params ...interface{}) (interface{}, error) {
+			items, err := itemParams(params)
+			if err != nil {
+				return nil, err
+			}
+
+			counts := make([]int64, len(items))
+			for i, item := range items {
+				counts[i] = v.count(newHash(item))
+			}
+
+			return counts, nil
+		},
+		Query: func(params ...interface{}) (interface{}, error) {
+			items, err := itemParams(params)
+			if err != nil {
+				return nil, err
+			}
+
+			in := make([]bool, len(items))
+			for i, item := range items {
+				_, in[i] = v.top.index[item]
+			}
+
+			return in, nil
+		},
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case List, Count, Query:
+		return true
+	}
+
+	return false
+}
+
+// reserve implements the RESERVE action.
+func (v *Value) reserve(params ...interface{}) (interface{}, error) {
+	if len(params) < 4 {
+		return nil, newParamLenErr(len(params), 4)
+	}
+
+	var dims [3]int
+	for i := range dims {
+		n, ok := params[i].(int)
+		if !ok {
+			return nil, newParamTypeErr(params[i], n)
+		}
+		dims[i] = n
+	}
+
+	decay, ok := params[3].(float64)
+	if !ok {
+		return nil, newParamTypeErr(params[3], decay)
+	}
+
+	if err := validate(dims[0], dims[1], dims[2], decay); err != nil {
+		return nil, newParamValueErr(err.Error())
+	}
+
+	if len(v.top.entries) > 0 {
+		return nil, ErrNotEmpty
+	}
+
+	*v = *newValue(dims[0], dims[1], dims[2], decay)
+	return nil, nil
+}
+
+// validate checks the parameters of a value.
+func validate(k, width, depth int, decay float64) error {
+	if k <= 0 {
+		return fmt.Errorf("k %d should be positive", k)
+	}
+
+	if width <= 0 || depth <= 0 || width > maxBuckets/depth {
+		return fmt.Errorf("invalid dimensions %dx%d", width, depth)
+	}
+
+	if !(decay > 0 && decay < 1) {
+		return fmt.Errorf("decay %v should be between 0 and 1", decay)
+	}
+
+	return nil
+}
+
+// add counts the item, returning the item removed from the top k if any.
+func (v *Value) add(item string) (string, bool) {
+	h := newHash(item)
+	fp := h.fingerprint()
+
+	var count int64
+	for i := 0; i < v.depth; i++ {
+		b := &v.buckets[v.index(h, i)]
+
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, 1
+		case b.fp == fp:
+			b.count++
+		case v.rand.Float64() < math.Pow(v.decay, float64(b.count)):
+			// the colliding count decays, and is replaced once it is gone
+			b.count--
+			if b.count == 0 {
+				b.fp, b.count = fp, 1
+			}
+		}
+
+		if b.fp == fp && b.count > count {
+			count = b.count
+		}
+	}
+
+	return v.top.update(item, count, v.k)
+}
+
+// count returns the estimated count of the item with the hash.
+func (v *Value) count(h hash) int64 {
+	fp := h.fingerprint()
+
+	var count int64
+	for i := 0; i < v.depth; i++ {
+		if b := v.buckets[v.index(h, i)]; b.fp == fp && b.count > count {
+			count = b.count
+		}
+	}
+
+	return count
+}
+
+// index returns the index of the bucket for the hash in the row.
+func (v *Value) index(h hash, row int) int {
+	return row*v.width + int((h.h1+uint64(row)*h.h2)%uint64(v.width))
+}
+
+// itemParams returns the parameters as strings, requiring at least one.
+func itemParams(params []interface{}) ([]string, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	items := make([]string, len(params))
+	for i, p := range params {
+		item, ok := p.(string)
+		if !ok {
+			return nil, newParamTypeErr(p, item)
+		}
+		items[i] = item
+	}
+
+	return items, nil
+}
+
+// entry is an item in the top k.
+type entry struct {
+	Item  string `json:"item"`
+	Count int64  `json:"count"`
+}
+
+// top is a min-heap of the top k items by their counts.
+type top struct {
+	entries []entry
+	index   map[string]int // of the item in entries
+}
+
+// update updates the count of the item, adding it to the top k if its count
+// is high enough. It returns the item removed to make room, if any.
+func (t *top) update(item string, count int64, k int) (string, bool) {
+	if i, ok := t.index[item]; ok {
+		if count > t.entries[i].Count {
+			t.entries[i].Count = count
+			heap.Fix(t, i)
+		}
+		return "", false
+	}
+
+	if len(t.entries) < k {
+		heap.Push(t, entry{Item: item, Count: count})
+		return "", false
+	}
+
+	if count <= t.entries[0].Count {
+		return "", false
+	}
+
+	removed := t.entries[0].Item
+	delete(t.index, removed)
+
+	t.entries[0] = entry{Item: item, Count: count}
+	t.index[item] = 0
+	heap.Fix(t, 0)
+
+	return removed, true
+}
+
+// Len implements heap.Interface.
+func (t *top) Len() int { return len(t.entries) }
+
+// Less implements heap.Interface.
+func (t *top) Less(i, j int) bool { return t.entries[i].Count < t.entries[j].Count }
+
+// Swap implements heap.Interface.
+func (t *top) Swap(i, j int) {
+	t.entries[i], t.entries[j] = t.entries[j], t.entries[i]
+	t.index[t.entries[i].Item] = i
+	t.index[t.entries[j].Item] = j
+}
+
+// Push implements heap.Interface.
+func (t *top) Push(x interface{}) {
+	e := x.(entry) //nolint:errcheck
+	t.index[e.Item] = len(t.entries)
+	t.entries = append(t.entries, e)
+}
+
+// Pop implements heap.Interface.
+func (t *top) Pop() interface{} {
+	n := len(t.entries) - 1
+	e := t.entries[n]
+	t.entries = t.entries[:n]
+	delete(t.index, e.Item)
+	return e
+}
+
+// hash is the pair of hashes of an item from which its bucket in each row is
+// derived by double hashing.
+type hash struct{ h1, h2 uint64 }
+
+// newHash hashes the item.
+func newHash(item string) hash {
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(item))
+	h1 := mix(f.Sum64())
+
+	return hash{h1: h1, h2: mix(h1^0x9e3779b97f4a7c15) | 1}
+}
+
+// fingerprint returns the fingerprint of the item kept in its buckets.
+func (h hash) fingerprint() uint32 {
+	return uint32(h.h2 >> 32)
+}
+
+// mix is the finalizer of MurmurHash3, which spreads the bits of the FNV hash.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// valueJSON is the JSON form of the value. The buckets are flattened into
+// pairs of fingerprint and count.
+type valueJSON struct {
+	K       int     `json:"k"`
+	Width   int     `json:"width"`
+	Depth   int     `json:"depth"`
+	Decay   float64 `json:"decay"`
+	Buckets []int64 `json:"buckets"`
+	Top     []entry `json:"top"`
+}
+
+// ToJSON returns the raw byte array of v's data.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	vj := valueJSON{
+		K:       v.k,
+		Width:   v.width,
+		Depth:   v.depth,
+		Decay:   v.decay,
+		Buckets: make([]int64, 0, 2*len(v.buckets)),
+		Top:     v.top.entries,
+	}
+
+	for _, b := range v.buckets {
+		vj.Buckets = append(vj.Buckets, int64(b.fp), b.count)
+	}
+
+	return json.Marshal(vj)
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var vj valueJSON
+	if err := json.Unmarshal(rawmessage, &vj); err != nil {
+		return err
+	}
+
+	if err := validate(vj.K, vj.Width, vj.Depth, vj.Decay); err != nil {
+		return err
+	}
+	if len(vj.Buckets) != 2*vj.Width*vj.Depth {
+		return fmt.Errorf("%d buckets do not match the dimensions %dx%d", len(vj.Buckets)/2, vj.Width, vj.Depth)
+	}
+	if len(vj.Top) > vj.K {
+		return fmt.Errorf("%d items in the top %d", len(vj.Top), vj.K)
+	}
+
+	nv := newValue(vj.K, vj.Width, vj.Depth, vj.Decay)
+	for i := range nv.buckets {
+		nv.buckets[i] = bucket{fp: uint32(vj.Buckets[2*i]), count: vj.Buckets[2*i+1]}
+	}
+
+	for _, e := range vj.Top {
+		if _, ok := nv.top.index[e.Item]; ok {
+			return fmt.Errorf("duplicate item %q in the top", e.Item)
+		}
+		heap.Push(&nv.top, e)
+	}
+
+	*v = *nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
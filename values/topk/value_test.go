@@ -0,0 +1,113 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package topk
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	if _, err := store.Do(key, Reserve, 3, 100, 4, 0.9); err != nil {
+		t.Fatalf("error while reserving: %v", err)
+	}
+
+	// heavy:i is added 100*i times, and each of them is followed by noise
+	for i := 1; i <= 5; i++ {
+		for j := 0; j < 100*i; j++ {
+			item := "heavy:" + strconv.Itoa(i)
+			noise := "noise:" + strconv.Itoa(i*1000+j)
+			if _, err := store.Do(key, Add, item, noise); err != nil {
+				t.Fatalf("error while adding: %v", err)
+			}
+		}
+	}
+
+	v, err := store.Do(key, List)
+	if err != nil || !reflect.DeepEqual(v, []string{"heavy:5", "heavy:4", "heavy:3"}) {
+		t.Errorf("expected top 3 heavy:5, heavy:4 and heavy:3; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Query, "heavy:5", "heavy:1", "noise:1000")
+	if err != nil || !reflect.DeepEqual(v, []bool{true, false, false}) {
+		t.Errorf("expected QUERY to return [true false false]; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Count, "heavy:5", "missing")
+	if err != nil {
+		t.Fatalf("error while counting: %v", err)
+	}
+	if counts := v.([]int64); counts[0] < 400 || counts[0] > 500 || counts[1] != 0 {
+		t.Errorf("expected counts around [500 0]; got %v", counts)
+	}
+
+	// adding a new heavy hitter expels the least frequent item of the top
+	var removed []string
+	for i := 0; i < 1000 && removed == nil; i++ {
+		v, err := store.Do(key, Add, "heavy:6")
+		if err != nil {
+			t.Fatalf("error while adding: %v", err)
+		}
+		removed = v.([]string)
+	}
+	if !reflect.DeepEqual(removed, []string{"heavy:3"}) {
+		t.Errorf("expected heavy:3 to be removed; got %v", removed)
+	}
+
+	if _, err := store.Do(key, Reserve, 3, 100, 4, 0.9); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty; got %v", err)
+	}
+	if _, err := store.Do(key, Add); !errors.Is(err, ErrInvalidParamLen) {
+		t.Errorf("expected ErrInvalidParamLen; got %v", err)
+	}
+	if _, err := store.Do(key, Query, 1); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType; got %v", err)
+	}
+
+	obj, err := store.ToJSON(key)
+	if err != nil {
+		t.Fatalf("ToJSON returned unexpected error: %v", err)
+	}
+
+	newKey := "xyz"
+	if err := store.AddKey(newKey, Type); err != nil {
+		t.Fatalf("cannot add new key to the store: %v", err)
+	}
+	if _, err := store.Do(newKey, Reserve, 1, 10, 1, 1.5); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue; got %v", err)
+	}
+	if err := store.FromJSON(newKey, obj); err != nil {
+		t.Fatalf("FromJSON returned unexpected error: %v", err)
+	}
+
+	v1, _ := store.Do(key, List)
+	v2, err := store.Do(newKey, List)
+	if err != nil || !reflect.DeepEqual(v1, v2) {
+		t.Errorf("expected list %v FromJSON; got %v (%v)", v1, v2, err)
+	}
+
+	v1, _ = store.Do(key, Count, "heavy:4", "noise:1000")
+	v2, err = store.Do(newKey, Count, "heavy:4", "noise:1000")
+	if err != nil || !reflect.DeepEqual(v1, v2) {
+		t.Errorf("expected counts %v FromJSON; got %v (%v)", v1, v2, err)
+	}
+
+	bad := `{"k":1,"width":1,"depth":1,"decay":0.9,"buckets":[0,0],` +
+		`"top":[{"item":"a","count":1},{"item":"b","count":1}]}`
+	if err := store.FromJSON(newKey, []byte(bad)); err == nil {
+		t.Errorf("expected error FromJSON for too many items in the top")
+	}
+}
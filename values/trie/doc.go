@@ -0,0 +1,12 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package trie implements a kiwi.Value which stores weighted words in a
+// prefix tree, to complete prefixes with the heaviest words and to match
+// words fuzzily.
+//
+// Each node keeps the number of words and the greatest weight below it, so
+// that counting the words with a prefix does not visit them, and completing
+// a prefix visits the heaviest branches first.
+package trie
@@ -0,0 +1,582 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package trie
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func init() {
+	kiwi.RegisterValue(func() kiwi.Value {
+		return &Value{root: new(node)}
+	})
+}
+
+// Type of trie value.
+const Type kiwi.ValueType = "trie"
+
+// maxDistance is the maximum edit distance for fuzzy matching, beyond which
+// most of the trie would match.
+const maxDistance = 2
+
+// Value can store weighted words in a trie.
+//
+// It implements the kiwi.Value interface.
+type Value struct {
+	root *node
+}
+
+// node is a node of the trie.
+type node struct {
+	children map[rune]*node
+
+	// word is true if a word ends at the node.
+	word   bool
+	weight float64
+
+	// count is the number of words and max is the greatest weight at or
+	// below the node.
+	count int
+	max   float64
+}
+
+// Completion is a word with its weight.
+type Completion struct {
+	Word   string
+	Weight float64
+}
+
+// Match is a word matched within an edit distance.
+type Match struct {
+	Word     string
+	Weight   float64
+	Distance int
+}
+
+// Various errors for trie value type.
+var (
+	ErrInvalidParamLen   = fmt.Errorf("not enough parameters")
+	ErrInvalidParamType  = fmt.Errorf("invalid parameter type")
+	ErrInvalidParamValue = fmt.Errorf("invalid parameter value")
+)
+
+// newParamLenErr creates an error where parameter length is wrong.
+func newParamLenErr(u, l int) error {
+	return fmt.Errorf("%w: got %d; requires %d", ErrInvalidParamLen, u, l)
+}
+
+// newParamTypeErr creates an error where parameter type is wrong.
+func newParamTypeErr(p, e interface{}) error {
+	typ := fmt.Sprintf("%T", e)
+	return fmt.Errorf("%w: %#v not a(n) %q", ErrInvalidParamType, p, typ)
+}
+
+// newParamValueErr creates an error where parameter value is wrong.
+func newParamValueErr(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParamValue, reason)
+}
+
+const (
+	// Insert inserts the word (string) with the weight (float64), 1 if not
+	// given. If the word exists, its weight is set if given.
+	//
+	// Returns true if the word was inserted.
+	Insert kiwi.Action = "INSERT"
+
+	// Remove removes the word(s) (string).
+	//
+	// Returns the number of words removed.
+	Remove kiwi.Action = "REMOVE"
+
+	// Has checks if the word (string) exists.
+	//
+	// Returns a bool.
+	Has kiwi.Action = "HAS"
+
+	// Prefix completes the prefix (string) with at most limit (int) words,
+	// all of them if the limit is 0.
+	//
+	// Returns a []Completion, the heaviest first and then by word.
+	Prefix kiwi.Action = "PREFIX"
+
+	// CountPrefix counts the words with the prefix (string).
+	//
+	// Returns an int.
+	CountPrefix kiwi.Action = "COUNTPREFIX"
+
+	// Fuzzy matches the words within the edit distance (int), at most 2, of
+	// the word (string). At most limit (int) words are matched if given and
+	// not 0.
+	//
+	// Returns a []Match, the closest first and then as the completions.
+	Fuzzy kiwi.Action = "FUZZY"
+
+	// Len gets the number of words.
+	//
+	// Returns an int.
+	Len kiwi.Action = "LEN"
+)
+
+// Type returns v's type, i.e., "trie".
+func (v *Value) Type() kiwi.ValueType {
+	return Type
+}
+
+// DoMap returns the map of v's actions with it's do functions.
+func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
+	return map[kiwi.Action]kiwi.DoFunc{
+		Insert:      v.insert,
+		Remove:      v.remove,
+		Has:         v.has,
+		Prefix:      v.prefix,
+		CountPrefix: v.countPrefix,
+		Fuzzy:       v.fuzzy,
+		Len:         v.len,
+	}
+}
+
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Has, Prefix, CountPrefix, Fuzzy, Len:
+		return true
+	}
+
+	return false
+}
+
+// insert implements the INSERT action.
+func (v *Value) insert(params ...interface{}) (interface{}, error) {
+	if len(params) != 1 && len(params) != 2 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	word, ok := params[0].(string)
+	if !ok {
+		return nil, newParamTypeErr(params[0], word)
+	}
+
+	weight, set := 1.0, len(params) == 2
+	if set {
+		if weight, ok = params[1].(float64); !ok {
+			return nil, newParamTypeErr(params[1], weight)
+		}
+	}
+
+	if word == "" {
+		return nil, newParamValueErr("word should not be empty")
+	}
+
+	if math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return nil, newParamValueErr(fmt.Sprintf("weight %v is not finite", weight))
+	}
+
+	return v.add(word, weight, set), nil
+}
+
+// add adds the word with the weight, setting the weight of an existing word
+// if set is true. It returns true if the word is new.
+func (v *Value) add(word string, weight float64, set bool) bool {
+	path := []*node{v.root}
+	for _, r := range word {
+		n := path[len(path)-1]
+		if n.children == nil {
+			n.children = make(map[rune]*node)
+		}
+
+		child, ok := n.children[r]
+		if !ok {
+			child = new(node)
+			n.children[r] = child
+		}
+		path = append(path, child)
+	}
+
+	last := path[len(path)-1]
+	added := !last.word
+	if added {
+		last.word, last.weight = true, weight
+		for _, n := range path {
+			n.count++
+		}
+	} else if set {
+		last.weight = weight
+	}
+
+	update(path)
+	return added
+}
+
+// remove implements the REMOVE action.
+func (v *Value) remove(params ...interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, newParamLenErr(len(params), 1)
+	}
+
+	words := make([]string, len(params))
+	for i := range params {
+		word, ok := params[i].(string)
+		if !ok {
+			return nil, newParamTypeErr(params[i], word)
+		}
+		words[i] = word
+	}
+
+	removed := 0
+	for _, word := range words {
+		path, runes := v.walk(word)
+		if path == nil || !path[len(path)-1].word {
+			continue
+		}
+
+		last := path[len(path)-1]
+		last.word, last.weight = false, 0
+		for _, n := range path {
+			n.count--
+		}
+
+		// prune the nodes left without words
+		for i := len(path) - 1; i > 0 && path[i].count == 0; i-- {
+			delete(path[i-1].children, runes[i-1])
+			path = path[:i]
+		}
+
+		update(path)
+		removed++
+	}
+
+	return removed, nil
+}
+
+// has implements the HAS action.
+func (v *Value) has(params ...interface{}) (interface{}, error) {
+	word, err := stringParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	path, _ := v.walk(word)
+	return path != nil && path[len(path)-1].word, nil
+}
+
+// prefix implements the PREFIX action.
+func (v *Value) prefix(params ...interface{}) (interface{}, error) {
+	if len(params) != 2 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	prefix, ok := params[0].(string)
+	if !ok {
+		return nil, newParamTypeErr(params[0], prefix)
+	}
+
+	limit, err := limitParam(params[1])
+	if err != nil {
+		return nil, err
+	}
+
+	completions := []Completion{}
+
+	path, _ := v.walk(prefix)
+	if path == nil || path[len(path)-1].count == 0 {
+		return completions, nil
+	}
+
+	// The heaviest item is popped first, where a node is as heavy as the
+	// heaviest word below it and sorts before them. Hence the words are
+	// popped in order.
+	h := &itemHeap{{n: path[len(path)-1], key: prefix, weight: path[len(path)-1].max}}
+	for h.Len() > 0 && (limit == 0 || len(completions) < limit) {
+		it := heap.Pop(h).(item) //nolint:errcheck
+
+		if it.n == nil {
+			completions = append(completions, Completion{Word: it.key, Weight: it.weight})
+			continue
+		}
+
+		if it.n.word {
+			heap.Push(h, item{key: it.key, weight: it.n.weight})
+		}
+
+		for r, child := range it.n.children {
+			heap.Push(h, item{n: child, key: it.key + string(r), weight: child.max})
+		}
+	}
+
+	return completions, nil
+}
+
+// countPrefix implements the COUNTPREFIX action.
+func (v *Value) countPrefix(params ...interface{}) (interface{}, error) {
+	prefix, err := stringParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	path, _ := v.walk(prefix)
+	if path == nil {
+		return 0, nil
+	}
+
+	return path[len(path)-1].count, nil
+}
+
+// fuzzy implements the FUZZY action.
+func (v *Value) fuzzy(params ...interface{}) (interface{}, error) {
+	if len(params) != 2 && len(params) != 3 {
+		return nil, newParamLenErr(len(params), 2)
+	}
+
+	word, ok := params[0].(string)
+	if !ok {
+		return nil, newParamTypeErr(params[0], word)
+	}
+
+	dist, ok := params[1].(int)
+	if !ok {
+		return nil, newParamTypeErr(params[1], dist)
+	}
+
+	limit := 0
+	if len(params) == 3 {
+		var err error
+		if limit, err = limitParam(params[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	if dist < 0 || dist > maxDistance {
+		return nil, newParamValueErr(fmt.Sprintf("distance %d should be between 0 and %d", dist, maxDistance))
+	}
+
+	target := []rune(word)
+
+	// row is the row of the Levenshtein matrix for the empty prefix
+	row := make([]int, len(target)+1)
+	for i := range row {
+		row[i] = i
+	}
+
+	matches := []Match{}
+	for r, child := range v.root.children {
+		matches = child.match(string(r), r, target, row, dist, matches)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		return a.Word < b.Word
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+// match appends the words at or below n, which is reached by the rune r and
+// has the key, within the distance of the target. prev is the row of the
+// Levenshtein matrix for the parent of n.
+func (n *node) match(key string, r rune, target []rune, prev []int, dist int, matches []Match) []Match {
+	row := make([]int, len(prev))
+	row[0] = prev[0] + 1
+
+	least := row[0]
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if target[i-1] == r {
+			cost = 0
+		}
+
+		row[i] = min(row[i-1]+1, prev[i]+1, prev[i-1]+cost)
+		if row[i] < least {
+			least = row[i]
+		}
+	}
+
+	if n.word && row[len(target)] <= dist {
+		matches = append(matches, Match{Word: key, Weight: n.weight, Distance: row[len(target)]})
+	}
+
+	// the distance only grows below if every cell exceeds it
+	if least > dist {
+		return matches
+	}
+
+	for c, child := range n.children {
+		matches = child.match(key+string(c), c, target, row, dist, matches)
+	}
+
+	return matches
+}
+
+// len implements the LEN action.
+func (v *Value) len(params ...interface{}) (interface{}, error) {
+	if len(params) != 0 {
+		return nil, newParamLenErr(len(params), 0)
+	}
+
+	return v.root.count, nil
+}
+
+// walk returns the nodes from the root to the node of the word along with its
+// runes, or nil if there is no such node.
+func (v *Value) walk(word string) ([]*node, []rune) {
+	runes := []rune(word)
+	path := []*node{v.root}
+
+	for _, r := range runes {
+		child, ok := path[len(path)-1].children[r]
+		if !ok {
+			return nil, nil
+		}
+		path = append(path, child)
+	}
+
+	return path, runes
+}
+
+// update updates the greatest weights of the nodes in the path, which are
+// ordered from the root.
+func update(path []*node) {
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+
+		n.max = math.Inf(-1)
+		if n.word {
+			n.max = n.weight
+		}
+		for _, child := range n.children {
+			n.max = math.Max(n.max, child.max)
+		}
+	}
+}
+
+// stringParam returns the single parameter as a string.
+func stringParam(params []interface{}) (string, error) {
+	if len(params) != 1 {
+		return "", newParamLenErr(len(params), 1)
+	}
+
+	s, ok := params[0].(string)
+	if !ok {
+		return "", newParamTypeErr(params[0], s)
+	}
+
+	return s, nil
+}
+
+// limitParam returns the parameter as a limit.
+func limitParam(p interface{}) (int, error) {
+	limit, ok := p.(int)
+	if !ok {
+		return 0, newParamTypeErr(p, limit)
+	}
+
+	if limit < 0 {
+		return 0, newParamValueErr(fmt.Sprintf("limit %d should not be negative", limit))
+	}
+
+	return limit, nil
+}
+
+// min returns the minimum of the ints.
+func min(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// item is a node or, if n is nil, a word to complete a prefix with.
+type item struct {
+	n      *node
+	key    string
+	weight float64
+}
+
+// itemHeap is a heap of items, the heaviest and then the smallest key at the
+// top.
+type itemHeap []item
+
+func (h itemHeap) Len() int { return len(h) }
+
+func (h itemHeap) Less(i, j int) bool {
+	if h[i].weight != h[j].weight {
+		return h[i].weight > h[j].weight
+	}
+	return h[i].key < h[j].key
+}
+
+func (h itemHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *itemHeap) Push(x interface{}) { *h = append(*h, x.(item)) } //nolint:errcheck
+
+func (h *itemHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// ToJSON returns the raw byte array of v's data, which maps the words to their
+// weights.
+func (v *Value) ToJSON() (json.RawMessage, error) {
+	words := make(map[string]float64, v.root.count)
+	v.root.collect("", words)
+
+	return json.Marshal(words)
+}
+
+// collect adds the words at or below n, which has the key, to words.
+func (n *node) collect(key string, words map[string]float64) {
+	if n.word {
+		words[key] = n.weight
+	}
+
+	for r, child := range n.children {
+		child.collect(key+string(r), words)
+	}
+}
+
+// FromJSON populates v with the data from RawMessage.
+func (v *Value) FromJSON(rawmessage json.RawMessage) error {
+	var words map[string]float64
+	if err := json.Unmarshal(rawmessage, &words); err != nil {
+		return err
+	}
+
+	nv := Value{root: new(node)}
+	for word, weight := range words {
+		if word == "" {
+			return fmt.Errorf("empty word")
+		}
+		if math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return fmt.Errorf("weight %v of %q is not finite", weight, word)
+		}
+		nv.add(word, weight, true)
+	}
+
+	*v = nv
+	return nil
+}
+
+// Interface guard.
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
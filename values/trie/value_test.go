@@ -0,0 +1,142 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package trie
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sdslabs/kiwi"
+)
+
+func TestValue(t *testing.T) {
+	key := "abc"
+
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{key: Type})
+	if err != nil {
+		t.Fatalf("error while creating store: %v", err)
+	}
+
+	words := map[string]float64{
+		"car":     5,
+		"card":    2,
+		"care":    7,
+		"careful": 3,
+		"cart":    5,
+		"cat":     9,
+		"dog":     1,
+		"café":    4,
+	}
+	for word, weight := range words {
+		v, err := store.Do(key, Insert, word, weight)
+		if err != nil || v != true {
+			t.Fatalf("expected INSERT %q to return true; got %v (%v)", word, v, err)
+		}
+	}
+
+	v, err := store.Do(key, Insert, "dog")
+	if err != nil || v != false {
+		t.Errorf("expected INSERT of existing word to return false; got %v (%v)", v, err)
+	}
+	if _, err := store.Do(key, Insert, ""); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for empty word; got %v", err)
+	}
+	for _, weight := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := store.Do(key, Insert, "inf", weight); !errors.Is(err, ErrInvalidParamValue) {
+			t.Errorf("expected ErrInvalidParamValue for weight %v; got %v", weight, err)
+		}
+	}
+	if _, err := store.Do(key, Insert, "dog", 1); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("expected ErrInvalidParamType for weight; got %v", err)
+	}
+
+	v, err = store.Do(key, Prefix, "car", 3)
+	if expected := []Completion{{"care", 7}, {"car", 5}, {"cart", 5}}; err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected PREFIX %v; got %v (%v)", expected, v, err)
+	}
+
+	v, err = store.Do(key, Prefix, "ca", 0)
+	if err != nil || len(v.([]Completion)) != 7 {
+		t.Errorf("expected PREFIX with no limit to return 7 words; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Prefix, "x", 3)
+	if err != nil || len(v.([]Completion)) != 0 {
+		t.Errorf("expected PREFIX with no words to be empty; got %v (%v)", v, err)
+	}
+
+	for prefix, expected := range map[string]int{"": 8, "car": 5, "caf": 1, "care": 2, "x": 0} {
+		v, err := store.Do(key, CountPrefix, prefix)
+		if err != nil || v != expected {
+			t.Errorf("expected COUNTPREFIX %q to return %d; got %v (%v)", prefix, expected, v, err)
+		}
+	}
+
+	v, err = store.Do(key, Fuzzy, "cars", 1)
+	expected := []Match{{"care", 7, 1}, {"car", 5, 1}, {"cart", 5, 1}, {"card", 2, 1}}
+	if err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected FUZZY %v; got %v (%v)", expected, v, err)
+	}
+
+	v, err = store.Do(key, Fuzzy, "cafe", 2, 2)
+	expected = []Match{{"care", 7, 1}, {"café", 4, 1}}
+	if err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected FUZZY %v; got %v (%v)", expected, v, err)
+	}
+
+	if _, err := store.Do(key, Fuzzy, "cat", 3); !errors.Is(err, ErrInvalidParamValue) {
+		t.Errorf("expected ErrInvalidParamValue for distance; got %v", err)
+	}
+
+	v, err = store.Do(key, Remove, "care", "ca", "cart")
+	if err != nil || v != 2 {
+		t.Errorf("expected REMOVE to return 2; got %v (%v)", v, err)
+	}
+
+	v, err = store.Do(key, Has, "care")
+	if err != nil || v != false {
+		t.Errorf("expected HAS removed word to return false; got %v (%v)", v, err)
+	}
+	v, err = store.Do(key, Has, "careful")
+	if err != nil || v != true {
+		t.Errorf("expected HAS to return true; got %v (%v)", v, err)
+	}
+
+	// the greatest weights are updated after removing
+	v, err = store.Do(key, Prefix, "car", 1)
+	if expected := []Completion{{"car", 5}}; err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected PREFIX %v; got %v (%v)", expected, v, err)
+	}
+
+	v, err = store.Do(key, Len)
+	if err != nil || v != 6 {
+		t.Errorf("expected LEN to return 6; got %v (%v)", v, err)
+	}
+}
+
+func TestValue_JSON(t *testing.T) {
+	v := &Value{root: new(node)}
+	for word, weight := range map[string]float64{"a": 1, "ab": 2.5, "b": -1} {
+		v.add(word, weight, true)
+	}
+
+	raw, err := v.ToJSON()
+	if err != nil {
+		t.Fatalf("error while converting to JSON: %v", err)
+	}
+	if string(raw) != `{"a":1,"ab":2.5,"b":-1}` {
+		t.Errorf("unexpected JSON %s", raw)
+	}
+
+	nv := new(Value)
+	if err := nv.FromJSON(raw); err != nil {
+		t.Fatalf("error while converting from JSON: %v", err)
+	}
+	if !reflect.DeepEqual(v, nv) {
+		t.Errorf("expected %+v; got %+v", v, nv)
+	}
+}
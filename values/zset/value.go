@@ -112,6 +112,16 @@ func (v *Value) DoMap() map[kiwi.Action]kiwi.DoFunc {
 	}
 }
 
+// ReadOnly tells if the action does not modify v.
+func (v *Value) ReadOnly(action kiwi.Action) bool {
+	switch action {
+	case Len, Get, PeekMax, PeekMin:
+		return true
+	}
+
+	return false
+}
+
 // ToJSON returns the raw byte array of v's data
 func (v *Value) ToJSON() (json.RawMessage, error) {
 	nodes := v.GetByRankRange(1, -1, false)
@@ -270,4 +280,4 @@ func (v *Value) peekmin(params ...interface{}) (interface{}, error) {
 }
 
 // Interface guard.
-var _ kiwi.Value = (*Value)(nil)
+var _ kiwi.ReadOnlyValue = (*Value)(nil)
@@ -0,0 +1,62 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+// Package watch streams the changes of a kiwi store to HTTP clients over
+// Server-Sent Events or WebSocket.
+//
+// A Hub listens to the events of the store and sends each of them, along with
+// the JSON of the new value of the key, to the subscriptions whose filter it
+// matches. Every event has a sequence number and the hub remembers the recent
+// events, so a client reconnecting after a failure resumes from the last event
+// it received. If the events after it have already been forgotten, the client
+// is sent a "reset" event instead and should load the store again.
+//
+// Actions which do not change the value, like "GET", are not streamed since
+// only the actions which change the JSON of the value are. The events are
+// queued by the hook of the hub and sent by its own goroutine, so operations
+// on the store do not wait for the values to be read. Since the value is read
+// when the event is sent, the changes of a key queued together may be sent as
+// a single event with the latest value.
+//
+// The hub is an http.Handler. A WebSocket handshake starts a WebSocket stream
+// which sends each event as a JSON text message, and other requests start a
+// Server-Sent Events stream. The query parameters of the request are:
+//
+//	pattern   glob pattern the keys should match, all keys if empty
+//	type      type of the values, can be repeated, all types if not given
+//	since     sequence number of the last event received, to resume from
+//
+// Server-Sent Events streams can also resume from the Last-Event-ID header,
+// which browsers send while reconnecting.
+//
+// An event looks like:
+//
+//	{
+//	  "seq": 42,
+//	  "op": "do",
+//	  "key": "users",
+//	  "type": "set",
+//	  "action": "INSERT",
+//	  "value": ["alice", "bob"]
+//	}
+//
+// Clients which cannot keep up with the events are disconnected so that the
+// store is never blocked; they can reconnect and resume. If the hub itself
+// falls behind the store by more than its QueueSize events, the events are
+// dropped and all the clients are disconnected, to be sent a reset event when
+// they reconnect.
+//
+//
+// Get Started
+//
+//	store := kiwi.NewStore()
+//
+//	hub := watch.NewHub(store, 1024)
+//	defer hub.Close()
+//
+//	http.Handle("/watch", hub)
+//	if err := http.ListenAndServe(":8080", nil); err != nil {
+//	  // handle error
+//	}
+package watch
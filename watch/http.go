@@ -0,0 +1,110 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+)
+
+// ServeHTTP streams the events over WebSocket if the request is a WebSocket
+// handshake and over Server-Sent Events otherwise.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	f := Filter{Pattern: query.Get("pattern")}
+	for _, typ := range query["type"] {
+		f.Types = append(f.Types, kiwi.ValueType(typ))
+	}
+
+	since := query.Get("since")
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		since = id
+	}
+
+	var seq uint64
+	if since != "" {
+		var err error
+		if seq, err = strconv.ParseUint(since, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid sequence number %q", since), http.StatusBadRequest)
+			return
+		}
+	}
+
+	subscribe := func() *Subscription {
+		if since != "" {
+			return h.Resume(f, seq)
+		}
+		return h.Subscribe(f)
+	}
+
+	if isWebSocket(r) {
+		h.serveWebSocket(w, r, subscribe)
+		return
+	}
+
+	h.serveSSE(w, r, subscribe)
+}
+
+// serveSSE streams the events of the subscription as Server-Sent Events.
+func (h *Hub) serveSSE(w http.ResponseWriter, r *http.Request, subscribe func() *Subscription) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := subscribe()
+	defer sub.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.Seq, data); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
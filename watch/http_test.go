@@ -0,0 +1,255 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package watch
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+func TestHub_SSE(t *testing.T) {
+	store := kiwi.NewStore()
+
+	hub := NewHub(store, 16)
+	defer hub.Close()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	all := hub.Subscribe(Filter{})
+	for _, key := range []string{"a1", "b", "a2"} {
+		if err := store.AddKey(key, str.Type); err != nil {
+			t.Fatalf("could not add key: %v", err)
+		}
+	}
+	expectSeq(t, all, 1, 2, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?pattern=a*&since=0", nil)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+	req.Header.Set("Last-Event-ID", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not send request: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected event stream; got %q", ct)
+	}
+
+	r := bufio.NewReader(resp.Body)
+
+	// resumes after the Last-Event-ID
+	expectSSE(t, r, 3, "a2")
+
+	for _, key := range []string{"a1", "b", "a2"} {
+		if _, err := store.Do(key, str.Update, "x"); err != nil {
+			t.Fatalf("could not update: %v", err)
+		}
+	}
+
+	expectSSE(t, r, 4, "a1")
+	expectSSE(t, r, 6, "a2")
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?since=x", nil)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not send request: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d for invalid since; got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+// expectSSE reads the next event from the stream and checks it.
+func expectSSE(t *testing.T, r *bufio.Reader, seq uint64, key string) {
+	t.Helper()
+
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("could not read event: %v", err)
+		}
+		if line == "\n" {
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "data: ") {
+		t.Fatalf("invalid event: %q", lines)
+	}
+
+	var e Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &e); err != nil {
+		t.Fatalf("invalid event data: %v", err)
+	}
+
+	if lines[0] != "id: "+strconv.FormatUint(seq, 10) || e.Seq != seq || e.Key != key {
+		t.Errorf("expected event %d for %q; got %q", seq, key, lines)
+	}
+}
+
+func TestHub_WebSocket(t *testing.T) {
+	store := kiwi.NewStore()
+
+	hub := NewHub(store, 16)
+	defer hub.Close()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("could not set deadline: %v", err)
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	_, err = io.WriteString(conn, "GET /?type=str HTTP/1.1\r\n"+
+		"Host: kiwi\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	if err != nil {
+		t.Fatalf("could not send handshake: %v", err)
+	}
+
+	r := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("could not read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d; got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != acceptKey(key) {
+		t.Errorf("invalid Sec-WebSocket-Accept: %q", accept)
+	}
+
+	// the subscription is created after the handshake
+	writeClientFrame(t, conn, opPing, []byte("hi"))
+	if op, payload := readServerFrame(t, r); op != opPong || string(payload) != "hi" {
+		t.Fatalf("expected pong; got %d %q", op, payload)
+	}
+
+	all := hub.Subscribe(Filter{})
+	if err := store.AddKey("a", str.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+	// the value is read when the event is sent, so both the events would be
+	// sent as one if queued together
+	expectSeq(t, all, 1)
+	if _, err := store.Do("a", str.Update, strings.Repeat("x", 200)); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+
+	for _, seq := range []uint64{1, 2} {
+		op, payload := readServerFrame(t, r)
+		if op != opText {
+			t.Fatalf("expected text frame; got %d", op)
+		}
+
+		var e Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			t.Fatalf("invalid event: %v", err)
+		}
+		if e.Seq != seq || e.Key != "a" {
+			t.Errorf("expected event %d; got %+v", seq, e)
+		}
+	}
+
+	writeClientFrame(t, conn, opClose, []byte{0x03, 0xe8})
+	if op, _ := readServerFrame(t, r); op != opClose {
+		t.Errorf("expected close frame; got %d", op)
+	}
+}
+
+// writeClientFrame writes a masked frame with a short payload.
+func writeClientFrame(t *testing.T, w io.Writer, op byte, payload []byte) {
+	t.Helper()
+
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{0x80 | op, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if _, err := w.Write(frame); err != nil {
+		t.Fatalf("could not write frame: %v", err)
+	}
+}
+
+// readServerFrame reads an unmasked frame.
+func readServerFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	t.Helper()
+
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatalf("could not read frame: %v", err)
+	}
+
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			t.Fatalf("could not read frame: %v", err)
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			t.Fatalf("could not read frame: %v", err)
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("could not read frame: %v", err)
+	}
+
+	return head[0] & 0x0f, payload
+}
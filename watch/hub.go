@@ -0,0 +1,375 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package watch
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+
+	"github.com/tidwall/match"
+)
+
+// OpReset is the operation of the event sent when a subscription cannot be
+// resumed since some of the events after it have been forgotten.
+const OpReset kiwi.EventOp = "reset"
+
+// Event is a change of the store sent to the subscriptions.
+type Event struct {
+	Seq    uint64          `json:"seq"`
+	Op     kiwi.EventOp    `json:"op"`
+	Key    string          `json:"key,omitempty"`
+	Type   kiwi.ValueType  `json:"type,omitempty"`
+	Action kiwi.Action     `json:"action,omitempty"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+// Filter selects the events sent to a subscription.
+type Filter struct {
+	// Pattern is the glob pattern the keys should match. All the keys match
+	// an empty pattern.
+	Pattern string
+
+	// Types are the value types of the keys. All the types match if empty.
+	Types []kiwi.ValueType
+}
+
+// match tells if the event passes the filter. Reset events always pass.
+func (f *Filter) match(e *Event) bool {
+	if e.Op == OpReset {
+		return true
+	}
+
+	if f.Pattern != "" && !match.Match(e.Key, f.Pattern) {
+		return false
+	}
+
+	if len(f.Types) == 0 {
+		return true
+	}
+
+	for _, typ := range f.Types {
+		if typ == e.Type {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Hub sends the events of a store to its subscriptions.
+type Hub struct {
+	store  *kiwi.Store
+	remove func()
+
+	// BufferSize is the number of events buffered for a subscription before
+	// it is closed for being too slow. Defaults to 256.
+	BufferSize int
+
+	// QueueSize is the number of events of the store queued for the hub to
+	// send. When the hub falls behind the store by more events, they are
+	// dropped and all the subscriptions are closed, which can only be
+	// resumed with a reset event afterwards. Defaults to 4096.
+	QueueSize int
+
+	// HeartbeatInterval is the interval at which idle HTTP streams are sent
+	// a heartbeat to keep the connection alive. Defaults to 15 seconds.
+	HeartbeatInterval time.Duration
+
+	// queue holds the events of the store until they are sent, so that the
+	// operations on the store do not wait for the values to be read. queued
+	// is signalled when an event is queued and overflowed is set when the
+	// events are dropped for the queue being full.
+	queue      []kiwi.Event
+	queued     chan struct{}
+	overflowed bool
+	queueMu    sync.Mutex
+
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	seq         uint64
+	history     []Event
+	historySize int
+	hashes      map[string]uint64
+	subs        map[*Subscription]struct{}
+	closed      bool
+	mu          sync.Mutex
+}
+
+// NewHub creates a hub for the store which remembers the last historySize
+// events for subscriptions to resume from.
+func NewHub(store *kiwi.Store, historySize int) *Hub {
+	h := &Hub{
+		store:             store,
+		BufferSize:        256,
+		QueueSize:         4096,
+		HeartbeatInterval: 15 * time.Second,
+		queued:            make(chan struct{}, 1),
+		queueMu:           sync.Mutex{},
+		stop:              make(chan struct{}),
+		stopped:           make(chan struct{}),
+		history:           make([]Event, 0, historySize),
+		historySize:       historySize,
+		hashes:            make(map[string]uint64),
+		subs:              make(map[*Subscription]struct{}),
+		mu:                sync.Mutex{},
+	}
+
+	h.remove = store.AddHook(h.handle)
+	go h.run()
+	return h
+}
+
+// Close stops listening to the store and closes all the subscriptions. The
+// events not sent yet are dropped.
+func (h *Hub) Close() {
+	h.remove()
+
+	h.closeOnce.Do(func() { close(h.stop) })
+	<-h.stopped
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		h.unsubscribe(sub)
+	}
+}
+
+// Seq returns the sequence number of the last event.
+func (h *Hub) Seq() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.seq
+}
+
+// Subscribe creates a subscription for the new events passing the filter.
+func (h *Hub) Subscribe(f Filter) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribe(f, nil)
+}
+
+// Resume creates a subscription for the events passing the filter after the
+// one with the sequence number since.
+//
+// If the hub does not remember all of those events, the first event of the
+// subscription is a reset event with the sequence number of the last event.
+func (h *Hub) Resume(f Filter, since uint64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	oldest := h.seq + 1
+	if len(h.history) > 0 {
+		oldest = h.history[0].Seq
+	}
+
+	if since > h.seq || since+1 < oldest {
+		return h.subscribe(f, []Event{{Seq: h.seq, Op: OpReset}})
+	}
+
+	var backlog []Event
+	for i := range h.history {
+		if e := &h.history[i]; e.Seq > since && f.match(e) {
+			backlog = append(backlog, *e)
+		}
+	}
+
+	return h.subscribe(f, backlog)
+}
+
+// subscribe creates a subscription which is sent the backlog first.
+//
+// It should be called with the hub locked.
+func (h *Hub) subscribe(f Filter, backlog []Event) *Subscription {
+	c := make(chan Event, h.BufferSize+len(backlog))
+	for _, e := range backlog {
+		c <- e
+	}
+
+	sub := &Subscription{
+		C:      c,
+		c:      c,
+		filter: f,
+		hub:    h,
+	}
+
+	if h.closed {
+		close(c)
+		return sub
+	}
+
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe closes the subscription if it is still open.
+//
+// It should be called with the hub locked.
+func (h *Hub) unsubscribe(sub *Subscription) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+
+	delete(h.subs, sub)
+	close(sub.c)
+}
+
+// handle is the hook which queues the events of the store which can change
+// the values.
+func (h *Hub) handle(se kiwi.Event) {
+	if se.Op == kiwi.EventDo && (se.ReadOnly || se.Err != nil) {
+		return
+	}
+
+	h.queueMu.Lock()
+	if len(h.queue) < h.QueueSize && !h.overflowed {
+		h.queue = append(h.queue, se)
+	} else {
+		// the hub is too slow, so the events queued are dropped as well
+		// since the subscriptions are reset anyway
+		h.queue = nil
+		h.overflowed = true
+	}
+	h.queueMu.Unlock()
+
+	select {
+	case h.queued <- struct{}{}:
+	default:
+		// already signalled
+	}
+}
+
+// run sends the queued events to the subscriptions until the hub is closed.
+func (h *Hub) run() {
+	defer close(h.stopped)
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-h.queued:
+		}
+
+		h.queueMu.Lock()
+		events, overflowed := h.queue, h.overflowed
+		h.queue, h.overflowed = nil, false
+		h.queueMu.Unlock()
+
+		if overflowed {
+			h.reset()
+		}
+
+		for _, se := range events {
+			h.send(se)
+		}
+	}
+}
+
+// reset closes all the subscriptions after events have been dropped. The
+// history is forgotten and the sequence number is skipped for the dropped
+// events, so that resuming from any earlier event sends a reset event.
+func (h *Hub) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	h.history = h.history[:0]
+	for sub := range h.subs {
+		h.unsubscribe(sub)
+	}
+}
+
+// send sends the event of the store to the subscriptions.
+func (h *Hub) send(se kiwi.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	e := Event{
+		Op:     se.Op,
+		Key:    se.Key,
+		Type:   se.Type,
+		Action: se.Action,
+	}
+
+	if se.Op == kiwi.EventDeleteKey {
+		delete(h.hashes, se.Key)
+	} else {
+		// The JSON is read when the event is sent so that the last event
+		// sent for a key always has its latest value, even if the events
+		// are queued out of order.
+		value, err := h.store.ToJSON(se.Key)
+		if err != nil {
+			// the key has been deleted meanwhile
+			return
+		}
+
+		hash := fnv.New64a()
+		_, _ = hash.Write(value)
+		sum := hash.Sum64()
+
+		if old, ok := h.hashes[se.Key]; ok && old == sum && se.Op == kiwi.EventDo {
+			// the action did not change the value
+			return
+		}
+
+		h.hashes[se.Key] = sum
+		e.Value = value
+	}
+
+	h.seq++
+	e.Seq = h.seq
+
+	if h.historySize > 0 {
+		if len(h.history) == h.historySize {
+			h.history = h.history[1:]
+		}
+		h.history = append(h.history, e)
+	}
+
+	for sub := range h.subs {
+		if !sub.filter.match(&e) {
+			continue
+		}
+
+		select {
+		case sub.c <- e:
+		default:
+			// the subscriber is too slow
+			h.unsubscribe(sub)
+		}
+	}
+}
+
+// Subscription receives the events passing its filter.
+type Subscription struct {
+	// C receives the events. It is closed when the subscription is closed,
+	// either by Close, when the hub is closed or when the events are not
+	// received fast enough.
+	C <-chan Event
+
+	c      chan Event
+	filter Filter
+	hub    *Hub
+}
+
+// Close closes the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	s.hub.unsubscribe(s)
+	s.hub.mu.Unlock()
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2020 SDSLabs
+// Use of this source code is governed by an MIT license
+// details of which can be found in the LICENSE file.
+
+package watch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sdslabs/kiwi"
+	"github.com/sdslabs/kiwi/values/hash"
+	"github.com/sdslabs/kiwi/values/str"
+)
+
+// next returns the next event of the subscription.
+func next(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-sub.C:
+		if !ok {
+			t.Fatalf("subscription closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("expected an event")
+		return Event{}
+	}
+}
+
+// expectSeq waits for the subscription to receive the events with the
+// sequence numbers, in order.
+func expectSeq(t *testing.T, sub *Subscription, seqs ...uint64) {
+	t.Helper()
+
+	for _, seq := range seqs {
+		if e := next(t, sub); e.Seq != seq {
+			t.Errorf("expected event %d; got %+v", seq, e)
+		}
+	}
+}
+
+// expectNone checks that the subscription has no event buffered. It should be
+// called once the last event sent by the hub has been received.
+func expectNone(t *testing.T, sub *Subscription) {
+	t.Helper()
+
+	select {
+	case e := <-sub.C:
+		t.Errorf("expected no event; got %+v", e)
+	default:
+	}
+}
+
+func TestHub(t *testing.T) {
+	store, err := kiwi.NewStoreFromSchema(kiwi.Schema{"user:1": str.Type})
+	if err != nil {
+		t.Fatalf("could not create store: %v", err)
+	}
+
+	hub := NewHub(store, 16)
+	defer hub.Close()
+
+	sub := hub.Subscribe(Filter{Pattern: "user:*", Types: []kiwi.ValueType{str.Type}})
+	all := hub.Subscribe(Filter{})
+
+	if _, err := store.Do("user:1", str.Update, "alice"); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+
+	e := next(t, sub)
+	if e.Seq != 1 || e.Op != kiwi.EventDo || e.Key != "user:1" || e.Type != str.Type ||
+		e.Action != str.Update || string(e.Value) != `"alice"` {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	// actions which do not change the value are not sent, and the events
+	// are sent in order, so the next event received is the deletion
+	if _, err := store.Do("user:1", str.Get); err != nil {
+		t.Fatalf("could not get: %v", err)
+	}
+	if _, err := store.Do("user:1", str.Update, "alice"); err != nil {
+		t.Fatalf("could not update: %v", err)
+	}
+
+	// filtered by pattern and type
+	if err := store.AddKey("session:1", str.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+	if err := store.AddKey("user:2", hash.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+
+	if err := store.DeleteKey("user:1"); err != nil {
+		t.Fatalf("could not delete key: %v", err)
+	}
+
+	e = next(t, sub)
+	if e.Seq != 4 || e.Op != kiwi.EventDeleteKey || e.Value != nil {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	expectNone(t, sub)
+
+	expectSeq(t, all, 1, 2, 3, 4)
+	expectNone(t, all)
+
+	all.Close()
+	if _, ok := <-all.C; ok {
+		t.Errorf("expected subscription to be closed")
+	}
+
+	hub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Errorf("expected subscription to be closed with the hub")
+	}
+}
+
+func TestHub_Resume(t *testing.T) {
+	store := kiwi.NewStore()
+
+	hub := NewHub(store, 3)
+	defer hub.Close()
+
+	all := hub.Subscribe(Filter{})
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		if err := store.AddKey(key, str.Type); err != nil {
+			t.Fatalf("could not add key: %v", err)
+		}
+	}
+	expectSeq(t, all, 1, 2, 3, 4, 5)
+
+	// events 3, 4 and 5 are remembered
+	sub := hub.Resume(Filter{}, 3)
+	expectSeq(t, sub, 4, 5)
+	expectNone(t, sub)
+
+	sub = hub.Resume(Filter{Pattern: "e"}, 2)
+	if e := next(t, sub); e.Seq != 5 {
+		t.Errorf("expected event 5; got %+v", e)
+	}
+
+	sub = hub.Resume(Filter{}, 5)
+	expectNone(t, sub)
+
+	for _, since := range []uint64{1, 6} {
+		sub = hub.Resume(Filter{}, since)
+		if e := next(t, sub); e.Op != OpReset || e.Seq != 5 {
+			t.Errorf("expected reset event after %d; got %+v", since, e)
+		}
+	}
+}
+
+func TestHub_SlowSubscription(t *testing.T) {
+	store := kiwi.NewStore()
+
+	hub := NewHub(store, 0)
+	defer hub.Close()
+
+	all := hub.Subscribe(Filter{})
+
+	hub.BufferSize = 1
+	sub := hub.Subscribe(Filter{})
+
+	for _, key := range []string{"a", "b"} {
+		if err := store.AddKey(key, str.Type); err != nil {
+			t.Fatalf("could not add key: %v", err)
+		}
+	}
+	expectSeq(t, all, 1, 2)
+
+	expectSeq(t, sub, 1)
+	if _, ok := <-sub.C; ok {
+		t.Errorf("expected slow subscription to be closed")
+	}
+}
+
+func TestHub_QueueOverflow(t *testing.T) {
+	store := kiwi.NewStore()
+
+	hub := NewHub(store, 16)
+	defer hub.Close()
+
+	hub.QueueSize = 1
+	sub := hub.Subscribe(Filter{})
+
+	// the hub cannot send the events while locked, so at most one event is
+	// taken from the queue and another one queued before it overflows
+	hub.mu.Lock()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := store.AddKey(key, str.Type); err != nil {
+			hub.mu.Unlock()
+			t.Fatalf("could not add key: %v", err)
+		}
+	}
+	hub.mu.Unlock()
+
+	for e := range sub.C {
+		if e.Seq != 1 || e.Key != "a" {
+			t.Errorf("expected only the first event before closing; got %+v", e)
+		}
+	}
+
+	if e := next(t, hub.Resume(Filter{}, 0)); e.Op != OpReset {
+		t.Errorf("expected reset event; got %+v", e)
+	}
+
+	// the events are sent again once the hub catches up
+	sub = hub.Subscribe(Filter{})
+	if err := store.AddKey("e", str.Type); err != nil {
+		t.Fatalf("could not add key: %v", err)
+	}
+	if e := next(t, sub); e.Key != "e" {
+		t.Errorf("expected event for e; got %+v", e)
+	}
+}